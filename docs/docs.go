@@ -15,25 +15,98 @@ const docTemplate = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
-        "/back/image": {
+        "/.well-known/jwks.json": {
+            "get": {
+                "description": "以JWKS格式返回所有非对称签名密钥的公钥(HS256密钥不会公开),供其他服务验证访问令牌.\n同一组密钥也用于签发两步验证等专用令牌,验证访问令牌时必须检查头部的typ为at+jwt",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "auth"
+                ],
+                "summary": "获取验证令牌用的公钥集合",
+                "responses": {
+                    "200": {
+                        "description": "公钥集合",
+                        "schema": {
+                            "$ref": "#/definitions/model.JWKS"
+                        }
+                    }
+                }
+            }
+        },
+        "/assert/{path}": {
+            "get": {
+                "description": "图片会根据Accept请求头选择浏览器支持的更小的格式(AVIF,WebP),添加format=original参数可获取原格式的图片.\n使用对象存储时重定向到文件的访问地址(可能是有时效的预签名地址);使用本地存储时直接返回文件",
+                "tags": [
+                    "image"
+                ],
+                "summary": "访问图片或头像文件",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "文件路径,如 images/big_xxx.jpg",
+                        "name": "path",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "填写original时不转换格式",
+                        "name": "format",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "浏览器支持的格式,如 image/avif,image/webp,*/*",
+                        "name": "Accept",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "文件内容",
+                        "schema": {
+                            "type": "file"
+                        }
+                    },
+                    "302": {
+                        "description": "重定向到文件地址",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "文件不存在",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/back/admin": {
             "get": {
                 "security": [
                     {
                         "BearerAuth": []
                     }
                 ],
-                "description": "管理员获取所有图片的详细信息",
+                "description": "获取所有管理员账号(无密码)",
+                "produces": [
+                    "application/json"
+                ],
                 "tags": [
                     "admin"
                 ],
-                "summary": "获取所有图片信息",
+                "summary": "获取所有管理员",
                 "responses": {
                     "200": {
-                        "description": "所有图片对象列表",
+                        "description": "管理员列表",
                         "schema": {
                             "type": "array",
                             "items": {
-                                "$ref": "#/definitions/model.Image"
+                                "$ref": "#/definitions/model.Admin"
                             }
                         }
                     },
@@ -43,49 +116,47 @@ const docTemplate = `{
                             "type": "string"
                         }
                     },
-                    "500": {
-                        "description": "服务器错误",
+                    "403": {
+                        "description": "缺少staff:manage权限",
                         "schema": {
                             "type": "string"
                         }
                     }
                 }
-            }
-        },
-        "/back/image/ban": {
-            "post": {
+            },
+            "put": {
                 "security": [
-                    {
-                        "BearerAuth": []
-                    },
                     {
                         "BearerAuth": []
                     }
                 ],
-                "description": "管理员封禁指定图片(仅id有效)",
+                "description": "修改管理员的角色和停用状态,password不为空时重置其密码;不能修改自己的角色和状态",
+                "consumes": [
+                    "application/json"
+                ],
                 "tags": [
                     "admin"
                 ],
-                "summary": "封禁图片",
+                "summary": "修改管理员",
                 "parameters": [
                     {
-                        "description": "图片对象，其中包含图片ID",
-                        "name": "image",
+                        "description": "管理员信息(username,role,isDisabled,password可选)",
+                        "name": "admin",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/model.Image"
+                            "$ref": "#/definitions/model.Admin"
                         }
                     }
                 ],
                 "responses": {
                     "204": {
-                        "description": "操作成功，无返回内容"
+                        "description": "修改成功，无返回内容"
                     },
                     "400": {
-                        "description": "图片不存在",
+                        "description": "新密码强度不足(管理员不存在或角色不存在时返回文本)",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/model.ValidationError"
                         }
                     },
                     "401": {
@@ -94,23 +165,27 @@ const docTemplate = `{
                             "type": "string"
                         }
                     },
+                    "403": {
+                        "description": "缺少staff:manage权限或修改自己的角色和状态",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
                     "500": {
-                        "description": "服务器错误",
+                        "description": "服务器内部错误",
                         "schema": {
                             "type": "string"
                         }
                     }
                 }
-            }
-        },
-        "/back/image/unban": {
+            },
             "post": {
                 "security": [
                     {
                         "BearerAuth": []
                     }
                 ],
-                "description": "解除对该图片的封禁状态(仅id有效)",
+                "description": "创建新的管理员账号,角色为空时默认为admin",
                 "consumes": [
                     "application/json"
                 ],
@@ -120,26 +195,29 @@ const docTemplate = `{
                 "tags": [
                     "admin"
                 ],
-                "summary": "解除图片封禁",
+                "summary": "创建管理员",
                 "parameters": [
                     {
-                        "description": "图片信息,包含id",
-                        "name": "image",
+                        "description": "管理员信息(username,password,role)",
+                        "name": "admin",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/model.Image"
+                            "$ref": "#/definitions/model.Admin"
                         }
                     }
                 ],
                 "responses": {
-                    "204": {
-                        "description": "解除封禁成功，无返回内容"
+                    "201": {
+                        "description": "创建的管理员(无密码)",
+                        "schema": {
+                            "$ref": "#/definitions/model.Admin"
+                        }
                     },
                     "400": {
-                        "description": "请求错误，图片ID无效或图片不存在",
+                        "description": "密码强度不足(用户名或密码为空或角色不存在时返回文本)",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/model.ValidationError"
                         }
                     },
                     "401": {
@@ -148,6 +226,12 @@ const docTemplate = `{
                             "type": "string"
                         }
                     },
+                    "403": {
+                        "description": "管理员已存在",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
                     "500": {
                         "description": "服务器内部错误",
                         "schema": {
@@ -157,48 +241,45 @@ const docTemplate = `{
                 }
             }
         },
-        "/back/login": {
-            "post": {
-                "description": "管理员登录",
-                "consumes": [
-                    "application/json"
-                ],
-                "produces": [
-                    "application/json"
+        "/back/admin/mfa/{username}": {
+            "delete": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
                 ],
+                "description": "管理员丢失验证器且恢复码用尽时,删除其两步验证配置,下次登录时重新绑定",
                 "tags": [
                     "admin"
                 ],
-                "summary": "管理员登录",
+                "summary": "重置管理员的两步验证",
                 "parameters": [
                     {
-                        "description": "管理员登录信息",
-                        "name": "admin",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/model.Admin"
-                        }
+                        "type": "string",
+                        "description": "管理员用户名",
+                        "name": "username",
+                        "in": "path",
+                        "required": true
                     }
                 ],
                 "responses": {
-                    "200": {
-                        "description": "JWT 令牌",
+                    "204": {
+                        "description": "重置成功，无返回内容"
+                    },
+                    "401": {
+                        "description": "未授权，用户未登录或会话失效",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "type": "string"
                         }
                     },
                     "403": {
-                        "description": "用户名或密码错误",
+                        "description": "缺少staff:manage权限",
                         "schema": {
                             "type": "string"
                         }
                     },
                     "500": {
-                        "description": "服务器错误",
+                        "description": "服务器内部错误",
                         "schema": {
                             "type": "string"
                         }
@@ -206,31 +287,28 @@ const docTemplate = `{
                 }
             }
         },
-        "/back/user": {
+        "/back/admin/role": {
             "get": {
                 "security": [
                     {
                         "BearerAuth": []
                     }
                 ],
-                "description": "返回所有用户的信息",
-                "consumes": [
-                    "application/json"
-                ],
+                "description": "获取所有被分配了管理角色的普通用户",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
                     "admin"
                 ],
-                "summary": "获取所有用户列表",
+                "summary": "获取普通用户的角色分配",
                 "responses": {
                     "200": {
-                        "description": "用户列表",
+                        "description": "角色分配列表",
                         "schema": {
                             "type": "array",
                             "items": {
-                                "$ref": "#/definitions/model.User"
+                                "$ref": "#/definitions/model.RoleAssignment"
                             }
                         }
                     },
@@ -239,48 +317,46 @@ const docTemplate = `{
                         "schema": {
                             "type": "string"
                         }
+                    },
+                    "403": {
+                        "description": "缺少staff:manage权限",
+                        "schema": {
+                            "type": "string"
+                        }
                     }
                 }
-            }
-        },
-        "/back/user/ban": {
+            },
             "post": {
                 "security": [
                     {
                         "BearerAuth": []
                     }
                 ],
-                "description": "封禁指定用户账号(只有username有用)",
+                "description": "为普通用户分配管理角色,用户使用自己的账号即可访问对应的管理接口",
                 "consumes": [
                     "application/json"
                 ],
-                "produces": [
-                    "application/json"
-                ],
                 "tags": [
                     "admin"
                 ],
-                "summary": "封禁用户",
+                "summary": "为普通用户分配角色",
                 "parameters": [
                     {
-                        "description": "用户信息，包含用户名",
-                        "name": "user",
+                        "description": "角色分配",
+                        "name": "assignment",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/model.User"
+                            "$ref": "#/definitions/model.RoleAssignment"
                         }
                     }
                 ],
                 "responses": {
                     "204": {
-                        "description": "封禁成功，无返回内容",
-                        "schema": {
-                            "type": "string"
-                        }
+                        "description": "分配成功，无返回内容"
                     },
                     "400": {
-                        "description": "用户不存在",
+                        "description": "用户不存在或角色不存在",
                         "schema": {
                             "type": "string"
                         }
@@ -290,48 +366,46 @@ const docTemplate = `{
                         "schema": {
                             "type": "string"
                         }
+                    },
+                    "403": {
+                        "description": "缺少staff:manage权限",
+                        "schema": {
+                            "type": "string"
+                        }
                     }
                 }
-            }
-        },
-        "/back/user/unban": {
-            "post": {
+            },
+            "delete": {
                 "security": [
                     {
                         "BearerAuth": []
                     }
                 ],
-                "description": "解除指定用户的封禁状态(只有username有用)",
+                "description": "撤销普通用户被分配的角色,立即生效",
                 "consumes": [
                     "application/json"
                 ],
-                "produces": [
-                    "application/json"
-                ],
                 "tags": [
                     "admin"
                 ],
-                "summary": "解除封禁用户",
+                "summary": "撤销普通用户的角色",
                 "parameters": [
                     {
-                        "description": "用户信息，包含用户名",
-                        "name": "user",
+                        "description": "角色分配",
+                        "name": "assignment",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/model.User"
+                            "$ref": "#/definitions/model.RoleAssignment"
                         }
                     }
                 ],
                 "responses": {
                     "204": {
-                        "description": "解除封禁成功，无返回内容",
-                        "schema": {
-                            "type": "string"
-                        }
+                        "description": "撤销成功，无返回内容"
                     },
                     "400": {
-                        "description": "用户不存在",
+                        "description": "角色分配不存在",
                         "schema": {
                             "type": "string"
                         }
@@ -341,47 +415,55 @@ const docTemplate = `{
                         "schema": {
                             "type": "string"
                         }
+                    },
+                    "403": {
+                        "description": "缺少staff:manage权限",
+                        "schema": {
+                            "type": "string"
+                        }
                     }
                 }
             }
         },
-        "/chat": {
-            "get": {
-                "description": "通过此端点建立 WebSocket 连接。连接后，进行实时聊天交流。",
-                "consumes": [
-                    "application/json"
-                ],
-                "produces": [
-                    "application/json"
+        "/back/admin/{username}": {
+            "delete": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
                 ],
+                "description": "删除指定管理员,不能删除自己",
                 "tags": [
-                    "chat"
+                    "admin"
                 ],
-                "summary": "websocket服务端(无法在swagger中测试)",
+                "summary": "删除管理员",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "子协议填写JWT(不需要Bearer),用于身份验证",
-                        "name": "Sec-WebSocket-Protocol",
-                        "in": "header",
+                        "description": "管理员用户名",
+                        "name": "username",
+                        "in": "path",
                         "required": true
                     }
                 ],
                 "responses": {
-                    "101": {
-                        "description": "WebSocket 连接建立成功",
+                    "204": {
+                        "description": "删除成功，无返回内容"
+                    },
+                    "400": {
+                        "description": "管理员不存在",
                         "schema": {
                             "type": "string"
                         }
                     },
-                    "200": {
-                        "description": "OK",
+                    "401": {
+                        "description": "未授权，用户未登录或会话失效",
                         "schema": {
-                            "$ref": "#/definitions/model.Message"
+                            "type": "string"
                         }
                     },
-                    "401": {
-                        "description": "未授权，JWT无效或已过期",
+                    "403": {
+                        "description": "缺少staff:manage权限或删除自己",
                         "schema": {
                             "type": "string"
                         }
@@ -389,46 +471,37 @@ const docTemplate = `{
                 }
             }
         },
-        "/image": {
-            "put": {
+        "/back/duplicate": {
+            "get": {
                 "security": [
                     {
                         "BearerAuth": []
                     }
                 ],
-                "description": "修改自己上传的图片信息(仅标题,简介和标签允许修改,其他均以数据库已有信息为准)",
-                "consumes": [
-                    "application/json"
-                ],
+                "description": "获取与其他作者更早发布的图片相似的图片,按相似度从高到低排列;确认转载后可通过 /back/image/ban 封禁图片",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "image"
+                    "admin"
                 ],
-                "summary": "修改图片信息",
+                "summary": "获取疑似转载记录",
                 "parameters": [
                     {
-                        "description": "图片信息",
-                        "name": "image",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/model.Image"
-                        }
+                        "type": "string",
+                        "description": "审核状态(pending,confirmed,dismissed),默认pending,填写all获取全部",
+                        "name": "status",
+                        "in": "query"
                     }
                 ],
                 "responses": {
-                    "201": {
-                        "description": "图片信息更新成功，返回更新后的图片信息",
-                        "schema": {
-                            "$ref": "#/definitions/model.Image"
-                        }
-                    },
-                    "400": {
-                        "description": "请求数据异常",
+                    "200": {
+                        "description": "疑似转载记录",
                         "schema": {
-                            "type": "string"
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/model.DuplicateReport"
+                            }
                         }
                     },
                     "401": {
@@ -438,56 +511,44 @@ const docTemplate = `{
                         }
                     },
                     "403": {
-                        "description": "图片被封禁",
-                        "schema": {
-                            "type": "string"
-                        }
-                    },
-                    "500": {
-                        "description": "服务器内部错误",
+                        "description": "缺少image:read权限",
                         "schema": {
                             "type": "string"
                         }
                     }
                 }
             },
-            "post": {
+            "put": {
                 "security": [
                     {
                         "BearerAuth": []
                     }
                 ],
-                "description": "创建图片对象,需要先调用 /image/file [POST] 接口,获取到图片对象(包括图片id,作者用户名以及图片各个大小的地址),然后将其他元数据补充入此对象,再请求",
+                "description": "将疑似转载记录标记为确认转载(confirmed)或不是转载(dismissed)(只有id和status有用)",
                 "consumes": [
                     "application/json"
                 ],
-                "produces": [
-                    "application/json"
-                ],
                 "tags": [
-                    "image"
+                    "admin"
                 ],
-                "summary": "创建图片元信息",
+                "summary": "审核疑似转载记录",
                 "parameters": [
                     {
-                        "description": "图片对象,在/image/file [POST] 接口的返回值上补充元数据所得",
-                        "name": "image",
+                        "description": "疑似转载记录,包含id和status",
+                        "name": "report",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/model.Image"
+                            "$ref": "#/definitions/model.DuplicateReport"
                         }
                     }
                 ],
                 "responses": {
-                    "201": {
-                        "description": "图片对象",
-                        "schema": {
-                            "$ref": "#/definitions/model.Image"
-                        }
+                    "204": {
+                        "description": "审核成功，无返回内容"
                     },
                     "400": {
-                        "description": "请求数据异常",
+                        "description": "记录不存在或状态不合法",
                         "schema": {
                             "type": "string"
                         }
@@ -498,8 +559,8 @@ const docTemplate = `{
                             "type": "string"
                         }
                     },
-                    "500": {
-                        "description": "服务器内部错误",
+                    "403": {
+                        "description": "缺少image:ban权限",
                         "schema": {
                             "type": "string"
                         }
@@ -507,38 +568,26 @@ const docTemplate = `{
                 }
             }
         },
-        "/image/file": {
+        "/back/file/gc": {
             "post": {
                 "security": [
                     {
                         "BearerAuth": []
                     }
                 ],
-                "description": "上传图片文件，返回图片对象(包括图片id,作者用户名和地址).",
-                "consumes": [
-                    "multipart/form-data"
-                ],
+                "description": "删除图片,头像和原图目录下超过宽限期且没有被任何图片或用户引用的文件",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "image"
-                ],
-                "summary": "上传图片文件",
-                "parameters": [
-                    {
-                        "type": "file",
-                        "description": "图片文件",
-                        "name": "image",
-                        "in": "formData",
-                        "required": true
-                    }
+                    "admin"
                 ],
+                "summary": "立即清理无用文件",
                 "responses": {
-                    "201": {
-                        "description": "图片上传成功，返回图片对象",
+                    "200": {
+                        "description": "清理报告",
                         "schema": {
-                            "$ref": "#/definitions/model.Image"
+                            "$ref": "#/definitions/model.FileGCReport"
                         }
                     },
                     "401": {
@@ -547,6 +596,12 @@ const docTemplate = `{
                             "type": "string"
                         }
                     },
+                    "403": {
+                        "description": "缺少file:gc权限",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
                     "500": {
                         "description": "服务器内部错误",
                         "schema": {
@@ -556,41 +611,38 @@ const docTemplate = `{
                 }
             }
         },
-        "/image/from/{username}": {
+        "/back/file/orphan": {
             "get": {
                 "security": [
                     {
                         "BearerAuth": []
                     }
                 ],
-                "description": "查询指定用户名上传的所有图片",
-                "consumes": [
-                    "application/json"
-                ],
+                "description": "列出图片,头像和原图目录下超过宽限期且没有被任何图片或用户引用的文件,不会删除",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "image"
-                ],
-                "summary": "获取指定用户上传的所有图片",
-                "parameters": [
-                    {
-                        "type": "string",
-                        "description": "用户名",
-                        "name": "username",
-                        "in": "path",
-                        "required": true
-                    }
+                    "admin"
                 ],
+                "summary": "查看无用文件",
                 "responses": {
                     "200": {
-                        "description": "返回指定用户上传的所有图片信息",
+                        "description": "清理报告(dryRun为true)",
                         "schema": {
-                            "type": "array",
-                            "items": {
-                                "$ref": "#/definitions/model.Image"
-                            }
+                            "$ref": "#/definitions/model.FileGCReport"
+                        }
+                    },
+                    "401": {
+                        "description": "未授权，用户未登录或会话失效",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "缺少file:gc权限",
+                        "schema": {
+                            "type": "string"
                         }
                     },
                     "500": {
@@ -602,27 +654,21 @@ const docTemplate = `{
                 }
             }
         },
-        "/image/newest": {
+        "/back/image": {
             "get": {
                 "security": [
                     {
                         "BearerAuth": []
                     }
                 ],
-                "description": "获取最新的9张图片",
-                "consumes": [
-                    "application/json"
-                ],
-                "produces": [
-                    "application/json"
-                ],
+                "description": "管理员获取所有图片的详细信息",
                 "tags": [
-                    "image"
+                    "admin"
                 ],
-                "summary": "获取最新的9张图片",
+                "summary": "获取所有图片信息",
                 "responses": {
                     "200": {
-                        "description": "返回最新的9张图片信息",
+                        "description": "所有图片对象列表",
                         "schema": {
                             "type": "array",
                             "items": {
@@ -630,8 +676,20 @@ const docTemplate = `{
                             }
                         }
                     },
+                    "401": {
+                        "description": "未授权，用户未登录或会话失效",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "缺少image:read权限",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
                     "500": {
-                        "description": "服务器内部错误",
+                        "description": "服务器错误",
                         "schema": {
                             "type": "string"
                         }
@@ -639,45 +697,56 @@ const docTemplate = `{
                 }
             }
         },
-        "/image/search": {
-            "get": {
+        "/back/image/ban": {
+            "post": {
                 "security": [
+                    {
+                        "BearerAuth": []
+                    },
                     {
                         "BearerAuth": []
                     }
                 ],
-                "description": "查询图片，进行标签匹配和标题模糊匹配",
-                "consumes": [
-                    "application/json"
-                ],
-                "produces": [
-                    "application/json"
-                ],
+                "description": "管理员封禁指定图片(仅id有效)",
                 "tags": [
-                    "image"
+                    "admin"
                 ],
-                "summary": "查询图片",
+                "summary": "封禁图片",
                 "parameters": [
                     {
-                        "type": "string",
-                        "description": "查询内容",
-                        "name": "search",
-                        "in": "query",
-                        "required": true
+                        "description": "图片对象，其中包含图片ID",
+                        "name": "image",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/model.Image"
+                        }
                     }
                 ],
                 "responses": {
-                    "200": {
-                        "description": "返回符合查询条件的图片信息",
+                    "204": {
+                        "description": "操作成功，无返回内容"
+                    },
+                    "400": {
+                        "description": "图片不存在",
                         "schema": {
-                            "type": "array",
-                            "items": {
-                                "$ref": "#/definitions/model.Image"
-                            }
+                            "type": "string"
+                        }
+                    },
+                    "401": {
+                        "description": "未授权，用户未登录或会话失效",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "缺少image:ban权限",
+                        "schema": {
+                            "type": "string"
                         }
                     },
                     "500": {
-                        "description": "服务器内部错误",
+                        "description": "服务器错误",
                         "schema": {
                             "type": "string"
                         }
@@ -685,14 +754,14 @@ const docTemplate = `{
                 }
             }
         },
-        "/image/{imageID}": {
-            "get": {
+        "/back/image/rating": {
+            "put": {
                 "security": [
                     {
                         "BearerAuth": []
                     }
                 ],
-                "description": "根据提供的图片ID，查找并返回该图片对象",
+                "description": "管理员修改指定图片的内容分级(仅id,rating和ratingLocked有效),ratingLocked为true时作者不能再修改分级",
                 "consumes": [
                     "application/json"
                 ],
@@ -700,27 +769,26 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "image"
+                    "admin"
                 ],
-                "summary": "获取指定ID的图片对象",
+                "summary": "设置图片内容分级",
                 "parameters": [
                     {
-                        "type": "string",
-                        "description": "图片ID",
-                        "name": "imageID",
-                        "in": "path",
-                        "required": true
-                    }
-                ],
-                "responses": {
-                    "200": {
-                        "description": "图片对象",
+                        "description": "图片信息,包含id,rating和ratingLocked",
+                        "name": "image",
+                        "in": "body",
+                        "required": true,
                         "schema": {
                             "$ref": "#/definitions/model.Image"
                         }
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "设置成功，无返回内容"
                     },
                     "400": {
-                        "description": "请求错误，图片ID无效",
+                        "description": "请求错误，图片不存在或分级不合法",
                         "schema": {
                             "type": "string"
                         }
@@ -732,7 +800,7 @@ const docTemplate = `{
                         }
                     },
                     "403": {
-                        "description": "图片被封禁",
+                        "description": "缺少image:ban权限",
                         "schema": {
                             "type": "string"
                         }
@@ -744,14 +812,16 @@ const docTemplate = `{
                         }
                     }
                 }
-            },
-            "delete": {
+            }
+        },
+        "/back/image/unban": {
+            "post": {
                 "security": [
                     {
                         "BearerAuth": []
                     }
                 ],
-                "description": "删除自己上传的指定ID的图片",
+                "description": "解除对该图片的封禁状态(仅id有效)",
                 "consumes": [
                     "application/json"
                 ],
@@ -759,24 +829,26 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "image"
+                    "admin"
                 ],
-                "summary": "删除指定ID的图片",
+                "summary": "解除图片封禁",
                 "parameters": [
                     {
-                        "type": "string",
-                        "description": "图片ID",
-                        "name": "imageID",
-                        "in": "path",
-                        "required": true
+                        "description": "图片信息,包含id",
+                        "name": "image",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/model.Image"
+                        }
                     }
                 ],
                 "responses": {
                     "204": {
-                        "description": "图片删除成功，无返回内容"
+                        "description": "解除封禁成功，无返回内容"
                     },
                     "400": {
-                        "description": "请求错误",
+                        "description": "请求错误，图片ID无效或图片不存在",
                         "schema": {
                             "type": "string"
                         }
@@ -787,8 +859,8 @@ const docTemplate = `{
                             "type": "string"
                         }
                     },
-                    "404": {
-                        "description": "未找到图片，图片ID不存在",
+                    "403": {
+                        "description": "缺少image:ban权限",
                         "schema": {
                             "type": "string"
                         }
@@ -802,80 +874,88 @@ const docTemplate = `{
                 }
             }
         },
-        "/jwt/test": {
+        "/back/lockout": {
             "get": {
                 "security": [
                     {
                         "BearerAuth": []
                     }
                 ],
-                "description": "测试JWT是否有效，验证用户是否具有访问权限",
+                "description": "获取按用户名和IP统计的登录失败记录,lockedUntil晚于当前时间的正在被锁定",
+                "produces": [
+                    "application/json"
+                ],
                 "tags": [
-                    "auth"
+                    "admin"
                 ],
+                "summary": "获取登录失败记录",
                 "responses": {
                     "200": {
-                        "description": "JWT验证通过",
+                        "description": "登录失败记录",
                         "schema": {
-                            "type": "string"
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/model.LoginAttempt"
+                            }
                         }
                     },
                     "401": {
-                        "description": "未授权，JWT无效或已过期",
+                        "description": "未授权，用户未登录或会话失效",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "缺少lockout:read权限",
                         "schema": {
                             "type": "string"
                         }
                     }
                 }
-            }
-        },
-        "/user": {
-            "put": {
+            },
+            "delete": {
                 "security": [
                     {
                         "BearerAuth": []
                     }
                 ],
-                "description": "允许已登录的用户更新自己的信息，包括密码。如果没有提供密码，密码保持不变。",
+                "description": "清除指定对象的登录失败记录和锁定(只有key有用)",
                 "consumes": [
                     "application/json"
                 ],
-                "produces": [
-                    "application/json"
-                ],
                 "tags": [
-                    "user"
+                    "admin"
                 ],
-                "summary": "更新用户信息",
+                "summary": "解除登录锁定",
                 "parameters": [
                     {
-                        "description": "用户信息",
-                        "name": "user",
+                        "description": "登录失败记录,包含key",
+                        "name": "attempt",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/model.User"
+                            "$ref": "#/definitions/model.LoginAttempt"
                         }
                     }
                 ],
                 "responses": {
                     "204": {
-                        "description": "用户信息更新成功，无返回内容"
+                        "description": "解除成功，无返回内容"
                     },
-                    "401": {
-                        "description": "未授权错误",
+                    "400": {
+                        "description": "记录不存在",
                         "schema": {
                             "type": "string"
                         }
                     },
-                    "403": {
-                        "description": "禁止操作，尝试修改非自己的信息",
+                    "401": {
+                        "description": "未授权，用户未登录或会话失效",
                         "schema": {
                             "type": "string"
                         }
                     },
-                    "500": {
-                        "description": "服务器内部错误",
+                    "403": {
+                        "description": "缺少lockout:clear权限",
                         "schema": {
                             "type": "string"
                         }
@@ -883,48 +963,57 @@ const docTemplate = `{
                 }
             }
         },
-        "/user/avatar": {
+        "/back/login": {
             "post": {
-                "security": [
-                    {
-                        "BearerAuth": []
-                    }
-                ],
-                "description": "上传用户自己的头像文件,后续需要再请求一次/user [put]来更新用户信息",
+                "description": "管理员登录",
                 "consumes": [
-                    "multipart/form-data"
+                    "application/json"
                 ],
                 "produces": [
-                    "text/plain"
+                    "application/json"
                 ],
                 "tags": [
-                    "user"
+                    "admin"
                 ],
-                "summary": "上传用户头像",
+                "summary": "管理员登录",
                 "parameters": [
                     {
-                        "type": "file",
-                        "description": "用户上传的头像文件",
-                        "name": "image",
-                        "in": "formData",
-                        "required": true
+                        "description": "管理员登录信息",
+                        "name": "admin",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/model.Admin"
+                        }
                     }
                 ],
                 "responses": {
-                    "201": {
-                        "description": "返回头像存储路径",
+                    "200": {
+                        "description": "登录凭证",
+                        "schema": {
+                            "$ref": "#/definitions/model.Token"
+                        }
+                    },
+                    "202": {
+                        "description": "需要两步验证,使用mfaToken请求 /back/login/mfa (enrollRequired为true时先请求 /back/login/mfa/enroll 绑定)",
+                        "schema": {
+                            "$ref": "#/definitions/model.MFAChallenge"
+                        }
+                    },
+                    "403": {
+                        "description": "用户名或密码错误或管理员被停用",
                         "schema": {
                             "type": "string"
                         }
                     },
-                    "401": {
-                        "description": "用户未授权",
+                    "429": {
+                        "description": "登录失败次数过多,Retry-After响应头给出需要等待的秒数",
                         "schema": {
                             "type": "string"
                         }
                     },
                     "500": {
-                        "description": "服务器内部错误",
+                        "description": "服务器错误",
                         "schema": {
                             "type": "string"
                         }
@@ -932,45 +1021,51 @@ const docTemplate = `{
                 }
             }
         },
-        "/user/login": {
+        "/back/login/mfa": {
             "post": {
-                "description": "用户通过用户名和密码登录，成功后返回 JWT Token",
+                "description": "管理员登录返回202时,提交两步验证令牌和验证码(或恢复码)完成登录;强制绑定时此验证码同时用于启用两步验证",
                 "consumes": [
                     "application/json"
                 ],
                 "produces": [
-                    "text/plain"
+                    "application/json"
                 ],
                 "tags": [
-                    "auth"
+                    "admin"
                 ],
-                "summary": "用户登录",
+                "summary": "管理员两步验证",
                 "parameters": [
                     {
-                        "description": "用户登录信息(只需要username和password)",
-                        "name": "user",
+                        "description": "两步验证令牌和验证码",
+                        "name": "verify",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/model.User"
+                            "$ref": "#/definitions/model.MFAVerify"
                         }
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "JWT Token",
+                        "description": "登录凭证",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/model.Token"
                         }
                     },
                     "400": {
-                        "description": "用户名或密码错误",
+                        "description": "验证码错误或尚未绑定",
                         "schema": {
                             "type": "string"
                         }
                     },
-                    "403": {
-                        "description": "用户名被封禁",
+                    "401": {
+                        "description": "两步验证令牌无效或已过期",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "429": {
+                        "description": "失败次数过多,Retry-After响应头给出需要等待的秒数",
                         "schema": {
                             "type": "string"
                         }
@@ -984,39 +1079,45 @@ const docTemplate = `{
                 }
             }
         },
-        "/user/register": {
+        "/back/login/mfa/enroll": {
             "post": {
-                "description": "用户进行注册，成功后返回 JWT Token",
+                "description": "强制两步验证而管理员尚未绑定时,使用两步验证令牌获取绑定信息,再通过 /back/login/mfa 提交验证码完成绑定和登录",
                 "consumes": [
                     "application/json"
                 ],
                 "produces": [
-                    "text/plain"
+                    "application/json"
                 ],
                 "tags": [
-                    "auth"
+                    "admin"
                 ],
-                "summary": "用户注册",
+                "summary": "管理员登录时绑定两步验证",
                 "parameters": [
                     {
-                        "description": "用户注册信息(只需要username和password)",
-                        "name": "user",
+                        "description": "两步验证令牌(只需要mfaToken)",
+                        "name": "verify",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/model.User"
+                            "$ref": "#/definitions/model.MFAVerify"
                         }
                     }
                 ],
                 "responses": {
                     "201": {
-                        "description": "JWT Token",
+                        "description": "绑定信息",
+                        "schema": {
+                            "$ref": "#/definitions/model.MFASetup"
+                        }
+                    },
+                    "400": {
+                        "description": "两步验证已启用",
                         "schema": {
                             "type": "string"
                         }
                     },
-                    "403": {
-                        "description": "用户名已存在",
+                    "401": {
+                        "description": "两步验证令牌无效或已过期",
                         "schema": {
                             "type": "string"
                         }
@@ -1030,33 +1131,46 @@ const docTemplate = `{
                 }
             }
         },
-        "/user/star": {
-            "get": {
+        "/back/logout": {
+            "post": {
                 "security": [
                     {
                         "BearerAuth": []
                     }
                 ],
-                "description": "查询用户自己的所有收藏信息",
-                "consumes": [
-                    "application/json"
-                ],
-                "produces": [
-                    "application/json"
-                ],
+                "description": "注销当前使用的访问令牌及其对应的刷新令牌",
                 "tags": [
-                    "user"
+                    "admin"
                 ],
-                "summary": "获取用户的收藏信息",
+                "summary": "管理员退出登录",
                 "responses": {
-                    "200": {
-                        "description": "返回用户的所有收藏记录",
+                    "204": {
+                        "description": "退出登录成功，无返回内容"
+                    },
+                    "401": {
+                        "description": "未授权，用户未登录或会话失效",
                         "schema": {
-                            "type": "array",
-                            "items": {
-                                "$ref": "#/definitions/model.Star"
-                            }
+                            "type": "string"
                         }
+                    }
+                }
+            }
+        },
+        "/back/logout/all": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "注销管理员在所有设备上签发的访问令牌和刷新令牌",
+                "tags": [
+                    "admin"
+                ],
+                "summary": "管理员退出所有设备的登录",
+                "responses": {
+                    "204": {
+                        "description": "退出登录成功，无返回内容"
                     },
                     "401": {
                         "description": "未授权，用户未登录或会话失效",
@@ -1065,43 +1179,42 @@ const docTemplate = `{
                         }
                     }
                 }
-            },
-            "post": {
+            }
+        },
+        "/back/password": {
+            "put": {
                 "security": [
                     {
                         "BearerAuth": []
                     }
                 ],
-                "description": "用户收藏指定的图片",
+                "description": "管理员修改自己的密码,需要提供原密码,修改后所有设备上的登录全部失效,需要重新登录",
                 "consumes": [
                     "application/json"
                 ],
-                "produces": [
-                    "application/json"
-                ],
                 "tags": [
-                    "user"
+                    "admin"
                 ],
-                "summary": "用户收藏图片",
+                "summary": "管理员修改密码",
                 "parameters": [
                     {
-                        "description": "收藏信息,只需要图片ID",
-                        "name": "star",
+                        "description": "原密码和新密码",
+                        "name": "change",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/model.Star"
+                            "$ref": "#/definitions/model.PasswordChange"
                         }
                     }
                 ],
                 "responses": {
                     "204": {
-                        "description": "图片收藏成功，无返回内容"
+                        "description": "修改成功，无返回内容"
                     },
                     "400": {
-                        "description": "请求错误，图片不存在",
+                        "description": "新密码强度不足(原密码错误时返回文本)",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/model.ValidationError"
                         }
                     },
                     "401": {
@@ -1111,7 +1224,7 @@ const docTemplate = `{
                         }
                     },
                     "403": {
-                        "description": "收藏的图片被封禁",
+                        "description": "不是管理员账号",
                         "schema": {
                             "type": "string"
                         }
@@ -1123,43 +1236,65 @@ const docTemplate = `{
                         }
                     }
                 }
-            },
-            "delete": {
+            }
+        },
+        "/back/permission": {
+            "get": {
                 "security": [
                     {
                         "BearerAuth": []
                     }
                 ],
-                "description": "取消自己收藏的图片",
-                "consumes": [
-                    "application/json"
-                ],
+                "description": "获取当前登录者(管理员账号或被分配角色的普通用户)的角色和权限,用于前端展示可用的管理功能",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "user"
+                    "admin"
                 ],
-                "summary": "取消用户的图片收藏",
-                "parameters": [
-                    {
-                        "description": "收藏信息,只需要图片ID",
-                        "name": "star",
-                        "in": "body",
-                        "required": true,
+                "summary": "获取当前登录者的角色和权限",
+                "responses": {
+                    "200": {
+                        "description": "角色和权限",
                         "schema": {
-                            "$ref": "#/definitions/model.Star"
+                            "$ref": "#/definitions/model.Permission"
+                        }
+                    },
+                    "401": {
+                        "description": "未授权，用户未登录或没有任何角色",
+                        "schema": {
+                            "type": "string"
                         }
                     }
+                }
+            }
+        },
+        "/back/user": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "返回所有用户的信息",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
                 ],
+                "summary": "获取所有用户列表",
                 "responses": {
-                    "204": {
-                        "description": "取消收藏成功，无返回内容"
-                    },
-                    "400": {
-                        "description": "请求错误，收藏记录不存在",
+                    "200": {
+                        "description": "用户列表",
                         "schema": {
-                            "type": "string"
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/model.User"
+                            }
                         }
                     },
                     "401": {
@@ -1167,134 +1302,3781 @@ const docTemplate = `{
                         "schema": {
                             "type": "string"
                         }
+                    },
+                    "403": {
+                        "description": "缺少user:read权限",
+                        "schema": {
+                            "type": "string"
+                        }
                     }
                 }
             }
         },
-        "/user/{username}": {
-            "get": {
+        "/back/user/ban": {
+            "post": {
                 "security": [
                     {
                         "BearerAuth": []
                     }
                 ],
-                "description": "根据用户名获取用户详细信息(无密码)，需要JWT验证",
+                "description": "封禁指定用户账号(只有username有用)",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
                 "tags": [
-                    "user"
+                    "admin"
                 ],
-                "summary": "获取指定用户名的用户对象(无密码)",
+                "summary": "封禁用户",
                 "parameters": [
                     {
-                        "type": "string",
-                        "description": "用户名",
-                        "name": "username",
-                        "in": "path",
-                        "required": true
+                        "description": "用户信息，包含用户名",
+                        "name": "user",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/model.User"
+                        }
                     }
                 ],
                 "responses": {
-                    "200": {
-                        "description": "用户对象(无密码)",
+                    "204": {
+                        "description": "封禁成功，无返回内容",
                         "schema": {
-                            "$ref": "#/definitions/model.User"
+                            "type": "string"
                         }
                     },
-                    "401": {
-                        "description": "未授权错误",
+                    "400": {
+                        "description": "用户不存在",
                         "schema": {
                             "type": "string"
                         }
                     },
-                    "403": {
-                        "description": "用户被封禁",
+                    "401": {
+                        "description": "未授权，用户未登录或会话失效",
                         "schema": {
                             "type": "string"
                         }
                     },
-                    "404": {
+                    "403": {
+                        "description": "缺少user:ban权限",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/back/user/unban": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "解除指定用户的封禁状态(只有username有用)",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "解除封禁用户",
+                "parameters": [
+                    {
+                        "description": "用户信息，包含用户名",
+                        "name": "user",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/model.User"
+                        }
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "解除封禁成功，无返回内容",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "400": {
                         "description": "用户不存在",
                         "schema": {
                             "type": "string"
                         }
+                    },
+                    "401": {
+                        "description": "未授权，用户未登录或会话失效",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "缺少user:ban权限",
+                        "schema": {
+                            "type": "string"
+                        }
                     }
                 }
             }
-        }
-    },
-    "definitions": {
-        "model.Admin": {
-            "description": "管理员",
-            "type": "object",
-            "properties": {
-                "password": {
+        },
+        "/chat": {
+            "get": {
+                "description": "通过此端点建立 WebSocket 连接。连接后，进行实时聊天交流。",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "chat"
+                ],
+                "summary": "websocket服务端(无法在swagger中测试)",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "子协议填写JWT或API令牌(不需要Bearer),用于身份验证;API令牌需要chat:read授权,发送消息还需要chat:write",
+                        "name": "Sec-WebSocket-Protocol",
+                        "in": "header",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "101": {
+                        "description": "WebSocket 连接建立成功",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/model.Message"
+                        }
+                    },
+                    "401": {
+                        "description": "未授权，JWT无效或已过期",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/image": {
+            "put": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "修改自己上传的图片信息(仅标题,简介,标签,可见性,定时发布时间和内容分级允许修改,其他均以数据库已有信息为准),替换图片文件请使用 /image/{imageID}/file [PUT];\n可见性,定时发布时间和内容分级为空时保持不变;取消定时发布需要设置cancelPublish为true,取消后保持私密;内容分级被管理员锁定后不能修改.\n定时发布期间作品为私密,此时设置的visibility保存为publishVisibility,到达发布时间后改为该可见性",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "image"
+                ],
+                "summary": "修改图片信息",
+                "parameters": [
+                    {
+                        "description": "图片信息",
+                        "name": "image",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/model.Image"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "图片信息更新成功，返回更新后的图片信息",
+                        "schema": {
+                            "$ref": "#/definitions/model.Image"
+                        }
+                    },
+                    "400": {
+                        "description": "标题,简介或标签不合法(其他请求数据异常时返回文本)",
+                        "schema": {
+                            "$ref": "#/definitions/model.ValidationError"
+                        }
+                    },
+                    "401": {
+                        "description": "未授权，用户未登录或会话失效",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "图片被封禁或分级已被管理员锁定",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "服务器内部错误",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "创建图片对象,需要先调用 /image/file [POST] 接口,获取到图片对象(包括图片id,作者用户名以及图片各个大小的地址),然后将其他元数据补充入此对象,再请求\n多页作品(漫画,设定图,草稿到成稿等)依次上传各页文件,将返回的id,bigURI和midURI按顺序填入pages,可用cover指定封面页;\n作品id为第一页的id,标题,简介,标签,收藏和搜索都以作品为单位",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "image"
+                ],
+                "summary": "创建图片元信息",
+                "parameters": [
+                    {
+                        "description": "图片对象,在/image/file [POST] 接口的返回值上补充元数据所得",
+                        "name": "image",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/model.Image"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "图片对象",
+                        "schema": {
+                            "$ref": "#/definitions/model.Image"
+                        }
+                    },
+                    "400": {
+                        "description": "标题,简介或标签不合法(其他请求数据异常,或拒绝疑似转载时图片还在处理中时返回文本)",
+                        "schema": {
+                            "$ref": "#/definitions/model.ValidationError"
+                        }
+                    },
+                    "401": {
+                        "description": "未授权，用户未登录或会话失效",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "409": {
+                        "description": "与其他作者已发布的图片过于相似(仅在拒绝疑似转载时)",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/model.DuplicateReport"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "服务器内部错误",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/image/file": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "上传图片文件，返回图片对象(包括图片id,作者用户名和地址).各尺寸的图片在后台生成,\n返回时status为pending,可通过 /image/file/{imageID} [GET] 查询处理状态,变为ready后图片地址才可访问",
+                "consumes": [
+                    "multipart/form-data"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "image"
+                ],
+                "summary": "上传图片文件",
+                "parameters": [
+                    {
+                        "type": "file",
+                        "description": "图片文件",
+                        "name": "image",
+                        "in": "formData",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "202": {
+                        "description": "图片上传成功，返回图片对象",
+                        "schema": {
+                            "$ref": "#/definitions/model.Image"
+                        }
+                    },
+                    "400": {
+                        "description": "图片尺寸超过限制",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "401": {
+                        "description": "未授权，用户未登录或会话失效",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "413": {
+                        "description": "文件超过大小限制",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "415": {
+                        "description": "不是JPEG,PNG,WebP或GIF格式的图片",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "服务器内部错误",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "503": {
+                        "description": "图片处理队列已满",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/image/file/{imageID}": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "查询自己上传的图片文件的处理状态(pending,ready,failed),临时错误会自动重试",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "image"
+                ],
+                "summary": "查询图片处理状态",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "图片ID",
+                        "name": "imageID",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "图片处理任务",
+                        "schema": {
+                            "$ref": "#/definitions/model.ImageJob"
+                        }
+                    },
+                    "401": {
+                        "description": "未授权，用户未登录或会话失效",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "处理任务不存在",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/image/from/{username}": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "查询指定用户名上传的所有图片(作品),多页作品的各页地址在pages中;\n只返回公开的和关注者可见(已关注作者时)的图片,查询自己时返回全部(包括不公开列出,私密和定时发布的);\n成人内容(nsfw)按用户的显示偏好不显示或隐去图片",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "image"
+                ],
+                "summary": "获取指定用户上传的所有图片",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "用户名",
+                        "name": "username",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "返回指定用户上传的所有图片信息",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/model.Image"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "服务器内部错误",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/image/newest": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "获取最新公开的9张图片(作品),按公开时间(定时发布的为发布时间)排序,多页作品的各页地址在pages中;成人内容(nsfw)按用户的显示偏好不显示或隐去图片",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "image"
+                ],
+                "summary": "获取最新的9张图片",
+                "responses": {
+                    "200": {
+                        "description": "返回最新的9张图片信息",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/model.Image"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "服务器内部错误",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/image/search": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "查询公开的图片(作品)，进行标签匹配和标题模糊匹配,多页作品的各页地址在pages中;成人内容(nsfw)按用户的显示偏好不显示或隐去图片",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "image"
+                ],
+                "summary": "查询图片",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "查询内容",
+                        "name": "search",
+                        "in": "query",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "返回符合查询条件的图片信息",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/model.Image"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "服务器内部错误",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/image/{imageID}": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "根据提供的图片ID，查找并返回该图片对象.不公开列出的图片知道id即可查看,仅关注者可见的需要关注作者,私密的只有作者可以查看;\n成人内容(nsfw)在用户的显示偏好不为show时隐去图片地址和简介(redacted为true)",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "image"
+                ],
+                "summary": "获取指定ID的图片对象",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "图片ID",
+                        "name": "imageID",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "图片对象",
+                        "schema": {
+                            "$ref": "#/definitions/model.Image"
+                        }
+                    },
+                    "400": {
+                        "description": "请求错误，图片ID无效",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "401": {
+                        "description": "未授权，用户未登录或会话失效",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "图片被封禁",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "服务器内部错误",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "删除自己上传的指定ID的图片",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "image"
+                ],
+                "summary": "删除指定ID的图片",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "图片ID",
+                        "name": "imageID",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "图片删除成功，无返回内容"
+                    },
+                    "400": {
+                        "description": "请求错误",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "401": {
+                        "description": "未授权，用户未登录或会话失效",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "未找到图片，图片ID不存在",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "服务器内部错误",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/image/{imageID}/file": {
+            "put": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "为自己作品中的一页上传新版本的文件(如修改笔误),收藏,点赞和搜索记录都保留.新文件在后台处理,\n处理完成后才替换当前文件,原文件保存为历史版本(每页最多保留10个),可通过 /image/{imageID}/revision 查看和回滚.\n替换后该页的id变为新文件的id,作品id不变.拒绝疑似转载时,新文件与其他作者已发布的图片相似则不替换,处理任务改为失败",
+                "consumes": [
+                    "multipart/form-data"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "image"
+                ],
+                "summary": "上传新版本文件",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "图片ID",
+                        "name": "imageID",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "file",
+                        "description": "新版本的图片文件",
+                        "name": "image",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "要替换的页面id,默认为封面页",
+                        "name": "page",
+                        "in": "formData"
+                    }
+                ],
+                "responses": {
+                    "202": {
+                        "description": "该页的当前版本,新版本的处理任务id和历史版本",
+                        "schema": {
+                            "$ref": "#/definitions/model.PageHistory"
+                        }
+                    },
+                    "400": {
+                        "description": "图片或页面不存在,或非本人上传",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "401": {
+                        "description": "未授权，用户未登录或会话失效",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "图片被封禁",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "413": {
+                        "description": "文件超过大小限制",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "415": {
+                        "description": "不是JPEG,PNG,WebP或GIF格式的图片",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "服务器内部错误",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "503": {
+                        "description": "图片处理队列已满",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/image/{imageID}/pages": {
+            "put": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "按给定顺序重新排列自己作品的各页,需要包含全部页面id;封面为空时使用第一页",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "image"
+                ],
+                "summary": "调整作品的页面顺序和封面",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "图片ID",
+                        "name": "imageID",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "页面顺序和封面",
+                        "name": "order",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/model.PageOrder"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "调整后的图片对象",
+                        "schema": {
+                            "$ref": "#/definitions/model.Image"
+                        }
+                    },
+                    "400": {
+                        "description": "图片不存在,非本人上传或页面顺序不合法",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "401": {
+                        "description": "未授权，用户未登录或会话失效",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "图片被封禁",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "服务器内部错误",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/image/{imageID}/revision": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "获取自己作品各页的当前版本,正在处理的新版本和历史版本",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "image"
+                ],
+                "summary": "获取历史版本",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "图片ID",
+                        "name": "imageID",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "各页的版本,按页面顺序排列",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/model.PageHistory"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "图片不存在或非本人上传",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "401": {
+                        "description": "未授权，用户未登录或会话失效",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "图片被封禁",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            },
+            "put": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "将自己作品中的一页回滚到历史版本,当前版本保存为历史版本;回滚后该页的id变为历史版本的id",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "image"
+                ],
+                "summary": "回滚到历史版本",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "图片ID",
+                        "name": "imageID",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "页面id和历史版本id",
+                        "name": "rollback",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/model.RevisionRollback"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "回滚后的图片对象",
+                        "schema": {
+                            "$ref": "#/definitions/model.Image"
+                        }
+                    },
+                    "400": {
+                        "description": "图片,页面或历史版本不存在,或非本人上传",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "401": {
+                        "description": "未授权，用户未登录或会话失效",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "图片被封禁",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "服务器内部错误",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/image/{imageID}/stage": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "为自己的作品上传一张创作过程中的阶段图片(草稿,线稿,铺色等),按上传顺序保存;\n图片在后台处理,可通过 /image/file/{stageID} [GET] 或作品的stages查询处理状态",
+                "consumes": [
+                    "multipart/form-data"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "image"
+                ],
+                "summary": "上传阶段图片",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "图片ID",
+                        "name": "imageID",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "file",
+                        "description": "阶段图片文件",
+                        "name": "image",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "阶段名称,如 草稿,线稿,铺色",
+                        "name": "name",
+                        "in": "formData",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "202": {
+                        "description": "阶段图片,处理中",
+                        "schema": {
+                            "$ref": "#/definitions/model.ImageStage"
+                        }
+                    },
+                    "400": {
+                        "description": "阶段名称不合法(图片不存在,非本人上传或阶段数超过限制时返回文本)",
+                        "schema": {
+                            "$ref": "#/definitions/model.ValidationError"
+                        }
+                    },
+                    "401": {
+                        "description": "未授权，用户未登录或会话失效",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "图片被封禁",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "413": {
+                        "description": "文件超过大小限制",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "415": {
+                        "description": "不是JPEG,PNG,WebP或GIF格式的图片",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "服务器内部错误",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "503": {
+                        "description": "图片处理队列已满",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/image/{imageID}/stage/{stageID}": {
+            "delete": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "删除自己作品中的一张阶段图片,已生成的延时视频需要重新生成",
+                "tags": [
+                    "image"
+                ],
+                "summary": "删除阶段图片",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "图片ID",
+                        "name": "imageID",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "阶段图片ID",
+                        "name": "stageID",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "删除成功，无返回内容"
+                    },
+                    "400": {
+                        "description": "图片或阶段图片不存在,或非本人上传",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "401": {
+                        "description": "未授权，用户未登录或会话失效",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "图片被封禁",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "服务器内部错误",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/image/{imageID}/timelapse": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "将自己作品的各阶段图片和最终图片(封面)依次生成带交叉淡化的MP4视频和GIF动图,保存在作品的timelapse中;\n在后台生成,完成后timelapse.status变为ready.生成完成后再次调用会重新生成,正在生成时返回409",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "image"
+                ],
+                "summary": "生成创作过程延时视频",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "图片ID",
+                        "name": "imageID",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "202": {
+                        "description": "延时视频,生成中",
+                        "schema": {
+                            "$ref": "#/definitions/model.Timelapse"
+                        }
+                    },
+                    "400": {
+                        "description": "图片不存在,非本人上传,没有阶段图片或阶段图片还在处理中",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "401": {
+                        "description": "未授权，用户未登录或会话失效",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "图片被封禁",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "409": {
+                        "description": "延时视频正在生成中",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "503": {
+                        "description": "生成队列已满",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/jwt/test": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "测试JWT是否有效，验证用户是否具有访问权限",
+                "tags": [
+                    "auth"
+                ],
+                "responses": {
+                    "200": {
+                        "description": "JWT验证通过",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "401": {
+                        "description": "未授权，JWT无效或已过期",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/oidc/providers": {
+            "get": {
+                "description": "列出已配置的OIDC身份提供方",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "oidc"
+                ],
+                "summary": "获取可用的第三方登录",
+                "responses": {
+                    "200": {
+                        "description": "身份提供方列表",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/model.OIDCProvider"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/oidc/{provider}/callback": {
+            "get": {
+                "description": "身份提供方验证后跳转到此地址,服务端完成验证后重定向到前端的 {siteURL}/oidc/callback,结果放在地址的#片段中:\n登录成功为accessToken,refreshToken和expiresAt;需要两步验证为mfaToken;关联流程为linkToken;失败为error",
+                "tags": [
+                    "oidc"
+                ],
+                "summary": "第三方登录回调",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "身份提供方名称",
+                        "name": "provider",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "授权码",
+                        "name": "code",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "state",
+                        "name": "state",
+                        "in": "query",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "302": {
+                        "description": "重定向到前端",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/oidc/{provider}/login": {
+            "get": {
+                "description": "浏览器直接跳转到此地址,服务端重定向到身份提供方(授权码+PKCE),完成后回到 /oidc/{provider}/callback.\n回调地址为身份提供方配置的redirectURL,未配置时为 {siteURL}/oidc/{provider}/callback,两者都未配置时返回500\n关联外部身份需要已登录的用户通过 /user/identities/link/{provider} [post] 发起,此处不接受mode=link",
+                "tags": [
+                    "oidc"
+                ],
+                "summary": "第三方登录",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "身份提供方名称",
+                        "name": "provider",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "302": {
+                        "description": "重定向到身份提供方",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "400": {
+                        "description": "关联流程必须登录后发起",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "身份提供方不存在",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "未配置回调地址",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "502": {
+                        "description": "无法连接身份提供方",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/user": {
+            "put": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "允许已登录的用户更新自己的信息，包括密码。如果没有提供密码，密码保持不变。\n修改密码需要在currentPassword中提供当前密码，修改后所有设备上的登录全部失效，需要重新登录。\n修改邮箱需要在currentPassword中提供当前密码(API令牌不能修改邮箱)，修改后需要重新验证，会向新邮箱发送验证邮件。\navatarURI只能是/user/avatar [post]返回的头像地址或默认头像，与当前头像相同时不修改。\nratingFilter为成人内容的显示偏好:hide(默认,列表中不显示,查看时隐去图片),blur(列表中显示但隐去图片),show(正常显示)",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "user"
+                ],
+                "summary": "更新用户信息",
+                "parameters": [
+                    {
+                        "description": "用户信息",
+                        "name": "user",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/model.User"
+                        }
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "用户信息更新成功，无返回内容"
+                    },
+                    "400": {
+                        "description": "用户信息不合法",
+                        "schema": {
+                            "$ref": "#/definitions/model.ValidationError"
+                        }
+                    },
+                    "401": {
+                        "description": "未授权错误",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "禁止操作，尝试修改非自己的信息,使用API令牌修改密码或邮箱,或修改密码或邮箱时当前密码错误",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "服务器内部错误",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/user/avatar": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "上传用户自己的头像文件,裁剪为正方形后生成32,128,512三种尺寸,后续需要再请求一次/user [put]将avatarURI更新到用户信息.\n可以指定裁剪区域(四个参数需同时提供),不是正方形时取其中心的最大正方形;不指定时取整张图片中心的最大正方形",
+                "consumes": [
+                    "multipart/form-data"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "user"
+                ],
+                "summary": "上传用户头像",
+                "parameters": [
+                    {
+                        "type": "file",
+                        "description": "用户上传的头像文件",
+                        "name": "image",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "裁剪区域左上角横坐标",
+                        "name": "cropX",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "裁剪区域左上角纵坐标",
+                        "name": "cropY",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "裁剪区域宽度",
+                        "name": "cropWidth",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "裁剪区域高度",
+                        "name": "cropHeight",
+                        "in": "formData"
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "返回各尺寸头像地址",
+                        "schema": {
+                            "$ref": "#/definitions/model.AvatarUpload"
+                        }
+                    },
+                    "400": {
+                        "description": "裁剪区域不合法(图片尺寸超过限制时返回文本)",
+                        "schema": {
+                            "$ref": "#/definitions/model.ValidationError"
+                        }
+                    },
+                    "401": {
+                        "description": "未授权",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "413": {
+                        "description": "文件超过大小限制",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "415": {
+                        "description": "不是JPEG,PNG,WebP或GIF格式的图片",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "服务器内部错误",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/user/email/verification": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "向用户自己尚未验证的邮箱重新发送验证邮件",
+                "tags": [
+                    "user"
+                ],
+                "summary": "重新发送邮箱验证邮件",
+                "responses": {
+                    "204": {
+                        "description": "验证邮件已发送，无返回内容"
+                    },
+                    "400": {
+                        "description": "没有填写邮箱或邮箱已验证",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "401": {
+                        "description": "未授权，用户未登录或会话失效",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "服务器内部错误",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/user/email/verify": {
+            "post": {
+                "description": "使用验证邮件中的令牌验证邮箱,令牌只能使用一次",
+                "consumes": [
+                    "application/json"
+                ],
+                "tags": [
+                    "auth"
+                ],
+                "summary": "验证邮箱",
+                "parameters": [
+                    {
+                        "description": "邮箱验证令牌",
+                        "name": "verification",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/model.EmailVerification"
+                        }
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "邮箱验证成功"
+                    },
+                    "401": {
+                        "description": "令牌无效,已过期或已被使用",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "服务器内部错误",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/user/follow": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "获取当前用户关注的所有用户,关注后可以查看作者仅关注者可见的图片",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "user"
+                ],
+                "summary": "获取用户关注的所有用户",
+                "responses": {
+                    "200": {
+                        "description": "返回用户的所有关注记录",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/model.Follow"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "未授权，用户未登录或会话失效",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "关注指定的用户",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "user"
+                ],
+                "summary": "关注用户",
+                "parameters": [
+                    {
+                        "description": "关注信息,只需要关注的用户名",
+                        "name": "follow",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/model.Follow"
+                        }
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "关注成功，无返回内容"
+                    },
+                    "400": {
+                        "description": "请求错误，用户不存在,不能关注自己或已关注",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "401": {
+                        "description": "未授权，用户未登录或会话失效",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "取消关注指定的用户",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "user"
+                ],
+                "summary": "取消关注用户",
+                "parameters": [
+                    {
+                        "description": "关注信息,只需要关注的用户名",
+                        "name": "follow",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/model.Follow"
+                        }
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "取消关注成功，无返回内容"
+                    },
+                    "400": {
+                        "description": "请求错误，关注记录不存在",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "401": {
+                        "description": "未授权，用户未登录或会话失效",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/user/identities": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "列出用户关联的第三方登录身份",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "oidc"
+                ],
+                "summary": "获取关联的外部身份",
+                "responses": {
+                    "200": {
+                        "description": "外部身份列表",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/model.Identity"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "未授权，用户未登录或会话失效",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "管理员账号或API令牌不能管理外部身份",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "提交 /user/identities/link/{provider} 发起的流程完成后得到的linkToken,将外部身份关联到当前用户;令牌只能由发起关联的用户提交",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "oidc"
+                ],
+                "summary": "关联外部身份",
+                "parameters": [
+                    {
+                        "description": "关联令牌",
+                        "name": "link",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/model.IdentityLink"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "关联的外部身份",
+                        "schema": {
+                            "$ref": "#/definitions/model.Identity"
+                        }
+                    },
+                    "401": {
+                        "description": "未授权或关联令牌无效",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "管理员账号或API令牌不能管理外部身份,或关联令牌不属于当前用户",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "409": {
+                        "description": "该外部身份已关联其他用户",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "服务器内部错误",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/user/identities/link/{provider}": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "由已登录的用户发起关联流程,返回身份提供方的授权地址,同时在 /oidc/{provider} 下设置保存流程状态的cookie.\n前端将浏览器跳转到返回的url,验证完成后跳转地址中带linkToken,只能由当前用户提交到 /user/identities 完成关联",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "oidc"
+                ],
+                "summary": "发起关联外部身份",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "身份提供方名称",
+                        "name": "provider",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "授权地址",
+                        "schema": {
+                            "$ref": "#/definitions/model.IdentityLinkStart"
+                        }
+                    },
+                    "401": {
+                        "description": "未授权，用户未登录或会话失效",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "管理员账号或API令牌不能管理外部身份",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "身份提供方不存在",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "未配置回调地址",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "502": {
+                        "description": "无法连接身份提供方",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/user/identities/{id}": {
+            "delete": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "解除指定的外部身份关联;邮箱未验证时不能解除最后一个关联,否则将无法登录",
+                "tags": [
+                    "oidc"
+                ],
+                "summary": "解除外部身份关联",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "关联id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "解除成功，无返回内容"
+                    },
+                    "400": {
+                        "description": "这是唯一的登录方式",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "401": {
+                        "description": "未授权，用户未登录或会话失效",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "管理员账号或API令牌不能管理外部身份",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "关联的外部身份不存在",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/user/login": {
+            "post": {
+                "description": "用户通过用户名和密码登录，成功后返回访问令牌(JWT)和刷新令牌",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "auth"
+                ],
+                "summary": "用户登录",
+                "parameters": [
+                    {
+                        "description": "用户登录信息(只需要username和password)",
+                        "name": "user",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/model.User"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "登录凭证",
+                        "schema": {
+                            "$ref": "#/definitions/model.Token"
+                        }
+                    },
+                    "202": {
+                        "description": "需要两步验证,使用mfaToken请求 /user/login/mfa",
+                        "schema": {
+                            "$ref": "#/definitions/model.MFAChallenge"
+                        }
+                    },
+                    "400": {
+                        "description": "用户名或密码错误",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "用户名被封禁",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "429": {
+                        "description": "登录失败次数过多,Retry-After响应头给出需要等待的秒数",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "服务器内部错误",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/user/login/mfa": {
+            "post": {
+                "description": "登录返回202时,提交两步验证令牌和验证器上的验证码(或恢复码)完成登录",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "auth"
+                ],
+                "summary": "用户两步验证",
+                "parameters": [
+                    {
+                        "description": "两步验证令牌和验证码",
+                        "name": "verify",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/model.MFAVerify"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "登录凭证",
+                        "schema": {
+                            "$ref": "#/definitions/model.Token"
+                        }
+                    },
+                    "400": {
+                        "description": "验证码错误",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "401": {
+                        "description": "两步验证令牌无效或已过期",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "429": {
+                        "description": "失败次数过多,Retry-After响应头给出需要等待的秒数",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "服务器内部错误",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/user/logout": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "注销当前使用的访问令牌及其对应的刷新令牌",
+                "tags": [
+                    "user"
+                ],
+                "summary": "退出登录",
+                "responses": {
+                    "204": {
+                        "description": "退出登录成功，无返回内容"
+                    },
+                    "401": {
+                        "description": "未授权，用户未登录或会话失效",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/user/logout/all": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "注销用户在所有设备上签发的访问令牌和刷新令牌",
+                "tags": [
+                    "user"
+                ],
+                "summary": "退出所有设备的登录",
+                "responses": {
+                    "204": {
+                        "description": "退出登录成功，无返回内容"
+                    },
+                    "401": {
+                        "description": "未授权，用户未登录或会话失效",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/user/mfa": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "生成新的TOTP密钥和恢复码,需要再调用 /user/mfa/enable 验证一次验证码后才会启用.管理员使用 /back/mfa 下的同名接口",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "mfa"
+                ],
+                "summary": "绑定两步验证",
+                "responses": {
+                    "201": {
+                        "description": "绑定信息",
+                        "schema": {
+                            "$ref": "#/definitions/model.MFASetup"
+                        }
+                    },
+                    "400": {
+                        "description": "两步验证已启用",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "401": {
+                        "description": "未授权，用户未登录或会话失效",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "服务器内部错误",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "提交验证码或恢复码以关闭两步验证;管理员强制两步验证时管理员账号不能关闭",
+                "consumes": [
+                    "application/json"
+                ],
+                "tags": [
+                    "mfa"
+                ],
+                "summary": "关闭两步验证",
+                "parameters": [
+                    {
+                        "description": "验证码或恢复码(只需要code)",
+                        "name": "verify",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/model.MFAVerify"
+                        }
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "关闭成功，无返回内容"
+                    },
+                    "400": {
+                        "description": "未启用或验证码错误",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "401": {
+                        "description": "未授权，用户未登录或会话失效",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "管理员必须启用两步验证",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/user/mfa/enable": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "提交验证器上的验证码以确认绑定并启用两步验证",
+                "consumes": [
+                    "application/json"
+                ],
+                "tags": [
+                    "mfa"
+                ],
+                "summary": "启用两步验证",
+                "parameters": [
+                    {
+                        "description": "验证码(只需要code)",
+                        "name": "verify",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/model.MFAVerify"
+                        }
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "启用成功，无返回内容"
+                    },
+                    "400": {
+                        "description": "未绑定,已启用或验证码错误",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "401": {
+                        "description": "未授权，用户未登录或会话失效",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/user/mfa/recovery": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "提交验证码或恢复码以重新生成恢复码,旧恢复码全部失效",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "mfa"
+                ],
+                "summary": "重新生成恢复码",
+                "parameters": [
+                    {
+                        "description": "验证码或恢复码(只需要code)",
+                        "name": "verify",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/model.MFAVerify"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "新的恢复码(只有recoveryCodes)",
+                        "schema": {
+                            "$ref": "#/definitions/model.MFASetup"
+                        }
+                    },
+                    "400": {
+                        "description": "未启用或验证码错误",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "401": {
+                        "description": "未授权，用户未登录或会话失效",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/user/password/forgot": {
+            "post": {
+                "description": "向用户已验证的邮箱发送重置密码邮件,可以提交用户名或邮箱;无论账号是否存在都返回204",
+                "consumes": [
+                    "application/json"
+                ],
+                "tags": [
+                    "auth"
+                ],
+                "summary": "忘记密码",
+                "parameters": [
+                    {
+                        "description": "用户名或邮箱(只需要username或email)",
+                        "name": "user",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/model.User"
+                        }
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "请求已受理"
+                    },
+                    "429": {
+                        "description": "请求过于频繁,Retry-After响应头给出需要等待的秒数",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/user/password/reset": {
+            "post": {
+                "description": "使用重置密码邮件中的令牌设置新密码,令牌只能使用一次,成功后用户在所有设备上的登录都会失效",
+                "consumes": [
+                    "application/json"
+                ],
+                "tags": [
+                    "auth"
+                ],
+                "summary": "重置密码",
+                "parameters": [
+                    {
+                        "description": "重置密码令牌和新密码",
+                        "name": "reset",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/model.PasswordReset"
+                        }
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "密码重置成功"
+                    },
+                    "400": {
+                        "description": "新密码不符合要求",
+                        "schema": {
+                            "$ref": "#/definitions/model.ValidationError"
+                        }
+                    },
+                    "401": {
+                        "description": "令牌无效,已过期或已被使用",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "服务器内部错误",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/user/register": {
+            "post": {
+                "description": "用户进行注册，成功后返回访问令牌(JWT)和刷新令牌",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "auth"
+                ],
+                "summary": "用户注册",
+                "parameters": [
+                    {
+                        "description": "用户注册信息(只需要username和password,email可选,填写后会发送验证邮件)",
+                        "name": "user",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/model.User"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "登录凭证",
+                        "schema": {
+                            "$ref": "#/definitions/model.Token"
+                        }
+                    },
+                    "400": {
+                        "description": "注册信息不合法",
+                        "schema": {
+                            "$ref": "#/definitions/model.ValidationError"
+                        }
+                    },
+                    "403": {
+                        "description": "用户名已存在",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "服务器内部错误",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/user/sessions": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "列出账号在各设备上的登录会话(设备,IP,登录时间和最近访问时间),current标记当前请求所用的会话.管理员使用 /back/sessions 下的同名接口",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "session"
+                ],
+                "summary": "获取登录会话",
+                "responses": {
+                    "200": {
+                        "description": "会话列表",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/model.Session"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "未授权，用户未登录或会话失效",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/user/sessions/{id}": {
+            "delete": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "注销指定会话的访问令牌和刷新令牌,并断开该会话的聊天室连接",
+                "tags": [
+                    "session"
+                ],
+                "summary": "注销登录会话",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "会话id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "注销成功，无返回内容"
+                    },
+                    "401": {
+                        "description": "未授权，用户未登录或会话失效",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "会话不存在",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/user/star": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "查询用户自己的所有收藏信息",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "user"
+                ],
+                "summary": "获取用户的收藏信息",
+                "responses": {
+                    "200": {
+                        "description": "返回用户的所有收藏记录",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/model.Star"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "未授权，用户未登录或会话失效",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "用户收藏指定的图片(作品),多页作品只需收藏作品id(即第一页的id)",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "user"
+                ],
+                "summary": "用户收藏图片",
+                "parameters": [
+                    {
+                        "description": "收藏信息,只需要图片ID",
+                        "name": "star",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/model.Star"
+                        }
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "图片收藏成功，无返回内容"
+                    },
+                    "400": {
+                        "description": "请求错误，图片不存在",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "401": {
+                        "description": "未授权，用户未登录或会话失效",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "收藏的图片被封禁",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "服务器内部错误",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "取消自己收藏的图片",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "user"
+                ],
+                "summary": "取消用户的图片收藏",
+                "parameters": [
+                    {
+                        "description": "收藏信息,只需要图片ID",
+                        "name": "star",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/model.Star"
+                        }
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "取消收藏成功，无返回内容"
+                    },
+                    "400": {
+                        "description": "请求错误，收藏记录不存在",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "401": {
+                        "description": "未授权，用户未登录或会话失效",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/user/token/refresh": {
+            "post": {
+                "description": "使用刷新令牌换取新的访问令牌和刷新令牌,旧的刷新令牌随即失效;重复使用已失效的刷新令牌会注销该次登录的所有令牌",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "auth"
+                ],
+                "summary": "刷新令牌",
+                "parameters": [
+                    {
+                        "description": "登录凭证(只需要refreshToken)",
+                        "name": "token",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/model.Token"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "新的登录凭证",
+                        "schema": {
+                            "$ref": "#/definitions/model.Token"
+                        }
+                    },
+                    "401": {
+                        "description": "刷新令牌无效或已过期",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "服务器内部错误",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/user/tokens": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "列出用户创建的所有API令牌(不含令牌明文),只能使用登录获得的令牌访问",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "token"
+                ],
+                "summary": "获取自己的API令牌",
+                "responses": {
+                    "200": {
+                        "description": "API令牌列表",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/model.APIToken"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "未授权，用户未登录或会话失效",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "管理员账号或API令牌不能管理API令牌",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "创建带名称,授权范围(image:read,image:write,user:read,user:write,chat:read,chat:write)和可选过期时间的API令牌.\n令牌明文只在创建时返回一次,请求时放在 Authorization: Bearer 请求头中;API令牌不能访问令牌管理,登出,两步验证和后台接口",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "token"
+                ],
+                "summary": "创建API令牌",
+                "parameters": [
+                    {
+                        "description": "令牌名称,授权范围和过期时间",
+                        "name": "token",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/model.APITokenCreate"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "新创建的API令牌",
+                        "schema": {
+                            "$ref": "#/definitions/model.APITokenCreated"
+                        }
+                    },
+                    "400": {
+                        "description": "请求参数不合法或令牌数量已达上限",
+                        "schema": {
+                            "$ref": "#/definitions/model.ValidationError"
+                        }
+                    },
+                    "401": {
+                        "description": "未授权，用户未登录或会话失效",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "管理员账号或API令牌不能管理API令牌",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "服务器内部错误",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/user/tokens/{id}": {
+            "delete": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "注销自己的API令牌,注销后立即失效",
+                "tags": [
+                    "token"
+                ],
+                "summary": "注销API令牌",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "令牌id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "注销成功，无返回内容"
+                    },
+                    "401": {
+                        "description": "未授权，用户未登录或会话失效",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "管理员账号或API令牌不能管理API令牌",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "API令牌不存在",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/user/{username}": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "根据用户名获取用户详细信息(无密码,邮箱和成人内容显示偏好只对本人可见)，需要JWT验证",
+                "tags": [
+                    "user"
+                ],
+                "summary": "获取指定用户名的用户对象(无密码)",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "用户名",
+                        "name": "username",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "用户对象(无密码)",
+                        "schema": {
+                            "$ref": "#/definitions/model.User"
+                        }
+                    },
+                    "401": {
+                        "description": "未授权错误",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "用户被封禁",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "用户不存在",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        }
+    },
+    "definitions": {
+        "model.APIToken": {
+            "description": "API令牌(不含令牌明文)",
+            "type": "object",
+            "properties": {
+                "createdAt": {
+                    "description": "创建时间",
+                    "type": "string",
+                    "example": "2024-12-03T10:18:36.897966604+08:00"
+                },
+                "expiresAt": {
+                    "description": "过期时间,为空表示永不过期",
+                    "type": "string",
+                    "example": "2025-12-03T10:18:36.897966604+08:00"
+                },
+                "id": {
+                    "description": "令牌id",
+                    "type": "string",
+                    "example": "294eacc6-e27a-41ed-8905-9e3e254e3bd8"
+                },
+                "lastUsedAt": {
+                    "description": "最近一次使用时间",
+                    "type": "string",
+                    "example": "2024-12-04T10:18:36.897966604+08:00"
+                },
+                "name": {
+                    "description": "令牌名称",
+                    "type": "string",
+                    "example": "批量上传脚本"
+                },
+                "prefix": {
+                    "description": "令牌开头几位,便于辨认",
+                    "type": "string",
+                    "example": "pe_Xk3d9a"
+                },
+                "scopes": {
+                    "description": "授权范围",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "image:read",
+                        "image:write"
+                    ]
+                }
+            }
+        },
+        "model.APITokenCreate": {
+            "description": "创建API令牌",
+            "type": "object",
+            "properties": {
+                "expiresAt": {
+                    "description": "过期时间,为空表示永不过期",
+                    "type": "string",
+                    "example": "2025-12-03T10:18:36.897966604+08:00"
+                },
+                "name": {
+                    "description": "令牌名称",
+                    "type": "string",
+                    "example": "批量上传脚本"
+                },
+                "scopes": {
+                    "description": "授权范围",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "image:read",
+                        "image:write"
+                    ]
+                }
+            }
+        },
+        "model.APITokenCreated": {
+            "description": "新创建的API令牌,令牌明文只返回这一次",
+            "type": "object",
+            "properties": {
+                "createdAt": {
+                    "description": "创建时间",
+                    "type": "string",
+                    "example": "2024-12-03T10:18:36.897966604+08:00"
+                },
+                "expiresAt": {
+                    "description": "过期时间,为空表示永不过期",
+                    "type": "string",
+                    "example": "2025-12-03T10:18:36.897966604+08:00"
+                },
+                "id": {
+                    "description": "令牌id",
+                    "type": "string",
+                    "example": "294eacc6-e27a-41ed-8905-9e3e254e3bd8"
+                },
+                "lastUsedAt": {
+                    "description": "最近一次使用时间",
+                    "type": "string",
+                    "example": "2024-12-04T10:18:36.897966604+08:00"
+                },
+                "name": {
+                    "description": "令牌名称",
+                    "type": "string",
+                    "example": "批量上传脚本"
+                },
+                "prefix": {
+                    "description": "令牌开头几位,便于辨认",
+                    "type": "string",
+                    "example": "pe_Xk3d9a"
+                },
+                "scopes": {
+                    "description": "授权范围",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "image:read",
+                        "image:write"
+                    ]
+                },
+                "token": {
+                    "description": "令牌明文,请求时放在 Authorization: Bearer 中",
+                    "type": "string",
+                    "example": "pe_Xk3d9a..."
+                }
+            }
+        },
+        "model.Admin": {
+            "description": "管理员",
+            "type": "object",
+            "properties": {
+                "createdAt": {
+                    "description": "创建时间",
+                    "type": "string",
+                    "example": "2024-12-03T10:18:36.897966604+08:00"
+                },
+                "isDisabled": {
+                    "description": "是否被停用",
+                    "type": "boolean",
+                    "example": false
+                },
+                "password": {
+                    "description": "密码",
+                    "type": "string",
+                    "example": "admin"
+                },
+                "role": {
+                    "description": "角色(moderator,admin,superAdmin)",
+                    "type": "string",
+                    "example": "admin"
+                },
+                "username": {
+                    "description": "用户名",
+                    "type": "string",
+                    "example": "admin"
+                }
+            }
+        },
+        "model.AvatarUpload": {
+            "description": "上传头像的结果",
+            "type": "object",
+            "properties": {
+                "avatarURI": {
+                    "description": "头像地址(最大尺寸),用于更新用户信息",
+                    "type": "string",
+                    "example": "assert/avatars/d18b9c4b-8d7f-407f-a630-cf2596bd7511_512.jpg"
+                },
+                "avatarURIs": {
+                    "description": "各尺寸头像地址(键为边长)",
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "model.DuplicateReport": {
+            "description": "疑似转载记录,新发布的图片与其他作者更早发布的图片相似",
+            "type": "object",
+            "properties": {
+                "createdAt": {
+                    "description": "发现时间",
+                    "type": "string",
+                    "example": "2024-12-03T10:18:36.897966604+08:00"
+                },
+                "distance": {
+                    "description": "哈希的汉明距离(0-64)",
+                    "type": "integer",
+                    "example": 3
+                },
+                "id": {
+                    "description": "记录id",
+                    "type": "integer",
+                    "example": 1
+                },
+                "imageID": {
+                    "description": "疑似转载的图片id",
+                    "type": "string",
+                    "example": "294eacc6-e27a-41ed-8905-9e3e254e3bd8"
+                },
+                "originalID": {
+                    "description": "相似的已有图片id",
+                    "type": "string",
+                    "example": "d18b9c4b-8d7f-407f-a630-cf2596bd7511"
+                },
+                "originalUsername": {
+                    "description": "已有图片作者",
+                    "type": "string",
+                    "example": "test"
+                },
+                "similarity": {
+                    "description": "相似度(0-1)",
+                    "type": "number",
+                    "example": 0.953
+                },
+                "status": {
+                    "description": "审核状态(pending,confirmed,dismissed)",
+                    "type": "string",
+                    "example": "pending"
+                },
+                "username": {
+                    "description": "疑似转载的图片作者",
+                    "type": "string",
+                    "example": "thief"
+                }
+            }
+        },
+        "model.EmailVerification": {
+            "description": "通过邮件中的令牌验证邮箱",
+            "type": "object",
+            "properties": {
+                "token": {
+                    "description": "邮箱验证令牌",
+                    "type": "string",
+                    "example": "eyJhbGciOiJIUzI1NiIsInR5cCI6IkpXVCJ9..."
+                }
+            }
+        },
+        "model.FieldError": {
+            "description": "字段校验错误",
+            "type": "object",
+            "properties": {
+                "field": {
+                    "description": "字段名(与请求中的json字段名一致)",
+                    "type": "string",
+                    "example": "username"
+                },
+                "message": {
+                    "description": "错误说明",
+                    "type": "string",
+                    "example": "用户名长度应为3到32个字符"
+                }
+            }
+        },
+        "model.FileGCReport": {
+            "description": "无用文件清理报告",
+            "type": "object",
+            "properties": {
+                "deleted": {
+                    "description": "已删除的文件数",
+                    "type": "integer",
+                    "example": 0
+                },
+                "dryRun": {
+                    "description": "是否只报告不删除",
+                    "type": "boolean",
+                    "example": true
+                },
+                "freedBytes": {
+                    "description": "释放的空间(字节)",
+                    "type": "integer",
+                    "example": 0
+                },
+                "orphans": {
+                    "description": "无用文件",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/model.OrphanFile"
+                    }
+                },
+                "scanned": {
+                    "description": "检查的文件数",
+                    "type": "integer",
+                    "example": 120
+                },
+                "startedAt": {
+                    "description": "开始时间",
+                    "type": "string",
+                    "example": "2024-12-03T10:18:36.897966604+08:00"
+                }
+            }
+        },
+        "model.Follow": {
+            "description": "关注信息",
+            "type": "object",
+            "properties": {
+                "following": {
+                    "description": "关注的用户名",
+                    "type": "string",
+                    "example": "painter"
+                },
+                "username": {
+                    "description": "用户名",
+                    "type": "string",
+                    "example": "test"
+                }
+            }
+        },
+        "model.Identity": {
+            "description": "关联到用户的外部身份",
+            "type": "object",
+            "properties": {
+                "createdAt": {
+                    "description": "关联时间",
+                    "type": "string",
+                    "example": "2024-12-03T10:18:36.897966604+08:00"
+                },
+                "email": {
+                    "description": "身份提供方给出的邮箱",
+                    "type": "string",
+                    "example": "test@example.com"
+                },
+                "id": {
+                    "description": "关联id",
+                    "type": "integer",
+                    "example": 1
+                },
+                "provider": {
+                    "description": "身份提供方名称",
+                    "type": "string",
+                    "example": "google"
+                }
+            }
+        },
+        "model.IdentityLink": {
+            "description": "关联外部身份,令牌来自 /user/identities/link/{provider} 发起的流程完成后跳转地址中的linkToken",
+            "type": "object",
+            "properties": {
+                "linkToken": {
+                    "description": "关联令牌",
+                    "type": "string",
+                    "example": "eyJhbGciOiJIUzI1NiIsInR5cCI6IkpXVCJ9..."
+                }
+            }
+        },
+        "model.IdentityLinkStart": {
+            "description": "发起关联外部身份,前端将浏览器跳转到url",
+            "type": "object",
+            "properties": {
+                "url": {
+                    "description": "身份提供方的授权地址",
+                    "type": "string",
+                    "example": "https://accounts.google.com/o/oauth2/v2/auth?response_type=code\u0026..."
+                }
+            }
+        },
+        "model.Image": {
+            "description": "图片(作品),可以包含多页",
+            "type": "object",
+            "properties": {
+                "auth": {
+                    "description": "图片作者用户名",
+                    "type": "string",
+                    "example": "test"
+                },
+                "authIsBan": {
+                    "description": "作者是否被封禁",
+                    "type": "boolean",
+                    "example": false
+                },
+                "bigURI": {
+                    "description": "大图地址",
+                    "type": "string",
+                    "example": "assert/images/big_294eacc6-e27a-41ed-8905-9e3e254e3bd8.jpg"
+                },
+                "cancelPublish": {
+                    "description": "修改图片时设置为true取消定时发布,不保存",
+                    "type": "boolean",
+                    "example": false
+                },
+                "cover": {
+                    "description": "封面页id,上面的地址,尺寸,其他格式和原图信息均为封面页的",
+                    "type": "string"
+                },
+                "createAt": {
+                    "description": "创建时间",
+                    "type": "string",
+                    "example": "2024-12-03T10:18:36.897966604+08:00"
+                },
+                "id": {
+                    "description": "图片id(UUID)",
+                    "type": "string",
+                    "example": "294eacc6-e27a-41ed-8905-9e3e254e3bd8"
+                },
+                "intro": {
+                    "description": "图片简介",
+                    "type": "string"
+                },
+                "isBan": {
+                    "description": "是否被ban",
+                    "type": "boolean",
+                    "example": false
+                },
+                "label": {
+                    "description": "图片标签",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "like": {
+                    "description": "收藏人数",
+                    "type": "integer",
+                    "example": 0
+                },
+                "metadata": {
+                    "description": "原图信息",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/model.ImageMetadata"
+                        }
+                    ]
+                },
+                "midURI": {
+                    "description": "中图地址",
+                    "type": "string",
+                    "example": "assert/images/mid_294eacc6-e27a-41ed-8905-9e3e254e3bd8.jpg"
+                },
+                "pages": {
+                    "description": "各页图片,按顺序排列;为空时(旧数据)只有一页,即上面的各地址",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/model.ImagePage"
+                    }
+                },
+                "publishAt": {
+                    "description": "定时发布时间,到达后可见性改为publishVisibility",
+                    "type": "string",
+                    "example": "2024-12-24T20:00:00+08:00"
+                },
+                "publishVisibility": {
+                    "description": "定时发布后的可见性,定时发布期间设置的visibility保存在这里,发布前为private",
+                    "type": "string",
+                    "example": "public"
+                },
+                "publishedAt": {
+                    "description": "公开的时间,最新图片按此排序",
+                    "type": "string",
+                    "example": "2024-12-24T20:00:00+08:00"
+                },
+                "rating": {
+                    "description": "内容分级(sfw,nsfw),为空视为sfw",
+                    "type": "string",
+                    "example": "sfw"
+                },
+                "ratingLocked": {
+                    "description": "分级是否由管理员锁定,锁定后作者不能修改",
+                    "type": "boolean",
+                    "example": false
+                },
+                "redacted": {
+                    "description": "是否按用户的分级偏好隐去了图片,为true时前端显示模糊占位图",
+                    "type": "boolean",
+                    "example": false
+                },
+                "sizes": {
+                    "description": "各尺寸图片,按宽度从小到大排列,可直接用于srcset",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/model.ImageSize"
+                    }
+                },
+                "stages": {
+                    "description": "创作过程中的阶段图片(草稿,线稿,铺色等),按上传顺序排列",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/model.ImageStage"
+                    }
+                },
+                "status": {
+                    "description": "处理状态(pending,ready,failed),为空视为ready",
+                    "type": "string",
+                    "example": "ready"
+                },
+                "timelapse": {
+                    "description": "由阶段图片生成的创作过程延时视频",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/model.Timelapse"
+                        }
+                    ]
+                },
+                "title": {
+                    "description": "图片标题",
+                    "type": "string",
+                    "example": "test"
+                },
+                "variants": {
+                    "description": "其他格式的图片,访问各尺寸图片时会根据Accept请求头自动选择",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/model.ImageVariant"
+                    }
+                },
+                "visibility": {
+                    "description": "可见性(public,unlisted,followers,private),为空视为public",
+                    "type": "string",
+                    "example": "public"
+                }
+            }
+        },
+        "model.ImageJob": {
+            "description": "图片处理任务",
+            "type": "object",
+            "properties": {
+                "attempts": {
+                    "description": "已尝试次数",
+                    "type": "integer",
+                    "example": 1
+                },
+                "bigURI": {
+                    "description": "大图地址",
+                    "type": "string",
+                    "example": "assert/images/big_294eacc6-e27a-41ed-8905-9e3e254e3bd8.jpg"
+                },
+                "createdAt": {
+                    "description": "上传时间",
+                    "type": "string",
+                    "example": "2024-12-03T10:18:36.897966604+08:00"
+                },
+                "error": {
+                    "description": "失败原因",
+                    "type": "string",
+                    "example": "图片解码失败"
+                },
+                "id": {
+                    "description": "图片id",
+                    "type": "string",
+                    "example": "294eacc6-e27a-41ed-8905-9e3e254e3bd8"
+                },
+                "metadata": {
+                    "description": "原图信息",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/model.ImageMetadata"
+                        }
+                    ]
+                },
+                "midURI": {
+                    "description": "中图地址",
+                    "type": "string",
+                    "example": "assert/images/mid_294eacc6-e27a-41ed-8905-9e3e254e3bd8.jpg"
+                },
+                "sizes": {
+                    "description": "各尺寸图片",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/model.ImageSize"
+                    }
+                },
+                "status": {
+                    "description": "处理状态(pending,ready,failed)",
+                    "type": "string",
+                    "example": "pending"
+                },
+                "updatedAt": {
+                    "description": "最近一次处理时间",
+                    "type": "string",
+                    "example": "2024-12-03T10:18:38.897966604+08:00"
+                },
+                "variants": {
+                    "description": "其他格式的图片",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/model.ImageVariant"
+                    }
+                }
+            }
+        },
+        "model.ImageMetadata": {
+            "description": "原图信息",
+            "type": "object",
+            "properties": {
+                "capturedAt": {
+                    "description": "拍摄或创作时间",
+                    "type": "string",
+                    "example": "2024-12-01T15:04:05+08:00"
+                },
+                "height": {
+                    "description": "原图高度",
+                    "type": "integer",
+                    "example": 3000
+                },
+                "software": {
+                    "description": "创作软件",
+                    "type": "string",
+                    "example": "Procreate"
+                },
+                "width": {
+                    "description": "原图宽度(已按拍摄方向旋转)",
+                    "type": "integer",
+                    "example": 4000
+                }
+            }
+        },
+        "model.ImagePage": {
+            "description": "作品中的一页图片",
+            "type": "object",
+            "properties": {
+                "bigURI": {
+                    "description": "大图地址",
+                    "type": "string",
+                    "example": "assert/images/big_294eacc6-e27a-41ed-8905-9e3e254e3bd8.jpg"
+                },
+                "id": {
+                    "description": "图片文件id,即 /image/file [POST] 返回的id",
+                    "type": "string",
+                    "example": "294eacc6-e27a-41ed-8905-9e3e254e3bd8"
+                },
+                "metadata": {
+                    "description": "原图信息",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/model.ImageMetadata"
+                        }
+                    ]
+                },
+                "midURI": {
+                    "description": "中图地址",
+                    "type": "string",
+                    "example": "assert/images/mid_294eacc6-e27a-41ed-8905-9e3e254e3bd8.jpg"
+                },
+                "sizes": {
+                    "description": "各尺寸图片",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/model.ImageSize"
+                    }
+                },
+                "status": {
+                    "description": "处理状态(pending,ready,failed)",
+                    "type": "string",
+                    "example": "ready"
+                },
+                "variants": {
+                    "description": "其他格式的图片",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/model.ImageVariant"
+                    }
+                }
+            }
+        },
+        "model.ImageRevision": {
+            "description": "一页图片的历史版本",
+            "type": "object",
+            "properties": {
+                "bigURI": {
+                    "description": "大图地址",
+                    "type": "string",
+                    "example": "assert/images/big_294eacc6-e27a-41ed-8905-9e3e254e3bd8.jpg"
+                },
+                "id": {
+                    "description": "图片文件id,即 /image/file [POST] 返回的id",
+                    "type": "string",
+                    "example": "294eacc6-e27a-41ed-8905-9e3e254e3bd8"
+                },
+                "metadata": {
+                    "description": "原图信息",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/model.ImageMetadata"
+                        }
+                    ]
+                },
+                "midURI": {
+                    "description": "中图地址",
+                    "type": "string",
+                    "example": "assert/images/mid_294eacc6-e27a-41ed-8905-9e3e254e3bd8.jpg"
+                },
+                "replacedAt": {
+                    "description": "被替换的时间",
+                    "type": "string",
+                    "example": "2024-12-03T10:18:36.897966604+08:00"
+                },
+                "sizes": {
+                    "description": "各尺寸图片",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/model.ImageSize"
+                    }
+                },
+                "status": {
+                    "description": "处理状态(pending,ready,failed)",
+                    "type": "string",
+                    "example": "ready"
+                },
+                "variants": {
+                    "description": "其他格式的图片",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/model.ImageVariant"
+                    }
+                }
+            }
+        },
+        "model.ImageSize": {
+            "description": "图片尺寸,用于生成srcset(如 \"assert/images/w1600_xxx.jpg 1600w\")",
+            "type": "object",
+            "properties": {
+                "height": {
+                    "description": "高度",
+                    "type": "integer",
+                    "example": 1200
+                },
+                "uri": {
+                    "description": "图片地址",
+                    "type": "string",
+                    "example": "assert/images/w1600_294eacc6-e27a-41ed-8905-9e3e254e3bd8.jpg"
+                },
+                "width": {
+                    "description": "宽度",
+                    "type": "integer",
+                    "example": 1600
+                }
+            }
+        },
+        "model.ImageStage": {
+            "description": "创作过程中的一个阶段",
+            "type": "object",
+            "properties": {
+                "bigURI": {
+                    "description": "大图地址",
+                    "type": "string",
+                    "example": "assert/images/big_294eacc6-e27a-41ed-8905-9e3e254e3bd8.jpg"
+                },
+                "createdAt": {
+                    "description": "上传时间",
+                    "type": "string",
+                    "example": "2024-12-03T10:18:36.897966604+08:00"
+                },
+                "id": {
+                    "description": "图片文件id,即 /image/file [POST] 返回的id",
+                    "type": "string",
+                    "example": "294eacc6-e27a-41ed-8905-9e3e254e3bd8"
+                },
+                "metadata": {
+                    "description": "原图信息",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/model.ImageMetadata"
+                        }
+                    ]
+                },
+                "midURI": {
+                    "description": "中图地址",
+                    "type": "string",
+                    "example": "assert/images/mid_294eacc6-e27a-41ed-8905-9e3e254e3bd8.jpg"
+                },
+                "name": {
+                    "description": "阶段名称",
+                    "type": "string",
+                    "example": "线稿"
+                },
+                "sizes": {
+                    "description": "各尺寸图片",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/model.ImageSize"
+                    }
+                },
+                "status": {
+                    "description": "处理状态(pending,ready,failed)",
+                    "type": "string",
+                    "example": "ready"
+                },
+                "variants": {
+                    "description": "其他格式的图片",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/model.ImageVariant"
+                    }
+                }
+            }
+        },
+        "model.ImageVariant": {
+            "description": "其他格式的图片",
+            "type": "object",
+            "properties": {
+                "bigURI": {
+                    "description": "大图地址",
                     "type": "string",
-                    "example": "admin"
+                    "example": "assert/images/big_294eacc6-e27a-41ed-8905-9e3e254e3bd8.webp"
                 },
-                "username": {
+                "format": {
+                    "description": "格式(webp,avif)",
                     "type": "string",
-                    "example": "admin"
+                    "example": "webp"
+                },
+                "midURI": {
+                    "description": "中图地址",
+                    "type": "string",
+                    "example": "assert/images/mid_294eacc6-e27a-41ed-8905-9e3e254e3bd8.webp"
                 }
             }
         },
-        "model.Image": {
-            "description": "图片",
+        "model.JWK": {
+            "description": "JSON Web Key(仅公钥)",
             "type": "object",
             "properties": {
-                "auth": {
-                    "description": "图片作者用户名",
+                "alg": {
+                    "description": "签名算法",
                     "type": "string",
-                    "example": "test"
+                    "example": "RS256"
                 },
-                "authIsBan": {
-                    "description": "作者是否被封禁",
-                    "type": "boolean",
-                    "example": false
+                "crv": {
+                    "description": "曲线(OKP,EC)",
+                    "type": "string",
+                    "example": "Ed25519"
                 },
-                "bigURI": {
-                    "description": "大图地址",
+                "e": {
+                    "description": "RSA指数",
                     "type": "string",
-                    "example": "assert/images/big_294eacc6-e27a-41ed-8905-9e3e254e3bd8.jpg"
+                    "example": "AQAB"
                 },
-                "createAt": {
-                    "description": "创建时间",
+                "kid": {
+                    "description": "密钥id",
                     "type": "string",
-                    "example": "2024-12-03T10:18:36.897966604+08:00"
+                    "example": "2024-12"
                 },
-                "id": {
-                    "description": "图片id(UUID)",
+                "kty": {
+                    "description": "密钥类型",
                     "type": "string",
-                    "example": "294eacc6-e27a-41ed-8905-9e3e254e3bd8"
+                    "example": "RSA"
                 },
-                "intro": {
-                    "description": "图片简介",
+                "n": {
+                    "description": "RSA模数",
                     "type": "string"
                 },
-                "isBan": {
-                    "description": "是否被ban",
+                "use": {
+                    "description": "用途",
+                    "type": "string",
+                    "example": "sig"
+                },
+                "x": {
+                    "description": "公钥(OKP)或x坐标(EC)",
+                    "type": "string"
+                },
+                "y": {
+                    "description": "y坐标(EC)",
+                    "type": "string"
+                }
+            }
+        },
+        "model.JWKS": {
+            "description": "用于验证访问令牌的公钥集合",
+            "type": "object",
+            "properties": {
+                "keys": {
+                    "description": "公钥列表",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/model.JWK"
+                    }
+                }
+            }
+        },
+        "model.LoginAttempt": {
+            "description": "登录失败记录(按用户名或IP统计)",
+            "type": "object",
+            "properties": {
+                "failures": {
+                    "description": "连续失败次数",
+                    "type": "integer",
+                    "example": 5
+                },
+                "key": {
+                    "description": "统计对象,格式为 user:用户名, admin:管理员用户名 或 ip:地址",
+                    "type": "string",
+                    "example": "user:test"
+                },
+                "lastFailure": {
+                    "description": "最近一次失败时间",
+                    "type": "string",
+                    "example": "2024-12-03T10:18:36.897966604+08:00"
+                },
+                "lockedUntil": {
+                    "description": "在此时间之前禁止登录",
+                    "type": "string",
+                    "example": "2024-12-03T10:33:36.897966604+08:00"
+                }
+            }
+        },
+        "model.MFAChallenge": {
+            "description": "密码验证通过但还需要两步验证时返回",
+            "type": "object",
+            "properties": {
+                "enrollRequired": {
+                    "description": "是否需要先绑定两步验证(管理员强制两步验证时)",
                     "type": "boolean",
                     "example": false
                 },
-                "label": {
-                    "description": "图片标签",
+                "expiresAt": {
+                    "description": "两步验证令牌过期时间",
+                    "type": "string",
+                    "example": "2024-12-03T10:23:36.897966604+08:00"
+                },
+                "mfaToken": {
+                    "description": "两步验证令牌,只能用于完成两步验证",
+                    "type": "string",
+                    "example": "eyJhbGciOiJIUzI1NiIsInR5cCI6IkpXVCJ9..."
+                }
+            }
+        },
+        "model.MFASetup": {
+            "description": "两步验证绑定信息,uri可直接生成二维码供验证器应用扫描",
+            "type": "object",
+            "properties": {
+                "recoveryCodes": {
+                    "description": "恢复码,每个只能使用一次,仅展示这一次",
                     "type": "array",
                     "items": {
                         "type": "string"
                     }
                 },
-                "like": {
-                    "description": "收藏人数",
-                    "type": "integer",
-                    "example": 0
+                "secret": {
+                    "description": "TOTP密钥(base32)",
+                    "type": "string",
+                    "example": "JBSWY3DPEHPK3PXPJBSWY3DPEHPK3PXP"
                 },
-                "midURI": {
-                    "description": "中图地址",
+                "uri": {
+                    "description": "otpauth地址(二维码内容)",
                     "type": "string",
-                    "example": "assert/images/mid_294eacc6-e27a-41ed-8905-9e3e254e3bd8.jpg"
+                    "example": "otpauth://totp/PaintingExchange:test?secret=JBSWY3DPEHPK3PXP\u0026issuer=PaintingExchange"
+                }
+            }
+        },
+        "model.MFAVerify": {
+            "description": "两步验证请求",
+            "type": "object",
+            "properties": {
+                "code": {
+                    "description": "验证器上的6位验证码或恢复码",
+                    "type": "string",
+                    "example": "123456"
                 },
-                "title": {
-                    "description": "图片标题",
+                "mfaToken": {
+                    "description": "两步验证令牌(登录时需要)",
                     "type": "string",
-                    "example": "test"
+                    "example": "eyJhbGciOiJIUzI1NiIsInR5cCI6IkpXVCJ9..."
                 }
             }
         },
@@ -1322,6 +5104,223 @@ const docTemplate = `{
                 }
             }
         },
+        "model.OIDCProvider": {
+            "description": "可用的身份提供方",
+            "type": "object",
+            "properties": {
+                "displayName": {
+                    "description": "显示名称",
+                    "type": "string",
+                    "example": "Google"
+                },
+                "name": {
+                    "description": "名称,用于 /oidc/{name}/login",
+                    "type": "string",
+                    "example": "google"
+                }
+            }
+        },
+        "model.OrphanFile": {
+            "description": "无用文件",
+            "type": "object",
+            "properties": {
+                "key": {
+                    "description": "文件路径",
+                    "type": "string",
+                    "example": "assert/images/big_294eacc6-e27a-41ed-8905-9e3e254e3bd8.jpg"
+                },
+                "modTime": {
+                    "description": "修改时间",
+                    "type": "string",
+                    "example": "2024-12-03T10:18:36.897966604+08:00"
+                },
+                "size": {
+                    "description": "文件大小(字节)",
+                    "type": "integer",
+                    "example": 204800
+                }
+            }
+        },
+        "model.PageHistory": {
+            "description": "一页图片的当前版本和历史版本",
+            "type": "object",
+            "properties": {
+                "current": {
+                    "description": "当前版本",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/model.ImagePage"
+                        }
+                    ]
+                },
+                "replacing": {
+                    "description": "正在处理的新版本文件id,可通过 /image/file/{imageID} [GET] 查询处理状态",
+                    "type": "string"
+                },
+                "revisions": {
+                    "description": "历史版本,按替换时间从早到晚排列",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/model.ImageRevision"
+                    }
+                }
+            }
+        },
+        "model.PageOrder": {
+            "description": "作品的页面顺序和封面",
+            "type": "object",
+            "properties": {
+                "cover": {
+                    "description": "封面页id,为空时使用第一页",
+                    "type": "string",
+                    "example": "294eacc6-e27a-41ed-8905-9e3e254e3bd8"
+                },
+                "pages": {
+                    "description": "按新顺序排列的全部页面id",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "model.PasswordChange": {
+            "description": "修改密码",
+            "type": "object",
+            "properties": {
+                "newPassword": {
+                    "description": "新密码",
+                    "type": "string",
+                    "example": "123456"
+                },
+                "oldPassword": {
+                    "description": "原密码",
+                    "type": "string",
+                    "example": "admin"
+                }
+            }
+        },
+        "model.PasswordReset": {
+            "description": "通过邮件中的令牌重置密码",
+            "type": "object",
+            "properties": {
+                "newPassword": {
+                    "description": "新密码",
+                    "type": "string",
+                    "example": "123456"
+                },
+                "token": {
+                    "description": "重置密码令牌",
+                    "type": "string",
+                    "example": "eyJhbGciOiJIUzI1NiIsInR5cCI6IkpXVCJ9..."
+                }
+            }
+        },
+        "model.Permission": {
+            "description": "当前登录者的角色和权限",
+            "type": "object",
+            "properties": {
+                "permissions": {
+                    "description": "权限",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "image:read",
+                        "image:ban"
+                    ]
+                },
+                "roles": {
+                    "description": "角色",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "moderator"
+                    ]
+                }
+            }
+        },
+        "model.RevisionRollback": {
+            "description": "回滚到历史版本",
+            "type": "object",
+            "properties": {
+                "page": {
+                    "description": "当前版本的文件id",
+                    "type": "string",
+                    "example": "294eacc6-e27a-41ed-8905-9e3e254e3bd8"
+                },
+                "revision": {
+                    "description": "要回滚到的历史版本的文件id",
+                    "type": "string",
+                    "example": "d18b9c4b-8d7f-407f-a630-cf2596bd7511"
+                }
+            }
+        },
+        "model.RoleAssignment": {
+            "description": "普通用户的角色分配(用户无需单独的管理员账号即可获得管理权限)",
+            "type": "object",
+            "properties": {
+                "role": {
+                    "description": "角色",
+                    "type": "string",
+                    "example": "moderator"
+                },
+                "username": {
+                    "description": "用户名",
+                    "type": "string",
+                    "example": "test"
+                }
+            }
+        },
+        "model.Session": {
+            "description": "登录会话",
+            "type": "object",
+            "properties": {
+                "createdAt": {
+                    "description": "登录时间",
+                    "type": "string",
+                    "example": "2024-12-03T10:18:36.897966604+08:00"
+                },
+                "current": {
+                    "description": "是否为当前请求所用的会话",
+                    "type": "boolean",
+                    "example": true
+                },
+                "device": {
+                    "description": "设备描述",
+                    "type": "string",
+                    "example": "Chrome on Windows"
+                },
+                "expiresAt": {
+                    "description": "刷新令牌过期时间,过期后会话结束",
+                    "type": "string",
+                    "example": "2025-01-02T10:18:36.897966604+08:00"
+                },
+                "id": {
+                    "description": "会话id",
+                    "type": "string",
+                    "example": "294eacc6-e27a-41ed-8905-9e3e254e3bd8"
+                },
+                "ip": {
+                    "description": "最近一次访问的IP",
+                    "type": "string",
+                    "example": "127.0.0.1"
+                },
+                "lastSeenAt": {
+                    "description": "最近一次访问时间",
+                    "type": "string",
+                    "example": "2024-12-03T11:18:36.897966604+08:00"
+                },
+                "userAgent": {
+                    "description": "浏览器UA",
+                    "type": "string",
+                    "example": "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36"
+                }
+            }
+        },
         "model.Star": {
             "description": "收藏信息",
             "type": "object",
@@ -1338,6 +5337,58 @@ const docTemplate = `{
                 }
             }
         },
+        "model.Timelapse": {
+            "description": "创作过程延时视频",
+            "type": "object",
+            "properties": {
+                "error": {
+                    "description": "失败原因",
+                    "type": "string",
+                    "example": "没有可用的阶段图片"
+                },
+                "gifURI": {
+                    "description": "GIF动图地址",
+                    "type": "string",
+                    "example": "assert/images/timelapse_xxx.gif"
+                },
+                "status": {
+                    "description": "生成状态(pending,ready,failed)",
+                    "type": "string",
+                    "example": "ready"
+                },
+                "updatedAt": {
+                    "description": "最近一次生成时间",
+                    "type": "string",
+                    "example": "2024-12-03T10:18:36.897966604+08:00"
+                },
+                "videoURI": {
+                    "description": "MP4视频地址",
+                    "type": "string",
+                    "example": "assert/images/timelapse_xxx.mp4"
+                }
+            }
+        },
+        "model.Token": {
+            "description": "登录凭证",
+            "type": "object",
+            "properties": {
+                "accessToken": {
+                    "description": "访问令牌(JWT)",
+                    "type": "string",
+                    "example": "eyJhbGciOiJIUzI1NiIsInR5cCI6IkpXVCJ9..."
+                },
+                "expiresAt": {
+                    "description": "访问令牌过期时间",
+                    "type": "string",
+                    "example": "2024-12-03T10:33:36.897966604+08:00"
+                },
+                "refreshToken": {
+                    "description": "刷新令牌",
+                    "type": "string",
+                    "example": "Jr0b5mQ3v1sCEa3Qpx8n2ZK9Tt2hLmUqj0m4WJq3m0U"
+                }
+            }
+        },
         "model.User": {
             "description": "用户",
             "type": "object",
@@ -1347,6 +5398,28 @@ const docTemplate = `{
                     "type": "string",
                     "example": "assert/avatars/d18b9c4b-8d7f-407f-a630-cf2596bd7511.jpg"
                 },
+                "avatarURIs": {
+                    "description": "各尺寸头像地址(键为边长,如32,128,512)",
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                },
+                "currentPassword": {
+                    "description": "当前密码,仅在修改密码或邮箱时需要提供",
+                    "type": "string",
+                    "example": "123456"
+                },
+                "email": {
+                    "description": "邮箱(可选,用于找回密码)",
+                    "type": "string",
+                    "example": "test@example.com"
+                },
+                "emailVerified": {
+                    "description": "邮箱是否已验证",
+                    "type": "boolean",
+                    "example": false
+                },
                 "intro": {
                     "description": "描述",
                     "type": "string",
@@ -1367,12 +5440,35 @@ const docTemplate = `{
                     "type": "string",
                     "example": "123456"
                 },
+                "ratingFilter": {
+                    "description": "成人内容(nsfw)显示偏好(hide,blur,show),为空视为hide,只对本人可见",
+                    "type": "string",
+                    "example": "hide"
+                },
                 "username": {
                     "description": "用户名",
                     "type": "string",
                     "example": "test"
                 }
             }
+        },
+        "model.ValidationError": {
+            "description": "请求参数校验失败,errors中列出每个不合法的字段",
+            "type": "object",
+            "properties": {
+                "errors": {
+                    "description": "字段错误",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/model.FieldError"
+                    }
+                },
+                "message": {
+                    "description": "错误说明",
+                    "type": "string",
+                    "example": "请求参数校验失败"
+                }
+            }
         }
     },
     "securityDefinitions": {
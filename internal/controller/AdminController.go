@@ -0,0 +1,343 @@
+package controller
+
+import (
+	"PaintingExchange/internal/model"
+	"PaintingExchange/internal/service"
+	"github.com/kataras/iris/v12"
+	"github.com/kataras/iris/v12/mvc"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+	"log"
+)
+
+// AdminController 管理员账号和角色分配管理控制器(需要staff:manage权限)
+type AdminController struct {
+	Ctx iris.Context
+	Db  *gorm.DB
+}
+
+// Get 获取所有管理员
+// @Summary 获取所有管理员
+// @Description 获取所有管理员账号(无密码)
+// @Tags admin
+// @Produce json
+// @Success 200 {array} model.Admin "管理员列表"
+// @Failure 401 {object} string "未授权，用户未登录或会话失效"
+// @Failure 403 {object} string "缺少staff:manage权限"
+// @Router /back/admin [get]
+// @Security BearerAuth
+func (c *AdminController) Get() mvc.Result {
+	var admins []model.Admin
+	c.Db.Find(&admins)
+	for i := range admins {
+		admins[i].Password = ""
+	}
+
+	return mvc.Response{
+		Code:   iris.StatusOK,
+		Object: admins,
+	}
+}
+
+// Post 创建管理员
+// @Summary 创建管理员
+// @Description 创建新的管理员账号,角色为空时默认为admin
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param admin body model.Admin true "管理员信息(username,password,role)"
+// @Success 201 {object} model.Admin "创建的管理员(无密码)"
+// @Failure 400 {object} model.ValidationError "密码强度不足(用户名或密码为空或角色不存在时返回文本)"
+// @Failure 401 {object} string "未授权，用户未登录或会话失效"
+// @Failure 403 {object} string "缺少staff:manage权限"
+// @Failure 403 {object} string "管理员已存在"
+// @Failure 500 {object} string "服务器内部错误"
+// @Router /back/admin [post]
+// @Security BearerAuth
+func (c *AdminController) Post(admin model.Admin) mvc.Result {
+	log.Println("创建管理员", admin.Username)
+	if admin.Username == "" || admin.Password == "" {
+		return mvc.Response{
+			Code: iris.StatusBadRequest,
+			Text: "用户名或密码为空",
+		}
+	}
+	if admin.Role == "" {
+		admin.Role = service.RoleAdmin
+	}
+	if !service.IsValidRole(admin.Role) {
+		return mvc.Response{
+			Code: iris.StatusBadRequest,
+			Text: "角色不存在",
+		}
+	}
+	if errs := service.ValidatePassword("password", admin.Password, admin.Username); len(errs) != 0 {
+		return invalidParams(errs)
+	}
+
+	// 验证用户名是否存在
+	var count int64
+	if c.Db.Model(&model.Admin{}).Where("username=?", admin.Username).Count(&count); count != 0 {
+		log.Println("管理员", admin.Username, "已存在")
+		return mvc.Response{
+			Code: iris.StatusForbidden,
+			Text: "管理员已存在",
+		}
+	}
+
+	// 加密密码
+	password, err := bcrypt.GenerateFromPassword([]byte(admin.Password), bcrypt.DefaultCost)
+	if err != nil {
+		log.Println("管理员", admin.Username, "密码加密失败")
+		return mvc.Response{
+			Code: iris.StatusInternalServerError,
+			Text: err.Error(),
+		}
+	}
+	admin.Password = string(password)
+
+	if err := c.Db.Create(&admin).Error; err != nil {
+		log.Println("管理员", admin.Username, "创建失败", err)
+		return mvc.Response{
+			Code: iris.StatusInternalServerError,
+			Text: err.Error(),
+		}
+	}
+
+	log.Println("管理员", admin.Username, "创建成功")
+	admin.Password = ""
+	return mvc.Response{
+		Code:   iris.StatusCreated,
+		Object: admin,
+	}
+}
+
+// Put 修改管理员
+// @Summary 修改管理员
+// @Description 修改管理员的角色和停用状态,password不为空时重置其密码;不能修改自己的角色和状态
+// @Tags admin
+// @Accept json
+// @Param admin body model.Admin true "管理员信息(username,role,isDisabled,password可选)"
+// @Success 204 {object} nil "修改成功，无返回内容"
+// @Failure 400 {object} model.ValidationError "新密码强度不足(管理员不存在或角色不存在时返回文本)"
+// @Failure 401 {object} string "未授权，用户未登录或会话失效"
+// @Failure 403 {object} string "缺少staff:manage权限或修改自己的角色和状态"
+// @Failure 500 {object} string "服务器内部错误"
+// @Router /back/admin [put]
+// @Security BearerAuth
+func (c *AdminController) Put(admin model.Admin) mvc.Result {
+	loginUser, err := c.Ctx.User().GetRaw()
+	if err != nil {
+		return mvc.Response{
+			Code: iris.StatusUnauthorized,
+			Text: iris.StatusText(iris.StatusUnauthorized),
+		}
+	}
+	loginUserName := loginUser.(iris.SimpleUser).Username
+	log.Println("管理员", loginUserName, "修改管理员", admin.Username)
+
+	// 获取原管理员对象
+	var prev model.Admin
+	if c.Db.Where("username=?", admin.Username).Find(&prev).RowsAffected == 0 {
+		return mvc.Response{
+			Code: iris.StatusBadRequest,
+			Text: "管理员不存在",
+		}
+	}
+
+	if !service.IsValidRole(admin.Role) {
+		return mvc.Response{
+			Code: iris.StatusBadRequest,
+			Text: "角色不存在",
+		}
+	}
+
+	// 防止误操作锁死自己
+	isSelf := prev.Username == loginUserName && loginUser.(iris.SimpleUser).Fields["isAdmin"] == true
+	if isSelf && (admin.Role != prev.Role || admin.IsDisabled) {
+		return mvc.Response{
+			Code: iris.StatusForbidden,
+			Text: "不能修改自己的角色和状态",
+		}
+	}
+
+	// 重置密码
+	prev.Role = admin.Role
+	prev.IsDisabled = admin.IsDisabled
+	if admin.Password != "" {
+		if errs := service.ValidatePassword("password", admin.Password, prev.Username); len(errs) != 0 {
+			return invalidParams(errs)
+		}
+		password, err := bcrypt.GenerateFromPassword([]byte(admin.Password), bcrypt.DefaultCost)
+		if err != nil {
+			log.Println("管理员", admin.Username, "密码加密失败")
+			return mvc.Response{
+				Code: iris.StatusInternalServerError,
+				Text: err.Error(),
+			}
+		}
+		prev.Password = string(password)
+	}
+	c.Db.Where("username=?", prev.Username).Select("password", "role", "is_disabled").Updates(&prev)
+
+	// 停用或重置密码后需要重新登录
+	if prev.IsDisabled || admin.Password != "" {
+		service.RevokeAllTokens(c.Db, prev.Username, true)
+	}
+
+	log.Println("管理员", admin.Username, "修改成功")
+	return mvc.Response{
+		Code: iris.StatusNoContent,
+	}
+}
+
+// DeleteBy 删除管理员
+// @Summary 删除管理员
+// @Description 删除指定管理员,不能删除自己
+// @Tags admin
+// @Param username path string true "管理员用户名"
+// @Success 204 {object} nil "删除成功，无返回内容"
+// @Failure 400 {object} string "管理员不存在"
+// @Failure 401 {object} string "未授权，用户未登录或会话失效"
+// @Failure 403 {object} string "缺少staff:manage权限或删除自己"
+// @Router /back/admin/{username} [delete]
+// @Security BearerAuth
+func (c *AdminController) DeleteBy(username string) mvc.Result {
+	loginUser, err := c.Ctx.User().GetRaw()
+	if err != nil {
+		return mvc.Response{
+			Code: iris.StatusUnauthorized,
+			Text: iris.StatusText(iris.StatusUnauthorized),
+		}
+	}
+	loginUserName := loginUser.(iris.SimpleUser).Username
+	log.Println("管理员", loginUserName, "删除管理员", username)
+
+	if username == loginUserName && loginUser.(iris.SimpleUser).Fields["isAdmin"] == true {
+		return mvc.Response{
+			Code: iris.StatusForbidden,
+			Text: "不能删除自己",
+		}
+	}
+
+	if c.Db.Where("username=?", username).Delete(&model.Admin{}).RowsAffected == 0 {
+		return mvc.Response{
+			Code: iris.StatusBadRequest,
+			Text: "管理员不存在",
+		}
+	}
+	service.RevokeAllTokens(c.Db, username, true)
+
+	log.Println("管理员", username, "删除成功")
+	return mvc.Response{
+		Code: iris.StatusNoContent,
+	}
+}
+
+// DeleteMfaBy 重置管理员的两步验证
+// @Summary 重置管理员的两步验证
+// @Description 管理员丢失验证器且恢复码用尽时,删除其两步验证配置,下次登录时重新绑定
+// @Tags admin
+// @Param username path string true "管理员用户名"
+// @Success 204 {object} nil "重置成功，无返回内容"
+// @Failure 401 {object} string "未授权，用户未登录或会话失效"
+// @Failure 403 {object} string "缺少staff:manage权限"
+// @Failure 500 {object} string "服务器内部错误"
+// @Router /back/admin/mfa/{username} [delete]
+// @Security BearerAuth
+func (c *AdminController) DeleteMfaBy(username string) mvc.Result {
+	log.Println("重置管理员", username, "的两步验证")
+	if err := service.ResetMFA(c.Db, username, true); err != nil {
+		return mvc.Response{
+			Code: iris.StatusInternalServerError,
+			Text: err.Error(),
+		}
+	}
+
+	return mvc.Response{
+		Code: iris.StatusNoContent,
+	}
+}
+
+// GetRole 获取普通用户的角色分配
+// @Summary 获取普通用户的角色分配
+// @Description 获取所有被分配了管理角色的普通用户
+// @Tags admin
+// @Produce json
+// @Success 200 {array} model.RoleAssignment "角色分配列表"
+// @Failure 401 {object} string "未授权，用户未登录或会话失效"
+// @Failure 403 {object} string "缺少staff:manage权限"
+// @Router /back/admin/role [get]
+// @Security BearerAuth
+func (c *AdminController) GetRole() mvc.Result {
+	var assignments []model.RoleAssignment
+	c.Db.Find(&assignments)
+
+	return mvc.Response{
+		Code:   iris.StatusOK,
+		Object: assignments,
+	}
+}
+
+// PostRole 为普通用户分配角色
+// @Summary 为普通用户分配角色
+// @Description 为普通用户分配管理角色,用户使用自己的账号即可访问对应的管理接口
+// @Tags admin
+// @Accept json
+// @Param assignment body model.RoleAssignment true "角色分配"
+// @Success 204 {object} nil "分配成功，无返回内容"
+// @Failure 400 {object} string "用户不存在或角色不存在"
+// @Failure 401 {object} string "未授权，用户未登录或会话失效"
+// @Failure 403 {object} string "缺少staff:manage权限"
+// @Router /back/admin/role [post]
+// @Security BearerAuth
+func (c *AdminController) PostRole(assignment model.RoleAssignment) mvc.Result {
+	log.Println("为用户", assignment.Username, "分配角色", assignment.Role)
+	if !service.IsValidRole(assignment.Role) {
+		return mvc.Response{
+			Code: iris.StatusBadRequest,
+			Text: "角色不存在",
+		}
+	}
+
+	// 验证用户是否存在
+	var count int64
+	if c.Db.Model(&model.User{}).Where("username=?", assignment.Username).Count(&count); count == 0 {
+		return mvc.Response{
+			Code: iris.StatusBadRequest,
+			Text: "用户不存在",
+		}
+	}
+
+	assignment.ID = 0
+	c.Db.Where(model.RoleAssignment{Username: assignment.Username, Role: assignment.Role}).FirstOrCreate(&assignment)
+	return mvc.Response{
+		Code: iris.StatusNoContent,
+	}
+}
+
+// DeleteRole 撤销普通用户的角色
+// @Summary 撤销普通用户的角色
+// @Description 撤销普通用户被分配的角色,立即生效
+// @Tags admin
+// @Accept json
+// @Param assignment body model.RoleAssignment true "角色分配"
+// @Success 204 {object} nil "撤销成功，无返回内容"
+// @Failure 400 {object} string "角色分配不存在"
+// @Failure 401 {object} string "未授权，用户未登录或会话失效"
+// @Failure 403 {object} string "缺少staff:manage权限"
+// @Router /back/admin/role [delete]
+// @Security BearerAuth
+func (c *AdminController) DeleteRole(assignment model.RoleAssignment) mvc.Result {
+	log.Println("撤销用户", assignment.Username, "的角色", assignment.Role)
+	if c.Db.Where("username=? AND role=?", assignment.Username, assignment.Role).Delete(&model.RoleAssignment{}).RowsAffected == 0 {
+		return mvc.Response{
+			Code: iris.StatusBadRequest,
+			Text: "角色分配不存在",
+		}
+	}
+
+	return mvc.Response{
+		Code: iris.StatusNoContent,
+	}
+}
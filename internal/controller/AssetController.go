@@ -0,0 +1,97 @@
+package controller
+
+import (
+	"PaintingExchange/internal/env"
+	"PaintingExchange/internal/service"
+	"github.com/kataras/iris/v12"
+	"io"
+	"log"
+	"mime"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// ServeAsset 访问图片和头像文件
+// @Summary 访问图片或头像文件
+// @Description 图片会根据Accept请求头选择浏览器支持的更小的格式(AVIF,WebP),添加format=original参数可获取原格式的图片.
+// @Description 使用对象存储时重定向到文件的访问地址(可能是有时效的预签名地址);使用本地存储时直接返回文件
+// @Tags image
+// @Param path path string true "文件路径,如 images/big_xxx.jpg"
+// @Param format query string false "填写original时不转换格式"
+// @Param Accept header string false "浏览器支持的格式,如 image/avif,image/webp,*/*"
+// @Success 200 {file} file "文件内容"
+// @Success 302 {string} string "重定向到文件地址"
+// @Failure 404 {string} string "文件不存在"
+// @Router /assert/{path} [get]
+func ServeAsset(storage service.Storage) iris.Handler {
+	return func(ctx iris.Context) {
+		key := path.Join("assert", path.Clean("/" + ctx.Params().Get("path"))[1:])
+		// 原图等其他文件不对外提供访问
+		if path.Dir(key) != env.GetImgDir() && path.Dir(key) != env.GetAvatarDir() {
+			ctx.StatusCode(iris.StatusNotFound)
+			return
+		}
+
+		// 选择浏览器支持的格式
+		if path.Dir(key) == env.GetImgDir() && ctx.URLParam("format") != "original" {
+			ctx.Header("Vary", "Accept")
+			key = negotiateImage(storage, key, ctx.GetHeader("Accept"))
+		}
+
+		if url := storage.URL(key); url != "" {
+			ctx.Redirect(url, iris.StatusFound)
+			return
+		}
+		info, err := storage.Stat(key)
+		if err != nil {
+			ctx.StatusCode(iris.StatusNotFound)
+			return
+		}
+		file, err := storage.Get(key)
+		if err != nil {
+			ctx.StatusCode(iris.StatusNotFound)
+			return
+		}
+		defer file.Close()
+		if content, ok := file.(io.ReadSeeker); ok {
+			ctx.ServeContent(content, path.Base(key), info.ModTime)
+			return
+		}
+		ctx.ContentType(info.ContentType)
+		ctx.Header("Content-Length", strconv.FormatInt(info.Size, 10))
+		if _, err := io.Copy(ctx, file); err != nil {
+			log.Println("文件", key, "发送失败", err)
+		}
+	}
+}
+
+// negotiateImage 按优先级选择浏览器支持且已生成的其他格式图片,都不支持时使用原图
+func negotiateImage(storage service.Storage, key string, accept string) string {
+	ext := strings.ToLower(path.Ext(key))
+	for _, format := range service.ImageFormats {
+		if format.Ext == ext || !acceptsType(accept, format.ContentType) {
+			continue
+		}
+		variant := service.VariantURI(key, format.Ext)
+		if _, err := storage.Stat(variant); err == nil {
+			return variant
+		}
+	}
+	return key
+}
+
+// acceptsType Accept请求头是否明确接受指定类型(不考虑通配符,q=0表示不接受)
+func acceptsType(accept string, contentType string) bool {
+	for _, item := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(item))
+		if err != nil || mediaType != contentType {
+			continue
+		}
+		if q, err := strconv.ParseFloat(params["q"], 64); err == nil && q <= 0 {
+			return false
+		}
+		return true
+	}
+	return false
+}
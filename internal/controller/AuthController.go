@@ -4,40 +4,65 @@ import (
 	"PaintingExchange/internal/env"
 	"PaintingExchange/internal/model"
 	"PaintingExchange/internal/service"
-	"github.com/golang-jwt/jwt/v5"
+	"errors"
 	"github.com/kataras/iris/v12"
 	"github.com/kataras/iris/v12/mvc"
 	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
 	"log"
-	"path/filepath"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
 	"time"
 )
 
+// oidcFlowCookie 保存第三方登录流程状态的cookie
+const oidcFlowCookie = "oidc_flow"
+
 // AuthController 用户登录注册控制器
 type AuthController struct {
-	Ctx iris.Context
-	Db  *gorm.DB
+	Ctx       iris.Context
+	Db        *gorm.DB
+	Guard     *service.LoginGuard
+	MailGuard *service.MailGuard
+	Mailer    service.Mailer
+}
+
+// BeforeActivation 注册带路径参数的路由
+func (c *AuthController) BeforeActivation(b mvc.BeforeActivation) {
+	b.Handle("GET", "/oidc/{provider}/login", "OidcLogin")
+	b.Handle("GET", "/oidc/{provider}/callback", "OidcCallback")
 }
 
 // PostUserLogin 登录
 // @Summary 用户登录
-// @Description 用户通过用户名和密码登录，成功后返回 JWT Token
+// @Description 用户通过用户名和密码登录，成功后返回访问令牌(JWT)和刷新令牌
 // @Tags auth
 // @Accept json
-// @Produce plain
+// @Produce json
 // @Param user body model.User true "用户登录信息(只需要username和password)"
-// @Success 200 {string} string "JWT Token"
+// @Success 200 {object} model.Token "登录凭证"
+// @Success 202 {object} model.MFAChallenge "需要两步验证,使用mfaToken请求 /user/login/mfa"
 // @Failure 400 {string} string "用户名或密码错误"
 // @Failure 403 {string} string "用户名被封禁"
+// @Failure 429 {string} string "登录失败次数过多,Retry-After响应头给出需要等待的秒数"
 // @Failure 500 {string} string "服务器内部错误"
 // @Router /user/login [post]
 func (c *AuthController) PostUserLogin(user model.User) mvc.Result {
 	log.Println("[登录注册] 用户", user.Username, "登录")
 
+	// 验证登录频率
+	keys := service.LoginKeys("user", user.Username, c.Ctx.RemoteAddr())
+	if wait := c.Guard.Attempt(keys...); wait > 0 {
+		log.Println("[登录注册] 用户", user.Username, "登录过于频繁")
+		return c.tooManyAttempts(wait)
+	}
+
 	// 验证密码
 	if res, err := service.CheckPass(user, *c.Db); err != nil {
 		log.Println("[登录注册] 用户", user.Username, "被封禁")
+		c.Guard.Cancel(keys...)
 		return mvc.Response{
 			Code: iris.StatusForbidden,
 			Text: "用户被封禁",
@@ -51,43 +76,53 @@ func (c *AuthController) PostUserLogin(user model.User) mvc.Result {
 		}
 	}
 
-	// 签发jwt
-	log.Println("[登录注册] 用户", user.Username, "验证通过,签发jwt")
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"exp":      time.Now().Add(time.Hour * 24).Unix(), // 24小时后过期
-		"username": user.Username,                         // 用户名
-		"isAdmin":  false,                                 // 是否为管理员
-	})
-	tokenString, err := token.SignedString(env.GetJWTKey())
+	// 启用两步验证时先签发两步验证令牌,失败记录要保留到两步验证通过,以限制验证码的猜测次数
+	if service.IsMFAEnabled(c.Db, user.Username, false) {
+		log.Println("[登录注册] 用户", user.Username, "密码验证通过,等待两步验证")
+		c.Guard.Cancel(keys...)
+		return c.mfaChallenge(user.Username, false, false)
+	}
+
+	// 签发令牌
+	log.Println("[登录注册] 用户", user.Username, "验证通过,签发令牌")
+	token, err := service.IssueToken(c.Db, user.Username, false, c.Ctx.GetHeader("User-Agent"), c.Ctx.RemoteAddr())
 	if err != nil {
-		log.Println("[登录注册] 用户", user.Username, "jwt签发失败", err)
+		log.Println("[登录注册] 用户", user.Username, "令牌签发失败", err)
+		c.Guard.Cancel(keys...)
 		return mvc.Response{
 			Code: iris.StatusInternalServerError,
 			Text: err.Error(),
 		}
 	}
+	c.Guard.Succeed(keys...)
 
 	log.Println("[登录注册] 用户", user.Username, "登录成功")
 	return mvc.Response{
-		Code: iris.StatusOK,
-		Text: tokenString,
+		Code:   iris.StatusOK,
+		Object: token,
 	}
 }
 
 // PostUserRegister 注册
 // @Summary 用户注册
-// @Description 用户进行注册，成功后返回 JWT Token
+// @Description 用户进行注册，成功后返回访问令牌(JWT)和刷新令牌
 // @Tags auth
 // @Accept json
-// @Produce plain
-// @Param user body model.User true "用户注册信息(只需要username和password)"
-// @Success 201 {string} string "JWT Token"
+// @Produce json
+// @Param user body model.User true "用户注册信息(只需要username和password,email可选,填写后会发送验证邮件)"
+// @Success 201 {object} model.Token "登录凭证"
+// @Failure 400 {object} model.ValidationError "注册信息不合法"
 // @Failure 403 {string} string "用户名已存在"
 // @Failure 500 {string} string "服务器内部错误"
 // @Router /user/register [post]
 func (c *AuthController) PostUserRegister(user model.User) mvc.Result {
 	log.Println("[登录注册] 用户", user.Username, "注册")
 
+	// 校验注册信息
+	if errs := service.ValidateRegister(user); len(errs) != 0 {
+		return invalidParams(errs)
+	}
+
 	// 验证用户名是否存在
 	var tmp model.User
 	if c.Db.Where("username=?", user.Username).Find(&tmp); tmp.Password != "" {
@@ -111,19 +146,20 @@ func (c *AuthController) PostUserRegister(user model.User) mvc.Result {
 
 	// 写入数据库
 	user.Nickname = user.Username
-	user.AvatarURI = filepath.Join(env.GetAvatarDir(), "0.png")
+	user.AvatarURI = service.DefaultAvatar
+	user.EmailVerified = false
 	c.Db.Create(&user)
 
-	// 签发jwt
-	log.Println("[登录注册] 用户", user.Username, "验证通过,签发jwt")
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"exp":      time.Now().Add(time.Hour * 24).Unix(), // 24小时后过期
-		"username": user.Username,                         // 用户名
-		"isAdmin":  false,                                 // 是否为管理员
-	})
-	tokenString, err := token.SignedString(env.GetJWTKey())
+	// 发送验证邮件
+	if user.Email != "" {
+		go sendVerificationEmail(c.Mailer, user)
+	}
+
+	// 签发令牌
+	log.Println("[登录注册] 用户", user.Username, "验证通过,签发令牌")
+	token, err := service.IssueToken(c.Db, user.Username, false, c.Ctx.GetHeader("User-Agent"), c.Ctx.RemoteAddr())
 	if err != nil {
-		log.Println("[登录注册] 用户", user.Username, "jwt签发失败", err)
+		log.Println("[登录注册] 用户", user.Username, "令牌签发失败", err)
 		return mvc.Response{
 			Code: iris.StatusInternalServerError,
 			Text: err.Error(),
@@ -132,8 +168,160 @@ func (c *AuthController) PostUserRegister(user model.User) mvc.Result {
 
 	log.Println("[登录注册] 用户", user.Username, "注册成功")
 	return mvc.Response{
-		Code: iris.StatusCreated,
-		Text: tokenString,
+		Code:   iris.StatusCreated,
+		Object: token,
+	}
+}
+
+// PostUserTokenRefresh 刷新令牌
+// @Summary 刷新令牌
+// @Description 使用刷新令牌换取新的访问令牌和刷新令牌,旧的刷新令牌随即失效;重复使用已失效的刷新令牌会注销该次登录的所有令牌
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param token body model.Token true "登录凭证(只需要refreshToken)"
+// @Success 200 {object} model.Token "新的登录凭证"
+// @Failure 401 {string} string "刷新令牌无效或已过期"
+// @Failure 500 {string} string "服务器内部错误"
+// @Router /user/token/refresh [post]
+func (c *AuthController) PostUserTokenRefresh(token model.Token) mvc.Result {
+	log.Println("[登录注册] 刷新令牌")
+
+	newToken, err := service.RefreshToken(c.Db, token.RefreshToken)
+	if errors.Is(err, service.ErrRefreshTokenInvalid) || errors.Is(err, service.ErrRefreshTokenExpired) || errors.Is(err, service.ErrRefreshTokenReused) {
+		log.Println("[登录注册] 刷新令牌失败", err)
+		return mvc.Response{
+			Code: iris.StatusUnauthorized,
+			Text: err.Error(),
+		}
+	} else if err != nil {
+		log.Println("[登录注册] 令牌签发失败", err)
+		return mvc.Response{
+			Code: iris.StatusInternalServerError,
+			Text: err.Error(),
+		}
+	}
+
+	log.Println("[登录注册] 刷新令牌成功")
+	return mvc.Response{
+		Code:   iris.StatusOK,
+		Object: newToken,
+	}
+}
+
+// PostUserPasswordForgot 忘记密码
+// @Summary 忘记密码
+// @Description 向用户已验证的邮箱发送重置密码邮件,可以提交用户名或邮箱;无论账号是否存在都返回204
+// @Tags auth
+// @Accept json
+// @Param user body model.User true "用户名或邮箱(只需要username或email)"
+// @Success 204 {object} nil "请求已受理"
+// @Failure 429 {string} string "请求过于频繁,Retry-After响应头给出需要等待的秒数"
+// @Router /user/password/forgot [post]
+func (c *AuthController) PostUserPasswordForgot(user model.User) mvc.Result {
+	account := user.Username
+	if account == "" {
+		account = user.Email
+	}
+	log.Println("[登录注册]", account, "请求重置密码")
+
+	// 限制发送频率,防止邮件轰炸
+	keys := service.MailKeys(account, c.Ctx.RemoteAddr())
+	if wait := c.MailGuard.Attempt(keys...); wait > 0 {
+		log.Println("[登录注册]", account, "请求重置密码过于频繁")
+		return c.tooManyAttempts(wait)
+	}
+
+	// 查找账号,不告知请求者账号是否存在
+	var found model.User
+	if user.Username != "" {
+		c.Db.Where("username=?", user.Username).Find(&found)
+	} else if user.Email != "" {
+		c.Db.Where("email=? AND email_verified=?", user.Email, true).Find(&found)
+	}
+	if found.Password != "" && !found.IsBan && found.EmailVerified {
+		go func() {
+			if err := service.SendPasswordResetEmail(c.Mailer, found); err != nil {
+				log.Println("[登录注册] 用户", found.Username, "重置密码邮件发送失败", err)
+			}
+		}()
+	} else {
+		log.Println("[登录注册]", account, "不存在或没有已验证的邮箱")
+	}
+
+	return mvc.Response{
+		Code: iris.StatusNoContent,
+	}
+}
+
+// PostUserPasswordReset 重置密码
+// @Summary 重置密码
+// @Description 使用重置密码邮件中的令牌设置新密码,令牌只能使用一次,成功后用户在所有设备上的登录都会失效
+// @Tags auth
+// @Accept json
+// @Param reset body model.PasswordReset true "重置密码令牌和新密码"
+// @Success 204 {object} nil "密码重置成功"
+// @Failure 400 {object} model.ValidationError "新密码不符合要求"
+// @Failure 401 {string} string "令牌无效,已过期或已被使用"
+// @Failure 500 {string} string "服务器内部错误"
+// @Router /user/password/reset [post]
+func (c *AuthController) PostUserPasswordReset(reset model.PasswordReset) mvc.Result {
+	username, err := service.PasswordResetUsername(c.Db, reset.Token)
+	if err == nil {
+		if errs := service.ValidatePassword("newPassword", reset.NewPassword, username); len(errs) != 0 {
+			return invalidParams(errs)
+		}
+		username, err = service.ResetPassword(c.Db, reset.Token, reset.NewPassword)
+	}
+	if errors.Is(err, service.ErrPurposeTokenInvalid) {
+		log.Println("[登录注册] 重置密码令牌无效")
+		return mvc.Response{
+			Code: iris.StatusUnauthorized,
+			Text: err.Error(),
+		}
+	} else if err != nil {
+		log.Println("[登录注册] 重置密码失败", err)
+		return mvc.Response{
+			Code: iris.StatusInternalServerError,
+			Text: err.Error(),
+		}
+	}
+
+	log.Println("[登录注册] 用户", username, "重置密码成功")
+	return mvc.Response{
+		Code: iris.StatusNoContent,
+	}
+}
+
+// PostUserEmailVerify 验证邮箱
+// @Summary 验证邮箱
+// @Description 使用验证邮件中的令牌验证邮箱,令牌只能使用一次
+// @Tags auth
+// @Accept json
+// @Param verification body model.EmailVerification true "邮箱验证令牌"
+// @Success 204 {object} nil "邮箱验证成功"
+// @Failure 401 {string} string "令牌无效,已过期或已被使用"
+// @Failure 500 {string} string "服务器内部错误"
+// @Router /user/email/verify [post]
+func (c *AuthController) PostUserEmailVerify(verification model.EmailVerification) mvc.Result {
+	username, err := service.VerifyEmail(c.Db, verification.Token)
+	if errors.Is(err, service.ErrPurposeTokenInvalid) {
+		log.Println("[登录注册] 邮箱验证令牌无效")
+		return mvc.Response{
+			Code: iris.StatusUnauthorized,
+			Text: err.Error(),
+		}
+	} else if err != nil {
+		log.Println("[登录注册] 邮箱验证失败", err)
+		return mvc.Response{
+			Code: iris.StatusInternalServerError,
+			Text: err.Error(),
+		}
+	}
+
+	log.Println("[登录注册] 用户", username, "邮箱验证成功")
+	return mvc.Response{
+		Code: iris.StatusNoContent,
 	}
 }
 
@@ -155,15 +343,31 @@ func (c *AuthController) GetJwtTest() {
 // @Accept json
 // @Produce json
 // @Param admin body model.Admin true "管理员登录信息"
-// @Success 200 {object} map[string]string "JWT 令牌"
-// @Failure 403 {string} string "用户名或密码错误"
+// @Success 200 {object} model.Token "登录凭证"
+// @Success 202 {object} model.MFAChallenge "需要两步验证,使用mfaToken请求 /back/login/mfa (enrollRequired为true时先请求 /back/login/mfa/enroll 绑定)"
+// @Failure 403 {string} string "用户名或密码错误或管理员被停用"
+// @Failure 429 {string} string "登录失败次数过多,Retry-After响应头给出需要等待的秒数"
 // @Failure 500 {string} string "服务器错误"
 // @Router /back/login [post]
 func (c *AuthController) PostBackLogin(admin model.Admin) mvc.Result {
 	log.Println("管理员", admin.Username, "登录")
 
+	// 验证登录频率
+	keys := service.LoginKeys("admin", admin.Username, c.Ctx.RemoteAddr())
+	if wait := c.Guard.Attempt(keys...); wait > 0 {
+		log.Println("[登录注册] 管理员", admin.Username, "登录过于频繁")
+		return c.tooManyAttempts(wait)
+	}
+
 	// 验证密码
-	if !service.CheckAdmin(admin, *c.Db) {
+	if res, err := service.CheckAdmin(admin, *c.Db); err != nil {
+		log.Println("[登录注册] 管理员", admin.Username, "被停用")
+		c.Guard.Cancel(keys...)
+		return mvc.Response{
+			Code: iris.StatusForbidden,
+			Text: "管理员被停用",
+		}
+	} else if !res {
 		log.Println("[登录注册] 管理员", admin.Username, "密码错误")
 		return mvc.Response{
 			Code: iris.StatusForbidden,
@@ -171,25 +375,375 @@ func (c *AuthController) PostBackLogin(admin model.Admin) mvc.Result {
 		}
 	}
 
-	// 签发jwt
-	log.Println("[登录注册] 管理员", admin.Username, "验证通过,签发jwt")
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"exp":      time.Now().Add(time.Hour * 24).Unix(), // 24小时后过期
-		"username": admin.Username,                        // 用户名
-		"isAdmin":  true,                                  // 是否为管理员
-	})
-	tokenString, err := token.SignedString(env.GetJWTKey())
+	// 启用两步验证或强制两步验证时先签发两步验证令牌,失败记录要保留到两步验证通过
+	if service.IsMFAEnabled(c.Db, admin.Username, true) {
+		log.Println("[登录注册] 管理员", admin.Username, "密码验证通过,等待两步验证")
+		c.Guard.Cancel(keys...)
+		return c.mfaChallenge(admin.Username, true, false)
+	} else if env.GetAdminRequireMFA() {
+		log.Println("[登录注册] 管理员", admin.Username, "密码验证通过,需要先绑定两步验证")
+		c.Guard.Cancel(keys...)
+		return c.mfaChallenge(admin.Username, true, true)
+	}
+
+	// 签发令牌
+	log.Println("[登录注册] 管理员", admin.Username, "验证通过,签发令牌")
+	token, err := service.IssueToken(c.Db, admin.Username, true, c.Ctx.GetHeader("User-Agent"), c.Ctx.RemoteAddr())
 	if err != nil {
-		log.Println("[登录注册] 用户", admin.Username, "jwt签发失败", err)
+		log.Println("[登录注册] 用户", admin.Username, "令牌签发失败", err)
+		c.Guard.Cancel(keys...)
 		return mvc.Response{
 			Code: iris.StatusInternalServerError,
 			Text: err.Error(),
 		}
 	}
+	c.Guard.Succeed(keys...)
 
 	log.Println("[登录注册] 用户", admin.Username, "登录成功")
 	return mvc.Response{
-		Code: iris.StatusOK,
-		Text: tokenString,
+		Code:   iris.StatusOK,
+		Object: token,
+	}
+}
+
+// PostUserLoginMfa 用户两步验证
+// @Summary 用户两步验证
+// @Description 登录返回202时,提交两步验证令牌和验证器上的验证码(或恢复码)完成登录
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param verify body model.MFAVerify true "两步验证令牌和验证码"
+// @Success 200 {object} model.Token "登录凭证"
+// @Failure 400 {string} string "验证码错误"
+// @Failure 401 {string} string "两步验证令牌无效或已过期"
+// @Failure 429 {string} string "失败次数过多,Retry-After响应头给出需要等待的秒数"
+// @Failure 500 {string} string "服务器内部错误"
+// @Router /user/login/mfa [post]
+func (c *AuthController) PostUserLoginMfa(verify model.MFAVerify) mvc.Result {
+	return c.completeMFA(verify, false)
+}
+
+// PostBackLoginMfa 管理员两步验证
+// @Summary 管理员两步验证
+// @Description 管理员登录返回202时,提交两步验证令牌和验证码(或恢复码)完成登录;强制绑定时此验证码同时用于启用两步验证
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param verify body model.MFAVerify true "两步验证令牌和验证码"
+// @Success 200 {object} model.Token "登录凭证"
+// @Failure 400 {string} string "验证码错误或尚未绑定"
+// @Failure 401 {string} string "两步验证令牌无效或已过期"
+// @Failure 429 {string} string "失败次数过多,Retry-After响应头给出需要等待的秒数"
+// @Failure 500 {string} string "服务器内部错误"
+// @Router /back/login/mfa [post]
+func (c *AuthController) PostBackLoginMfa(verify model.MFAVerify) mvc.Result {
+	return c.completeMFA(verify, true)
+}
+
+// PostBackLoginMfaEnroll 管理员登录时绑定两步验证
+// @Summary 管理员登录时绑定两步验证
+// @Description 强制两步验证而管理员尚未绑定时,使用两步验证令牌获取绑定信息,再通过 /back/login/mfa 提交验证码完成绑定和登录
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param verify body model.MFAVerify true "两步验证令牌(只需要mfaToken)"
+// @Success 201 {object} model.MFASetup "绑定信息"
+// @Failure 400 {string} string "两步验证已启用"
+// @Failure 401 {string} string "两步验证令牌无效或已过期"
+// @Failure 500 {string} string "服务器内部错误"
+// @Router /back/login/mfa/enroll [post]
+func (c *AuthController) PostBackLoginMfaEnroll(verify model.MFAVerify) mvc.Result {
+	username, isAdmin, err := service.ParseMFAToken(c.Db, verify.MFAToken)
+	if err != nil || !isAdmin {
+		return mvc.Response{
+			Code: iris.StatusUnauthorized,
+			Text: service.ErrMFATokenInvalid.Error(),
+		}
+	}
+	log.Println("[登录注册] 管理员", username, "登录时绑定两步验证")
+
+	setup, err := service.BeginMFASetup(c.Db, username, true)
+	if errors.Is(err, service.ErrMFAEnabled) {
+		return mvc.Response{
+			Code: iris.StatusBadRequest,
+			Text: err.Error(),
+		}
+	} else if err != nil {
+		log.Println("[登录注册] 管理员", username, "两步验证绑定失败", err)
+		return mvc.Response{
+			Code: iris.StatusInternalServerError,
+			Text: err.Error(),
+		}
+	}
+
+	return mvc.Response{
+		Code:   iris.StatusCreated,
+		Object: setup,
+	}
+}
+
+// GetOidcProviders 获取可用的第三方登录
+// @Summary 获取可用的第三方登录
+// @Description 列出已配置的OIDC身份提供方
+// @Tags oidc
+// @Produce json
+// @Success 200 {array} model.OIDCProvider "身份提供方列表"
+// @Router /oidc/providers [get]
+func (c *AuthController) GetOidcProviders() mvc.Result {
+	return mvc.Response{
+		Code:   iris.StatusOK,
+		Object: service.GetOIDCProviders(),
+	}
+}
+
+// OidcLogin 第三方登录
+// @Summary 第三方登录
+// @Description 浏览器直接跳转到此地址,服务端重定向到身份提供方(授权码+PKCE),完成后回到 /oidc/{provider}/callback.
+// @Description 回调地址为身份提供方配置的redirectURL,未配置时为 {siteURL}/oidc/{provider}/callback,两者都未配置时返回500
+// @Description 关联外部身份需要已登录的用户通过 /user/identities/link/{provider} [post] 发起,此处不接受mode=link
+// @Tags oidc
+// @Param provider path string true "身份提供方名称"
+// @Success 302 {string} string "重定向到身份提供方"
+// @Failure 400 {string} string "关联流程必须登录后发起"
+// @Failure 404 {string} string "身份提供方不存在"
+// @Failure 500 {string} string "未配置回调地址"
+// @Failure 502 {string} string "无法连接身份提供方"
+// @Router /oidc/{provider}/login [get]
+func (c *AuthController) OidcLogin(provider string) mvc.Result {
+	// 浏览器跳转无法携带登录令牌,关联流程只能由已登录的用户通过接口发起
+	if mode := c.Ctx.URLParamDefault("mode", "login"); mode != "login" {
+		return mvc.Response{
+			Code: iris.StatusBadRequest,
+			Text: "关联外部身份请登录后通过 /user/identities/link/" + provider + " 发起",
+		}
+	}
+	log.Println("[登录注册] 开始第三方登录", provider)
+
+	authURL, flowToken, err := service.BeginOIDCLogin(provider, "login", "")
+	if errors.Is(err, service.ErrOIDCProviderNotFound) {
+		return mvc.Response{
+			Code: iris.StatusNotFound,
+			Text: err.Error(),
+		}
+	} else if errors.Is(err, service.ErrOIDCRedirectURL) {
+		log.Println("[登录注册] 第三方登录", provider, "失败", err)
+		return mvc.Response{
+			Code: iris.StatusInternalServerError,
+			Text: err.Error(),
+		}
+	} else if err != nil {
+		log.Println("[登录注册] 第三方登录", provider, "失败", err)
+		return mvc.Response{
+			Code: iris.StatusBadGateway,
+			Text: err.Error(),
+		}
+	}
+
+	setOIDCFlowCookie(c.Ctx, provider, flowToken)
+	return mvc.Response{
+		Code: iris.StatusFound,
+		Path: authURL,
+	}
+}
+
+// setOIDCFlowCookie 流程状态只保存在HttpOnly的cookie中,只在回调地址下发送
+func setOIDCFlowCookie(ctx iris.Context, provider string, flowToken string) {
+	ctx.SetCookie(&http.Cookie{
+		Name:     oidcFlowCookie,
+		Value:    flowToken,
+		Path:     "/oidc/" + provider,
+		MaxAge:   int((10 * time.Minute).Seconds()),
+		HttpOnly: true,
+		Secure:   ctx.Request().TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// OidcCallback 第三方登录回调
+// @Summary 第三方登录回调
+// @Description 身份提供方验证后跳转到此地址,服务端完成验证后重定向到前端的 {siteURL}/oidc/callback,结果放在地址的#片段中:
+// @Description 登录成功为accessToken,refreshToken和expiresAt;需要两步验证为mfaToken;关联流程为linkToken;失败为error
+// @Tags oidc
+// @Param provider path string true "身份提供方名称"
+// @Param code query string true "授权码"
+// @Param state query string true "state"
+// @Success 302 {string} string "重定向到前端"
+// @Router /oidc/{provider}/callback [get]
+func (c *AuthController) OidcCallback(provider string) mvc.Result {
+	log.Println("[登录注册] 第三方登录回调", provider)
+	flowToken := c.Ctx.GetCookie(oidcFlowCookie)
+	c.Ctx.RemoveCookie(oidcFlowCookie, iris.CookiePath("/oidc/"+provider))
+
+	if errCode := c.Ctx.URLParam("error"); errCode != "" {
+		log.Println("[登录注册] 身份提供方", provider, "返回错误", errCode, c.Ctx.URLParam("error_description"))
+		return oidcRedirect(url.Values{"error": {errCode}})
+	}
+
+	identity, mode, linkUser, err := service.FinishOIDCLogin(c.Db, provider, flowToken, c.Ctx.URLParam("state"), c.Ctx.URLParam("code"))
+	if err != nil {
+		log.Println("[登录注册] 第三方登录", provider, "验证失败", err)
+		return oidcRedirect(url.Values{"error": {err.Error()}})
+	}
+
+	// 关联流程只返回绑定到发起用户的关联令牌
+	if mode == "link" {
+		linkToken, err := service.IssueIdentityLinkToken(identity, linkUser)
+		if err != nil {
+			return oidcRedirect(url.Values{"error": {err.Error()}})
+		}
+		return oidcRedirect(url.Values{"linkToken": {linkToken}})
+	}
+
+	user, err := service.LoginWithOIDC(c.Db, identity)
+	if err != nil {
+		log.Println("[登录注册] 第三方登录", provider, "用户创建失败", err)
+		return oidcRedirect(url.Values{"error": {err.Error()}})
+	}
+	if user.IsBan {
+		log.Println("[登录注册] 用户", user.Username, "被封禁")
+		return oidcRedirect(url.Values{"error": {"用户被封禁"}})
+	}
+
+	// 启用两步验证时先签发两步验证令牌
+	if service.IsMFAEnabled(c.Db, user.Username, false) {
+		log.Println("[登录注册] 用户", user.Username, "第三方登录通过,等待两步验证")
+		challenge, err := service.IssueMFAToken(user.Username, false, false)
+		if err != nil {
+			return oidcRedirect(url.Values{"error": {err.Error()}})
+		}
+		return oidcRedirect(url.Values{"mfaToken": {challenge.MFAToken}})
+	}
+
+	token, err := service.IssueToken(c.Db, user.Username, false, c.Ctx.GetHeader("User-Agent"), c.Ctx.RemoteAddr())
+	if err != nil {
+		log.Println("[登录注册] 用户", user.Username, "令牌签发失败", err)
+		return oidcRedirect(url.Values{"error": {err.Error()}})
+	}
+	log.Println("[登录注册] 用户", user.Username, "通过", provider, "登录成功")
+	return oidcRedirect(url.Values{
+		"accessToken":  {token.AccessToken},
+		"refreshToken": {token.RefreshToken},
+		"expiresAt":    {token.ExpiresAt.Format(time.RFC3339)},
+	})
+}
+
+// oidcRedirect 重定向到前端的第三方登录结果页,令牌放在#片段中,不会出现在服务器日志和Referer中
+func oidcRedirect(values url.Values) mvc.Result {
+	return mvc.Response{
+		Code: iris.StatusFound,
+		Path: env.GetSiteURL() + "/oidc/callback#" + values.Encode(),
+	}
+}
+
+// mfaChallenge 签发两步验证令牌的响应
+func (c *AuthController) mfaChallenge(username string, isAdmin bool, enrollRequired bool) mvc.Result {
+	challenge, err := service.IssueMFAToken(username, isAdmin, enrollRequired)
+	if err != nil {
+		log.Println("[登录注册]", username, "两步验证令牌签发失败", err)
+		return mvc.Response{
+			Code: iris.StatusInternalServerError,
+			Text: err.Error(),
+		}
+	}
+
+	return mvc.Response{
+		Code:   iris.StatusAccepted,
+		Object: challenge,
+	}
+}
+
+// completeMFA 验证两步验证令牌和验证码,通过后签发令牌
+func (c *AuthController) completeMFA(verify model.MFAVerify, isAdmin bool) mvc.Result {
+	username, tokenIsAdmin, err := service.ParseMFAToken(c.Db, verify.MFAToken)
+	if err != nil || tokenIsAdmin != isAdmin {
+		log.Println("[登录注册] 两步验证令牌无效")
+		return mvc.Response{
+			Code: iris.StatusUnauthorized,
+			Text: service.ErrMFATokenInvalid.Error(),
+		}
+	}
+	log.Println("[登录注册]", username, "两步验证")
+
+	// 验证码同样受登录频率限制
+	accountType := "user"
+	if isAdmin {
+		accountType = "admin"
+	}
+	keys := service.LoginKeys(accountType, username, c.Ctx.RemoteAddr())
+	if wait := c.Guard.Attempt(keys...); wait > 0 {
+		log.Println("[登录注册]", username, "两步验证过于频繁")
+		return c.tooManyAttempts(wait)
+	}
+
+	// 强制绑定的管理员以第一次验证启用两步验证
+	if isAdmin && !service.IsMFAEnabled(c.Db, username, true) && env.GetAdminRequireMFA() {
+		err = service.EnableMFA(c.Db, username, true, verify.Code)
+	} else {
+		err = service.VerifyMFA(c.Db, username, isAdmin, verify.Code)
+	}
+	if err != nil {
+		log.Println("[登录注册]", username, "两步验证失败", err)
+		if !errors.Is(err, service.ErrMFACodeInvalid) {
+			c.Guard.Cancel(keys...)
+		}
+		return mvc.Response{
+			Code: iris.StatusBadRequest,
+			Text: err.Error(),
+		}
+	}
+	// 同一个两步验证令牌只能换取一次令牌
+	if err := service.RevokeMFAToken(c.Db, verify.MFAToken); err != nil {
+		log.Println("[登录注册]", username, "两步验证令牌注销失败", err)
+		c.Guard.Cancel(keys...)
+		return mvc.Response{
+			Code: iris.StatusUnauthorized,
+			Text: service.ErrMFATokenInvalid.Error(),
+		}
+	}
+
+	// 签发令牌
+	log.Println("[登录注册]", username, "两步验证通过,签发令牌")
+	token, err := service.IssueToken(c.Db, username, isAdmin, c.Ctx.GetHeader("User-Agent"), c.Ctx.RemoteAddr())
+	if err != nil {
+		log.Println("[登录注册]", username, "令牌签发失败", err)
+		c.Guard.Cancel(keys...)
+		return mvc.Response{
+			Code: iris.StatusInternalServerError,
+			Text: err.Error(),
+		}
+	}
+	c.Guard.Succeed(keys...)
+
+	return mvc.Response{
+		Code:   iris.StatusOK,
+		Object: token,
+	}
+}
+
+// sendVerificationEmail 发送邮箱验证邮件并记录失败
+func sendVerificationEmail(mailer service.Mailer, user model.User) {
+	if err := service.SendVerificationEmail(mailer, user); err != nil {
+		log.Println("用户", user.Username, "验证邮件发送失败", err)
+	}
+}
+
+// tooManyAttempts 登录过于频繁的响应
+func (c *AuthController) tooManyAttempts(wait time.Duration) mvc.Result {
+	c.Ctx.Header("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+	return mvc.Response{
+		Code: iris.StatusTooManyRequests,
+		Text: "登录失败次数过多,请稍后再试",
 	}
 }
+
+// GetJWKS 获取验证令牌用的公钥集合
+// @Summary 获取验证令牌用的公钥集合
+// @Description 以JWKS格式返回所有非对称签名密钥的公钥(HS256密钥不会公开),供其他服务验证访问令牌.
+// @Description 同一组密钥也用于签发两步验证等专用令牌,验证访问令牌时必须检查头部的typ为at+jwt
+// @Tags auth
+// @Produce json
+// @Success 200 {object} model.JWKS "公钥集合"
+// @Router /.well-known/jwks.json [get]
+func GetJWKS(ctx iris.Context) {
+	ctx.JSON(service.GetJWKS())
+}
@@ -8,15 +8,180 @@ import (
 	"github.com/kataras/iris/v12/mvc"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
+	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
 	"log"
+	"slices"
+	"strings"
 )
 
+// BackController 后台管理控制器
 type BackController struct {
-	Ctx  iris.Context
-	Db   *gorm.DB
-	Mg   *mongo.Client
-	Algo service.SearchServiceClient
+	Ctx        iris.Context
+	Db         *gorm.DB
+	Mg         *mongo.Client
+	Algo       service.SearchServiceClient
+	Guard      *service.LoginGuard
+	GC         *service.FileGC
+	Duplicates *service.DuplicateDetector
+}
+
+// BeforeActivation 为各个管理接口绑定所需权限
+func (c *BackController) BeforeActivation(b mvc.BeforeActivation) {
+	b.Handle(iris.MethodGet, "/user", "GetUser", service.RequirePermission(service.PermUserRead))
+	b.Handle(iris.MethodPost, "/user/ban", "PostUserBan", service.RequirePermission(service.PermUserBan))
+	b.Handle(iris.MethodPost, "/user/unban", "PostUserUnban", service.RequirePermission(service.PermUserBan))
+	b.Handle(iris.MethodGet, "/image", "GetImage", service.RequirePermission(service.PermImageRead))
+	b.Handle(iris.MethodPost, "/image/ban", "PostImageBan", service.RequirePermission(service.PermImageBan))
+	b.Handle(iris.MethodPost, "/image/unban", "PostImageUnban", service.RequirePermission(service.PermImageBan))
+	b.Handle(iris.MethodPut, "/image/rating", "PutImageRating", service.RequirePermission(service.PermImageBan))
+	b.Handle(iris.MethodGet, "/lockout", "GetLockout", service.RequirePermission(service.PermLockoutRead))
+	b.Handle(iris.MethodDelete, "/lockout", "DeleteLockout", service.RequirePermission(service.PermLockoutClear))
+	b.Handle(iris.MethodGet, "/duplicate", "GetDuplicate", service.RequirePermission(service.PermImageRead))
+	b.Handle(iris.MethodPut, "/duplicate", "PutDuplicate", service.RequirePermission(service.PermImageBan))
+	b.Handle(iris.MethodGet, "/file/orphan", "GetFileOrphan", service.RequirePermission(service.PermFileGC))
+	b.Handle(iris.MethodPost, "/file/gc", "PostFileGc", service.RequirePermission(service.PermFileGC))
+}
+
+// GetPermission 获取当前登录者的角色和权限
+// @Summary 获取当前登录者的角色和权限
+// @Description 获取当前登录者(管理员账号或被分配角色的普通用户)的角色和权限,用于前端展示可用的管理功能
+// @Tags admin
+// @Produce json
+// @Success 200 {object} model.Permission "角色和权限"
+// @Failure 401 {object} string "未授权，用户未登录或没有任何角色"
+// @Router /back/permission [get]
+// @Security BearerAuth
+func (c *BackController) GetPermission() mvc.Result {
+	loginUser, err := c.Ctx.User().GetRaw()
+	if err != nil {
+		return mvc.Response{
+			Code: iris.StatusUnauthorized,
+			Text: iris.StatusText(iris.StatusUnauthorized),
+		}
+	}
+	roles := loginUser.(iris.SimpleUser).Roles
+
+	return mvc.Response{
+		Code: iris.StatusOK,
+		Object: model.Permission{
+			Roles:       roles,
+			Permissions: service.GetPermissions(roles),
+		},
+	}
+}
+
+// PostLogout 管理员退出登录
+// @Summary 管理员退出登录
+// @Description 注销当前使用的访问令牌及其对应的刷新令牌
+// @Tags admin
+// @Success 204 {object} nil "退出登录成功，无返回内容"
+// @Failure 401 {object} string "未授权，用户未登录或会话失效"
+// @Router /back/logout [post]
+// @Security BearerAuth
+func (c *BackController) PostLogout() mvc.Result {
+	loginUser, err := c.Ctx.User().GetRaw()
+	if err != nil {
+		return mvc.Response{
+			Code: iris.StatusUnauthorized,
+			Text: iris.StatusText(iris.StatusUnauthorized),
+		}
+	}
+	log.Println("管理员", loginUser.(iris.SimpleUser).Username, "退出登录")
+
+	service.RevokeCurrentToken(c.Db, loginUser.(iris.SimpleUser))
+	return mvc.Response{
+		Code: iris.StatusNoContent,
+	}
+}
+
+// PostLogoutAll 管理员退出所有设备的登录
+// @Summary 管理员退出所有设备的登录
+// @Description 注销管理员在所有设备上签发的访问令牌和刷新令牌
+// @Tags admin
+// @Success 204 {object} nil "退出登录成功，无返回内容"
+// @Failure 401 {object} string "未授权，用户未登录或会话失效"
+// @Router /back/logout/all [post]
+// @Security BearerAuth
+func (c *BackController) PostLogoutAll() mvc.Result {
+	loginUser, err := c.Ctx.User().GetRaw()
+	if err != nil {
+		return mvc.Response{
+			Code: iris.StatusUnauthorized,
+			Text: iris.StatusText(iris.StatusUnauthorized),
+		}
+	}
+	loginUserName := loginUser.(iris.SimpleUser).Username
+	log.Println("管理员", loginUserName, "退出所有设备的登录")
+
+	service.RevokeCurrentToken(c.Db, loginUser.(iris.SimpleUser))
+	service.RevokeAllTokens(c.Db, loginUserName, loginUser.(iris.SimpleUser).Fields["isAdmin"] == true)
+	return mvc.Response{
+		Code: iris.StatusNoContent,
+	}
+}
+
+// PutPassword 管理员修改自己的密码
+// @Summary 管理员修改密码
+// @Description 管理员修改自己的密码,需要提供原密码,修改后所有设备上的登录全部失效,需要重新登录
+// @Tags admin
+// @Accept json
+// @Param change body model.PasswordChange true "原密码和新密码"
+// @Success 204 {object} nil "修改成功，无返回内容"
+// @Failure 400 {object} model.ValidationError "新密码强度不足(原密码错误时返回文本)"
+// @Failure 401 {object} string "未授权，用户未登录或会话失效"
+// @Failure 403 {object} string "不是管理员账号"
+// @Failure 500 {object} string "服务器内部错误"
+// @Router /back/password [put]
+// @Security BearerAuth
+func (c *BackController) PutPassword(change model.PasswordChange) mvc.Result {
+	loginUser, err := c.Ctx.User().GetRaw()
+	if err != nil {
+		return mvc.Response{
+			Code: iris.StatusUnauthorized,
+			Text: iris.StatusText(iris.StatusUnauthorized),
+		}
+	}
+	loginUserName := loginUser.(iris.SimpleUser).Username
+	log.Println("管理员", loginUserName, "修改密码")
+
+	// 被分配角色的普通用户通过 /user [put] 修改密码
+	if loginUser.(iris.SimpleUser).Fields["isAdmin"] != true {
+		return mvc.Response{
+			Code: iris.StatusForbidden,
+			Text: "只有管理员账号可以在此修改密码",
+		}
+	}
+
+	// 验证原密码
+	if ok, _ := service.CheckAdmin(model.Admin{Username: loginUserName, Password: change.OldPassword}, *c.Db); !ok {
+		log.Println("管理员", loginUserName, "原密码错误")
+		return mvc.Response{
+			Code: iris.StatusBadRequest,
+			Text: "原密码错误",
+		}
+	}
+	if errs := service.ValidatePassword("newPassword", change.NewPassword, loginUserName); len(errs) != 0 {
+		return invalidParams(errs)
+	}
+
+	// 写入新密码
+	password, err := bcrypt.GenerateFromPassword([]byte(change.NewPassword), bcrypt.DefaultCost)
+	if err != nil {
+		log.Println("管理员", loginUserName, "密码加密失败")
+		return mvc.Response{
+			Code: iris.StatusInternalServerError,
+			Text: err.Error(),
+		}
+	}
+	c.Db.Model(&model.Admin{}).Where("username=?", loginUserName).Update("password", string(password))
+
+	// 所有设备重新登录
+	service.RevokeAllTokens(c.Db, loginUserName, true)
+	log.Println("管理员", loginUserName, "密码修改成功")
+	return mvc.Response{
+		Code: iris.StatusNoContent,
+	}
 }
 
 // GetUser 获取所有用户
@@ -27,11 +192,15 @@ type BackController struct {
 // @Produce json
 // @Success 200 {array} model.User "用户列表"
 // @Failure 401 {object} string "未授权，用户未登录或会话失效"
+// @Failure 403 {object} string "缺少user:read权限"
 // @Router /back/user [get]
 // @Security BearerAuth
 func (c *BackController) GetUser() mvc.Result {
 	var users []model.User
 	c.Db.Find(&users)
+	for i := range users {
+		users[i].AvatarURIs = service.AvatarURIs(users[i].AvatarURI)
+	}
 
 	return mvc.Response{
 		Code:   iris.StatusOK,
@@ -49,6 +218,7 @@ func (c *BackController) GetUser() mvc.Result {
 // @Success 204 {string} string "封禁成功，无返回内容"
 // @Failure 401 {object} string "未授权，用户未登录或会话失效"
 // @Failure 400 {string} string "用户不存在"
+// @Failure 403 {object} string "缺少user:ban权限"
 // @Router /back/user/ban [post]
 // @Security BearerAuth
 func (c *BackController) PostUserBan(user model.User) mvc.Result {
@@ -90,6 +260,7 @@ func (c *BackController) PostUserBan(user model.User) mvc.Result {
 // @Success 204 {string} string "解除封禁成功，无返回内容"
 // @Failure 401 {object} string "未授权，用户未登录或会话失效"
 // @Failure 400 {string} string "用户不存在"
+// @Failure 403 {object} string "缺少user:ban权限"
 // @Router /back/user/unban [post]
 // @Security BearerAuth
 func (c *BackController) PostUserUnban(user model.User) mvc.Result {
@@ -129,6 +300,7 @@ func (c *BackController) PostUserUnban(user model.User) mvc.Result {
 // @Success 200 {array} model.Image "所有图片对象列表"
 // @Failure 401 {object} string "未授权，用户未登录或会话失效"
 // @Failure 500 {string} string "服务器错误"
+// @Failure 403 {object} string "缺少image:read权限"
 // @Router /back/image [get]
 // @Security BearerAuth
 func (c *BackController) GetImage() mvc.Result {
@@ -171,44 +343,63 @@ func (c *BackController) GetImage() mvc.Result {
 // @Failure 400 {string} string "图片不存在"
 // @Failure 401 {object} string "未授权，用户未登录或会话失效"
 // @Failure 500 {string} string "服务器错误"
+// @Failure 403 {object} string "缺少image:ban权限"
 // @Router /back/image/ban [post]
 // @Security BearerAuth
 func (c *BackController) PostImageBan(image model.Image) mvc.Result {
+	return c.setImageBan(image.ID, true)
+}
+
+// PostImageUnban 解禁图片
+// @Summary 解除图片封禁
+// @Description 解除对该图片的封禁状态(仅id有效)
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param image body model.Image true "图片信息,包含id"
+// @Success 204 "解除封禁成功，无返回内容"
+// @Failure 400 {object} string "请求错误，图片ID无效或图片不存在"
+// @Failure 401 {object} string "未授权，用户未登录或会话失效"
+// @Failure 500 {object} string "服务器内部错误"
+// @Failure 403 {object} string "缺少image:ban权限"
+// @Router /back/image/unban [post]
+// @Security BearerAuth
+func (c *BackController) PostImageUnban(image model.Image) mvc.Result {
+	return c.setImageBan(image.ID, false)
+}
+
+// setImageBan 设置图片的封禁状态并同步到算法层
+// 只修改isBan,以免覆盖后台处理或作者同时修改的其他字段
+func (c *BackController) setImageBan(imageID string, ban bool) mvc.Result {
 	images := c.Mg.Database("PaintingExchange").Collection("Images")
 
-	// 获取原图片对象
-	var prev model.Image
-	filter := bson.D{{"_id", image.ID}}
-	if res := images.FindOne(nil, filter); res.Err() != nil {
-		log.Println("图片", image.ID, "查找失败", res.Err().Error())
+	// 存入数据库
+	filter := bson.D{{"_id", imageID}}
+	if res, err := images.UpdateOne(nil, filter, bson.M{"$set": bson.M{"isBan": ban}}); err != nil {
+		log.Println("图片", imageID, "封禁信息写入失败", err)
+		return mvc.Response{
+			Code: iris.StatusInternalServerError,
+			Text: err.Error(),
+		}
+	} else if res.MatchedCount == 0 {
+		log.Println("图片", imageID, "不存在")
 		return mvc.Response{
 			Code: iris.StatusBadRequest,
 			Text: "图片不存在",
 		}
-	} else {
-		res.Decode(&prev)
 	}
+	log.Println("图片", imageID, "封禁状态设置为", ban)
 
-	// 设置封禁
-	prev.IsBan = true
-
-	// 存入数据库
-	update := bson.D{{"$set", prev}}
-	if _, err := images.UpdateOne(nil, filter, update); err != nil {
-		log.Println("图片", image.ID, "封禁信息写入失败", err)
+	// 修改向量记录
+	var image model.Image
+	if err := images.FindOne(nil, filter).Decode(&image); err != nil {
+		log.Println("图片", imageID, "查找失败", err)
 		return mvc.Response{
 			Code: iris.StatusInternalServerError,
 			Text: err.Error(),
 		}
 	}
-
-	// 修改向量记录
-	if _, err := c.Algo.UpdateImage(context.Background(), &service.Image{
-		Id:    prev.ID,
-		Title: prev.Title,
-		Label: prev.Label,
-		IsBan: prev.IsBan || prev.AuthIsBan,
-	}); err != nil {
+	if _, err := c.Algo.UpdateImage(context.Background(), service.SearchImage(image)); err != nil {
 		return mvc.Response{
 			Code: iris.StatusInternalServerError,
 			Text: err.Error(),
@@ -220,55 +411,186 @@ func (c *BackController) PostImageBan(image model.Image) mvc.Result {
 	}
 }
 
-// PostImageUnban 解禁图片
-// @Summary 解除图片封禁
-// @Description 解除对该图片的封禁状态(仅id有效)
+// PutImageRating 设置图片分级
+// @Summary 设置图片内容分级
+// @Description 管理员修改指定图片的内容分级(仅id,rating和ratingLocked有效),ratingLocked为true时作者不能再修改分级
 // @Tags admin
 // @Accept json
 // @Produce json
-// @Param image body model.Image true "图片信息,包含id"
-// @Success 204 "解除封禁成功，无返回内容"
-// @Failure 400 {object} string "请求错误，图片ID无效或图片不存在"
+// @Param image body model.Image true "图片信息,包含id,rating和ratingLocked"
+// @Success 204 "设置成功，无返回内容"
+// @Failure 400 {object} string "请求错误，图片不存在或分级不合法"
 // @Failure 401 {object} string "未授权，用户未登录或会话失效"
 // @Failure 500 {object} string "服务器内部错误"
-// @Router /back/image/unban [post]
+// @Failure 403 {object} string "缺少image:ban权限"
+// @Router /back/image/rating [put]
 // @Security BearerAuth
-func (c *BackController) PostImageUnban(image model.Image) mvc.Result {
+func (c *BackController) PutImageRating(image model.Image) mvc.Result {
 	images := c.Mg.Database("PaintingExchange").Collection("Images")
 
-	// 获取原图片对象
-	var prev model.Image
+	if !slices.Contains(service.Ratings, image.Rating) {
+		return mvc.Response{
+			Code: iris.StatusBadRequest,
+			Text: "内容分级只能是" + strings.Join(service.Ratings, ","),
+		}
+	}
+
+	// 设置分级
 	filter := bson.D{{"_id", image.ID}}
-	if res := images.FindOne(nil, filter); res.Err() != nil {
-		log.Println("图片", image.ID, "查找失败", res.Err().Error())
+	update := bson.M{"$set": bson.M{"rating": image.Rating, "ratingLocked": image.RatingLocked}}
+	if res, err := images.UpdateOne(nil, filter, update); err != nil {
+		log.Println("图片", image.ID, "分级写入失败", err)
+		return mvc.Response{
+			Code: iris.StatusInternalServerError,
+			Text: err.Error(),
+		}
+	} else if res.MatchedCount == 0 {
+		log.Println("图片", image.ID, "不存在")
 		return mvc.Response{
 			Code: iris.StatusBadRequest,
 			Text: "图片不存在",
 		}
-	} else {
-		res.Decode(&prev)
 	}
+	log.Println("图片", image.ID, "分级设置为", image.Rating, "锁定:", image.RatingLocked)
 
-	// 解除封禁
-	prev.IsBan = false
+	return mvc.Response{
+		Code: iris.StatusNoContent,
+	}
+}
 
-	// 存入数据库
-	update := bson.D{{"$set", prev}}
-	if _, err := images.UpdateOne(nil, filter, update); err != nil {
-		log.Println("图片", image.ID, "封禁信息写入失败", err)
+// GetLockout 获取登录失败记录
+// @Summary 获取登录失败记录
+// @Description 获取按用户名和IP统计的登录失败记录,lockedUntil晚于当前时间的正在被锁定
+// @Tags admin
+// @Produce json
+// @Success 200 {array} model.LoginAttempt "登录失败记录"
+// @Failure 401 {object} string "未授权，用户未登录或会话失效"
+// @Failure 403 {object} string "缺少lockout:read权限"
+// @Router /back/lockout [get]
+// @Security BearerAuth
+func (c *BackController) GetLockout() mvc.Result {
+	return mvc.Response{
+		Code:   iris.StatusOK,
+		Object: c.Guard.List(),
+	}
+}
+
+// DeleteLockout 解除登录锁定
+// @Summary 解除登录锁定
+// @Description 清除指定对象的登录失败记录和锁定(只有key有用)
+// @Tags admin
+// @Accept json
+// @Param attempt body model.LoginAttempt true "登录失败记录,包含key"
+// @Success 204 {object} nil "解除成功，无返回内容"
+// @Failure 400 {object} string "记录不存在"
+// @Failure 401 {object} string "未授权，用户未登录或会话失效"
+// @Failure 403 {object} string "缺少lockout:clear权限"
+// @Router /back/lockout [delete]
+// @Security BearerAuth
+func (c *BackController) DeleteLockout(attempt model.LoginAttempt) mvc.Result {
+	log.Println("解除登录锁定", attempt.Key)
+	if !c.Guard.Clear(attempt.Key) {
 		return mvc.Response{
-			Code: iris.StatusInternalServerError,
-			Text: err.Error(),
+			Code: iris.StatusBadRequest,
+			Text: "记录不存在",
 		}
 	}
 
-	// 修改向量记录
-	if _, err := c.Algo.UpdateImage(context.Background(), &service.Image{
-		Id:    prev.ID,
-		Title: prev.Title,
-		Label: prev.Label,
-		IsBan: prev.IsBan || prev.AuthIsBan,
-	}); err != nil {
+	return mvc.Response{
+		Code: iris.StatusNoContent,
+	}
+}
+
+// GetDuplicate 获取疑似转载记录
+// @Summary 获取疑似转载记录
+// @Description 获取与其他作者更早发布的图片相似的图片,按相似度从高到低排列;确认转载后可通过 /back/image/ban 封禁图片
+// @Tags admin
+// @Produce json
+// @Param status query string false "审核状态(pending,confirmed,dismissed),默认pending,填写all获取全部"
+// @Success 200 {array} model.DuplicateReport "疑似转载记录"
+// @Failure 401 {object} string "未授权，用户未登录或会话失效"
+// @Failure 403 {object} string "缺少image:read权限"
+// @Router /back/duplicate [get]
+// @Security BearerAuth
+func (c *BackController) GetDuplicate() mvc.Result {
+	status := c.Ctx.URLParamDefault("status", service.DuplicatePending)
+	if status == "all" {
+		status = ""
+	}
+
+	return mvc.Response{
+		Code:   iris.StatusOK,
+		Object: c.Duplicates.List(status),
+	}
+}
+
+// PutDuplicate 审核疑似转载记录
+// @Summary 审核疑似转载记录
+// @Description 将疑似转载记录标记为确认转载(confirmed)或不是转载(dismissed)(只有id和status有用)
+// @Tags admin
+// @Accept json
+// @Param report body model.DuplicateReport true "疑似转载记录,包含id和status"
+// @Success 204 {object} nil "审核成功，无返回内容"
+// @Failure 400 {object} string "记录不存在或状态不合法"
+// @Failure 401 {object} string "未授权，用户未登录或会话失效"
+// @Failure 403 {object} string "缺少image:ban权限"
+// @Router /back/duplicate [put]
+// @Security BearerAuth
+func (c *BackController) PutDuplicate(report model.DuplicateReport) mvc.Result {
+	log.Println("审核疑似转载记录", report.ID, report.Status)
+	if report.Status != service.DuplicateConfirmed && report.Status != service.DuplicateDismissed {
+		return mvc.Response{
+			Code: iris.StatusBadRequest,
+			Text: "审核状态只能是confirmed或dismissed",
+		}
+	}
+	if !c.Duplicates.Resolve(report.ID, report.Status) {
+		return mvc.Response{
+			Code: iris.StatusBadRequest,
+			Text: "记录不存在",
+		}
+	}
+
+	return mvc.Response{
+		Code: iris.StatusNoContent,
+	}
+}
+
+// GetFileOrphan 查看无用文件
+// @Summary 查看无用文件
+// @Description 列出图片,头像和原图目录下超过宽限期且没有被任何图片或用户引用的文件,不会删除
+// @Tags admin
+// @Produce json
+// @Success 200 {object} model.FileGCReport "清理报告(dryRun为true)"
+// @Failure 401 {object} string "未授权，用户未登录或会话失效"
+// @Failure 403 {object} string "缺少file:gc权限"
+// @Failure 500 {object} string "服务器内部错误"
+// @Router /back/file/orphan [get]
+// @Security BearerAuth
+func (c *BackController) GetFileOrphan() mvc.Result {
+	return c.runFileGC(true)
+}
+
+// PostFileGc 立即清理无用文件
+// @Summary 立即清理无用文件
+// @Description 删除图片,头像和原图目录下超过宽限期且没有被任何图片或用户引用的文件
+// @Tags admin
+// @Produce json
+// @Success 200 {object} model.FileGCReport "清理报告"
+// @Failure 401 {object} string "未授权，用户未登录或会话失效"
+// @Failure 403 {object} string "缺少file:gc权限"
+// @Failure 500 {object} string "服务器内部错误"
+// @Router /back/file/gc [post]
+// @Security BearerAuth
+func (c *BackController) PostFileGc() mvc.Result {
+	return c.runFileGC(false)
+}
+
+func (c *BackController) runFileGC(dryRun bool) mvc.Result {
+	log.Println("清理无用文件,只报告:", dryRun)
+	report, err := c.GC.Run(dryRun)
+	if err != nil {
+		log.Println("无用文件清理失败", err)
 		return mvc.Response{
 			Code: iris.StatusInternalServerError,
 			Text: err.Error(),
@@ -276,7 +598,8 @@ func (c *BackController) PostImageUnban(image model.Image) mvc.Result {
 	}
 
 	return mvc.Response{
-		Code: iris.StatusNoContent,
+		Code:   iris.StatusOK,
+		Object: report,
 	}
 }
 
@@ -300,12 +623,7 @@ func (c *BackController) changAuthBan(username string, isBan bool) error {
 			go func(cursor *mongo.Cursor) {
 				var image model.Image
 				cursor.Decode(&image)
-				if _, err := c.Algo.UpdateImage(context.Background(), &service.Image{
-					Id:    image.ID,
-					Title: image.Title,
-					Label: image.Label,
-					IsBan: image.IsBan || image.AuthIsBan,
-				}); err != nil {
+				if _, err := c.Algo.UpdateImage(context.Background(), service.SearchImage(image)); err != nil {
 				}
 			}(cursor)
 		}
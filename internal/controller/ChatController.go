@@ -2,6 +2,7 @@ package controller
 
 import (
 	"PaintingExchange/internal/model"
+	"PaintingExchange/internal/service"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -28,13 +29,20 @@ var online = sync.Map{}
 // wlock 连接写互斥锁
 var wlock = sync.Map{}
 
+// sessionConns 登录会话id到websocket连接的映射,会话注销时断开连接
+var sessionConns = sync.Map{}
+
+func init() {
+	service.OnSessionRevoked(closeSessionConn)
+}
+
 // HandleWebsocket websocket服务端
 // @Summary websocket服务端(无法在swagger中测试)
 // @Description 通过此端点建立 WebSocket 连接。连接后，进行实时聊天交流。
 // @Tags chat
 // @Accept json
 // @Produce json
-// @Param Sec-WebSocket-Protocol header string true "子协议填写JWT(不需要Bearer),用于身份验证"
+// @Param Sec-WebSocket-Protocol header string true "子协议填写JWT或API令牌(不需要Bearer),用于身份验证;API令牌需要chat:read授权,发送消息还需要chat:write"
 // @Success 101 {string} string "WebSocket 连接建立成功"
 // @Success 200 {object} model.Message
 // @Failure 401 {object} string "未授权，JWT无效或已过期"
@@ -52,6 +60,8 @@ func HandleWebsocket(ctx iris.Context) {
 		}
 		user, _ := ctx.User().GetRaw()
 		username := user.(iris.SimpleUser).Username
+		canSend := service.HasScope(user.(iris.SimpleUser), service.ScopeChatWrite)
+		sid, _ := user.(iris.SimpleUser).Fields["sid"].(string)
 		if prev, ok := online.Load(username); ok {
 			log.Println("用户", username, "重复登录")
 			prev.(*websocket.Conn).Close()
@@ -60,6 +70,9 @@ func HandleWebsocket(ctx iris.Context) {
 		log.Println("用户", username, "连接ws聊天室成功")
 		online.Store(username, conn)
 		wlock.Store(username, &sync.Mutex{})
+		if sid != "" {
+			sessionConns.Store(sid, conn)
+		}
 
 		// 历史聊天记录
 		//db.Raw("SELECT * FROM (SELECT *, ROW_NUMBER() OVER (PARTITION BY `from` ORDER BY time DESC) AS rn FROM messages WHERE `to` = ?) AS subq WHERE rn <= 10", username).Scan(&initMess)
@@ -102,6 +115,9 @@ func HandleWebsocket(ctx iris.Context) {
 
 				if t == -1 {
 					conn.Close()
+					if sid != "" {
+						sessionConns.CompareAndDelete(sid, conn)
+					}
 					break
 				}
 
@@ -110,6 +126,11 @@ func HandleWebsocket(ctx iris.Context) {
 					continue
 				}
 
+				if !canSend {
+					log.Println("用户", username, "的API令牌没有chat:write授权,忽略发送的消息")
+					continue
+				}
+
 				var messobj model.Message
 				if err = json.Unmarshal(message, &messobj); err != nil {
 					log.Println("json消息解析失败", err)
@@ -131,6 +152,25 @@ func HandleWebsocket(ctx iris.Context) {
 	}
 }
 
+// closeSessionConn 断开登录会话的websocket连接
+func closeSessionConn(sid string) {
+	connA, ok := sessionConns.LoadAndDelete(sid)
+	if !ok {
+		return
+	}
+	conn := connA.(*websocket.Conn)
+	online.Range(func(username, value interface{}) bool {
+		if value == conn {
+			log.Println("用户", username, "的会话已注销,断开websocket连接")
+			online.CompareAndDelete(username, conn)
+			wlock.Delete(username)
+			return false
+		}
+		return true
+	})
+	conn.Close()
+}
+
 // sendMessage 发送聊天记录
 func sendMessage(message model.Message) error {
 	return sendHistoryMessage(message, message.To)
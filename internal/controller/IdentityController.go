@@ -0,0 +1,210 @@
+package controller
+
+import (
+	"PaintingExchange/internal/model"
+	"PaintingExchange/internal/service"
+	"errors"
+	"github.com/kataras/iris/v12"
+	"github.com/kataras/iris/v12/mvc"
+	"gorm.io/gorm"
+	"log"
+)
+
+// IdentityController 外部身份关联管理控制器
+type IdentityController struct {
+	Ctx iris.Context
+	Db  *gorm.DB
+}
+
+// Get 获取关联的外部身份
+// @Summary 获取关联的外部身份
+// @Description 列出用户关联的第三方登录身份
+// @Tags oidc
+// @Produce json
+// @Success 200 {array} model.Identity "外部身份列表"
+// @Failure 401 {object} string "未授权，用户未登录或会话失效"
+// @Failure 403 {object} string "管理员账号或API令牌不能管理外部身份"
+// @Router /user/identities [get]
+// @Security BearerAuth
+func (c *IdentityController) Get() mvc.Result {
+	username, ok := c.account()
+	if !ok {
+		return mvc.Response{
+			Code: iris.StatusForbidden,
+			Text: iris.StatusText(iris.StatusForbidden),
+		}
+	}
+	log.Println("用户", username, "查询关联的外部身份")
+
+	return mvc.Response{
+		Code:   iris.StatusOK,
+		Object: service.ListIdentities(c.Db, username),
+	}
+}
+
+// PostLinkBy 发起关联外部身份
+// @Summary 发起关联外部身份
+// @Description 由已登录的用户发起关联流程,返回身份提供方的授权地址,同时在 /oidc/{provider} 下设置保存流程状态的cookie.
+// @Description 前端将浏览器跳转到返回的url,验证完成后跳转地址中带linkToken,只能由当前用户提交到 /user/identities 完成关联
+// @Tags oidc
+// @Produce json
+// @Param provider path string true "身份提供方名称"
+// @Success 200 {object} model.IdentityLinkStart "授权地址"
+// @Failure 401 {object} string "未授权，用户未登录或会话失效"
+// @Failure 403 {object} string "管理员账号或API令牌不能管理外部身份"
+// @Failure 404 {object} string "身份提供方不存在"
+// @Failure 500 {object} string "未配置回调地址"
+// @Failure 502 {object} string "无法连接身份提供方"
+// @Router /user/identities/link/{provider} [post]
+// @Security BearerAuth
+func (c *IdentityController) PostLinkBy(provider string) mvc.Result {
+	username, ok := c.account()
+	if !ok {
+		return mvc.Response{
+			Code: iris.StatusForbidden,
+			Text: iris.StatusText(iris.StatusForbidden),
+		}
+	}
+	log.Println("用户", username, "发起关联外部身份", provider)
+
+	authURL, flowToken, err := service.BeginOIDCLogin(provider, "link", username)
+	if errors.Is(err, service.ErrOIDCProviderNotFound) {
+		return mvc.Response{
+			Code: iris.StatusNotFound,
+			Text: err.Error(),
+		}
+	} else if errors.Is(err, service.ErrOIDCRedirectURL) {
+		log.Println("用户", username, "发起关联外部身份失败", err)
+		return mvc.Response{
+			Code: iris.StatusInternalServerError,
+			Text: err.Error(),
+		}
+	} else if err != nil {
+		log.Println("用户", username, "发起关联外部身份失败", err)
+		return mvc.Response{
+			Code: iris.StatusBadGateway,
+			Text: err.Error(),
+		}
+	}
+
+	setOIDCFlowCookie(c.Ctx, provider, flowToken)
+	return mvc.Response{
+		Code:   iris.StatusOK,
+		Object: model.IdentityLinkStart{URL: authURL},
+	}
+}
+
+// Post 关联外部身份
+// @Summary 关联外部身份
+// @Description 提交 /user/identities/link/{provider} 发起的流程完成后得到的linkToken,将外部身份关联到当前用户;令牌只能由发起关联的用户提交
+// @Tags oidc
+// @Accept json
+// @Produce json
+// @Param link body model.IdentityLink true "关联令牌"
+// @Success 201 {object} model.Identity "关联的外部身份"
+// @Failure 401 {object} string "未授权或关联令牌无效"
+// @Failure 403 {object} string "管理员账号或API令牌不能管理外部身份,或关联令牌不属于当前用户"
+// @Failure 409 {object} string "该外部身份已关联其他用户"
+// @Failure 500 {object} string "服务器内部错误"
+// @Router /user/identities [post]
+// @Security BearerAuth
+func (c *IdentityController) Post(link model.IdentityLink) mvc.Result {
+	username, ok := c.account()
+	if !ok {
+		return mvc.Response{
+			Code: iris.StatusForbidden,
+			Text: iris.StatusText(iris.StatusForbidden),
+		}
+	}
+	log.Println("用户", username, "关联外部身份")
+
+	identity, err := service.LinkIdentity(c.Db, username, link.LinkToken)
+	if errors.Is(err, service.ErrPurposeTokenInvalid) {
+		return mvc.Response{
+			Code: iris.StatusUnauthorized,
+			Text: err.Error(),
+		}
+	} else if errors.Is(err, service.ErrIdentityLinkUser) {
+		log.Println("关联令牌不属于用户", username)
+		return mvc.Response{
+			Code: iris.StatusForbidden,
+			Text: err.Error(),
+		}
+	} else if errors.Is(err, service.ErrIdentityLinked) {
+		log.Println("外部身份已关联其他用户")
+		return mvc.Response{
+			Code: iris.StatusConflict,
+			Text: err.Error(),
+		}
+	} else if err != nil {
+		log.Println("用户", username, "关联外部身份失败", err)
+		return mvc.Response{
+			Code: iris.StatusInternalServerError,
+			Text: err.Error(),
+		}
+	}
+
+	return mvc.Response{
+		Code:   iris.StatusCreated,
+		Object: identity,
+	}
+}
+
+// DeleteBy 解除外部身份关联
+// @Summary 解除外部身份关联
+// @Description 解除指定的外部身份关联;邮箱未验证时不能解除最后一个关联,否则将无法登录
+// @Tags oidc
+// @Param id path int true "关联id"
+// @Success 204 {object} nil "解除成功，无返回内容"
+// @Failure 400 {object} string "这是唯一的登录方式"
+// @Failure 401 {object} string "未授权，用户未登录或会话失效"
+// @Failure 403 {object} string "管理员账号或API令牌不能管理外部身份"
+// @Failure 404 {object} string "关联的外部身份不存在"
+// @Router /user/identities/{id} [delete]
+// @Security BearerAuth
+func (c *IdentityController) DeleteBy(id uint) mvc.Result {
+	username, ok := c.account()
+	if !ok {
+		return mvc.Response{
+			Code: iris.StatusForbidden,
+			Text: iris.StatusText(iris.StatusForbidden),
+		}
+	}
+	log.Println("用户", username, "解除外部身份关联", id)
+
+	err := service.UnlinkIdentity(c.Db, username, id)
+	if errors.Is(err, service.ErrIdentityNotFound) {
+		return mvc.Response{
+			Code: iris.StatusNotFound,
+			Text: err.Error(),
+		}
+	} else if errors.Is(err, service.ErrIdentityLastLogin) {
+		return mvc.Response{
+			Code: iris.StatusBadRequest,
+			Text: err.Error(),
+		}
+	} else if err != nil {
+		return mvc.Response{
+			Code: iris.StatusInternalServerError,
+			Text: err.Error(),
+		}
+	}
+
+	return mvc.Response{
+		Code: iris.StatusNoContent,
+	}
+}
+
+// account 获取当前登录的普通用户,管理员和API令牌不能管理外部身份
+func (c *IdentityController) account() (string, bool) {
+	loginUser, err := c.Ctx.User().GetRaw()
+	if err != nil {
+		return "", false
+	}
+	user := loginUser.(iris.SimpleUser)
+	if isAdmin, _ := user.Fields["isAdmin"].(bool); isAdmin || service.IsAPITokenUser(user) {
+		log.Println(user.Username, "不能管理外部身份")
+		return "", false
+	}
+	return user.Username, true
+}
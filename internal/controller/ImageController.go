@@ -4,19 +4,21 @@ import (
 	"PaintingExchange/internal/env"
 	"PaintingExchange/internal/model"
 	"PaintingExchange/internal/service"
+	"bytes"
 	"context"
+	"errors"
+	"fmt"
 	"github.com/google/uuid"
 	"github.com/kataras/iris/v12"
 	"github.com/kataras/iris/v12/mvc"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
-	"gocv.io/x/gocv"
 	"golang.org/x/sync/errgroup"
 	"gorm.io/gorm"
 	"log"
-	"os"
 	"path/filepath"
+	"slices"
 	"sort"
 	"strings"
 	"sync"
@@ -25,15 +27,20 @@ import (
 
 // ImageController 用户相关操作控制器
 type ImageController struct {
-	Ctx  iris.Context
-	Db   *gorm.DB
-	Mg   *mongo.Client
-	Algo service.SearchServiceClient
+	Ctx        iris.Context
+	Db         *gorm.DB
+	Mg         *mongo.Client
+	Algo       service.SearchServiceClient
+	Queue      *service.ImageQueue
+	Storage    service.Storage
+	Duplicates *service.DuplicateDetector
+	Timelapse  *service.TimelapseRenderer
 }
 
 // GetBy 获取图片对象
 // @Summary 获取指定ID的图片对象
-// @Description 根据提供的图片ID，查找并返回该图片对象
+// @Description 根据提供的图片ID，查找并返回该图片对象.不公开列出的图片知道id即可查看,仅关注者可见的需要关注作者,私密的只有作者可以查看;
+// @Description 成人内容(nsfw)在用户的显示偏好不为show时隐去图片地址和简介(redacted为true)
 // @Tags image
 // @Accept json
 // @Produce json
@@ -68,6 +75,19 @@ func (c *ImageController) GetBy(imageID string) mvc.Result {
 			}
 		}
 
+		// 验证可见性,无权查看时与不存在相同
+		viewer := currentUsername(c.Ctx)
+		if !service.CanViewImage(c.Db, image, viewer) {
+			log.Println("图片", imageID, "不可见")
+			return mvc.Response{
+				Code: iris.StatusBadRequest,
+				Text: "图片不存在",
+			}
+		}
+
+		// 按分级偏好隐去成人内容
+		service.FilterRating(&image, viewer, service.UserRatingFilter(c.Db, viewer), false)
+
 		return mvc.Response{
 			Code:   iris.StatusOK,
 			Object: image,
@@ -78,13 +98,16 @@ func (c *ImageController) GetBy(imageID string) mvc.Result {
 // Post 创建图片(元信息)
 // @Summary 创建图片元信息
 // @Description 创建图片对象,需要先调用 /image/file [POST] 接口,获取到图片对象(包括图片id,作者用户名以及图片各个大小的地址),然后将其他元数据补充入此对象,再请求
+// @Description 多页作品(漫画,设定图,草稿到成稿等)依次上传各页文件,将返回的id,bigURI和midURI按顺序填入pages,可用cover指定封面页;
+// @Description 作品id为第一页的id,标题,简介,标签,收藏和搜索都以作品为单位
 // @Tags image
 // @Accept json
 // @Produce json
 // @Param image body model.Image true "图片对象,在/image/file [POST] 接口的返回值上补充元数据所得"
 // @Success 201 {object} model.Image "图片对象"
-// @Failure 400 {object} string "请求数据异常"
+// @Failure 400 {object} model.ValidationError "标题,简介或标签不合法(其他请求数据异常,或拒绝疑似转载时图片还在处理中时返回文本)"
 // @Failure 401 {object} string "未授权，用户未登录或会话失效"
+// @Failure 409 {array} model.DuplicateReport "与其他作者已发布的图片过于相似(仅在拒绝疑似转载时)"
 // @Failure 500 {object} string "服务器内部错误"
 // @Router /image [post]
 // @Security BearerAuth
@@ -109,26 +132,72 @@ func (c *ImageController) Post(image model.Image) mvc.Result {
 		}
 	}
 
-	// 验证图片元数据是否已经存在
-	log.Println("尝试查询图片,以验证是否重复创建(下一行的查找错误为正常)")
-	if c.GetBy(image.ID).(mvc.Response).Code != iris.StatusBadRequest {
-		log.Println("重复创建图片")
+	// 校验图片信息
+	if errs := service.ValidateImage(image); len(errs) != 0 {
+		return invalidParams(errs)
+	}
+
+	// 只有一页时可以不填pages,作品id为第一页的文件id
+	if len(image.Pages) == 0 {
+		image.Pages = []model.ImagePage{{ID: image.ID, BigURI: image.BigURI, MidURI: image.MidURI}}
+	}
+	image.ID = image.Pages[0].ID
+	pageIDs := make([]string, 0, len(image.Pages))
+	for _, page := range image.Pages {
+		if slices.Contains(pageIDs, page.ID) {
+			return mvc.Response{
+				Code: iris.StatusBadRequest,
+				Text: "作品中有重复的页面",
+			}
+		}
+		pageIDs = append(pageIDs, page.ID)
+	}
+	if image.Cover != "" && !slices.Contains(pageIDs, image.Cover) {
 		return mvc.Response{
 			Code: iris.StatusBadRequest,
-			Text: "图片信息已存在",
+			Text: service.ErrPageCover.Error(),
 		}
 	}
 
-	// 验证文件名
-	if !checkImageFile(image.BigURI, image.ID, "big") || !checkImageFile(image.MidURI, image.ID, "mid") {
-		log.Println("图片id或路径异常")
+	// 验证各页是否已经属于其他作品
+	filter := bson.M{"$or": bson.A{bson.M{"_id": bson.M{"$in": pageIDs}}, bson.M{"pages.id": bson.M{"$in": pageIDs}}}}
+	if count, err := images.CountDocuments(nil, filter); err != nil {
+		log.Println("图片查询失败", err)
+		return mvc.Response{
+			Code: iris.StatusInternalServerError,
+			Text: err.Error(),
+		}
+	} else if count != 0 {
+		log.Println("重复创建图片")
 		return mvc.Response{
 			Code: iris.StatusBadRequest,
-			Text: "请求数据中id或图片路径信息异常",
+			Text: "图片信息已存在",
 		}
 	}
 
-	// 创建图片
+	// 验证各页的文件
+	var queued []string
+	for i := range image.Pages {
+		ok, res := c.checkPage(image.ID, loginUserName, &image.Pages[i])
+		if res != nil {
+			return res
+		}
+		if ok {
+			queued = append(queued, image.Pages[i].ID)
+		}
+	}
+	service.ApplyCover(&image)
+
+	// 创建图片,阶段图片和延时视频需要创建后另行上传
+	image.PublishVisibility = ""
+	image.PublishedAt = nil
+	service.NormalizeVisibility(&image)
+	if image.Rating == "" {
+		image.Rating = service.RatingSFW
+	}
+	image.RatingLocked = false
+	image.Stages = nil
+	image.Timelapse = nil
 	image.Like = 0
 	image.CreatedAt = time.Now()
 	if _, err := images.InsertOne(nil, &image); err != nil {
@@ -139,14 +208,13 @@ func (c *ImageController) Post(image model.Image) mvc.Result {
 		}
 	}
 	log.Println("图片创建成功")
+	for _, id := range queued {
+		// 创建期间可能已处理完成,同时加入疑似转载检测
+		c.Queue.SyncImageStatus(id)
+	}
 
 	// 调用算法层向量化
-	_, err = c.Algo.CreateImage(context.Background(), &service.Image{
-		Id:    image.ID,
-		Title: image.Title,
-		Label: image.Label,
-		IsBan: image.IsBan || image.AuthIsBan,
-	})
+	_, err = c.Algo.CreateImage(context.Background(), service.SearchImage(image))
 	if err != nil {
 		log.Println("算法层gRPC调用失败", err)
 		return mvc.Response{
@@ -163,14 +231,19 @@ func (c *ImageController) Post(image model.Image) mvc.Result {
 
 // PostFile 上传图片文件
 // @Summary 上传图片文件
-// @Description 上传图片文件，返回图片对象(包括图片id,作者用户名和地址).
+// @Description 上传图片文件，返回图片对象(包括图片id,作者用户名和地址).各尺寸的图片在后台生成,
+// @Description 返回时status为pending,可通过 /image/file/{imageID} [GET] 查询处理状态,变为ready后图片地址才可访问
 // @Tags image
 // @Accept multipart/form-data
 // @Produce json
 // @Param image formData file true "图片文件"
-// @Success 201 {object} model.Image "图片上传成功，返回图片对象"
+// @Success 202 {object} model.Image "图片上传成功，返回图片对象"
+// @Failure 400 {object} string "图片尺寸超过限制"
 // @Failure 401 {object} string "未授权，用户未登录或会话失效"
+// @Failure 413 {object} string "文件超过大小限制"
+// @Failure 415 {object} string "不是JPEG,PNG,WebP或GIF格式的图片"
 // @Failure 500 {object} string "服务器内部错误"
+// @Failure 503 {object} string "图片处理队列已满"
 // @Router /image/file [post]
 // @Security BearerAuth
 func (c *ImageController) PostFile() mvc.Result {
@@ -184,65 +257,81 @@ func (c *ImageController) PostFile() mvc.Result {
 	}
 	loginUserName := loginUser.(iris.SimpleUser).Username
 
-	// 读取图片
+	// 读取并检查图片
 	log.Println(loginUserName, "上传图片文件")
-	file, info, err := c.Ctx.FormFile("image")
-	if err != nil {
-		log.Println("图片文件上传失败", err)
-		return mvc.Response{
-			Code: iris.StatusInternalServerError,
-			Text: err.Error(),
-		}
-	}
-	img, err := service.FileToMat(file)
-	if err != nil {
-		log.Println("图片文件读取失败", err)
-		return mvc.Response{
-			Code: iris.StatusInternalServerError,
-			Text: err.Error(),
-		}
+	upload, res := readUpload(c.Ctx, "image")
+	if res != nil {
+		return res
 	}
 
-	// 生成图片id
-	imageID := uuid.New().String()
-
-	originalWidth := img.Cols()
-	originalHeight := img.Rows()
-
-	// 保存大尺寸图片
-	bigImg := service.ResizeImage(img, originalWidth, originalHeight, service.BigSize)
-	bigURI := filepath.Join("./assert/images", "big_"+imageID+filepath.Ext(info.Filename))
-	gocv.IMWrite(bigURI, bigImg)
-
-	// 保存中尺寸图片
-	midImg := service.ResizeImage(img, originalWidth, originalHeight, service.MidSize)
-	midURI := filepath.Join("./assert/images", "mid_"+imageID+filepath.Ext(info.Filename))
-	gocv.IMWrite(midURI, midImg)
-	log.Println("图片文件保存成功")
+	// 保存并提交后台处理
+	job, res := c.submitUpload(loginUserName, upload)
+	if res != nil {
+		return res
+	}
+	log.Println("图片文件保存成功,等待处理")
 
 	// 封装成对象返回
 	var image model.Image
-	image.ID = imageID
+	image.ID = job.ID
 	image.Auth = loginUserName
-	image.BigURI = bigURI
-	image.MidURI = midURI
+	image.BigURI = job.BigURI
+	image.MidURI = job.MidURI
+	image.Status = service.ImageStatusPending
 	return mvc.Response{
-		Code:   iris.StatusCreated,
+		Code:   iris.StatusAccepted,
 		Object: image,
 	}
 }
 
+// GetFileBy 查询图片处理状态
+// @Summary 查询图片处理状态
+// @Description 查询自己上传的图片文件的处理状态(pending,ready,failed),临时错误会自动重试
+// @Tags image
+// @Produce json
+// @Param imageID path string true "图片ID"
+// @Success 200 {object} model.ImageJob "图片处理任务"
+// @Failure 401 {object} string "未授权，用户未登录或会话失效"
+// @Failure 404 {object} string "处理任务不存在"
+// @Router /image/file/{imageID} [get]
+// @Security BearerAuth
+func (c *ImageController) GetFileBy(imageID string) mvc.Result {
+	// 获取用户名
+	loginUser, err := c.Ctx.User().GetRaw()
+	if err != nil {
+		return mvc.Response{
+			Code: iris.StatusUnauthorized,
+			Text: iris.StatusText(iris.StatusUnauthorized),
+		}
+	}
+	loginUserName := loginUser.(iris.SimpleUser).Username
+
+	job, ok := c.Queue.Job(imageID)
+	if !ok || job.Username != loginUserName {
+		return mvc.Response{
+			Code: iris.StatusNotFound,
+			Text: "处理任务不存在",
+		}
+	}
+	return mvc.Response{
+		Code:   iris.StatusOK,
+		Object: job,
+	}
+}
+
 // Put 修改图片
 // @Summary 修改图片信息
-// @Description 修改自己上传的图片信息(仅标题,简介和标签允许修改,其他均以数据库已有信息为准)
+// @Description 修改自己上传的图片信息(仅标题,简介,标签,可见性,定时发布时间和内容分级允许修改,其他均以数据库已有信息为准),替换图片文件请使用 /image/{imageID}/file [PUT];
+// @Description 可见性,定时发布时间和内容分级为空时保持不变;取消定时发布需要设置cancelPublish为true,取消后保持私密;内容分级被管理员锁定后不能修改.
+// @Description 定时发布期间作品为私密,此时设置的visibility保存为publishVisibility,到达发布时间后改为该可见性
 // @Tags image
 // @Accept json
 // @Produce json
 // @Param image body model.Image true "图片信息"
 // @Success 201 {object} model.Image "图片信息更新成功，返回更新后的图片信息"
-// @Failure 400 {object} string "请求数据异常"
+// @Failure 400 {object} model.ValidationError "标题,简介或标签不合法(其他请求数据异常时返回文本)"
 // @Failure 401 {object} string "未授权，用户未登录或会话失效"
-// @Failure 403 {object} string "图片被封禁"
+// @Failure 403 {object} string "图片被封禁或分级已被管理员锁定"
 // @Failure 500 {object} string "服务器内部错误"
 // @Router /image [put]
 // @Security BearerAuth
@@ -260,6 +349,11 @@ func (c *ImageController) Put(image model.Image) mvc.Result {
 	loginUserName := loginUser.(iris.SimpleUser).Username
 	log.Println("用户", loginUserName, "修改图片", image.ID)
 
+	// 校验图片信息
+	if errs := service.ValidateImage(image); len(errs) != 0 {
+		return invalidParams(errs)
+	}
+
 	// 查询原图片对象
 	prevImageRes := c.GetBy(image.ID).(mvc.Response)
 	if prevImageRes.Code == iris.StatusBadRequest {
@@ -290,8 +384,49 @@ func (c *ImageController) Put(image model.Image) mvc.Result {
 	prevImage.Title = image.Title
 	prevImage.Intro = image.Intro
 	prevImage.Label = image.Label
+	// 可见性和定时发布时间为空时保持不变,只有明确要求时才取消定时发布
+	if image.CancelPublish {
+		prevImage.PublishAt = nil
+	} else if image.PublishAt != nil {
+		prevImage.PublishAt = image.PublishAt
+	}
+	// 定时发布期间设置的可见性为发布后的可见性
+	if image.Visibility != "" && prevImage.PublishAt != nil {
+		prevImage.PublishVisibility = image.Visibility
+	} else if image.Visibility != "" {
+		prevImage.Visibility = image.Visibility
+	}
+	service.NormalizeVisibility(&prevImage)
+	// 分级为空时保持不变,管理员锁定后不能修改
+	if image.Rating != "" && image.Rating != prevImage.Rating {
+		if prevImage.RatingLocked {
+			log.Println("图片", prevImage.ID, "分级已被管理员锁定")
+			return mvc.Response{
+				Code: iris.StatusForbidden,
+				Text: "分级已由管理员设置,不能修改",
+			}
+		}
+		prevImage.Rating = image.Rating
+	}
+	// 只更新可修改的字段,以免覆盖后台处理同时写入的各页信息
 	filter := bson.D{{"_id", prevImage.ID}}
-	update := bson.D{{"$set", prevImage}}
+	set := bson.M{
+		"title":      prevImage.Title,
+		"intro":      prevImage.Intro,
+		"label":      prevImage.Label,
+		"visibility": prevImage.Visibility,
+		"rating":     prevImage.Rating,
+	}
+	update := bson.M{"$set": set}
+	if prevImage.PublishAt != nil {
+		set["publishAt"] = prevImage.PublishAt
+		set["publishVisibility"] = prevImage.PublishVisibility
+	} else {
+		update["$unset"] = bson.M{"publishAt": "", "publishVisibility": ""}
+	}
+	if prevImage.PublishedAt != nil {
+		set["publishedAt"] = prevImage.PublishedAt
+	}
 	if _, err := images.UpdateOne(nil, filter, update); err != nil {
 		log.Println("图片更新失败", err)
 		return mvc.Response{
@@ -302,12 +437,7 @@ func (c *ImageController) Put(image model.Image) mvc.Result {
 	log.Println("图片更新成功")
 
 	// 调用算法层向量化
-	c.Algo.UpdateImage(context.Background(), &service.Image{
-		Id:    image.ID,
-		Title: image.Title,
-		Label: image.Label,
-		IsBan: image.IsBan || image.AuthIsBan,
-	})
+	c.Algo.UpdateImage(context.Background(), service.SearchImage(prevImage))
 
 	return mvc.Response{
 		Code:   iris.StatusCreated,
@@ -316,6 +446,366 @@ func (c *ImageController) Put(image model.Image) mvc.Result {
 
 }
 
+// PutByPages 调整作品的页面顺序和封面
+// @Summary 调整作品的页面顺序和封面
+// @Description 按给定顺序重新排列自己作品的各页,需要包含全部页面id;封面为空时使用第一页
+// @Tags image
+// @Accept json
+// @Produce json
+// @Param imageID path string true "图片ID"
+// @Param order body model.PageOrder true "页面顺序和封面"
+// @Success 200 {object} model.Image "调整后的图片对象"
+// @Failure 400 {object} string "图片不存在,非本人上传或页面顺序不合法"
+// @Failure 401 {object} string "未授权，用户未登录或会话失效"
+// @Failure 403 {object} string "图片被封禁"
+// @Failure 500 {object} string "服务器内部错误"
+// @Router /image/{imageID}/pages [put]
+// @Security BearerAuth
+func (c *ImageController) PutByPages(imageID string, order model.PageOrder) mvc.Result {
+	log.Println("调整作品", imageID, "的页面顺序")
+	if _, res := c.ownImage(imageID); res != nil {
+		return res
+	}
+
+	// 调整顺序
+	image, err := c.Queue.UpdatePages(bson.D{{"_id", imageID}}, func(image *model.Image) error {
+		return service.ReorderPages(image, order)
+	})
+	if err != nil {
+		log.Println("页面顺序更新失败", err)
+		return pagesError(err)
+	}
+	log.Println("页面顺序更新成功")
+
+	return mvc.Response{
+		Code:   iris.StatusOK,
+		Object: image,
+	}
+}
+
+// PutByFile 上传新版本文件
+// @Summary 上传新版本文件
+// @Description 为自己作品中的一页上传新版本的文件(如修改笔误),收藏,点赞和搜索记录都保留.新文件在后台处理,
+// @Description 处理完成后才替换当前文件,原文件保存为历史版本(每页最多保留10个),可通过 /image/{imageID}/revision 查看和回滚.
+// @Description 替换后该页的id变为新文件的id,作品id不变.拒绝疑似转载时,新文件与其他作者已发布的图片相似则不替换,处理任务改为失败
+// @Tags image
+// @Accept multipart/form-data
+// @Produce json
+// @Param imageID path string true "图片ID"
+// @Param image formData file true "新版本的图片文件"
+// @Param page formData string false "要替换的页面id,默认为封面页"
+// @Success 202 {object} model.PageHistory "该页的当前版本,新版本的处理任务id和历史版本"
+// @Failure 400 {object} string "图片或页面不存在,或非本人上传"
+// @Failure 401 {object} string "未授权，用户未登录或会话失效"
+// @Failure 403 {object} string "图片被封禁"
+// @Failure 413 {object} string "文件超过大小限制"
+// @Failure 415 {object} string "不是JPEG,PNG,WebP或GIF格式的图片"
+// @Failure 500 {object} string "服务器内部错误"
+// @Failure 503 {object} string "图片处理队列已满"
+// @Router /image/{imageID}/file [put]
+// @Security BearerAuth
+func (c *ImageController) PutByFile(imageID string) mvc.Result {
+	log.Println("作品", imageID, "上传新版本文件")
+	prevImage, res := c.ownImage(imageID)
+	if res != nil {
+		return res
+	}
+	pageID := c.Ctx.FormValueDefault("page", prevImage.Cover)
+	if pageID == "" {
+		pageID = prevImage.ID
+	}
+	if !slices.ContainsFunc(service.ImagePages(prevImage), func(page model.ImagePage) bool {
+		return page.ID == pageID
+	}) {
+		return pagesError(service.ErrPageNotExist)
+	}
+
+	// 读取并检查图片
+	upload, res := readUpload(c.Ctx, "image")
+	if res != nil {
+		return res
+	}
+
+	// 保存并提交后台处理
+	job, res := c.submitUpload(prevImage.Auth, upload)
+	if res != nil {
+		return res
+	}
+
+	// 记录正在处理的新版本
+	var history model.PageHistory
+	image, err := c.Queue.UpdatePages(bson.D{{"_id", imageID}}, func(image *model.Image) error {
+		for i, page := range image.Pages {
+			if page.ID == pageID {
+				image.Pages[i].Replacing = job.ID
+				history = service.PageHistory(image.Pages[i])
+				return nil
+			}
+		}
+		return service.ErrPageNotExist
+	})
+	if err != nil {
+		log.Println("新版本文件保存失败", err)
+		return pagesError(err)
+	}
+	// 保存期间可能已处理完成
+	c.Queue.SyncImageStatus(job.ID)
+	log.Println("作品", image.ID, "新版本文件保存成功,等待处理")
+
+	return mvc.Response{
+		Code:   iris.StatusAccepted,
+		Object: history,
+	}
+}
+
+// GetByRevision 获取历史版本
+// @Summary 获取历史版本
+// @Description 获取自己作品各页的当前版本,正在处理的新版本和历史版本
+// @Tags image
+// @Produce json
+// @Param imageID path string true "图片ID"
+// @Success 200 {array} model.PageHistory "各页的版本,按页面顺序排列"
+// @Failure 400 {object} string "图片不存在或非本人上传"
+// @Failure 401 {object} string "未授权，用户未登录或会话失效"
+// @Failure 403 {object} string "图片被封禁"
+// @Router /image/{imageID}/revision [get]
+// @Security BearerAuth
+func (c *ImageController) GetByRevision(imageID string) mvc.Result {
+	prevImage, res := c.ownImage(imageID)
+	if res != nil {
+		return res
+	}
+
+	return mvc.Response{
+		Code:   iris.StatusOK,
+		Object: service.PageHistories(prevImage),
+	}
+}
+
+// PutByRevision 回滚到历史版本
+// @Summary 回滚到历史版本
+// @Description 将自己作品中的一页回滚到历史版本,当前版本保存为历史版本;回滚后该页的id变为历史版本的id
+// @Tags image
+// @Accept json
+// @Produce json
+// @Param imageID path string true "图片ID"
+// @Param rollback body model.RevisionRollback true "页面id和历史版本id"
+// @Success 200 {object} model.Image "回滚后的图片对象"
+// @Failure 400 {object} string "图片,页面或历史版本不存在,或非本人上传"
+// @Failure 401 {object} string "未授权，用户未登录或会话失效"
+// @Failure 403 {object} string "图片被封禁"
+// @Failure 500 {object} string "服务器内部错误"
+// @Router /image/{imageID}/revision [put]
+// @Security BearerAuth
+func (c *ImageController) PutByRevision(imageID string, rollback model.RevisionRollback) mvc.Result {
+	log.Println("作品", imageID, "的页面", rollback.Page, "回滚到", rollback.Revision)
+	if _, res := c.ownImage(imageID); res != nil {
+		return res
+	}
+
+	var dropped []model.ImageRevision
+	image, err := c.Queue.UpdatePages(bson.D{{"_id", imageID}}, func(image *model.Image) (err error) {
+		dropped, err = service.RollbackPage(image, rollback.Page, rollback.Revision)
+		return err
+	})
+	if err != nil {
+		log.Println("回滚失败", err)
+		return pagesError(err)
+	}
+	for _, revision := range dropped {
+		service.DeletePageFiles(c.Db, c.Storage, revision.ImagePage)
+	}
+	log.Println("回滚成功")
+
+	return mvc.Response{
+		Code:   iris.StatusOK,
+		Object: image,
+	}
+}
+
+// PostByStage 上传阶段图片
+// @Summary 上传阶段图片
+// @Description 为自己的作品上传一张创作过程中的阶段图片(草稿,线稿,铺色等),按上传顺序保存;
+// @Description 图片在后台处理,可通过 /image/file/{stageID} [GET] 或作品的stages查询处理状态
+// @Tags image
+// @Accept multipart/form-data
+// @Produce json
+// @Param imageID path string true "图片ID"
+// @Param image formData file true "阶段图片文件"
+// @Param name formData string true "阶段名称,如 草稿,线稿,铺色"
+// @Success 202 {object} model.ImageStage "阶段图片,处理中"
+// @Failure 400 {object} model.ValidationError "阶段名称不合法(图片不存在,非本人上传或阶段数超过限制时返回文本)"
+// @Failure 401 {object} string "未授权，用户未登录或会话失效"
+// @Failure 403 {object} string "图片被封禁"
+// @Failure 413 {object} string "文件超过大小限制"
+// @Failure 415 {object} string "不是JPEG,PNG,WebP或GIF格式的图片"
+// @Failure 500 {object} string "服务器内部错误"
+// @Failure 503 {object} string "图片处理队列已满"
+// @Router /image/{imageID}/stage [post]
+// @Security BearerAuth
+func (c *ImageController) PostByStage(imageID string) mvc.Result {
+	images := c.Mg.Database("PaintingExchange").Collection("Images")
+
+	log.Println("作品", imageID, "上传阶段图片")
+	prevImage, res := c.ownImage(imageID)
+	if res != nil {
+		return res
+	}
+	if len(prevImage.Stages) >= service.ImageMaxStages {
+		return mvc.Response{
+			Code: iris.StatusBadRequest,
+			Text: fmt.Sprintf("阶段图片不能超过%d张", service.ImageMaxStages),
+		}
+	}
+
+	// 读取并检查图片
+	upload, res := readUpload(c.Ctx, "image")
+	if res != nil {
+		return res
+	}
+	name := c.Ctx.FormValue("name")
+	if errs := service.ValidateStage(name); len(errs) != 0 {
+		return invalidParams(errs)
+	}
+
+	// 保存并提交后台处理
+	job, res := c.submitUpload(prevImage.Auth, upload)
+	if res != nil {
+		return res
+	}
+
+	// 加入作品
+	stage := model.ImageStage{
+		ImagePage: model.ImagePage{
+			ID:     job.ID,
+			BigURI: job.BigURI,
+			MidURI: job.MidURI,
+			Status: service.ImageStatusPending,
+		},
+		Name:      name,
+		CreatedAt: time.Now(),
+	}
+	filter := bson.D{{"_id", imageID}}
+	update := bson.M{"$push": bson.M{"stages": stage}}
+	if _, err := images.UpdateOne(nil, filter, update); err != nil {
+		log.Println("阶段图片保存失败", err)
+		return mvc.Response{
+			Code: iris.StatusInternalServerError,
+			Text: err.Error(),
+		}
+	}
+	// 保存期间可能已处理完成
+	c.Queue.SyncImageStatus(stage.ID)
+	log.Println("阶段图片保存成功,等待处理")
+
+	return mvc.Response{
+		Code:   iris.StatusAccepted,
+		Object: stage,
+	}
+}
+
+// DeleteByStageBy 删除阶段图片
+// @Summary 删除阶段图片
+// @Description 删除自己作品中的一张阶段图片,已生成的延时视频需要重新生成
+// @Tags image
+// @Param imageID path string true "图片ID"
+// @Param stageID path string true "阶段图片ID"
+// @Success 204 {object} nil "删除成功，无返回内容"
+// @Failure 400 {object} string "图片或阶段图片不存在,或非本人上传"
+// @Failure 401 {object} string "未授权，用户未登录或会话失效"
+// @Failure 403 {object} string "图片被封禁"
+// @Failure 500 {object} string "服务器内部错误"
+// @Router /image/{imageID}/stage/{stageID} [delete]
+// @Security BearerAuth
+func (c *ImageController) DeleteByStageBy(imageID string, stageID string) mvc.Result {
+	images := c.Mg.Database("PaintingExchange").Collection("Images")
+
+	log.Println("作品", imageID, "删除阶段图片", stageID)
+	prevImage, res := c.ownImage(imageID)
+	if res != nil {
+		return res
+	}
+	i := slices.IndexFunc(prevImage.Stages, func(stage model.ImageStage) bool {
+		return stage.ID == stageID
+	})
+	if i < 0 {
+		return mvc.Response{
+			Code: iris.StatusBadRequest,
+			Text: "阶段图片不存在",
+		}
+	}
+
+	filter := bson.D{{"_id", imageID}}
+	update := bson.M{"$pull": bson.M{"stages": bson.M{"id": stageID}}}
+	if _, err := images.UpdateOne(nil, filter, update); err != nil {
+		log.Println("阶段图片删除失败", err)
+		return mvc.Response{
+			Code: iris.StatusInternalServerError,
+			Text: err.Error(),
+		}
+	}
+	service.DeletePageFiles(c.Db, c.Storage, prevImage.Stages[i].ImagePage)
+	log.Println("阶段图片删除成功")
+
+	return mvc.Response{
+		Code: iris.StatusNoContent,
+	}
+}
+
+// PostByTimelapse 生成创作过程延时视频
+// @Summary 生成创作过程延时视频
+// @Description 将自己作品的各阶段图片和最终图片(封面)依次生成带交叉淡化的MP4视频和GIF动图,保存在作品的timelapse中;
+// @Description 在后台生成,完成后timelapse.status变为ready.生成完成后再次调用会重新生成,正在生成时返回409
+// @Tags image
+// @Produce json
+// @Param imageID path string true "图片ID"
+// @Success 202 {object} model.Timelapse "延时视频,生成中"
+// @Failure 400 {object} string "图片不存在,非本人上传,没有阶段图片或阶段图片还在处理中"
+// @Failure 401 {object} string "未授权，用户未登录或会话失效"
+// @Failure 403 {object} string "图片被封禁"
+// @Failure 409 {object} string "延时视频正在生成中"
+// @Failure 503 {object} string "生成队列已满"
+// @Router /image/{imageID}/timelapse [post]
+// @Security BearerAuth
+func (c *ImageController) PostByTimelapse(imageID string) mvc.Result {
+	log.Println("作品", imageID, "生成延时视频")
+	prevImage, res := c.ownImage(imageID)
+	if res != nil {
+		return res
+	}
+	if len(prevImage.Stages) == 0 {
+		return mvc.Response{
+			Code: iris.StatusBadRequest,
+			Text: service.ErrTimelapseStages.Error(),
+		}
+	}
+	if !isImageReady(prevImage) || slices.ContainsFunc(prevImage.Stages, func(stage model.ImageStage) bool {
+		return stage.Status == service.ImageStatusPending
+	}) {
+		return mvc.Response{
+			Code: iris.StatusBadRequest,
+			Text: "图片还在处理中,请稍后再试",
+		}
+	}
+
+	timelapse, err := c.Timelapse.Submit(imageID)
+	if errors.Is(err, service.ErrTimelapsePending) {
+		return mvc.Response{
+			Code: iris.StatusConflict,
+			Text: err.Error(),
+		}
+	} else if err != nil {
+		return mvc.Response{
+			Code: iris.StatusServiceUnavailable,
+			Text: err.Error(),
+		}
+	}
+	return mvc.Response{
+		Code:   iris.StatusAccepted,
+		Object: timelapse,
+	}
+}
+
 // DeleteBy 删除图片
 // @Summary 删除指定ID的图片
 // @Description 删除自己上传的指定ID的图片
@@ -382,6 +872,10 @@ func (c *ImageController) DeleteBy(imageID string) mvc.Result {
 		IsBan: false,
 	})
 
+	// 删除图片文件和哈希索引
+	service.DeleteImageFiles(c.Db, c.Storage, prevImage)
+	c.Duplicates.Remove(imageID)
+
 	log.Println("图片删除成功")
 	return mvc.Response{
 		Code: iris.StatusNoContent,
@@ -391,7 +885,7 @@ func (c *ImageController) DeleteBy(imageID string) mvc.Result {
 
 // GetNewest 获取最新9个图片
 // @Summary 获取最新的9张图片
-// @Description 获取最新的9张图片
+// @Description 获取最新公开的9张图片(作品),按公开时间(定时发布的为发布时间)排序,多页作品的各页地址在pages中;成人内容(nsfw)按用户的显示偏好不显示或隐去图片
 // @Tags image
 // @Accept json
 // @Produce json
@@ -403,9 +897,11 @@ func (c *ImageController) GetNewest() mvc.Result {
 	images := c.Mg.Database("PaintingExchange").Collection("Images")
 
 	log.Println("获取最新9个图片")
+	viewer := currentUsername(c.Ctx)
+	ratingFilter := service.UserRatingFilter(c.Db, viewer)
 	// 获取最新9个图片
 	findOptions := options.Find()
-	findOptions.SetSort(bson.D{{Key: "createdAt", Value: -1}}) // 按创建时间降序排序
+	findOptions.SetSort(bson.D{{Key: "publishedAt", Value: -1}, {Key: "createAt", Value: -1}}) // 按公开时间降序排序
 	cursor, err := images.Find(nil, bson.D{}, findOptions)
 	if err != nil {
 		log.Println("最新图片查询失败", err)
@@ -428,8 +924,13 @@ func (c *ImageController) GetNewest() mvc.Result {
 			}
 		}
 
-		// 跳过已封禁
-		if image.IsBan || image.AuthIsBan {
+		// 跳过已封禁,未处理完成和不公开的
+		if image.IsBan || image.AuthIsBan || !isImageReady(image) || !service.IsListed(image) {
+			continue
+		}
+
+		// 按分级偏好跳过或隐去成人内容
+		if !service.FilterRating(&image, viewer, ratingFilter, true) {
 			continue
 		}
 
@@ -451,7 +952,9 @@ func (c *ImageController) GetNewest() mvc.Result {
 
 // GetFromBy 获取指定用户上传的所有图片
 // @Summary 获取指定用户上传的所有图片
-// @Description 查询指定用户名上传的所有图片
+// @Description 查询指定用户名上传的所有图片(作品),多页作品的各页地址在pages中;
+// @Description 只返回公开的和关注者可见(已关注作者时)的图片,查询自己时返回全部(包括不公开列出,私密和定时发布的);
+// @Description 成人内容(nsfw)按用户的显示偏好不显示或隐去图片
 // @Tags image
 // @Accept json
 // @Produce json
@@ -464,6 +967,9 @@ func (c *ImageController) GetFromBy(username string) mvc.Result {
 	images := c.Mg.Database("PaintingExchange").Collection("Images")
 
 	log.Println("查询用户", username, "上传的图片")
+	viewer := currentUsername(c.Ctx)
+	following := viewer != username && service.IsFollowing(c.Db, viewer, username)
+	ratingFilter := service.UserRatingFilter(c.Db, viewer)
 
 	// 查询用户上传的图片
 	filter := bson.M{
@@ -491,8 +997,13 @@ func (c *ImageController) GetFromBy(username string) mvc.Result {
 			}
 		}
 
-		// 跳过已封禁
-		if image.IsBan || image.AuthIsBan {
+		// 跳过已封禁,未处理完成和无权查看的
+		if image.IsBan || image.AuthIsBan || !isImageReady(image) || !service.CanListImage(image, viewer, following) {
+			continue
+		}
+
+		// 按分级偏好跳过或隐去成人内容
+		if !service.FilterRating(&image, viewer, ratingFilter, true) {
 			continue
 		}
 
@@ -514,7 +1025,7 @@ func (c *ImageController) GetFromBy(username string) mvc.Result {
 
 // GetSearch 查询图片
 // @Summary 查询图片
-// @Description 查询图片，进行标签匹配和标题模糊匹配
+// @Description 查询公开的图片(作品)，进行标签匹配和标题模糊匹配,多页作品的各页地址在pages中;成人内容(nsfw)按用户的显示偏好不显示或隐去图片
 // @Tags image
 // @Accept json
 // @Produce json
@@ -537,6 +1048,8 @@ func (c *ImageController) GetSearch() mvc.Result {
 	}
 
 	log.Println("查询图片,内容:", search)
+	viewer := currentUsername(c.Ctx)
+	ratingFilter := service.UserRatingFilter(c.Db, viewer)
 
 	// 并发查询
 	var res []model.Image
@@ -574,8 +1087,13 @@ func (c *ImageController) GetSearch() mvc.Result {
 				return err
 			}
 
-			// 跳过已封禁
-			if image.IsBan || image.AuthIsBan {
+			// 跳过已封禁,未处理完成和不公开的
+			if image.IsBan || image.AuthIsBan || !isImageReady(image) || !service.IsListed(image) {
+				continue
+			}
+
+			// 按分级偏好跳过或隐去成人内容
+			if !service.FilterRating(&image, viewer, ratingFilter, true) {
 				continue
 			}
 
@@ -609,8 +1127,13 @@ func (c *ImageController) GetSearch() mvc.Result {
 				return err
 			}
 
-			// 跳过已封禁
-			if image.IsBan || image.AuthIsBan {
+			// 跳过已封禁,未处理完成和不公开的
+			if image.IsBan || image.AuthIsBan || !isImageReady(image) || !service.IsListed(image) {
+				continue
+			}
+
+			// 按分级偏好跳过或隐去成人内容
+			if !service.FilterRating(&image, viewer, ratingFilter, true) {
 				continue
 			}
 
@@ -651,8 +1174,13 @@ func (c *ImageController) GetSearch() mvc.Result {
 				return err
 			}
 
-			// 跳过已封禁
-			if image.IsBan || image.AuthIsBan {
+			// 跳过已封禁,未处理完成和不公开的
+			if image.IsBan || image.AuthIsBan || !isImageReady(image) || !service.IsListed(image) {
+				continue
+			}
+
+			// 按分级偏好跳过或隐去成人内容
+			if !service.FilterRating(&image, viewer, ratingFilter, true) {
 				continue
 			}
 
@@ -695,17 +1223,164 @@ func getFilenameWithoutExt(path string) string {
 	return filenameWithoutExt
 }
 
-// fileExists 确认文件是否存在
-func fileExists(filePath string) bool {
-	_, err := os.Stat(filePath)
-	if os.IsNotExist(err) {
-		return false
+// ownImage 获取当前用户自己的图片对象
+func (c *ImageController) ownImage(imageID string) (model.Image, mvc.Result) {
+	loginUser, err := c.Ctx.User().GetRaw()
+	if err != nil {
+		return model.Image{}, mvc.Response{
+			Code: iris.StatusUnauthorized,
+			Text: iris.StatusText(iris.StatusUnauthorized),
+		}
+	}
+	loginUserName := loginUser.(iris.SimpleUser).Username
+
+	// 查询原图片对象
+	prevImageRes := c.GetBy(imageID).(mvc.Response)
+	if prevImageRes.Code != iris.StatusOK {
+		return model.Image{}, prevImageRes
+	}
+	prevImage := prevImageRes.Object.(model.Image)
+
+	// 验证是否为本人操作
+	if loginUserName != prevImage.Auth {
+		log.Println("图片非用户", loginUserName, "本人上传")
+		return model.Image{}, mvc.Response{
+			Code: iris.StatusBadRequest,
+			Text: "图片文件非本人上传",
+		}
+	}
+	return prevImage, nil
+}
+
+// submitUpload 保存上传的原图并提交后台处理
+func (c *ImageController) submitUpload(username string, upload service.Upload) (model.ImageJob, mvc.Result) {
+	// 生成图片id
+	imageID := uuid.New().String()
+
+	// 保存原图,扩展名以实际格式为准
+	source := filepath.Join(env.GetOriginDir(), imageID+upload.Ext)
+	if err := c.Storage.Put(source, bytes.NewReader(upload.Data), int64(len(upload.Data)), upload.ContentType); err != nil {
+		log.Println("原图文件保存失败", err)
+		return model.ImageJob{}, mvc.Response{
+			Code: iris.StatusInternalServerError,
+			Text: err.Error(),
+		}
+	}
+
+	// 提交后台处理
+	job := model.ImageJob{
+		ID:       imageID,
+		Username: username,
+		Source:   source,
+		BigURI:   filepath.Join(env.GetImgDir(), "big_"+imageID+upload.OutputExt()),
+		MidURI:   filepath.Join(env.GetImgDir(), "mid_"+imageID+upload.OutputExt()),
+	}
+	if err := c.Queue.Submit(job); err != nil {
+		c.Storage.Delete(source)
+		log.Println("图片处理任务提交失败", err)
+		if errors.Is(err, service.ErrImageQueueFull) {
+			return job, mvc.Response{
+				Code: iris.StatusServiceUnavailable,
+				Text: err.Error(),
+			}
+		}
+		return job, mvc.Response{
+			Code: iris.StatusInternalServerError,
+			Text: err.Error(),
+		}
+	}
+	return job, nil
+}
+
+// checkPage 验证作品中一页的文件,后台处理的图片以处理任务为准,返回是否为后台处理的图片
+func (c *ImageController) checkPage(imageID string, username string, page *model.ImagePage) (bool, mvc.Result) {
+	job, ok := c.Queue.Job(page.ID)
+	if !ok {
+		if !checkImageFile(c.Storage, page.BigURI, page.ID, "big") || !checkImageFile(c.Storage, page.MidURI, page.ID, "mid") {
+			log.Println("图片id或路径异常")
+			return false, mvc.Response{
+				Code: iris.StatusBadRequest,
+				Text: "请求数据中id或图片路径信息异常",
+			}
+		}
+		*page = model.ImagePage{ID: page.ID, BigURI: page.BigURI, MidURI: page.MidURI, Status: service.ImageStatusReady}
+		return false, nil
+	}
+
+	if job.Username != username || job.BigURI != page.BigURI || job.MidURI != page.MidURI {
+		log.Println("图片id或路径异常")
+		return false, mvc.Response{
+			Code: iris.StatusBadRequest,
+			Text: "请求数据中id或图片路径信息异常",
+		}
+	}
+	if job.Status == service.ImageStatusFailed {
+		log.Println("图片处理失败", job.Error)
+		return false, mvc.Response{
+			Code: iris.StatusBadRequest,
+			Text: "图片处理失败: " + job.Error,
+		}
+	}
+
+	// 图片与其他作者已发布的图片相似时拒绝发布,处理完成前无法判断
+	if c.Duplicates.Policy == service.DuplicateReject {
+		if job.Status != service.ImageStatusReady {
+			log.Println("图片", job.ID, "还在处理中")
+			return false, mvc.Response{
+				Code: iris.StatusBadRequest,
+				Text: "图片还在处理中,处理完成后才能发布",
+			}
+		}
+		reports, err := c.Duplicates.Similar(job.Username, job.Hash)
+		if err != nil {
+			log.Println("相似图片查询失败", err)
+			return false, mvc.Response{
+				Code: iris.StatusInternalServerError,
+				Text: err.Error(),
+			}
+		}
+		for i := range reports {
+			reports[i].ImageID = imageID
+		}
+		if len(reports) != 0 {
+			log.Println("图片", job.ID, service.ErrDuplicateImage, reports[0].OriginalID)
+			return false, mvc.Response{
+				Code:   iris.StatusConflict,
+				Object: reports,
+			}
+		}
+	}
+
+	page.Status = job.Status
+	page.Sizes = job.Sizes
+	page.Variants = job.Variants
+	page.Metadata = job.Metadata
+	return true, nil
+}
+
+// pagesError 修改作品各页失败时的返回结果
+func pagesError(err error) mvc.Result {
+	switch {
+	case errors.Is(err, service.ErrPageOrder), errors.Is(err, service.ErrPageCover),
+		errors.Is(err, service.ErrPageNotExist), errors.Is(err, service.ErrRevisionNotExist):
+		return mvc.Response{
+			Code: iris.StatusBadRequest,
+			Text: err.Error(),
+		}
+	case errors.Is(err, mongo.ErrNoDocuments):
+		return mvc.Response{
+			Code: iris.StatusBadRequest,
+			Text: "图片不存在",
+		}
+	}
+	return mvc.Response{
+		Code: iris.StatusInternalServerError,
+		Text: err.Error(),
 	}
-	return true
 }
 
 // checkImageFile 检查文件地址是否正确
-func checkImageFile(filePath string, id string, size string) bool {
+func checkImageFile(storage service.Storage, filePath string, id string, size string) bool {
 	// 检查文件名
 	filename := strings.SplitN(getFilenameWithoutExt(filePath), "_", 2)
 	if len(filename) < 2 || id != filename[1] || size != filename[0] {
@@ -718,13 +1393,27 @@ func checkImageFile(filePath string, id string, size string) bool {
 	}
 
 	// 检查文件是否存在
-	if !fileExists(filePath) {
+	if _, err := storage.Stat(filePath); err != nil {
 		return false
 	}
 
 	return true
 }
 
+// currentUsername 获取当前登录的用户名
+func currentUsername(ctx iris.Context) string {
+	loginUser, err := ctx.User().GetRaw()
+	if err != nil {
+		return ""
+	}
+	return loginUser.(iris.SimpleUser).Username
+}
+
+// isImageReady 图片的各尺寸文件是否已生成(旧数据没有处理状态)
+func isImageReady(image model.Image) bool {
+	return image.Status == "" || image.Status == service.ImageStatusReady
+}
+
 // uniqueSortedImages 图片切片排序并去重
 func uniqueSortedImages(images []model.Image) []model.Image {
 	if len(images) <= 1 {
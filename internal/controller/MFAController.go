@@ -0,0 +1,187 @@
+package controller
+
+import (
+	"PaintingExchange/internal/env"
+	"PaintingExchange/internal/model"
+	"PaintingExchange/internal/service"
+	"errors"
+	"github.com/kataras/iris/v12"
+	"github.com/kataras/iris/v12/mvc"
+	"gorm.io/gorm"
+	"log"
+)
+
+// MFAController 两步验证管理控制器(用户和管理员通用,账号类型以令牌为准)
+type MFAController struct {
+	Ctx iris.Context
+	Db  *gorm.DB
+}
+
+// Post 绑定两步验证
+// @Summary 绑定两步验证
+// @Description 生成新的TOTP密钥和恢复码,需要再调用 /user/mfa/enable 验证一次验证码后才会启用.管理员使用 /back/mfa 下的同名接口
+// @Tags mfa
+// @Produce json
+// @Success 201 {object} model.MFASetup "绑定信息"
+// @Failure 400 {object} string "两步验证已启用"
+// @Failure 401 {object} string "未授权，用户未登录或会话失效"
+// @Failure 500 {object} string "服务器内部错误"
+// @Router /user/mfa [post]
+// @Security BearerAuth
+func (c *MFAController) Post() mvc.Result {
+	username, isAdmin, ok := c.account()
+	if !ok {
+		return mvc.Response{
+			Code: iris.StatusUnauthorized,
+			Text: iris.StatusText(iris.StatusUnauthorized),
+		}
+	}
+	log.Println(username, "绑定两步验证")
+
+	setup, err := service.BeginMFASetup(c.Db, username, isAdmin)
+	if errors.Is(err, service.ErrMFAEnabled) {
+		return mvc.Response{
+			Code: iris.StatusBadRequest,
+			Text: err.Error(),
+		}
+	} else if err != nil {
+		log.Println(username, "两步验证绑定失败", err)
+		return mvc.Response{
+			Code: iris.StatusInternalServerError,
+			Text: err.Error(),
+		}
+	}
+
+	return mvc.Response{
+		Code:   iris.StatusCreated,
+		Object: setup,
+	}
+}
+
+// PostEnable 启用两步验证
+// @Summary 启用两步验证
+// @Description 提交验证器上的验证码以确认绑定并启用两步验证
+// @Tags mfa
+// @Accept json
+// @Param verify body model.MFAVerify true "验证码(只需要code)"
+// @Success 204 {object} nil "启用成功，无返回内容"
+// @Failure 400 {object} string "未绑定,已启用或验证码错误"
+// @Failure 401 {object} string "未授权，用户未登录或会话失效"
+// @Router /user/mfa/enable [post]
+// @Security BearerAuth
+func (c *MFAController) PostEnable(verify model.MFAVerify) mvc.Result {
+	username, isAdmin, ok := c.account()
+	if !ok {
+		return mvc.Response{
+			Code: iris.StatusUnauthorized,
+			Text: iris.StatusText(iris.StatusUnauthorized),
+		}
+	}
+	log.Println(username, "启用两步验证")
+
+	if err := service.EnableMFA(c.Db, username, isAdmin, verify.Code); err != nil {
+		log.Println(username, "两步验证启用失败", err)
+		return mvc.Response{
+			Code: iris.StatusBadRequest,
+			Text: err.Error(),
+		}
+	}
+
+	return mvc.Response{
+		Code: iris.StatusNoContent,
+	}
+}
+
+// Delete 关闭两步验证
+// @Summary 关闭两步验证
+// @Description 提交验证码或恢复码以关闭两步验证;管理员强制两步验证时管理员账号不能关闭
+// @Tags mfa
+// @Accept json
+// @Param verify body model.MFAVerify true "验证码或恢复码(只需要code)"
+// @Success 204 {object} nil "关闭成功，无返回内容"
+// @Failure 400 {object} string "未启用或验证码错误"
+// @Failure 401 {object} string "未授权，用户未登录或会话失效"
+// @Failure 403 {object} string "管理员必须启用两步验证"
+// @Router /user/mfa [delete]
+// @Security BearerAuth
+func (c *MFAController) Delete(verify model.MFAVerify) mvc.Result {
+	username, isAdmin, ok := c.account()
+	if !ok {
+		return mvc.Response{
+			Code: iris.StatusUnauthorized,
+			Text: iris.StatusText(iris.StatusUnauthorized),
+		}
+	}
+	log.Println(username, "关闭两步验证")
+
+	if isAdmin && env.GetAdminRequireMFA() {
+		return mvc.Response{
+			Code: iris.StatusForbidden,
+			Text: "管理员必须启用两步验证",
+		}
+	}
+
+	if err := service.DisableMFA(c.Db, username, isAdmin, verify.Code); err != nil {
+		log.Println(username, "两步验证关闭失败", err)
+		return mvc.Response{
+			Code: iris.StatusBadRequest,
+			Text: err.Error(),
+		}
+	}
+
+	return mvc.Response{
+		Code: iris.StatusNoContent,
+	}
+}
+
+// PostRecovery 重新生成恢复码
+// @Summary 重新生成恢复码
+// @Description 提交验证码或恢复码以重新生成恢复码,旧恢复码全部失效
+// @Tags mfa
+// @Accept json
+// @Produce json
+// @Param verify body model.MFAVerify true "验证码或恢复码(只需要code)"
+// @Success 200 {object} model.MFASetup "新的恢复码(只有recoveryCodes)"
+// @Failure 400 {object} string "未启用或验证码错误"
+// @Failure 401 {object} string "未授权，用户未登录或会话失效"
+// @Router /user/mfa/recovery [post]
+// @Security BearerAuth
+func (c *MFAController) PostRecovery(verify model.MFAVerify) mvc.Result {
+	username, isAdmin, ok := c.account()
+	if !ok {
+		return mvc.Response{
+			Code: iris.StatusUnauthorized,
+			Text: iris.StatusText(iris.StatusUnauthorized),
+		}
+	}
+	log.Println(username, "重新生成恢复码")
+
+	codes, err := service.RegenerateRecoveryCodes(c.Db, username, isAdmin, verify.Code)
+	if errors.Is(err, service.ErrMFANotSetup) || errors.Is(err, service.ErrMFACodeInvalid) {
+		return mvc.Response{
+			Code: iris.StatusBadRequest,
+			Text: err.Error(),
+		}
+	} else if err != nil {
+		log.Println(username, "恢复码生成失败", err)
+		return mvc.Response{
+			Code: iris.StatusInternalServerError,
+			Text: err.Error(),
+		}
+	}
+
+	return mvc.Response{
+		Code:   iris.StatusOK,
+		Object: model.MFASetup{RecoveryCodes: codes},
+	}
+}
+
+// account 获取当前登录的账号
+func (c *MFAController) account() (string, bool, bool) {
+	loginUser, err := c.Ctx.User().GetRaw()
+	if err != nil {
+		return "", false, false
+	}
+	user := loginUser.(iris.SimpleUser)
+	return user.Username, user.Fields["isAdmin"] == true, true
+}
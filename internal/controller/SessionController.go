@@ -0,0 +1,87 @@
+package controller
+
+import (
+	"PaintingExchange/internal/service"
+	"github.com/kataras/iris/v12"
+	"github.com/kataras/iris/v12/mvc"
+	"gorm.io/gorm"
+	"log"
+)
+
+// SessionController 登录会话管理控制器(用户和管理员通用,账号类型以令牌为准)
+type SessionController struct {
+	Ctx iris.Context
+	Db  *gorm.DB
+}
+
+// Get 获取登录会话
+// @Summary 获取登录会话
+// @Description 列出账号在各设备上的登录会话(设备,IP,登录时间和最近访问时间),current标记当前请求所用的会话.管理员使用 /back/sessions 下的同名接口
+// @Tags session
+// @Produce json
+// @Success 200 {array} model.Session "会话列表"
+// @Failure 401 {object} string "未授权，用户未登录或会话失效"
+// @Router /user/sessions [get]
+// @Security BearerAuth
+func (c *SessionController) Get() mvc.Result {
+	username, isAdmin, sid, ok := c.account()
+	if !ok {
+		return mvc.Response{
+			Code: iris.StatusUnauthorized,
+			Text: iris.StatusText(iris.StatusUnauthorized),
+		}
+	}
+	log.Println(username, "查询登录会话")
+
+	sessions := service.ListSessions(c.Db, username, isAdmin)
+	for i := range sessions {
+		sessions[i].Current = sessions[i].ID == sid
+	}
+	return mvc.Response{
+		Code:   iris.StatusOK,
+		Object: sessions,
+	}
+}
+
+// DeleteBy 注销登录会话
+// @Summary 注销登录会话
+// @Description 注销指定会话的访问令牌和刷新令牌,并断开该会话的聊天室连接
+// @Tags session
+// @Param id path string true "会话id"
+// @Success 204 {object} nil "注销成功，无返回内容"
+// @Failure 401 {object} string "未授权，用户未登录或会话失效"
+// @Failure 404 {object} string "会话不存在"
+// @Router /user/sessions/{id} [delete]
+// @Security BearerAuth
+func (c *SessionController) DeleteBy(id string) mvc.Result {
+	username, isAdmin, _, ok := c.account()
+	if !ok {
+		return mvc.Response{
+			Code: iris.StatusUnauthorized,
+			Text: iris.StatusText(iris.StatusUnauthorized),
+		}
+	}
+	log.Println(username, "注销登录会话", id)
+
+	if err := service.RevokeSession(c.Db, username, isAdmin, id); err != nil {
+		return mvc.Response{
+			Code: iris.StatusNotFound,
+			Text: err.Error(),
+		}
+	}
+	return mvc.Response{
+		Code: iris.StatusNoContent,
+	}
+}
+
+// account 获取当前登录的账号和会话id
+func (c *SessionController) account() (string, bool, string, bool) {
+	loginUser, err := c.Ctx.User().GetRaw()
+	if err != nil {
+		return "", false, "", false
+	}
+	user := loginUser.(iris.SimpleUser)
+	isAdmin, _ := user.Fields["isAdmin"].(bool)
+	sid, _ := user.Fields["sid"].(string)
+	return user.Username, isAdmin, sid, true
+}
@@ -0,0 +1,135 @@
+package controller
+
+import (
+	"PaintingExchange/internal/model"
+	"PaintingExchange/internal/service"
+	"errors"
+	"github.com/kataras/iris/v12"
+	"github.com/kataras/iris/v12/mvc"
+	"gorm.io/gorm"
+	"log"
+)
+
+// TokenController API令牌管理控制器
+type TokenController struct {
+	Ctx iris.Context
+	Db  *gorm.DB
+}
+
+// Get 获取自己的API令牌
+// @Summary 获取自己的API令牌
+// @Description 列出用户创建的所有API令牌(不含令牌明文),只能使用登录获得的令牌访问
+// @Tags token
+// @Produce json
+// @Success 200 {array} model.APIToken "API令牌列表"
+// @Failure 401 {object} string "未授权，用户未登录或会话失效"
+// @Failure 403 {object} string "管理员账号或API令牌不能管理API令牌"
+// @Router /user/tokens [get]
+// @Security BearerAuth
+func (c *TokenController) Get() mvc.Result {
+	username, ok := c.account()
+	if !ok {
+		return mvc.Response{
+			Code: iris.StatusForbidden,
+			Text: iris.StatusText(iris.StatusForbidden),
+		}
+	}
+	log.Println("用户", username, "查询API令牌")
+
+	return mvc.Response{
+		Code:   iris.StatusOK,
+		Object: service.ListAPITokens(c.Db, username),
+	}
+}
+
+// Post 创建API令牌
+// @Summary 创建API令牌
+// @Description 创建带名称,授权范围(image:read,image:write,user:read,user:write,chat:read,chat:write)和可选过期时间的API令牌.
+// @Description 令牌明文只在创建时返回一次,请求时放在 Authorization: Bearer 请求头中;API令牌不能访问令牌管理,登出,两步验证和后台接口
+// @Tags token
+// @Accept json
+// @Produce json
+// @Param token body model.APITokenCreate true "令牌名称,授权范围和过期时间"
+// @Success 201 {object} model.APITokenCreated "新创建的API令牌"
+// @Failure 400 {object} model.ValidationError "请求参数不合法或令牌数量已达上限"
+// @Failure 401 {object} string "未授权，用户未登录或会话失效"
+// @Failure 403 {object} string "管理员账号或API令牌不能管理API令牌"
+// @Failure 500 {object} string "服务器内部错误"
+// @Router /user/tokens [post]
+// @Security BearerAuth
+func (c *TokenController) Post(create model.APITokenCreate) mvc.Result {
+	username, ok := c.account()
+	if !ok {
+		return mvc.Response{
+			Code: iris.StatusForbidden,
+			Text: iris.StatusText(iris.StatusForbidden),
+		}
+	}
+	log.Println("用户", username, "创建API令牌", create.Name)
+
+	if errs := service.ValidateAPITokenCreate(create); len(errs) != 0 {
+		return invalidParams(errs)
+	}
+
+	token, err := service.CreateAPIToken(c.Db, username, create)
+	if errors.Is(err, service.ErrAPITokenTooMany) {
+		return invalidParams([]model.FieldError{{Field: "name", Message: err.Error()}})
+	} else if err != nil {
+		log.Println("用户", username, "API令牌创建失败", err)
+		return mvc.Response{
+			Code: iris.StatusInternalServerError,
+			Text: err.Error(),
+		}
+	}
+
+	return mvc.Response{
+		Code:   iris.StatusCreated,
+		Object: token,
+	}
+}
+
+// DeleteBy 注销API令牌
+// @Summary 注销API令牌
+// @Description 注销自己的API令牌,注销后立即失效
+// @Tags token
+// @Param id path string true "令牌id"
+// @Success 204 {object} nil "注销成功，无返回内容"
+// @Failure 401 {object} string "未授权，用户未登录或会话失效"
+// @Failure 403 {object} string "管理员账号或API令牌不能管理API令牌"
+// @Failure 404 {object} string "API令牌不存在"
+// @Router /user/tokens/{id} [delete]
+// @Security BearerAuth
+func (c *TokenController) DeleteBy(id string) mvc.Result {
+	username, ok := c.account()
+	if !ok {
+		return mvc.Response{
+			Code: iris.StatusForbidden,
+			Text: iris.StatusText(iris.StatusForbidden),
+		}
+	}
+	log.Println("用户", username, "注销API令牌", id)
+
+	if err := service.RevokeAPIToken(c.Db, username, id); err != nil {
+		return mvc.Response{
+			Code: iris.StatusNotFound,
+			Text: err.Error(),
+		}
+	}
+	return mvc.Response{
+		Code: iris.StatusNoContent,
+	}
+}
+
+// account 获取当前登录的普通用户,管理员和API令牌不能管理API令牌
+func (c *TokenController) account() (string, bool) {
+	loginUser, err := c.Ctx.User().GetRaw()
+	if err != nil {
+		return "", false
+	}
+	user := loginUser.(iris.SimpleUser)
+	if isAdmin, _ := user.Fields["isAdmin"].(bool); isAdmin || service.IsAPITokenUser(user) {
+		log.Println(user.Username, "不能管理API令牌")
+		return "", false
+	}
+	return user.Username, true
+}
@@ -3,6 +3,8 @@ package controller
 import (
 	"PaintingExchange/internal/env"
 	"PaintingExchange/internal/model"
+	"PaintingExchange/internal/service"
+	"errors"
 	"github.com/google/uuid"
 	"github.com/kataras/iris/v12"
 	"github.com/kataras/iris/v12/mvc"
@@ -10,22 +12,24 @@ import (
 	"go.mongodb.org/mongo-driver/mongo"
 	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
-	"io"
+	"image"
 	"log"
-	"os"
 	"path/filepath"
+	"strconv"
 )
 
 // UserController 用户相关操作控制器
 type UserController struct {
-	Ctx iris.Context
-	Db  *gorm.DB
-	Mg  *mongo.Client
+	Ctx     iris.Context
+	Db      *gorm.DB
+	Mg      *mongo.Client
+	Mailer  service.Mailer
+	Storage service.Storage
 }
 
 // GetBy 获取指定用户名的用户对象(无密码)
 // @Summary 获取指定用户名的用户对象(无密码)
-// @Description 根据用户名获取用户详细信息(无密码)，需要JWT验证
+// @Description 根据用户名获取用户详细信息(无密码,邮箱和成人内容显示偏好只对本人可见)，需要JWT验证
 // @Tags user
 // @Param username path string true "用户名"
 // @Success 200 {object} model.User "用户对象(无密码)"
@@ -59,6 +63,12 @@ func (c *UserController) GetBy(username string) mvc.Result {
 
 	log.Println("查询用户", username, "成功")
 	user.Password = ""
+	user.AvatarURIs = service.AvatarURIs(user.AvatarURI)
+	if loginUser, err := c.Ctx.User().GetRaw(); err != nil || loginUser.(iris.SimpleUser).Username != username {
+		user.Email = ""
+		user.EmailVerified = false
+		user.RatingFilter = ""
+	}
 	return mvc.Response{
 		Code:   iris.StatusOK,
 		Object: user,
@@ -67,13 +77,21 @@ func (c *UserController) GetBy(username string) mvc.Result {
 
 // PostAvatar 上传用户头像
 // @Summary 上传用户头像
-// @Description 上传用户自己的头像文件,后续需要再请求一次/user [put]来更新用户信息
+// @Description 上传用户自己的头像文件,裁剪为正方形后生成32,128,512三种尺寸,后续需要再请求一次/user [put]将avatarURI更新到用户信息.
+// @Description 可以指定裁剪区域(四个参数需同时提供),不是正方形时取其中心的最大正方形;不指定时取整张图片中心的最大正方形
 // @Tags user
 // @Accept multipart/form-data
-// @Produce text/plain
+// @Produce json
 // @Param image formData file true "用户上传的头像文件"
-// @Success 201 {string} string "返回头像存储路径"
-// @Failure 401 {string} string "用户未授权"
+// @Param cropX formData int false "裁剪区域左上角横坐标"
+// @Param cropY formData int false "裁剪区域左上角纵坐标"
+// @Param cropWidth formData int false "裁剪区域宽度"
+// @Param cropHeight formData int false "裁剪区域高度"
+// @Success 201 {object} model.AvatarUpload "返回各尺寸头像地址"
+// @Failure 400 {object} model.ValidationError "裁剪区域不合法(图片尺寸超过限制时返回文本)"
+// @Failure 401 {string} string "未授权"
+// @Failure 413 {string} string "文件超过大小限制"
+// @Failure 415 {string} string "不是JPEG,PNG,WebP或GIF格式的图片"
 // @Failure 500 {string} string "服务器内部错误"
 // @Router /user/avatar [post]
 // @Security BearerAuth
@@ -87,34 +105,24 @@ func (c *UserController) PostAvatar() mvc.Result {
 		}
 	}
 	loginUserName := loginUser.(iris.SimpleUser).Username
-	// 读取图片
+	// 读取并检查图片
 	log.Println(loginUserName, "上传头像")
-	file, info, err := c.Ctx.FormFile("image")
-	if err != nil {
-		log.Println("图片文件上传失败", err)
-		return mvc.Response{
-			Code: iris.StatusInternalServerError,
-			Text: err.Error(),
-		}
+	upload, res := readUpload(c.Ctx, "image")
+	if res != nil {
+		return res
+	}
+	crop, errs := parseAvatarCrop(c.Ctx)
+	if len(errs) != 0 {
+		return invalidParams(errs)
 	}
 
-	// 生成图片id
+	// 生成图片id,裁剪并写入各尺寸头像
 	imageID := uuid.New().String()
-	// 创建对应文件
-	uri := filepath.Join(env.GetAvatarDir(), imageID+filepath.Ext(info.Filename))
-	out, err := os.Create(uri)
-	if err != nil {
-		log.Println("头像文件创建失败", err)
-		return mvc.Response{
-			Code: iris.StatusInternalServerError,
-			Text: err.Error(),
+	base := filepath.Join(env.GetAvatarDir(), imageID)
+	if err := service.ProcessAvatar(c.Storage, upload.Data, crop, base, upload.OutputExt()); err != nil {
+		if errors.Is(err, service.ErrAvatarCrop) {
+			return invalidParams([]model.FieldError{{Field: "crop", Message: err.Error()}})
 		}
-	}
-	defer out.Close()
-
-	// 写入图片
-	_, err = io.Copy(out, file)
-	if err != nil {
 		log.Println("头像文件保存失败", err)
 		return mvc.Response{
 			Code: iris.StatusInternalServerError,
@@ -124,22 +132,58 @@ func (c *UserController) PostAvatar() mvc.Result {
 
 	log.Println("头像文件保存成功")
 	// 返回头像地址
+	uri := service.AvatarURI(base, upload.OutputExt(), service.AvatarSizes[len(service.AvatarSizes)-1])
 	return mvc.Response{
 		Code: iris.StatusCreated,
-		Text: uri,
+		Object: model.AvatarUpload{
+			AvatarURI:  uri,
+			AvatarURIs: service.AvatarURIs(uri),
+		},
 	}
 }
 
+// parseAvatarCrop 读取头像裁剪区域,没有提供时返回空区域
+func parseAvatarCrop(ctx iris.Context) (image.Rectangle, []model.FieldError) {
+	fields := []string{"cropX", "cropY", "cropWidth", "cropHeight"}
+	var values [4]int
+	provided := 0
+	var errs []model.FieldError
+	for i, field := range fields {
+		value := ctx.PostValue(field)
+		if value == "" {
+			continue
+		}
+		provided++
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 || (i >= 2 && n == 0) {
+			errs = append(errs, model.FieldError{Field: field, Message: "必须是正整数"})
+		}
+		values[i] = n
+	}
+	if provided != 0 && provided != len(fields) {
+		errs = append(errs, model.FieldError{Field: "crop", Message: "裁剪区域需要同时提供cropX,cropY,cropWidth和cropHeight"})
+	}
+	if provided == 0 || len(errs) != 0 {
+		return image.Rectangle{}, errs
+	}
+	return image.Rect(values[0], values[1], values[0]+values[2], values[1]+values[3]), nil
+}
+
 // Put 更新用户对象(仅限自己)
 // @Summary 更新用户信息
 // @Description 允许已登录的用户更新自己的信息，包括密码。如果没有提供密码，密码保持不变。
+// @Description 修改密码需要在currentPassword中提供当前密码，修改后所有设备上的登录全部失效，需要重新登录。
+// @Description 修改邮箱需要在currentPassword中提供当前密码(API令牌不能修改邮箱)，修改后需要重新验证，会向新邮箱发送验证邮件。
+// @Description avatarURI只能是/user/avatar [post]返回的头像地址或默认头像，与当前头像相同时不修改。
+// @Description ratingFilter为成人内容的显示偏好:hide(默认,列表中不显示,查看时隐去图片),blur(列表中显示但隐去图片),show(正常显示)
 // @Tags user
 // @Accept json
 // @Produce json
 // @Param user body model.User true "用户信息"
 // @Success 204 {object} nil "用户信息更新成功，无返回内容"
+// @Failure 400 {object} model.ValidationError "用户信息不合法"
 // @Failure 401 {object} string "未授权错误"
-// @Failure 403 {object} string "禁止操作，尝试修改非自己的信息"
+// @Failure 403 {object} string "禁止操作，尝试修改非自己的信息,使用API令牌修改密码或邮箱,或修改密码或邮箱时当前密码错误"
 // @Failure 500 {object} string "服务器内部错误"
 // @Router /user [put]
 // @Security BearerAuth
@@ -161,10 +205,56 @@ func (c *UserController) Put(user model.User) mvc.Result {
 			Text: "只能修改自己的用户信息",
 		}
 	}
-	// 设置密码
+	// API令牌不能修改密码
+	if user.Password != "" && service.IsAPITokenUser(loginUser.(iris.SimpleUser)) {
+		log.Println("API令牌不能修改密码")
+		return mvc.Response{
+			Code: iris.StatusForbidden,
+			Text: "API令牌不能修改密码",
+		}
+	}
+
+	// 头像未修改时不校验,兼容旧的单尺寸头像
 	var prevUser model.User
 	c.Db.Find(&prevUser, "username=?", user.Username)
-	if user.Password == "" {
+	if user.AvatarURI == prevUser.AvatarURI {
+		user.AvatarURI = ""
+	}
+
+	// 校验用户信息
+	if errs := service.ValidateUserUpdate(user); len(errs) != 0 {
+		return invalidParams(errs)
+	}
+	if user.AvatarURI != "" && user.AvatarURI != service.DefaultAvatar {
+		if _, err := c.Storage.Stat(user.AvatarURI); err != nil {
+			log.Println("头像文件不存在", user.AvatarURI, err)
+			return invalidParams([]model.FieldError{{Field: "avatarURI", Message: "头像文件不存在,请先上传头像"}})
+		}
+	}
+
+	// 修改邮箱后可以通过邮件重置密码,只能在登录会话中提供当前密码后修改
+	passwordChanged := user.Password != ""
+	emailChanged := user.Email != "" && user.Email != prevUser.Email
+	if emailChanged && service.IsAPITokenUser(loginUser.(iris.SimpleUser)) {
+		log.Println("API令牌不能修改邮箱")
+		return mvc.Response{
+			Code: iris.StatusForbidden,
+			Text: "API令牌不能修改邮箱",
+		}
+	}
+	// 被盗用的会话不能直接改掉密码或邮箱
+	if passwordChanged || emailChanged {
+		if ok, _ := service.CheckPass(model.User{Username: user.Username, Password: user.CurrentPassword}, *c.Db); !ok {
+			log.Println("修改密码或邮箱时当前密码错误")
+			return mvc.Response{
+				Code: iris.StatusForbidden,
+				Text: "修改密码或邮箱需要提供正确的当前密码",
+			}
+		}
+	}
+
+	// 设置密码
+	if !passwordChanged {
 		user.Password = prevUser.Password
 	} else {
 		// 修改了密码
@@ -180,14 +270,127 @@ func (c *UserController) Put(user model.User) mvc.Result {
 		}
 	}
 
+	// 邮箱验证状态不能由用户直接修改,修改邮箱后需要重新验证
+	user.EmailVerified = prevUser.EmailVerified
+	if emailChanged {
+		log.Println("修改了邮箱")
+		user.EmailVerified = false
+	}
+
 	// 更新用户
 	c.Db.Where("username=?", user.Username).Updates(&user)
+	if emailChanged {
+		c.Db.Model(&model.User{}).Where("username=?", user.Username).Update("email_verified", false)
+		if user.Nickname == "" {
+			user.Nickname = prevUser.Nickname
+		}
+		go sendVerificationEmail(c.Mailer, user)
+	}
+	// 修改密码后所有设备重新登录
+	if passwordChanged {
+		service.RevokeAllTokens(c.Db, user.Username, false)
+	}
 	log.Println(user.Username, "用户信息更新完成")
 	return mvc.Response{
 		Code: iris.StatusNoContent,
 	}
 }
 
+// PostEmailVerification 重新发送邮箱验证邮件
+// @Summary 重新发送邮箱验证邮件
+// @Description 向用户自己尚未验证的邮箱重新发送验证邮件
+// @Tags user
+// @Success 204 {object} nil "验证邮件已发送，无返回内容"
+// @Failure 400 {object} string "没有填写邮箱或邮箱已验证"
+// @Failure 401 {object} string "未授权，用户未登录或会话失效"
+// @Failure 500 {object} string "服务器内部错误"
+// @Router /user/email/verification [post]
+// @Security BearerAuth
+func (c *UserController) PostEmailVerification() mvc.Result {
+	// 获取用户名
+	loginUser, err := c.Ctx.User().GetRaw()
+	if err != nil {
+		return mvc.Response{
+			Code: iris.StatusUnauthorized,
+			Text: iris.StatusText(iris.StatusUnauthorized),
+		}
+	}
+	loginUserName := loginUser.(iris.SimpleUser).Username
+	log.Println("用户", loginUserName, "重新发送验证邮件")
+
+	var user model.User
+	c.Db.Where("username=?", loginUserName).Find(&user)
+	if user.Email == "" || user.EmailVerified {
+		return mvc.Response{
+			Code: iris.StatusBadRequest,
+			Text: "没有填写邮箱或邮箱已验证",
+		}
+	}
+
+	if err := service.SendVerificationEmail(c.Mailer, user); err != nil {
+		log.Println("用户", loginUserName, "验证邮件发送失败", err)
+		return mvc.Response{
+			Code: iris.StatusInternalServerError,
+			Text: err.Error(),
+		}
+	}
+	return mvc.Response{
+		Code: iris.StatusNoContent,
+	}
+}
+
+// PostLogout 退出登录
+// @Summary 退出登录
+// @Description 注销当前使用的访问令牌及其对应的刷新令牌
+// @Tags user
+// @Success 204 {object} nil "退出登录成功，无返回内容"
+// @Failure 401 {object} string "未授权，用户未登录或会话失效"
+// @Router /user/logout [post]
+// @Security BearerAuth
+func (c *UserController) PostLogout() mvc.Result {
+	// 获取用户名
+	loginUser, err := c.Ctx.User().GetRaw()
+	if err != nil {
+		return mvc.Response{
+			Code: iris.StatusUnauthorized,
+			Text: iris.StatusText(iris.StatusUnauthorized),
+		}
+	}
+	log.Println("用户", loginUser.(iris.SimpleUser).Username, "退出登录")
+
+	service.RevokeCurrentToken(c.Db, loginUser.(iris.SimpleUser))
+	return mvc.Response{
+		Code: iris.StatusNoContent,
+	}
+}
+
+// PostLogoutAll 退出所有设备的登录
+// @Summary 退出所有设备的登录
+// @Description 注销用户在所有设备上签发的访问令牌和刷新令牌
+// @Tags user
+// @Success 204 {object} nil "退出登录成功，无返回内容"
+// @Failure 401 {object} string "未授权，用户未登录或会话失效"
+// @Router /user/logout/all [post]
+// @Security BearerAuth
+func (c *UserController) PostLogoutAll() mvc.Result {
+	// 获取用户名
+	loginUser, err := c.Ctx.User().GetRaw()
+	if err != nil {
+		return mvc.Response{
+			Code: iris.StatusUnauthorized,
+			Text: iris.StatusText(iris.StatusUnauthorized),
+		}
+	}
+	loginUserName := loginUser.(iris.SimpleUser).Username
+	log.Println("用户", loginUserName, "退出所有设备的登录")
+
+	service.RevokeCurrentToken(c.Db, loginUser.(iris.SimpleUser))
+	service.RevokeAllTokens(c.Db, loginUserName, false)
+	return mvc.Response{
+		Code: iris.StatusNoContent,
+	}
+}
+
 // GetStar 获取用户的收藏信息
 // @Summary 获取用户的收藏信息
 // @Description 查询用户自己的所有收藏信息
@@ -222,7 +425,7 @@ func (c *UserController) GetStar() mvc.Result {
 
 // PostStar 用户收藏图片
 // @Summary 用户收藏图片
-// @Description 用户收藏指定的图片
+// @Description 用户收藏指定的图片(作品),多页作品只需收藏作品id(即第一页的id)
 // @Tags user
 // @Accept json
 // @Produce json
@@ -272,6 +475,14 @@ func (c *UserController) PostStar(star model.Star) mvc.Result {
 		var image model.Image
 		res.Decode(&image)
 
+		// 验证是否有权查看
+		if !service.CanViewImage(c.Db, image, loginUserName) {
+			return mvc.Response{
+				Code: iris.StatusBadRequest,
+				Text: "图片不存在",
+			}
+		}
+
 		// 验证图片是否被封
 		if image.IsBan || image.AuthIsBan {
 			return mvc.Response{
@@ -357,3 +568,133 @@ func (c *UserController) DeleteStar(star model.Star) mvc.Result {
 		}
 	}
 }
+
+// GetFollow 查询关注
+// @Summary 获取用户关注的所有用户
+// @Description 获取当前用户关注的所有用户,关注后可以查看作者仅关注者可见的图片
+// @Tags user
+// @Accept json
+// @Produce json
+// @Success 200 {array} model.Follow "返回用户的所有关注记录"
+// @Failure 401 {object} string "未授权，用户未登录或会话失效"
+// @Router /user/follow [get]
+// @Security BearerAuth
+func (c *UserController) GetFollow() mvc.Result {
+	// 获取用户名
+	loginUser, err := c.Ctx.User().GetRaw()
+	if err != nil {
+		return mvc.Response{
+			Code: iris.StatusUnauthorized,
+			Text: iris.StatusText(iris.StatusUnauthorized),
+		}
+	}
+	loginUserName := loginUser.(iris.SimpleUser).Username
+	log.Println("用户", loginUserName, "查询关注")
+
+	// 读取所有关注信息
+	var follows []model.Follow
+	c.Db.Where("username=?", loginUserName).Find(&follows)
+
+	return mvc.Response{
+		Code:   iris.StatusOK,
+		Object: follows,
+	}
+}
+
+// PostFollow 关注用户
+// @Summary 关注用户
+// @Description 关注指定的用户
+// @Tags user
+// @Accept json
+// @Produce json
+// @Param follow body model.Follow true "关注信息,只需要关注的用户名"
+// @Success 204 {object} nil "关注成功，无返回内容"
+// @Failure 400 {object} string "请求错误，用户不存在,不能关注自己或已关注"
+// @Failure 401 {object} string "未授权，用户未登录或会话失效"
+// @Router /user/follow [post]
+// @Security BearerAuth
+func (c *UserController) PostFollow(follow model.Follow) mvc.Result {
+	follow.ID = 0
+
+	// 获取用户名
+	loginUser, err := c.Ctx.User().GetRaw()
+	if err != nil {
+		return mvc.Response{
+			Code: iris.StatusUnauthorized,
+			Text: iris.StatusText(iris.StatusUnauthorized),
+		}
+	}
+	loginUserName := loginUser.(iris.SimpleUser).Username
+	log.Println("用户", loginUserName, "关注用户", follow.Following)
+	follow.Username = loginUserName
+
+	if follow.Following == loginUserName {
+		return mvc.Response{
+			Code: iris.StatusBadRequest,
+			Text: "不能关注自己",
+		}
+	}
+
+	// 验证用户是否存在
+	var count int64
+	if c.Db.Model(&model.User{}).Where("username=?", follow.Following).Count(&count); count == 0 {
+		return mvc.Response{
+			Code: iris.StatusBadRequest,
+			Text: "用户不存在",
+		}
+	}
+
+	// 验证是否已关注
+	if c.Db.Model(&model.Follow{}).Where("username=? AND following=?", follow.Username, follow.Following).Count(&count); count >= 1 {
+		log.Println("重复关注")
+		return mvc.Response{
+			Code: iris.StatusBadRequest,
+			Text: "已关注,请勿重复关注",
+		}
+	}
+
+	// 记录关注信息
+	c.Db.Create(&follow)
+
+	return mvc.Response{
+		Code: iris.StatusNoContent,
+	}
+}
+
+// DeleteFollow 取消关注
+// @Summary 取消关注用户
+// @Description 取消关注指定的用户
+// @Tags user
+// @Accept json
+// @Produce json
+// @Param follow body model.Follow true "关注信息,只需要关注的用户名"
+// @Success 204 {object} nil "取消关注成功，无返回内容"
+// @Failure 400 {object} string "请求错误，关注记录不存在"
+// @Failure 401 {object} string "未授权，用户未登录或会话失效"
+// @Router /user/follow [delete]
+// @Security BearerAuth
+func (c *UserController) DeleteFollow(follow model.Follow) mvc.Result {
+	// 获取用户名
+	loginUser, err := c.Ctx.User().GetRaw()
+	if err != nil {
+		return mvc.Response{
+			Code: iris.StatusUnauthorized,
+			Text: iris.StatusText(iris.StatusUnauthorized),
+		}
+	}
+	loginUserName := loginUser.(iris.SimpleUser).Username
+	log.Println("用户", loginUserName, "取消关注", follow.Following)
+
+	// 从数据库中删除
+	if c.Db.Where("username=? AND following=?", loginUserName, follow.Following).Delete(&model.Follow{}).RowsAffected == 0 {
+		log.Println("关注记录不存在")
+		return mvc.Response{
+			Code: iris.StatusBadRequest,
+			Text: "关注记录不存在",
+		}
+	}
+
+	return mvc.Response{
+		Code: iris.StatusNoContent,
+	}
+}
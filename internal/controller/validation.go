@@ -0,0 +1,60 @@
+package controller
+
+import (
+	"PaintingExchange/internal/model"
+	"PaintingExchange/internal/service"
+	"errors"
+	"github.com/kataras/iris/v12"
+	"github.com/kataras/iris/v12/mvc"
+	"log"
+	"net/http"
+)
+
+// invalidParams 请求参数校验失败的响应
+func invalidParams(errs []model.FieldError) mvc.Result {
+	log.Println("请求参数校验失败", errs)
+	return mvc.Response{
+		Code: iris.StatusBadRequest,
+		Object: model.ValidationError{
+			Message: "请求参数校验失败",
+			Errors:  errs,
+		},
+	}
+}
+
+// readUpload 读取并检查上传的图片文件,失败时返回对应的响应
+func readUpload(ctx iris.Context, field string) (service.Upload, mvc.Result) {
+	ctx.SetMaxRequestBodySize(service.MaxUploadBody())
+	file, info, err := ctx.FormFile(field)
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		err = service.ErrUploadTooLarge
+	} else if err != nil {
+		log.Println("图片文件上传失败", err)
+		return service.Upload{}, mvc.Response{
+			Code: iris.StatusBadRequest,
+			Text: err.Error(),
+		}
+	} else {
+		defer file.Close()
+		var upload service.Upload
+		if upload, err = service.ReadUpload(file, info); err == nil {
+			return upload, nil
+		}
+	}
+
+	log.Println("图片文件检查失败", err)
+	code := iris.StatusInternalServerError
+	switch {
+	case errors.Is(err, service.ErrUploadTooLarge):
+		code = iris.StatusRequestEntityTooLarge
+	case errors.Is(err, service.ErrUploadType):
+		code = iris.StatusUnsupportedMediaType
+	case errors.Is(err, service.ErrUploadPixels):
+		code = iris.StatusBadRequest
+	}
+	return service.Upload{}, mvc.Response{
+		Code: code,
+		Text: err.Error(),
+	}
+}
@@ -1,6 +1,10 @@
 package env
 
-import "os"
+import (
+	"os"
+	"strconv"
+	"time"
+)
 
 // GetEnv 获取环境变量
 func GetEnv(key string, value string) string {
@@ -11,9 +15,107 @@ func GetEnv(key string, value string) string {
 	return env
 }
 
-// GetJWTKey 获取jwt密钥
-func GetJWTKey() []byte {
-	return []byte("PaintingExchange")
+// GetDurationEnv 获取时长类型的环境变量(如 15m, 720h),格式错误时使用默认值
+func GetDurationEnv(key string, value time.Duration) time.Duration {
+	duration, err := time.ParseDuration(GetEnv(key, ""))
+	if err != nil || duration <= 0 {
+		return value
+	}
+	return duration
+}
+
+// GetJWTKeyDir 获取jwt密钥目录
+func GetJWTKeyDir() string {
+	return GetEnv("jwtKeyDir", "keys")
+}
+
+// GetJWTSecret 获取通过环境变量配置的HS256密钥
+func GetJWTSecret() string {
+	return GetEnv("jwtSecret", "")
+}
+
+// GetJWTSigningKid 获取签发令牌使用的密钥id
+func GetJWTSigningKid() string {
+	return GetEnv("jwtSigningKid", "")
+}
+
+// GetAccessTokenTTL 获取访问令牌有效期
+func GetAccessTokenTTL() time.Duration {
+	return GetDurationEnv("accessTokenTTL", 15*time.Minute)
+}
+
+// GetRefreshTokenTTL 获取刷新令牌有效期
+func GetRefreshTokenTTL() time.Duration {
+	return GetDurationEnv("refreshTokenTTL", 30*24*time.Hour)
+}
+
+// GetIntEnv 获取整数类型的环境变量,格式错误时使用默认值
+func GetIntEnv(key string, value int) int {
+	res, err := strconv.Atoi(GetEnv(key, ""))
+	if err != nil || res <= 0 {
+		return value
+	}
+	return res
+}
+
+// GetLoginLimit 获取登录限制配置:同一用户名和同一IP允许的连续失败次数,首次退避时长和锁定时长
+func GetLoginLimit() (userFailures int, ipFailures int, baseDelay time.Duration, lockout time.Duration) {
+	return GetIntEnv("loginMaxFailures", 5),
+		GetIntEnv("loginMaxIPFailures", 20),
+		GetDurationEnv("loginBaseDelay", time.Second),
+		GetDurationEnv("loginLockout", 15*time.Minute)
+}
+
+// GetMailLimit 获取重置密码邮件的频率限制:同一账号和同一IP允许的请求次数,首次请求后的等待时长和锁定时长
+func GetMailLimit() (accountRequests int, ipRequests int, baseDelay time.Duration, lockout time.Duration) {
+	return GetIntEnv("mailMaxRequests", 3),
+		GetIntEnv("mailMaxIPRequests", 10),
+		GetDurationEnv("mailBaseDelay", time.Minute),
+		GetDurationEnv("mailLockout", time.Hour)
+}
+
+// GetMFAIssuer 获取验证器应用中显示的服务名称
+func GetMFAIssuer() string {
+	return GetEnv("mfaIssuer", "PaintingExchange")
+}
+
+// GetAdminRequireMFA 获取管理员登录是否强制两步验证
+func GetAdminRequireMFA() bool {
+	return GetEnv("adminRequireMFA", "false") == "true"
+}
+
+// GetMailer 获取邮件发送方式(smtp或log)
+func GetMailer() string {
+	return GetEnv("mailer", "log")
+}
+
+// GetSMTP 获取SMTP服务器地址,认证信息和发件人
+func GetSMTP() (addr string, username string, password string, from string) {
+	return GetEnv("smtpAddr", "localhost:25"),
+		GetEnv("smtpUsername", ""),
+		GetEnv("smtpPassword", ""),
+		GetEnv("smtpFrom", "noreply@localhost")
+}
+
+// GetSiteURL 获取前端站点地址,用于生成邮件中的链接
+func GetSiteURL() string {
+	return GetEnv("siteURL", "http://localhost")
+}
+
+// IsSiteURLSet 是否显式配置了前端站点地址
+func IsSiteURLSet() bool {
+	_, ok := os.LookupEnv("siteURL")
+	return ok
+}
+
+// GetOIDCConfig 获取OIDC身份提供方配置文件路径
+func GetOIDCConfig() string {
+	return GetEnv("oidcConfig", "oidc.json")
+}
+
+// GetBootstrapAdmin 获取初始超级管理员的用户名和密码
+func GetBootstrapAdmin() (string, string) {
+	return GetEnv("adminUsername", ""), GetEnv("adminPassword", "")
 }
 
 func GetImgDir() string {
@@ -23,3 +125,86 @@ func GetImgDir() string {
 func GetAvatarDir() string {
 	return "assert/avatars"
 }
+
+// GetOriginDir 获取上传原图的保存目录(不对外提供访问)
+func GetOriginDir() string {
+	return "assert/originals"
+}
+
+// GetImageQueue 获取图片处理队列的并发数,队列长度,最多尝试次数和首次重试间隔
+func GetImageQueue() (workers int, size int, maxAttempts int, retryDelay time.Duration) {
+	return GetIntEnv("imageWorkers", 2),
+		GetIntEnv("imageQueueSize", 100),
+		GetIntEnv("imageMaxAttempts", 3),
+		GetDurationEnv("imageRetryDelay", 5*time.Second)
+}
+
+// GetStorage 获取文件存储类型(local或s3)
+func GetStorage() string {
+	return GetEnv("storage", "local")
+}
+
+// S3Config S3兼容对象存储配置
+type S3Config struct {
+	Endpoint  string // 服务地址,如 http://minio:9000
+	Region    string
+	Bucket    string
+	AccessKey string
+	SecretKey string
+	PathStyle bool   // 使用 endpoint/bucket/key 形式的地址(MinIO等需要)
+	PublicURL string // 公开访问地址,为空时使用预签名地址
+}
+
+// GetS3Config 获取S3兼容对象存储配置
+func GetS3Config() S3Config {
+	return S3Config{
+		Endpoint:  GetEnv("s3Endpoint", ""),
+		Region:    GetEnv("s3Region", "us-east-1"),
+		Bucket:    GetEnv("s3Bucket", ""),
+		AccessKey: GetEnv("s3AccessKey", ""),
+		SecretKey: GetEnv("s3SecretKey", ""),
+		PathStyle: GetEnv("s3PathStyle", "true") == "true",
+		PublicURL: GetEnv("s3PublicURL", ""),
+	}
+}
+
+// GetFileGC 获取无用文件清理的模式(on,dryrun,off),执行间隔和宽限期
+// 默认dryrun,只记录无用文件,确认无误后再设置为on删除
+// 宽限期内新上传的文件即使没有被引用也不会被清理
+func GetFileGC() (mode string, interval time.Duration, grace time.Duration) {
+	return GetEnv("fileGC", "dryrun"),
+		GetDurationEnv("fileGCInterval", 24*time.Hour),
+		GetDurationEnv("fileGCGrace", 24*time.Hour)
+}
+
+// GetUploadLimit 获取上传图片的大小上限(字节),最大边长和最大像素数
+func GetUploadLimit() (maxSize int64, maxSide int, maxPixels int) {
+	return int64(GetIntEnv("uploadMaxMB", 20)) << 20,
+		GetIntEnv("uploadMaxSide", 16384),
+		GetIntEnv("uploadMaxPixels", 50000000)
+}
+
+// GetImageSizes 获取图片尺寸阶梯的计算方式(height,width,edge)和逗号分隔的尺寸列表
+func GetImageSizes() (mode string, sizes string) {
+	return GetEnv("imageSizeMode", "height"),
+		GetEnv("imageSizes", "3000,1600,800,300")
+}
+
+// GetDuplicatePolicy 获取疑似转载图片的处理方式(flag:标记待审核 reject:拒绝发布 off:不检测)和判定为相似的最大汉明距离
+func GetDuplicatePolicy() (policy string, maxDistance int) {
+	return GetEnv("duplicatePolicy", "flag"),
+		GetIntEnv("duplicateDistance", 6)
+}
+
+// GetTimelapse 获取创作过程延时视频的帧率,每个阶段的停留时间,交叉淡化时间和视频最长边
+func GetTimelapse() (fps int, hold time.Duration, fade time.Duration, maxSide int) {
+	return GetIntEnv("timelapseFPS", 24),
+		GetDurationEnv("timelapseHold", 1500*time.Millisecond),
+		GetDurationEnv("timelapseFade", time.Second),
+		GetIntEnv("timelapseMaxSide", 1080)
+}
+
+// GetPublishInterval 获取检查定时发布图片的间隔
+func GetPublishInterval() time.Duration {
+	return GetDurationEnv("publishInterval", time.Minute)
+}
@@ -1,8 +1,20 @@
 package model
 
+import "time"
+
 // Admin 管理员
 // @Description 管理员
 type Admin struct {
-	Username string `json:"username" example:"admin"`
-	Password string `json:"password" example:"admin"`
+	Username   string    `gorm:"primary_key" json:"username" example:"admin"`             // 用户名
+	Password   string    `gorm:"not null" json:"password" example:"admin"`                // 密码
+	Role       string    `gorm:"size:32;default:admin" json:"role" example:"admin"`       // 角色(moderator,admin,superAdmin)
+	IsDisabled bool      `json:"isDisabled" example:"false"`                              // 是否被停用
+	CreatedAt  time.Time `json:"createdAt" example:"2024-12-03T10:18:36.897966604+08:00"` // 创建时间
+}
+
+// PasswordChange 修改密码
+// @Description 修改密码
+type PasswordChange struct {
+	OldPassword string `json:"oldPassword" example:"admin"`  // 原密码
+	NewPassword string `json:"newPassword" example:"123456"` // 新密码
 }
@@ -0,0 +1,32 @@
+package model
+
+import "time"
+
+// APIToken 用户创建的API令牌,用于脚本等非交互式访问
+// @Description API令牌(不含令牌明文)
+type APIToken struct {
+	ID         string     `gorm:"primary_key;size:36" json:"id" example:"294eacc6-e27a-41ed-8905-9e3e254e3bd8"` // 令牌id
+	Username   string     `gorm:"index;size:191" json:"-"`                                                      // 所属用户名
+	Name       string     `json:"name" example:"批量上传脚本"`                                                        // 令牌名称
+	Hash       string     `gorm:"uniqueIndex;size:64" json:"-"`                                                 // 令牌的sha256哈希
+	Prefix     string     `json:"prefix" example:"pe_Xk3d9a"`                                                   // 令牌开头几位,便于辨认
+	Scopes     []string   `gorm:"serializer:json" json:"scopes" example:"image:read,image:write"`               // 授权范围
+	ExpiresAt  *time.Time `json:"expiresAt" example:"2025-12-03T10:18:36.897966604+08:00"`                      // 过期时间,为空表示永不过期
+	LastUsedAt *time.Time `json:"lastUsedAt" example:"2024-12-04T10:18:36.897966604+08:00"`                     // 最近一次使用时间
+	CreatedAt  time.Time  `json:"createdAt" example:"2024-12-03T10:18:36.897966604+08:00"`                      // 创建时间
+}
+
+// APITokenCreate 创建API令牌
+// @Description 创建API令牌
+type APITokenCreate struct {
+	Name      string     `json:"name" example:"批量上传脚本"`                                   // 令牌名称
+	Scopes    []string   `json:"scopes" example:"image:read,image:write"`                 // 授权范围
+	ExpiresAt *time.Time `json:"expiresAt" example:"2025-12-03T10:18:36.897966604+08:00"` // 过期时间,为空表示永不过期
+}
+
+// APITokenCreated 新创建的API令牌
+// @Description 新创建的API令牌,令牌明文只返回这一次
+type APITokenCreated struct {
+	APIToken
+	Token string `json:"token" example:"pe_Xk3d9a..."` // 令牌明文,请求时放在 Authorization: Bearer 中
+}
@@ -0,0 +1,12 @@
+package model
+
+import "time"
+
+// LoginAttempt 登录失败记录
+// @Description 登录失败记录(按用户名或IP统计)
+type LoginAttempt struct {
+	Key         string    `json:"key" example:"user:test"`                                   // 统计对象,格式为 user:用户名, admin:管理员用户名 或 ip:地址
+	Failures    int       `json:"failures" example:"5"`                                      // 连续失败次数
+	LastFailure time.Time `json:"lastFailure" example:"2024-12-03T10:18:36.897966604+08:00"` // 最近一次失败时间
+	LockedUntil time.Time `json:"lockedUntil" example:"2024-12-03T10:33:36.897966604+08:00"` // 在此时间之前禁止登录
+}
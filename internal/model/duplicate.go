@@ -0,0 +1,31 @@
+package model
+
+import "time"
+
+// ImageHash 图片的感知哈希索引
+// 哈希按16位分为4段分别建立索引(多索引哈希),查找相似图片时先按各段查找候选,不需要扫描全表
+type ImageHash struct {
+	ImageID   string    `gorm:"primary_key;size:36"` // 图片文件id(作品中的一页)
+	WorkID    string    `gorm:"index;size:36"`       // 所属图片(作品)id,旧数据为空时与ImageID相同
+	Username  string    `gorm:"index;size:191"`      // 作者用户名
+	Hash      uint64    // 64位差异哈希(dHash)
+	Band0     uint16    `gorm:"index"` // 哈希的第0-15位
+	Band1     uint16    `gorm:"index"` // 哈希的第16-31位
+	Band2     uint16    `gorm:"index"` // 哈希的第32-47位
+	Band3     uint16    `gorm:"index"` // 哈希的第48-63位
+	CreatedAt time.Time // 加入索引的时间
+}
+
+// DuplicateReport 疑似转载记录
+// @Description 疑似转载记录,新发布的图片与其他作者更早发布的图片相似
+type DuplicateReport struct {
+	ID               uint      `gorm:"primary_key" json:"id" example:"1"`                                              // 记录id
+	ImageID          string    `gorm:"index;size:36" json:"imageID" example:"294eacc6-e27a-41ed-8905-9e3e254e3bd8"`    // 疑似转载的图片id
+	Username         string    `gorm:"size:191" json:"username" example:"thief"`                                       // 疑似转载的图片作者
+	OriginalID       string    `gorm:"index;size:36" json:"originalID" example:"d18b9c4b-8d7f-407f-a630-cf2596bd7511"` // 相似的已有图片id
+	OriginalUsername string    `gorm:"size:191" json:"originalUsername" example:"test"`                                // 已有图片作者
+	Distance         int       `json:"distance" example:"3"`                                                           // 哈希的汉明距离(0-64)
+	Similarity       float64   `json:"similarity" example:"0.953"`                                                     // 相似度(0-1)
+	Status           string    `gorm:"index;size:16" json:"status" example:"pending"`                                  // 审核状态(pending,confirmed,dismissed)
+	CreatedAt        time.Time `json:"createdAt" example:"2024-12-03T10:18:36.897966604+08:00"`                        // 发现时间
+}
@@ -0,0 +1,22 @@
+package model
+
+import "time"
+
+// OrphanFile 没有被任何图片或用户引用的文件
+// @Description 无用文件
+type OrphanFile struct {
+	Key     string    `json:"key" example:"assert/images/big_294eacc6-e27a-41ed-8905-9e3e254e3bd8.jpg"` // 文件路径
+	Size    int64     `json:"size" example:"204800"`                                                    // 文件大小(字节)
+	ModTime time.Time `json:"modTime" example:"2024-12-03T10:18:36.897966604+08:00"`                    // 修改时间
+}
+
+// FileGCReport 无用文件清理报告
+// @Description 无用文件清理报告
+type FileGCReport struct {
+	DryRun     bool         `json:"dryRun" example:"true"`                                   // 是否只报告不删除
+	Scanned    int          `json:"scanned" example:"120"`                                   // 检查的文件数
+	Orphans    []OrphanFile `json:"orphans"`                                                 // 无用文件
+	Deleted    int          `json:"deleted" example:"0"`                                     // 已删除的文件数
+	FreedBytes int64        `json:"freedBytes" example:"0"`                                  // 释放的空间(字节)
+	StartedAt  time.Time    `json:"startedAt" example:"2024-12-03T10:18:36.897966604+08:00"` // 开始时间
+}
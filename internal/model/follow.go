@@ -0,0 +1,9 @@
+package model
+
+// Follow 关注信息
+// @Description 关注信息
+type Follow struct {
+	ID        uint   `gorm:"primary_key" swaggerignore:"true"`                  // 主键
+	Username  string `gorm:"index;size:191" json:"username" example:"test"`     // 用户名
+	Following string `gorm:"index;size:191" json:"following" example:"painter"` // 关注的用户名
+}
@@ -0,0 +1,33 @@
+package model
+
+import "time"
+
+// Identity 关联到用户的外部身份(OIDC)
+// @Description 关联到用户的外部身份
+type Identity struct {
+	ID        uint      `gorm:"primary_key" json:"id" example:"1"`                                 // 关联id
+	Provider  string    `gorm:"uniqueIndex:idx_identity;size:64" json:"provider" example:"google"` // 身份提供方名称
+	Subject   string    `gorm:"uniqueIndex:idx_identity;size:191" json:"-"`                        // 身份提供方中的用户标识(sub)
+	Username  string    `gorm:"index;size:191" json:"-"`                                           // 关联的用户名
+	Email     string    `json:"email" example:"test@example.com"`                                  // 身份提供方给出的邮箱
+	CreatedAt time.Time `json:"createdAt" example:"2024-12-03T10:18:36.897966604+08:00"`           // 关联时间
+}
+
+// OIDCProvider 可用的身份提供方
+// @Description 可用的身份提供方
+type OIDCProvider struct {
+	Name        string `json:"name" example:"google"`        // 名称,用于 /oidc/{name}/login
+	DisplayName string `json:"displayName" example:"Google"` // 显示名称
+}
+
+// IdentityLink 关联外部身份
+// @Description 关联外部身份,令牌来自 /user/identities/link/{provider} 发起的流程完成后跳转地址中的linkToken
+type IdentityLink struct {
+	LinkToken string `json:"linkToken" example:"eyJhbGciOiJIUzI1NiIsInR5cCI6IkpXVCJ9..."` // 关联令牌
+}
+
+// IdentityLinkStart 发起关联外部身份
+// @Description 发起关联外部身份,前端将浏览器跳转到url
+type IdentityLinkStart struct {
+	URL string `json:"url" example:"https://accounts.google.com/o/oauth2/v2/auth?response_type=code&..."` // 身份提供方的授权地址
+}
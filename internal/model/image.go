@@ -2,18 +2,127 @@ package model
 
 import "time"
 
-// Image 图片
-// @Description 图片
+// Image 图片(作品),可以包含多页,标题,简介,标签,收藏和搜索都以作品为单位
+// @Description 图片(作品),可以包含多页
 type Image struct {
-	ID        string    `json:"id" bson:"_id" example:"294eacc6-e27a-41ed-8905-9e3e254e3bd8"`                              // 图片id(UUID)
-	Auth      string    `json:"auth" bson:"auth" example:"test"`                                                           // 图片作者用户名
-	BigURI    string    `json:"bigURI" bson:"bigURI" example:"assert/images/big_294eacc6-e27a-41ed-8905-9e3e254e3bd8.jpg"` // 大图地址
-	MidURI    string    `json:"midURI" bson:"midURI" example:"assert/images/mid_294eacc6-e27a-41ed-8905-9e3e254e3bd8.jpg"` // 中图地址
-	Title     string    `json:"title" bson:"title" example:"test"`                                                         // 图片标题
-	Label     []string  `json:"label" bson:"label"`                                                                        // 图片标签
-	Intro     string    `json:"intro" bson:"intro"`                                                                        // 图片简介
-	Like      int       `json:"like" bson:"like" example:"0"`                                                              // 收藏人数
-	CreatedAt time.Time `json:"createAt" bson:"createAt" example:"2024-12-03T10:18:36.897966604+08:00"`                    // 创建时间
-	IsBan     bool      `json:"isBan" bson:"isBan" example:"false"`                                                        // 是否被ban
-	AuthIsBan bool      `json:"authIsBan" bson:"authIsBan" example:"false"`                                                // 作者是否被封禁
+	ID         string     `json:"id" bson:"_id" example:"294eacc6-e27a-41ed-8905-9e3e254e3bd8"`                              // 图片id(UUID)
+	Auth       string     `json:"auth" bson:"auth" example:"test"`                                                           // 图片作者用户名
+	BigURI     string     `json:"bigURI" bson:"bigURI" example:"assert/images/big_294eacc6-e27a-41ed-8905-9e3e254e3bd8.jpg"` // 大图地址
+	MidURI     string     `json:"midURI" bson:"midURI" example:"assert/images/mid_294eacc6-e27a-41ed-8905-9e3e254e3bd8.jpg"` // 中图地址
+	Title      string     `json:"title" bson:"title" example:"test"`                                                         // 图片标题
+	Label      []string   `json:"label" bson:"label"`                                                                        // 图片标签
+	Intro      string     `json:"intro" bson:"intro"`                                                                        // 图片简介
+	Like       int        `json:"like" bson:"like" example:"0"`                                                              // 收藏人数
+	CreatedAt  time.Time  `json:"createAt" bson:"createAt" example:"2024-12-03T10:18:36.897966604+08:00"`                    // 创建时间
+	IsBan      bool       `json:"isBan" bson:"isBan" example:"false"`                                                        // 是否被ban
+	AuthIsBan  bool       `json:"authIsBan" bson:"authIsBan" example:"false"`                                                // 作者是否被封禁
+	Status     string     `json:"status" bson:"status" example:"ready"`                                                      // 处理状态(pending,ready,failed),为空视为ready
+	Visibility string     `json:"visibility" bson:"visibility" example:"public"`                                             // 可见性(public,unlisted,followers,private),为空视为public
+	PublishAt  *time.Time `json:"publishAt,omitempty" bson:"publishAt,omitempty" example:"2024-12-24T20:00:00+08:00"`        // 定时发布时间,到达后可见性改为publishVisibility
+
+	PublishVisibility string     `json:"publishVisibility,omitempty" bson:"publishVisibility,omitempty" example:"public"`        // 定时发布后的可见性,定时发布期间设置的visibility保存在这里,发布前为private
+	PublishedAt       *time.Time `json:"publishedAt,omitempty" bson:"publishedAt,omitempty" example:"2024-12-24T20:00:00+08:00"` // 公开的时间,最新图片按此排序
+
+	CancelPublish bool `json:"cancelPublish,omitempty" bson:"-" example:"false"` // 修改图片时设置为true取消定时发布,不保存
+
+	Rating       string `json:"rating" bson:"rating" example:"sfw"`               // 内容分级(sfw,nsfw),为空视为sfw
+	RatingLocked bool   `json:"ratingLocked" bson:"ratingLocked" example:"false"` // 分级是否由管理员锁定,锁定后作者不能修改
+	Redacted     bool   `json:"redacted,omitempty" bson:"-" example:"false"`      // 是否按用户的分级偏好隐去了图片,为true时前端显示模糊占位图
+
+	Sizes    []ImageSize    `json:"sizes,omitempty" bson:"sizes,omitempty"`       // 各尺寸图片,按宽度从小到大排列,可直接用于srcset
+	Variants []ImageVariant `json:"variants,omitempty" bson:"variants,omitempty"` // 其他格式的图片,访问各尺寸图片时会根据Accept请求头自动选择
+	Metadata *ImageMetadata `json:"metadata,omitempty" bson:"metadata,omitempty"` // 原图信息
+
+	Pages []ImagePage `json:"pages,omitempty" bson:"pages,omitempty"` // 各页图片,按顺序排列;为空时(旧数据)只有一页,即上面的各地址
+	Cover string      `json:"cover,omitempty" bson:"cover,omitempty"` // 封面页id,上面的地址,尺寸,其他格式和原图信息均为封面页的
+
+	Stages    []ImageStage `json:"stages,omitempty" bson:"stages,omitempty"`       // 创作过程中的阶段图片(草稿,线稿,铺色等),按上传顺序排列
+	Timelapse *Timelapse   `json:"timelapse,omitempty" bson:"timelapse,omitempty"` // 由阶段图片生成的创作过程延时视频
+}
+
+// ImagePage 作品中的一页图片
+// @Description 作品中的一页图片
+type ImagePage struct {
+	ID       string         `json:"id" bson:"id" example:"294eacc6-e27a-41ed-8905-9e3e254e3bd8"`                               // 图片文件id,即 /image/file [POST] 返回的id
+	BigURI   string         `json:"bigURI" bson:"bigURI" example:"assert/images/big_294eacc6-e27a-41ed-8905-9e3e254e3bd8.jpg"` // 大图地址
+	MidURI   string         `json:"midURI" bson:"midURI" example:"assert/images/mid_294eacc6-e27a-41ed-8905-9e3e254e3bd8.jpg"` // 中图地址
+	Status   string         `json:"status" bson:"status" example:"ready"`                                                      // 处理状态(pending,ready,failed)
+	Sizes    []ImageSize    `json:"sizes,omitempty" bson:"sizes,omitempty"`                                                    // 各尺寸图片
+	Variants []ImageVariant `json:"variants,omitempty" bson:"variants,omitempty"`                                              // 其他格式的图片
+	Metadata *ImageMetadata `json:"metadata,omitempty" bson:"metadata,omitempty"`                                              // 原图信息
+
+	Revisions []ImageRevision `json:"-" bson:"revisions,omitempty"` // 历史版本,只有作者可以查看
+	Replacing string          `json:"-" bson:"replacing,omitempty"` // 正在处理的新版本文件id,处理完成后替换当前文件
+}
+
+// ImageRevision 一页图片被替换前的历史版本
+// @Description 一页图片的历史版本
+type ImageRevision struct {
+	ImagePage  `bson:",inline"`
+	ReplacedAt time.Time `json:"replacedAt" bson:"replacedAt" example:"2024-12-03T10:18:36.897966604+08:00"` // 被替换的时间
+}
+
+// PageHistory 一页图片的当前版本和历史版本
+// @Description 一页图片的当前版本和历史版本
+type PageHistory struct {
+	Current   ImagePage       `json:"current"`             // 当前版本
+	Replacing string          `json:"replacing,omitempty"` // 正在处理的新版本文件id,可通过 /image/file/{imageID} [GET] 查询处理状态
+	Revisions []ImageRevision `json:"revisions"`           // 历史版本,按替换时间从早到晚排列
+}
+
+// RevisionRollback 回滚到历史版本
+// @Description 回滚到历史版本
+type RevisionRollback struct {
+	Page     string `json:"page" example:"294eacc6-e27a-41ed-8905-9e3e254e3bd8"`     // 当前版本的文件id
+	Revision string `json:"revision" example:"d18b9c4b-8d7f-407f-a630-cf2596bd7511"` // 要回滚到的历史版本的文件id
+}
+
+// ImageStage 创作过程中的一个阶段
+// @Description 创作过程中的一个阶段
+type ImageStage struct {
+	ImagePage `bson:",inline"`
+	Name      string    `json:"name" bson:"name" example:"线稿"`                                            // 阶段名称
+	CreatedAt time.Time `json:"createdAt" bson:"createdAt" example:"2024-12-03T10:18:36.897966604+08:00"` // 上传时间
+}
+
+// Timelapse 创作过程延时视频,依次展示各阶段和最终图片,阶段之间交叉淡化
+// @Description 创作过程延时视频
+type Timelapse struct {
+	Status    string    `json:"status" bson:"status" example:"ready"`                                                   // 生成状态(pending,ready,failed)
+	VideoURI  string    `json:"videoURI,omitempty" bson:"videoURI,omitempty" example:"assert/images/timelapse_xxx.mp4"` // MP4视频地址
+	GifURI    string    `json:"gifURI,omitempty" bson:"gifURI,omitempty" example:"assert/images/timelapse_xxx.gif"`     // GIF动图地址
+	Error     string    `json:"error,omitempty" bson:"error,omitempty" example:"没有可用的阶段图片"`                             // 失败原因
+	UpdatedAt time.Time `json:"updatedAt" bson:"updatedAt" example:"2024-12-03T10:18:36.897966604+08:00"`               // 最近一次生成时间
+}
+
+// PageOrder 作品的页面顺序和封面
+// @Description 作品的页面顺序和封面
+type PageOrder struct {
+	Pages []string `json:"pages"`                                                // 按新顺序排列的全部页面id
+	Cover string   `json:"cover" example:"294eacc6-e27a-41ed-8905-9e3e254e3bd8"` // 封面页id,为空时使用第一页
+}
+
+// ImageMetadata 从原图EXIF中提取的非敏感信息,GPS位置,相机型号和序列号等不会保存
+// @Description 原图信息
+type ImageMetadata struct {
+	Software   string     `json:"software,omitempty" bson:"software,omitempty" example:"Procreate"`                     // 创作软件
+	Width      int        `json:"width" bson:"width" example:"4000"`                                                    // 原图宽度(已按拍摄方向旋转)
+	Height     int        `json:"height" bson:"height" example:"3000"`                                                  // 原图高度
+	CapturedAt *time.Time `json:"capturedAt,omitempty" bson:"capturedAt,omitempty" example:"2024-12-01T15:04:05+08:00"` // 拍摄或创作时间
+}
+
+// ImageSize 图片尺寸
+// @Description 图片尺寸,用于生成srcset(如 "assert/images/w1600_xxx.jpg 1600w")
+type ImageSize struct {
+	Width  int    `json:"width" bson:"width" example:"1600"`                                                     // 宽度
+	Height int    `json:"height" bson:"height" example:"1200"`                                                   // 高度
+	URI    string `json:"uri" bson:"uri" example:"assert/images/w1600_294eacc6-e27a-41ed-8905-9e3e254e3bd8.jpg"` // 图片地址
+}
+
+// ImageVariant 其他格式的图片
+// @Description 其他格式的图片
+type ImageVariant struct {
+	Format string `json:"format" bson:"format" example:"webp"`                                                        // 格式(webp,avif)
+	BigURI string `json:"bigURI" bson:"bigURI" example:"assert/images/big_294eacc6-e27a-41ed-8905-9e3e254e3bd8.webp"` // 大图地址
+	MidURI string `json:"midURI" bson:"midURI" example:"assert/images/mid_294eacc6-e27a-41ed-8905-9e3e254e3bd8.webp"` // 中图地址
 }
@@ -0,0 +1,22 @@
+package model
+
+import "time"
+
+// ImageJob 图片处理任务,上传的原图在后台生成各尺寸的图片
+// @Description 图片处理任务
+type ImageJob struct {
+	ID        string         `gorm:"primary_key;size:36" json:"id" example:"294eacc6-e27a-41ed-8905-9e3e254e3bd8"` // 图片id
+	Username  string         `gorm:"index;size:191" json:"-"`                                                      // 上传者用户名
+	Source    string         `json:"-"`                                                                            // 原图路径
+	BigURI    string         `json:"bigURI" example:"assert/images/big_294eacc6-e27a-41ed-8905-9e3e254e3bd8.jpg"`  // 大图地址
+	MidURI    string         `json:"midURI" example:"assert/images/mid_294eacc6-e27a-41ed-8905-9e3e254e3bd8.jpg"`  // 中图地址
+	Sizes     []ImageSize    `gorm:"serializer:json" json:"sizes,omitempty"`                                       // 各尺寸图片
+	Variants  []ImageVariant `gorm:"serializer:json" json:"variants,omitempty"`                                    // 其他格式的图片
+	Metadata  *ImageMetadata `gorm:"serializer:json" json:"metadata,omitempty"`                                    // 原图信息
+	Hash      uint64         `json:"-"`                                                                            // 感知哈希,用于检测转载
+	Status    string         `gorm:"index;size:16" json:"status" example:"pending"`                                // 处理状态(pending,ready,failed)
+	Attempts  int            `json:"attempts" example:"1"`                                                         // 已尝试次数
+	Error     string         `json:"error,omitempty" example:"图片解码失败"`                                             // 失败原因
+	CreatedAt time.Time      `json:"createdAt" example:"2024-12-03T10:18:36.897966604+08:00"`                      // 上传时间
+	UpdatedAt time.Time      `json:"updatedAt" example:"2024-12-03T10:18:38.897966604+08:00"`                      // 最近一次处理时间
+}
@@ -0,0 +1,21 @@
+package model
+
+// JWKS JSON Web Key Set
+// @Description 用于验证访问令牌的公钥集合
+type JWKS struct {
+	Keys []JWK `json:"keys"` // 公钥列表
+}
+
+// JWK JSON Web Key(仅公钥)
+// @Description JSON Web Key(仅公钥)
+type JWK struct {
+	Kty string `json:"kty" example:"RSA"`               // 密钥类型
+	Kid string `json:"kid" example:"2024-12"`           // 密钥id
+	Use string `json:"use" example:"sig"`               // 用途
+	Alg string `json:"alg" example:"RS256"`             // 签名算法
+	N   string `json:"n,omitempty"`                     // RSA模数
+	E   string `json:"e,omitempty" example:"AQAB"`      // RSA指数
+	Crv string `json:"crv,omitempty" example:"Ed25519"` // 曲线(OKP,EC)
+	X   string `json:"x,omitempty"`                     // 公钥(OKP)或x坐标(EC)
+	Y   string `json:"y,omitempty"`                     // y坐标(EC)
+}
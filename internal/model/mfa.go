@@ -0,0 +1,38 @@
+package model
+
+import "time"
+
+// MFA 两步验证(TOTP)配置
+type MFA struct {
+	ID            uint      `gorm:"primary_key"`
+	Username      string    `gorm:"uniqueIndex:idx_mfa_account;size:191"` // 用户名
+	IsAdmin       bool      `gorm:"uniqueIndex:idx_mfa_account"`          // 是否为管理员账号
+	Secret        string    // TOTP密钥(base32)
+	Enabled       bool      // 是否已启用,绑定后需验证一次验证码才启用
+	LastStep      int64     // 最近一次使用的时间步,防止验证码重放
+	RecoveryCodes []string  `gorm:"serializer:json"` // 未使用的恢复码的sha256哈希
+	CreatedAt     time.Time // 绑定时间
+}
+
+// MFASetup 两步验证绑定信息
+// @Description 两步验证绑定信息,uri可直接生成二维码供验证器应用扫描
+type MFASetup struct {
+	Secret        string   `json:"secret,omitempty" example:"JBSWY3DPEHPK3PXPJBSWY3DPEHPK3PXP"`                                                  // TOTP密钥(base32)
+	URI           string   `json:"uri,omitempty" example:"otpauth://totp/PaintingExchange:test?secret=JBSWY3DPEHPK3PXP&issuer=PaintingExchange"` // otpauth地址(二维码内容)
+	RecoveryCodes []string `json:"recoveryCodes"`                                                                                                // 恢复码,每个只能使用一次,仅展示这一次
+}
+
+// MFAChallenge 两步验证挑战
+// @Description 密码验证通过但还需要两步验证时返回
+type MFAChallenge struct {
+	MFAToken       string    `json:"mfaToken" example:"eyJhbGciOiJIUzI1NiIsInR5cCI6IkpXVCJ9..."` // 两步验证令牌,只能用于完成两步验证
+	ExpiresAt      time.Time `json:"expiresAt" example:"2024-12-03T10:23:36.897966604+08:00"`    // 两步验证令牌过期时间
+	EnrollRequired bool      `json:"enrollRequired" example:"false"`                             // 是否需要先绑定两步验证(管理员强制两步验证时)
+}
+
+// MFAVerify 两步验证请求
+// @Description 两步验证请求
+type MFAVerify struct {
+	MFAToken string `json:"mfaToken" example:"eyJhbGciOiJIUzI1NiIsInR5cCI6IkpXVCJ9..."` // 两步验证令牌(登录时需要)
+	Code     string `json:"code" example:"123456"`                                      // 验证器上的6位验证码或恢复码
+}
@@ -0,0 +1,16 @@
+package model
+
+// RoleAssignment 普通用户的角色分配
+// @Description 普通用户的角色分配(用户无需单独的管理员账号即可获得管理权限)
+type RoleAssignment struct {
+	ID       uint   `gorm:"primary_key" json:"-"`                                              // 主键
+	Username string `gorm:"uniqueIndex:idx_user_role;size:191" json:"username" example:"test"` // 用户名
+	Role     string `gorm:"uniqueIndex:idx_user_role;size:32" json:"role" example:"moderator"` // 角色
+}
+
+// Permission 当前登录者的角色和权限
+// @Description 当前登录者的角色和权限
+type Permission struct {
+	Roles       []string `json:"roles" example:"moderator"`                  // 角色
+	Permissions []string `json:"permissions" example:"image:read,image:ban"` // 权限
+}
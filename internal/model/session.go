@@ -0,0 +1,18 @@
+package model
+
+import "time"
+
+// Session 登录会话,每次登录签发的令牌族对应一个会话
+// @Description 登录会话
+type Session struct {
+	ID         string    `gorm:"primary_key;size:36" json:"id" example:"294eacc6-e27a-41ed-8905-9e3e254e3bd8"`                     // 会话id
+	Username   string    `gorm:"index:idx_session_account;size:191" json:"-"`                                                      // 用户名
+	IsAdmin    bool      `gorm:"index:idx_session_account" json:"-"`                                                               // 是否为管理员账号
+	Device     string    `json:"device" example:"Chrome on Windows"`                                                               // 设备描述
+	UserAgent  string    `gorm:"size:512" json:"userAgent" example:"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36"` // 浏览器UA
+	IP         string    `json:"ip" example:"127.0.0.1"`                                                                           // 最近一次访问的IP
+	CreatedAt  time.Time `json:"createdAt" example:"2024-12-03T10:18:36.897966604+08:00"`                                          // 登录时间
+	LastSeenAt time.Time `json:"lastSeenAt" example:"2024-12-03T11:18:36.897966604+08:00"`                                         // 最近一次访问时间
+	ExpiresAt  time.Time `json:"expiresAt" example:"2025-01-02T10:18:36.897966604+08:00"`                                          // 刷新令牌过期时间,过期后会话结束
+	Current    bool      `gorm:"-" json:"current" example:"true"`                                                                  // 是否为当前请求所用的会话
+}
@@ -0,0 +1,30 @@
+package model
+
+import "time"
+
+// Token 登录凭证
+// @Description 登录凭证
+type Token struct {
+	AccessToken  string    `json:"accessToken" example:"eyJhbGciOiJIUzI1NiIsInR5cCI6IkpXVCJ9..."`      // 访问令牌(JWT)
+	RefreshToken string    `json:"refreshToken" example:"Jr0b5mQ3v1sCEa3Qpx8n2ZK9Tt2hLmUqj0m4WJq3m0U"` // 刷新令牌
+	ExpiresAt    time.Time `json:"expiresAt" example:"2024-12-03T10:33:36.897966604+08:00"`            // 访问令牌过期时间
+}
+
+// RefreshToken 服务端保存的刷新令牌
+type RefreshToken struct {
+	ID              string    `gorm:"primary_key;size:64"` // 令牌的sha256哈希
+	Family          string    `gorm:"index;size:36"`       // 令牌族,同一次登录轮换产生的令牌属于同一族
+	Username        string    `gorm:"index"`               // 用户名
+	IsAdmin         bool      // 是否为管理员
+	AccessJTI       string    `gorm:"size:36"` // 同时签发的访问令牌id
+	AccessExpiresAt time.Time // 同时签发的访问令牌过期时间
+	ExpiresAt       time.Time // 过期时间
+	Revoked         bool      // 是否已失效
+	CreatedAt       time.Time // 签发时间
+}
+
+// RevokedToken 已注销的访问令牌
+type RevokedToken struct {
+	JTI       string    `gorm:"primary_key;size:36"` // 访问令牌id
+	ExpiresAt time.Time `gorm:"index"`               // 访问令牌原过期时间,过期后记录可清理
+}
@@ -3,10 +3,36 @@ package model
 // User 用户
 // @Description 用户
 type User struct {
-	Username  string `gorm:"primary_key" json:"username" example:"test"`                                  // 用户名
-	Password  string `gorm:"not null" json:"password" example:"123456"`                                   // 密码
-	Nickname  string `json:"nickname" example:"test"`                                                     // 昵称
-	AvatarURI string `json:"avatarURI" example:"assert/avatars/d18b9c4b-8d7f-407f-a630-cf2596bd7511.jpg"` // 头像地址
-	Intro     string `json:"intro" example:"我是test"`                                                      // 描述
-	IsBan     bool   `json:"isBan" example:"false"`                                                       // 是否被封禁
+	Username      string `gorm:"primary_key" json:"username" example:"test"`                                  // 用户名
+	Password      string `gorm:"not null" json:"password" example:"123456"`                                   // 密码
+	Nickname      string `json:"nickname" example:"test"`                                                     // 昵称
+	AvatarURI     string `json:"avatarURI" example:"assert/avatars/d18b9c4b-8d7f-407f-a630-cf2596bd7511.jpg"` // 头像地址
+	Intro         string `json:"intro" example:"我是test"`                                                      // 描述
+	IsBan         bool   `json:"isBan" example:"false"`                                                       // 是否被封禁
+	Email         string `gorm:"index;size:191" json:"email" example:"test@example.com"`                      // 邮箱(可选,用于找回密码)
+	EmailVerified bool   `json:"emailVerified" example:"false"`                                               // 邮箱是否已验证
+	RatingFilter  string `gorm:"size:10" json:"ratingFilter" example:"hide"`                                  // 成人内容(nsfw)显示偏好(hide,blur,show),为空视为hide,只对本人可见
+
+	AvatarURIs      map[string]string `gorm:"-" json:"avatarURIs,omitempty"`                       // 各尺寸头像地址(键为边长,如32,128,512)
+	CurrentPassword string            `gorm:"-" json:"currentPassword,omitempty" example:"123456"` // 当前密码,仅在修改密码或邮箱时需要提供
+}
+
+// AvatarUpload 上传头像的结果
+// @Description 上传头像的结果
+type AvatarUpload struct {
+	AvatarURI  string            `json:"avatarURI" example:"assert/avatars/d18b9c4b-8d7f-407f-a630-cf2596bd7511_512.jpg"` // 头像地址(最大尺寸),用于更新用户信息
+	AvatarURIs map[string]string `json:"avatarURIs"`                                                                      // 各尺寸头像地址(键为边长)
+}
+
+// PasswordReset 重置密码
+// @Description 通过邮件中的令牌重置密码
+type PasswordReset struct {
+	Token       string `json:"token" example:"eyJhbGciOiJIUzI1NiIsInR5cCI6IkpXVCJ9..."` // 重置密码令牌
+	NewPassword string `json:"newPassword" example:"123456"`                            // 新密码
+}
+
+// EmailVerification 验证邮箱
+// @Description 通过邮件中的令牌验证邮箱
+type EmailVerification struct {
+	Token string `json:"token" example:"eyJhbGciOiJIUzI1NiIsInR5cCI6IkpXVCJ9..."` // 邮箱验证令牌
 }
@@ -0,0 +1,15 @@
+package model
+
+// FieldError 字段校验错误
+// @Description 字段校验错误
+type FieldError struct {
+	Field   string `json:"field" example:"username"`         // 字段名(与请求中的json字段名一致)
+	Message string `json:"message" example:"用户名长度应为3到32个字符"` // 错误说明
+}
+
+// ValidationError 请求参数校验失败
+// @Description 请求参数校验失败,errors中列出每个不合法的字段
+type ValidationError struct {
+	Message string       `json:"message" example:"请求参数校验失败"` // 错误说明
+	Errors  []FieldError `json:"errors"`                     // 字段错误
+}
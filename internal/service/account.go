@@ -0,0 +1,135 @@
+package service
+
+import (
+	"PaintingExchange/internal/env"
+	"PaintingExchange/internal/model"
+	"errors"
+	"github.com/golang-jwt/jwt/v5"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+	"net/mail"
+	"net/url"
+	"time"
+)
+
+const (
+	emailVerifyTTL   = 24 * time.Hour
+	passwordResetTTL = 30 * time.Minute
+)
+
+var ErrEmailInvalid = errors.New("邮箱格式错误")
+
+// IsValidEmail 验证邮箱格式
+func IsValidEmail(email string) bool {
+	addr, err := mail.ParseAddress(email)
+	return err == nil && addr.Address == email
+}
+
+// SendVerificationEmail 发送邮箱验证邮件
+func SendVerificationEmail(mailer Mailer, user model.User) error {
+	if !IsValidEmail(user.Email) {
+		return ErrEmailInvalid
+	}
+	token, _, err := IssuePurposeToken("emailVerify", jwt.MapClaims{
+		"username": user.Username,
+		"email":    user.Email,
+	}, emailVerifyTTL)
+	if err != nil {
+		return err
+	}
+
+	link := env.GetSiteURL() + "/email/verify?token=" + url.QueryEscape(token)
+	return mailer.Send(user.Email, "验证邮箱",
+		"你好 "+user.Nickname+":\n\n请在24小时内打开以下链接验证你的邮箱:\n"+link+"\n\n如果这不是你本人的操作,请忽略此邮件.\n")
+}
+
+// VerifyEmail 使用邮箱验证令牌验证邮箱,返回用户名
+// 令牌签发后修改过邮箱的,旧令牌不能再使用
+func VerifyEmail(db *gorm.DB, tokenString string) (string, error) {
+	claims, err := ParsePurposeToken(db, "emailVerify", tokenString)
+	if err != nil {
+		return "", err
+	}
+	username, _ := claims["username"].(string)
+	email, _ := claims["email"].(string)
+
+	var user model.User
+	if db.Where("username=?", username).Find(&user).RowsAffected == 0 || user.Email != email {
+		return "", ErrPurposeTokenInvalid
+	}
+	if err := RevokePurposeToken(db, claims); err != nil {
+		return "", err
+	}
+	if err := db.Model(&model.User{}).Where("username=?", username).Update("email_verified", true).Error; err != nil {
+		return "", err
+	}
+	return username, nil
+}
+
+// SendPasswordResetEmail 发送重置密码邮件,只发送到已验证的邮箱
+func SendPasswordResetEmail(mailer Mailer, user model.User) error {
+	if user.Email == "" || !user.EmailVerified {
+		return ErrEmailInvalid
+	}
+	token, _, err := IssuePurposeToken("passwordReset", jwt.MapClaims{
+		"username": user.Username,
+		"pwd":      passwordFingerprint(user.Password),
+	}, passwordResetTTL)
+	if err != nil {
+		return err
+	}
+
+	link := env.GetSiteURL() + "/password/reset?token=" + url.QueryEscape(token)
+	return mailer.Send(user.Email, "重置密码",
+		"你好 "+user.Nickname+":\n\n请在30分钟内打开以下链接重置你的密码:\n"+link+"\n\n如果这不是你本人的操作,请忽略此邮件,你的密码不会被修改.\n")
+}
+
+// PasswordResetUsername 检查重置密码令牌,返回令牌对应的用户名,不使用令牌
+// 用于在重置前按用户名校验新密码
+func PasswordResetUsername(db *gorm.DB, tokenString string) (string, error) {
+	_, username, err := parsePasswordResetToken(db, tokenString)
+	return username, err
+}
+
+// parsePasswordResetToken 解析重置密码令牌,令牌签发后修改过密码的,旧令牌不能再使用
+func parsePasswordResetToken(db *gorm.DB, tokenString string) (jwt.MapClaims, string, error) {
+	claims, err := ParsePurposeToken(db, "passwordReset", tokenString)
+	if err != nil {
+		return nil, "", err
+	}
+	username, _ := claims["username"].(string)
+	fingerprint, _ := claims["pwd"].(string)
+
+	var user model.User
+	if db.Where("username=?", username).Find(&user).RowsAffected == 0 || passwordFingerprint(user.Password) != fingerprint {
+		return nil, "", ErrPurposeTokenInvalid
+	}
+	return claims, username, nil
+}
+
+// ResetPassword 使用重置密码令牌设置新密码,并注销用户的所有令牌,返回用户名
+// 令牌签发后修改过密码的,旧令牌不能再使用
+func ResetPassword(db *gorm.DB, tokenString string, newPassword string) (string, error) {
+	claims, username, err := parsePasswordResetToken(db, tokenString)
+	if err != nil {
+		return "", err
+	}
+
+	password, err := bcrypt.GenerateFromPassword([]byte(newPassword), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	if err := RevokePurposeToken(db, claims); err != nil {
+		return "", err
+	}
+	if err := db.Model(&model.User{}).Where("username=?", username).Update("password", string(password)).Error; err != nil {
+		return "", err
+	}
+	RevokeAllTokens(db, username, false)
+	return username, nil
+}
+
+// passwordFingerprint 密码哈希的指纹,密码修改后随之改变
+func passwordFingerprint(password string) string {
+	return hashToken(password)[:16]
+}
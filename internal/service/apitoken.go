@@ -0,0 +1,193 @@
+package service
+
+import (
+	"PaintingExchange/internal/model"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"github.com/google/uuid"
+	"github.com/kataras/iris/v12"
+	"gorm.io/gorm"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// APITokenPrefix API令牌前缀,jwt中间件据此区分API令牌和jwt
+const APITokenPrefix = "pe_"
+
+// 授权范围
+const (
+	ScopeImageRead  = "image:read"  // 查看图片
+	ScopeImageWrite = "image:write" // 上传,修改和删除图片
+	ScopeUserRead   = "user:read"   // 查看用户信息和收藏
+	ScopeUserWrite  = "user:write"  // 修改个人信息和收藏
+	ScopeChatRead   = "chat:read"   // 连接聊天室接收消息
+	ScopeChatWrite  = "chat:write"  // 在聊天室发送消息
+)
+
+const (
+	apiTokenMaxCount      = 20          // 每个用户最多拥有的令牌数
+	apiTokenTouchInterval = time.Minute // 最近使用时间的更新间隔,减少数据库写入
+)
+
+var scopes = map[string]bool{
+	ScopeImageRead: true, ScopeImageWrite: true,
+	ScopeUserRead: true, ScopeUserWrite: true,
+	ScopeChatRead: true, ScopeChatWrite: true,
+}
+
+var (
+	ErrAPITokenInvalid  = errors.New("API令牌无效或已过期")
+	ErrAPITokenTooMany  = errors.New("API令牌数量已达上限")
+	ErrAPITokenNotFound = errors.New("API令牌不存在")
+)
+
+// ValidateAPITokenCreate 校验创建API令牌的请求
+func ValidateAPITokenCreate(create model.APITokenCreate) []model.FieldError {
+	var v Validator
+	if strings.TrimSpace(create.Name) == "" {
+		v.Add("name", "名称不能为空")
+	} else if v.Length("name", "名称", create.Name, 1, 64) {
+		v.Printable("name", "名称", create.Name, false)
+	}
+	if len(create.Scopes) == 0 {
+		v.Add("scopes", "至少需要一个授权范围")
+	}
+	for _, scope := range create.Scopes {
+		if !scopes[scope] {
+			v.Add("scopes", "授权范围%s不存在", scope)
+		}
+	}
+	if create.ExpiresAt != nil && !create.ExpiresAt.After(time.Now()) {
+		v.Add("expiresAt", "过期时间必须晚于当前时间")
+	}
+	return v.Errors
+}
+
+// CreateAPIToken 创建API令牌,令牌明文只在此时返回,服务端只保存哈希
+func CreateAPIToken(db *gorm.DB, username string, create model.APITokenCreate) (model.APITokenCreated, error) {
+	var count int64
+	db.Model(&model.APIToken{}).Where("username=?", username).Count(&count)
+	if count >= apiTokenMaxCount {
+		return model.APITokenCreated{}, ErrAPITokenTooMany
+	}
+
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return model.APITokenCreated{}, err
+	}
+	token := APITokenPrefix + base64.RawURLEncoding.EncodeToString(buf)
+
+	record := model.APIToken{
+		ID:        uuid.New().String(),
+		Username:  username,
+		Name:      create.Name,
+		Hash:      hashToken(token),
+		Prefix:    token[:len(APITokenPrefix)+6],
+		Scopes:    uniqueStrings(create.Scopes),
+		ExpiresAt: create.ExpiresAt,
+		CreatedAt: time.Now(),
+	}
+	if err := db.Create(&record).Error; err != nil {
+		return model.APITokenCreated{}, err
+	}
+	return model.APITokenCreated{APIToken: record, Token: token}, nil
+}
+
+// ListAPITokens 获取用户的所有API令牌
+func ListAPITokens(db *gorm.DB, username string) []model.APIToken {
+	tokens := []model.APIToken{}
+	db.Where("username=?", username).Order("created_at DESC").Find(&tokens)
+	return tokens
+}
+
+// RevokeAPIToken 注销用户的API令牌
+func RevokeAPIToken(db *gorm.DB, username string, id string) error {
+	if db.Where("id=? AND username=?", id, username).Delete(&model.APIToken{}).RowsAffected == 0 {
+		return ErrAPITokenNotFound
+	}
+	return nil
+}
+
+// CheckAPIToken 验证API令牌并更新最近使用时间
+func CheckAPIToken(db *gorm.DB, token string) (model.APIToken, error) {
+	var record model.APIToken
+	if db.Where("hash=?", hashToken(token)).Find(&record).RowsAffected == 0 {
+		return record, ErrAPITokenInvalid
+	}
+	now := time.Now()
+	if record.ExpiresAt != nil && now.After(*record.ExpiresAt) {
+		return record, ErrAPITokenInvalid
+	}
+	if record.LastUsedAt == nil || now.Sub(*record.LastUsedAt) > apiTokenTouchInterval {
+		db.Model(&model.APIToken{}).Where("id=?", record.ID).Update("last_used_at", now)
+		record.LastUsedAt = &now
+	}
+	return record, nil
+}
+
+// APITokenScope 获取请求需要的授权范围,ok为false表示API令牌不能访问该接口
+// 令牌管理,登出,两步验证,邮箱和后台等接口只能使用登录获得的令牌访问
+func APITokenScope(method string, path string) (scope string, ok bool) {
+	read := method == http.MethodGet || method == http.MethodHead
+	switch {
+	case path == "/jwt/test":
+		return "", true
+	case path == "/chat":
+		return ScopeChatRead, true
+	case path == "/image" || strings.HasPrefix(path, "/image/"):
+		if read {
+			return ScopeImageRead, true
+		}
+		return ScopeImageWrite, true
+	case path == "/user" || path == "/user/avatar" || path == "/user/star":
+		if read {
+			return ScopeUserRead, true
+		}
+		return ScopeUserWrite, true
+	case strings.HasPrefix(path, "/user/") && strings.Count(path, "/") == 2 && read:
+		// /user/{username}
+		return ScopeUserRead, !isUserSubRoute(path)
+	}
+	return "", false
+}
+
+// HasScope 验证登录用户是否拥有授权范围,使用jwt登录时拥有全部范围
+func HasScope(user iris.SimpleUser, scope string) bool {
+	granted, isAPIToken := user.Fields["scopes"].([]string)
+	if !isAPIToken {
+		return true
+	}
+	for _, item := range granted {
+		if item == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// IsAPITokenUser 登录用户是否通过API令牌认证
+func IsAPITokenUser(user iris.SimpleUser) bool {
+	_, ok := user.Fields["apiToken"]
+	return ok
+}
+
+// isUserSubRoute 是否为 /user 下不以用户名区分的路由
+func isUserSubRoute(path string) bool {
+	name := strings.TrimPrefix(path, "/user/")
+	return reservedUsernames[strings.ToLower(name)]
+}
+
+// uniqueStrings 去重并保持顺序
+func uniqueStrings(items []string) []string {
+	var res []string
+	exist := map[string]bool{}
+	for _, item := range items {
+		if !exist[item] {
+			exist[item] = true
+			res = append(res, item)
+		}
+	}
+	return res
+}
@@ -1,7 +1,6 @@
 package service
 
 import (
-	"PaintingExchange/internal/env"
 	"PaintingExchange/internal/model"
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/kataras/iris/v12"
@@ -35,10 +34,15 @@ func checkJwtFrom(ctx iris.Context, header string) {
 		return
 	}
 
+	// API令牌
+	if strings.HasPrefix(tokenString, APITokenPrefix) {
+		checkAPITokenFrom(ctx, db, authHeader, tokenString)
+		return
+	}
+
 	// 解析 JWT Token
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		return env.GetJWTKey(), nil
-	})
+	// 只接受访问令牌类型,专用令牌的类型不同,签名正确也会被拒绝
+	token, err := ParseToken(AccessTokenType, tokenString)
 	if err != nil || !token.Valid {
 		log.Println("jwt验证失败,token非法:", err)
 		ctx.StatusCode(iris.StatusUnauthorized)
@@ -53,13 +57,49 @@ func checkJwtFrom(ctx iris.Context, header string) {
 		return
 	}
 
+	// 两步验证令牌等专用令牌不能作为访问令牌
+	if _, exist := claims["purpose"]; exist {
+		log.Println("jwt验证失败,token不是访问令牌")
+		ctx.StatusCode(iris.StatusUnauthorized)
+		ctx.Text(iris.StatusText(iris.StatusUnauthorized))
+		return
+	}
+
+	// 验证令牌是否已被注销
+	jti, _ := claims["jti"].(string)
+	username, _ := claims["username"].(string)
+	expiresAt, _ := claims.GetExpirationTime()
+	issuedAt, _ := claims.GetIssuedAt()
+	if jti == "" || username == "" || expiresAt == nil || issuedAt == nil {
+		log.Println("jwt验证失败,token缺少jti,exp,iat或username")
+		ctx.StatusCode(iris.StatusUnauthorized)
+		ctx.Text(iris.StatusText(iris.StatusUnauthorized))
+		return
+	}
+	if IsTokenRevoked(db, jti) {
+		log.Println("jwt验证失败,token已被注销")
+		ctx.StatusCode(iris.StatusUnauthorized)
+		ctx.Text(iris.StatusText(iris.StatusUnauthorized))
+		return
+	}
+
 	user := model.User{
-		Username: claims["username"].(string),
+		Username: username,
 	}
 	var roles []string
 	// 验证用户是否是管理员
-	if isAdmin, exist := claims["isAdmin"]; exist && isAdmin.(bool) {
-		roles = append(roles, "admin")
+	isAdmin, _ := claims["isAdmin"].(bool)
+	if isAdmin {
+		// 验证管理员是否存在或被停用
+		admin := model.Admin{Username: user.Username}
+		rows := db.Where(&admin).Find(&admin).RowsAffected
+		if rows == 0 || admin.IsDisabled {
+			log.Println("jwt验证失败,管理员不存在或被停用")
+			ctx.StatusCode(iris.StatusUnauthorized)
+			ctx.Text(iris.StatusText(iris.StatusUnauthorized))
+			return
+		}
+		roles = append(roles, admin.Role)
 	} else {
 		// 验证用户是否存在或被封禁
 		rows := db.Where(&user).Find(&user).RowsAffected
@@ -69,42 +109,72 @@ func checkJwtFrom(ctx iris.Context, header string) {
 			ctx.Text(iris.StatusText(iris.StatusUnauthorized))
 			return
 		}
+		// 普通用户可能被分配了管理角色
+		roles = GetUserRoles(db, user.Username)
+	}
+
+	// 记录会话的最近访问
+	sid, _ := claims["sid"].(string)
+	if sid != "" {
+		TouchSession(db, sid, ctx.RemoteAddr())
 	}
 
 	// 将用户信息存入上下文
 	ctx.SetUser(iris.SimpleUser{
 		Username:      user.Username,
 		Authorization: authHeader,
+		AuthorizedAt:  issuedAt.Time,
 		Roles:         roles,
+		Fields: iris.Map{
+			"jti":       jti,
+			"sid":       sid,
+			"expiresAt": expiresAt.Time,
+			"isAdmin":   isAdmin,
+		},
 	})
 	ctx.Next()
 }
 
-func CheckIsAdmin(ctx iris.Context) {
-	// 查询用户
-	loginUser, err := ctx.User().GetRaw()
+// checkAPITokenFrom 验证API令牌,API令牌只能访问其授权范围内的接口,且不具有任何管理角色
+func checkAPITokenFrom(ctx iris.Context, db *gorm.DB, authHeader string, tokenString string) {
+	record, err := CheckAPIToken(db, tokenString)
 	if err != nil {
-		log.Println("管理员验证失败,用户对象不存在")
+		log.Println("API令牌验证失败", err)
 		ctx.StatusCode(iris.StatusUnauthorized)
 		ctx.Text(iris.StatusText(iris.StatusUnauthorized))
 		return
 	}
 
-	// 检查身份是否包含管理员
-	isAdmin := false
-	for _, item := range loginUser.(iris.SimpleUser).Roles {
-		if item == "admin" {
-			isAdmin = true
-		}
+	// 验证授权范围
+	scope, ok := APITokenScope(ctx.Method(), ctx.Path())
+	user := iris.SimpleUser{Username: record.Username, Fields: iris.Map{"scopes": record.Scopes}}
+	if !ok || (scope != "" && !HasScope(user, scope)) {
+		log.Println("API令牌", record.ID, "无权访问", ctx.Method(), ctx.Path())
+		ctx.StatusCode(iris.StatusForbidden)
+		ctx.Text(iris.StatusText(iris.StatusForbidden))
+		return
 	}
 
-	if !isAdmin {
-		log.Println("管理员验证失败,用户不是管理员")
+	// 验证用户是否存在或被封禁
+	var owner model.User
+	rows := db.Where("username=?", record.Username).Find(&owner).RowsAffected
+	if rows == 0 || owner.IsBan {
+		log.Println("API令牌验证失败,用户名不存在或被封禁")
 		ctx.StatusCode(iris.StatusUnauthorized)
 		ctx.Text(iris.StatusText(iris.StatusUnauthorized))
 		return
 	}
 
+	ctx.SetUser(iris.SimpleUser{
+		Username:      record.Username,
+		Authorization: authHeader,
+		AuthorizedAt:  record.CreatedAt,
+		Fields: iris.Map{
+			"apiToken": record.ID,
+			"scopes":   record.Scopes,
+			"isAdmin":  false,
+		},
+	})
 	ctx.Next()
 }
 
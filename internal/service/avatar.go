@@ -0,0 +1,84 @@
+package service
+
+import (
+	"PaintingExchange/internal/env"
+	"errors"
+	"fmt"
+	"gocv.io/x/gocv"
+	"image"
+	"path/filepath"
+	"regexp"
+	"strconv"
+)
+
+// AvatarSizes 头像的各个边长,最后一个作为用户的头像地址
+var AvatarSizes = []int{32, 128, 512}
+
+var ErrAvatarCrop = errors.New("裁剪区域超出图片范围")
+
+// avatarURIPattern 各尺寸头像的文件名: {id}_{边长}{扩展名}
+var avatarURIPattern = regexp.MustCompile(`^(.+)_(\d+)(\.[a-z]+)$`)
+
+// avatarKeyPattern 上传头像返回的地址: {头像目录}/{uuid}_{最大边长}{扩展名}
+var avatarKeyPattern = regexp.MustCompile(fmt.Sprintf(`^%s/[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}_%d\.(jpg|png|webp)$`,
+	regexp.QuoteMeta(filepath.ToSlash(env.GetAvatarDir())), AvatarSizes[len(AvatarSizes)-1]))
+
+// IsAvatarKey 是否是上传头像返回的地址或默认头像,用户只能将头像设置为这些地址
+func IsAvatarKey(uri string) bool {
+	return uri == DefaultAvatar || avatarKeyPattern.MatchString(uri)
+}
+
+// AvatarURI 获取指定边长的头像地址
+func AvatarURI(base string, ext string, size int) string {
+	return fmt.Sprintf("%s_%d%s", base, size, ext)
+}
+
+// AvatarURIs 根据头像地址推导各尺寸头像地址,旧的单尺寸头像各尺寸都使用原地址
+func AvatarURIs(uri string) map[string]string {
+	uris := map[string]string{}
+	match := avatarURIPattern.FindStringSubmatch(uri)
+	for _, size := range AvatarSizes {
+		if match != nil && match[2] == strconv.Itoa(AvatarSizes[len(AvatarSizes)-1]) {
+			uris[strconv.Itoa(size)] = AvatarURI(match[1], match[3], size)
+		} else {
+			uris[strconv.Itoa(size)] = uri
+		}
+	}
+	return uris
+}
+
+// ProcessAvatar 裁剪头像并生成各尺寸的正方形头像
+// crop为空时使用整张图片;裁剪区域不是正方形时取其中心的最大正方形
+func ProcessAvatar(storage Storage, data []byte, crop image.Rectangle, base string, ext string) error {
+	img, _, err := DecodeImage(data)
+	if err != nil {
+		return err
+	}
+	defer img.Close()
+
+	bounds := image.Rect(0, 0, img.Cols(), img.Rows())
+	if crop.Empty() {
+		crop = bounds
+	} else if !crop.In(bounds) {
+		return ErrAvatarCrop
+	}
+	side := min(crop.Dx(), crop.Dy())
+	offset := image.Pt((crop.Dx()-side)/2, (crop.Dy()-side)/2)
+	square := img.Region(image.Rectangle{Min: crop.Min.Add(offset), Max: crop.Min.Add(offset).Add(image.Pt(side, side))})
+	defer square.Close()
+
+	for _, size := range AvatarSizes {
+		interpolation := gocv.InterpolationArea
+		if side < size {
+			interpolation = gocv.InterpolationCubic
+		}
+		resized := gocv.NewMat()
+		gocv.Resize(square, &resized, image.Pt(size, size), 0, 0, interpolation)
+		err := writeImage(storage, AvatarURI(base, ext, size), resized)
+		resized.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,240 @@
+package service
+
+import (
+	"PaintingExchange/internal/env"
+	"PaintingExchange/internal/model"
+	"errors"
+	"fmt"
+	"gocv.io/x/gocv"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"image"
+	"log"
+	"math"
+	"math/bits"
+	"sort"
+	"strings"
+	"time"
+)
+
+// 疑似转载图片的处理方式
+const (
+	DuplicateFlag   = "flag"   // 允许发布,标记待审核
+	DuplicateReject = "reject" // 拒绝发布,图片处理完成后才能发布;新版本文件相似时不替换
+	DuplicateOff    = "off"    // 不检测
+)
+
+// 疑似转载记录的审核状态
+const (
+	DuplicatePending   = "pending"   // 待审核
+	DuplicateConfirmed = "confirmed" // 确认为转载
+	DuplicateDismissed = "dismissed" // 不是转载
+)
+
+var ErrDuplicateImage = errors.New("图片与其他作者已发布的图片过于相似")
+
+// 多索引哈希:64位哈希分为4段,每段16位
+const (
+	hashBands     = 4
+	hashBandBits  = 64 / hashBands
+	maxBandRadius = 2 // 每段查找的最大汉明半径,超过时候选太多,改为扫描全表
+)
+
+// DHash 计算图片的64位差异哈希:缩小为9x8的灰度图,比较每行相邻像素的亮度
+// 对缩放,压缩和轻微调色不敏感,可用于发现重新上传的图片
+func DHash(img gocv.Mat) uint64 {
+	gray := gocv.NewMat()
+	defer gray.Close()
+	gocv.CvtColor(img, &gray, gocv.ColorBGRToGray)
+	small := gocv.NewMat()
+	defer small.Close()
+	gocv.Resize(gray, &small, image.Pt(9, 8), 0, 0, gocv.InterpolationArea)
+
+	var hash uint64
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			hash <<= 1
+			if small.GetUCharAt(y, x) < small.GetUCharAt(y, x+1) {
+				hash |= 1
+			}
+		}
+	}
+	return hash
+}
+
+// Similarity 根据汉明距离计算相似度
+func Similarity(distance int) float64 {
+	return math.Round((1-float64(distance)/64)*1000) / 1000
+}
+
+// DuplicateDetector 疑似转载检测
+type DuplicateDetector struct {
+	Db          *gorm.DB
+	Policy      string // 处理方式(flag,reject,off)
+	MaxDistance int    // 判定为相似的最大汉明距离
+}
+
+// NewDuplicateDetector 根据环境变量配置创建疑似转载检测
+func NewDuplicateDetector(db *gorm.DB) *DuplicateDetector {
+	policy, maxDistance := env.GetDuplicatePolicy()
+	return &DuplicateDetector{Db: db, Policy: policy, MaxDistance: maxDistance}
+}
+
+// Similar 查找其他作者已发布的相似图片,按相似度从高到低排列;记录的ImageID(所属作品的id)由调用方填写
+// 汉明距离不超过d时,按抽屉原理至少有一段的距离不超过d/4,只需查找各段在该半径内的值
+func (d *DuplicateDetector) Similar(username string, hash uint64) ([]model.DuplicateReport, error) {
+	var reports []model.DuplicateReport
+	if d.Policy == DuplicateOff {
+		return reports, nil
+	}
+
+	query := d.Db.Where("username<>? AND BIT_COUNT(hash ^ ?)<=?", username, hash, d.MaxDistance)
+	if radius := d.MaxDistance / hashBands; radius <= maxBandRadius {
+		conditions := make([]string, 0, hashBands)
+		args := make([]interface{}, 0, hashBands)
+		for i, band := range hashBandValues(hash) {
+			conditions = append(conditions, fmt.Sprintf("band%d IN ?", i))
+			args = append(args, bandNeighbors(band, radius))
+		}
+		query = query.Where("("+strings.Join(conditions, " OR ")+")", args...)
+	}
+	var hashes []model.ImageHash
+	if err := query.Find(&hashes).Error; err != nil {
+		return nil, err
+	}
+	for _, item := range hashes {
+		distance := bits.OnesCount64(item.Hash ^ hash)
+		originalID := item.WorkID
+		if originalID == "" {
+			originalID = item.ImageID
+		}
+		reports = append(reports, model.DuplicateReport{
+			Username:         username,
+			OriginalID:       originalID,
+			OriginalUsername: item.Username,
+			Distance:         distance,
+			Similarity:       Similarity(distance),
+			Status:           DuplicatePending,
+		})
+	}
+	sort.SliceStable(reports, func(i, j int) bool {
+		return reports[i].Distance < reports[j].Distance
+	})
+	return reports, nil
+}
+
+// Rejects 拒绝疑似转载时,根据Similar的结果判断图片是否与其他作者已发布的图片相似
+func (d *DuplicateDetector) Rejects(imageID string, reports []model.DuplicateReport) bool {
+	if d.Policy != DuplicateReject || len(reports) == 0 {
+		return false
+	}
+	log.Println("图片", imageID, ErrDuplicateImage, reports[0].OriginalID)
+	return true
+}
+
+// Index 将已发布作品中一页图片的哈希加入索引,并为Similar找到的相似图片创建待审核记录
+// 重复调用时不会重复创建记录
+func (d *DuplicateDetector) Index(imageID string, pageID string, username string, hash uint64, reports []model.DuplicateReport) {
+	if d.Policy == DuplicateOff {
+		return
+	}
+
+	bands := hashBandValues(hash)
+	err := d.Db.Transaction(func(tx *gorm.DB) error {
+		res := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&model.ImageHash{
+			ImageID:   pageID,
+			WorkID:    imageID,
+			Username:  username,
+			Hash:      hash,
+			Band0:     bands[0],
+			Band1:     bands[1],
+			Band2:     bands[2],
+			Band3:     bands[3],
+			CreatedAt: time.Now(),
+		})
+		if res.Error != nil || res.RowsAffected == 0 || len(reports) == 0 {
+			return res.Error
+		}
+		log.Println("图片", imageID, "疑似转载", len(reports), "张其他作者的图片")
+		for i := range reports {
+			reports[i].ImageID = imageID
+			reports[i].CreatedAt = time.Now()
+		}
+		return tx.Create(&reports).Error
+	})
+	if err != nil {
+		log.Println("图片", pageID, "哈希索引失败", err)
+	}
+}
+
+// MigrateHashBands 为旧的哈希索引补全分段
+func MigrateHashBands(db *gorm.DB) error {
+	res := db.Model(&model.ImageHash{}).Where("hash<>0 AND band0=0 AND band1=0 AND band2=0 AND band3=0").
+		Updates(map[string]interface{}{
+			"band0": gorm.Expr("hash & 0xffff"),
+			"band1": gorm.Expr("(hash >> 16) & 0xffff"),
+			"band2": gorm.Expr("(hash >> 32) & 0xffff"),
+			"band3": gorm.Expr("(hash >> 48) & 0xffff"),
+		})
+	if res.RowsAffected != 0 {
+		log.Println("补全", res.RowsAffected, "条哈希索引的分段")
+	}
+	return res.Error
+}
+
+// hashBandValues 将哈希从低位到高位分为4段
+func hashBandValues(hash uint64) [hashBands]uint16 {
+	var bands [hashBands]uint16
+	for i := range bands {
+		bands[i] = uint16(hash >> (i * hashBandBits))
+	}
+	return bands
+}
+
+// bandNeighbors 与一段哈希的汉明距离不超过radius的所有值
+func bandNeighbors(band uint16, radius int) []uint16 {
+	neighbors := []uint16{band}
+	// 每轮在上一轮的结果上再翻转一个比已翻转的位都高的位,不会重复
+	frontier := []uint16{band}
+	highest := []int{-1}
+	for r := 1; r <= radius; r++ {
+		var next []uint16
+		var nextHighest []int
+		for i, value := range frontier {
+			for bit := highest[i] + 1; bit < hashBandBits; bit++ {
+				next = append(next, value^1<<bit)
+				nextHighest = append(nextHighest, bit)
+			}
+		}
+		neighbors = append(neighbors, next...)
+		frontier, highest = next, nextHighest
+	}
+	return neighbors
+}
+
+// Remove 删除作品各页的哈希索引和相关的疑似转载记录
+func (d *DuplicateDetector) Remove(imageID string) {
+	d.Db.Where("work_id=? OR image_id=?", imageID, imageID).Delete(&model.ImageHash{})
+	d.Db.Where("image_id=? OR original_id=?", imageID, imageID).Delete(&model.DuplicateReport{})
+}
+
+// List 获取疑似转载记录,status为空时获取全部
+func (d *DuplicateDetector) List(status string) []model.DuplicateReport {
+	reports := []model.DuplicateReport{}
+	query := d.Db.Order("similarity DESC, created_at DESC")
+	if status != "" {
+		query = query.Where("status=?", status)
+	}
+	query.Find(&reports)
+	return reports
+}
+
+// Resolve 审核疑似转载记录
+func (d *DuplicateDetector) Resolve(id uint, status string) bool {
+	var report model.DuplicateReport
+	if d.Db.Where("id=?", id).Find(&report).RowsAffected == 0 {
+		return false
+	}
+	d.Db.Model(&report).Update("status", status)
+	return true
+}
@@ -0,0 +1,68 @@
+package service
+
+import (
+	"math/bits"
+	"math/rand"
+	"slices"
+	"testing"
+)
+
+func TestHashBandValues(t *testing.T) {
+	bands := hashBandValues(0x0123456789abcdef)
+	if bands != [hashBands]uint16{0xcdef, 0x89ab, 0x4567, 0x0123} {
+		t.Errorf("bands = %x", bands)
+	}
+}
+
+func TestBandNeighbors(t *testing.T) {
+	tests := []struct {
+		radius int
+		want   int
+	}{
+		{0, 1},
+		{1, 1 + 16},
+		{2, 1 + 16 + 120},
+	}
+	for _, tt := range tests {
+		neighbors := bandNeighbors(0xa5a5, tt.radius)
+		if len(neighbors) != tt.want {
+			t.Errorf("radius %d: %d个值, want %d", tt.radius, len(neighbors), tt.want)
+		}
+		unique := slices.Compact(slices.Sorted(slices.Values(neighbors)))
+		if len(unique) != len(neighbors) {
+			t.Errorf("radius %d: 有重复的值", tt.radius)
+		}
+		for _, value := range neighbors {
+			if d := bits.OnesCount16(value ^ 0xa5a5); d > tt.radius {
+				t.Errorf("radius %d: %x 距离为%d", tt.radius, value, d)
+			}
+		}
+	}
+}
+
+// 距离不超过阈值的哈希一定能通过某一段的候选值找到
+func TestBandCandidatesCoverDistance(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for maxDistance := 0; maxDistance < hashBands*(maxBandRadius+1); maxDistance++ {
+		radius := maxDistance / hashBands
+		for n := 0; n < 200; n++ {
+			hash := rng.Uint64()
+			other := hash
+			for _, bit := range rng.Perm(64)[:maxDistance] {
+				other ^= 1 << bit
+			}
+
+			found := false
+			otherBands := hashBandValues(other)
+			for i, band := range hashBandValues(hash) {
+				if slices.Contains(bandNeighbors(band, radius), otherBands[i]) {
+					found = true
+					break
+				}
+			}
+			if !found {
+				t.Fatalf("距离%d: %x 与 %x 没有匹配的段", maxDistance, hash, other)
+			}
+		}
+	}
+}
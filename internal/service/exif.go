@@ -0,0 +1,208 @@
+package service
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// EXIF标签
+const (
+	exifTagOrientation        = 0x0112
+	exifTagSoftware           = 0x0131
+	exifTagDateTime           = 0x0132
+	exifTagExifIFD            = 0x8769
+	exifTagDateTimeOriginal   = 0x9003
+	exifTagOffsetTimeOriginal = 0x9011
+)
+
+// Exif 从图片中读取的EXIF信息,只包含需要的字段
+type Exif struct {
+	Orientation int       // 方向(1-8),0表示没有
+	Software    string    // 创作软件
+	CapturedAt  time.Time // 拍摄时间
+}
+
+// ReadExif 读取JPEG,PNG或WebP图片中的EXIF信息,没有或格式错误时返回空值
+func ReadExif(data []byte) Exif {
+	tiff := findExif(data)
+	if tiff == nil {
+		return Exif{}
+	}
+	return parseExif(tiff)
+}
+
+// findExif 查找图片中的EXIF数据(TIFF格式)
+func findExif(data []byte) []byte {
+	switch {
+	case bytes.HasPrefix(data, []byte{0xff, 0xd8}):
+		// JPEG: APP1段,以 Exif\0\0 开头
+		for i := 2; i+4 <= len(data) && data[i] == 0xff; {
+			marker := data[i+1]
+			if marker == 0xd8 || marker == 0x01 || (marker >= 0xd0 && marker <= 0xd7) {
+				i += 2
+				continue
+			}
+			if marker == 0xda || marker == 0xd9 {
+				// 图像数据开始,之后不会再有元数据
+				return nil
+			}
+			length := int(binary.BigEndian.Uint16(data[i+2:]))
+			if length < 2 || i+2+length > len(data) {
+				return nil
+			}
+			segment := data[i+4 : i+2+length]
+			if marker == 0xe1 && bytes.HasPrefix(segment, []byte("Exif\x00\x00")) {
+				return segment[6:]
+			}
+			i += 2 + length
+		}
+	case bytes.HasPrefix(data, []byte("\x89PNG\r\n\x1a\n")):
+		// PNG: eXIf块
+		for i := 8; i+8 <= len(data); {
+			length := int(binary.BigEndian.Uint32(data[i:]))
+			if length < 0 || i+12+length > len(data) {
+				return nil
+			}
+			if string(data[i+4:i+8]) == "eXIf" {
+				return data[i+8 : i+8+length]
+			}
+			if string(data[i+4:i+8]) == "IDAT" {
+				return nil
+			}
+			i += 12 + length
+		}
+	case len(data) >= 12 && string(data[:4]) == "RIFF" && string(data[8:12]) == "WEBP":
+		// WebP: EXIF块,部分软件写入时带有 Exif\0\0 前缀
+		for i := 12; i+8 <= len(data); {
+			length := int(binary.LittleEndian.Uint32(data[i+4:]))
+			if length < 0 || i+8+length > len(data) {
+				return nil
+			}
+			if string(data[i:i+4]) == "EXIF" {
+				return bytes.TrimPrefix(data[i+8:i+8+length], []byte("Exif\x00\x00"))
+			}
+			i += 8 + length + length%2
+		}
+	}
+	return nil
+}
+
+// parseExif 解析TIFF格式的EXIF数据
+func parseExif(tiff []byte) Exif {
+	if len(tiff) < 8 {
+		return Exif{}
+	}
+	var order binary.ByteOrder
+	switch {
+	case bytes.HasPrefix(tiff, []byte("II*\x00")):
+		order = binary.LittleEndian
+	case bytes.HasPrefix(tiff, []byte("MM\x00*")):
+		order = binary.BigEndian
+	default:
+		return Exif{}
+	}
+
+	var exif Exif
+	var dateTime, dateTimeOriginal, offsetTimeOriginal string
+	ifd0 := readIFD(tiff, order, int(order.Uint32(tiff[4:])))
+	if value, ok := ifd0[exifTagOrientation]; ok && value.typ == 3 && len(value.data) >= 2 {
+		exif.Orientation = int(order.Uint16(value.data))
+	}
+	exif.Software = ifd0[exifTagSoftware].text()
+	dateTime = ifd0[exifTagDateTime].text()
+	if value, ok := ifd0[exifTagExifIFD]; ok && value.typ == 4 && len(value.data) >= 4 {
+		sub := readIFD(tiff, order, int(order.Uint32(value.data)))
+		dateTimeOriginal = sub[exifTagDateTimeOriginal].text()
+		offsetTimeOriginal = sub[exifTagOffsetTimeOriginal].text()
+	}
+	if dateTimeOriginal == "" {
+		dateTimeOriginal = dateTime
+	}
+	exif.CapturedAt = parseExifTime(dateTimeOriginal, offsetTimeOriginal)
+	if exif.Orientation < 1 || exif.Orientation > 8 {
+		exif.Orientation = 0
+	}
+	return exif
+}
+
+// exifValue IFD中的一个字段
+type exifValue struct {
+	typ  int
+	data []byte
+}
+
+// text 字符串类型的值,去掉结尾的\0和不可打印字符
+func (v exifValue) text() string {
+	if v.typ != 2 {
+		return ""
+	}
+	text := strings.TrimSpace(strings.TrimRight(string(v.data), "\x00"))
+	return strings.Map(func(r rune) rune {
+		if !unicode.IsPrint(r) || r == unicode.ReplacementChar {
+			return -1
+		}
+		return r
+	}, text)
+}
+
+// readIFD 读取一个IFD中的所有字段,只支持需要用到的ASCII,SHORT和LONG类型
+func readIFD(tiff []byte, order binary.ByteOrder, offset int) map[int]exifValue {
+	values := map[int]exifValue{}
+	if offset < 8 || offset+2 > len(tiff) {
+		return values
+	}
+	count := int(order.Uint16(tiff[offset:]))
+	for i := 0; i < count; i++ {
+		entry := offset + 2 + i*12
+		if entry+12 > len(tiff) {
+			break
+		}
+		tag := int(order.Uint16(tiff[entry:]))
+		typ := int(order.Uint16(tiff[entry+2:]))
+		n := int(order.Uint32(tiff[entry+4:]))
+		var size int
+		switch typ {
+		case 2:
+			size = n
+		case 3:
+			size = n * 2
+		case 4:
+			size = n * 4
+		default:
+			continue
+		}
+		if n < 0 || size < 0 || size > 1<<16 {
+			continue
+		}
+		data := tiff[entry+8 : entry+12]
+		if size > 4 {
+			start := int(order.Uint32(data))
+			if start < 0 || start+size > len(tiff) {
+				continue
+			}
+			data = tiff[start : start+size]
+		}
+		values[tag] = exifValue{typ: typ, data: data[:min(size, len(data))]}
+	}
+	return values
+}
+
+// parseExifTime 解析EXIF时间(2006:01:02 15:04:05),没有时区时使用本地时区
+func parseExifTime(value string, offset string) time.Time {
+	if value == "" {
+		return time.Time{}
+	}
+	if offset != "" {
+		if t, err := time.Parse("2006:01:02 15:04:05-07:00", value+offset); err == nil {
+			return t
+		}
+	}
+	t, err := time.ParseInLocation("2006:01:02 15:04:05", value, time.Local)
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
@@ -0,0 +1,259 @@
+package service
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+	"time"
+)
+
+// testIFDEntry 测试用的IFD字段,data为按字节序编码后的值
+type testIFDEntry struct {
+	tag   int
+	typ   int
+	count int
+	data  []byte
+}
+
+func ifdASCII(tag int, value string) testIFDEntry {
+	return testIFDEntry{tag: tag, typ: 2, count: len(value) + 1, data: []byte(value + "\x00")}
+}
+
+func ifdShort(order binary.ByteOrder, tag int, value uint16) testIFDEntry {
+	data := make([]byte, 2)
+	order.PutUint16(data, value)
+	return testIFDEntry{tag: tag, typ: 3, count: 1, data: data}
+}
+
+// makeTIFF 构造TIFF格式的EXIF数据,exifIFD不为空时在IFD0中添加指向它的字段
+func makeTIFF(order binary.ByteOrder, ifd0 []testIFDEntry, exifIFD []testIFDEntry) []byte {
+	ifdSize := func(entries []testIFDEntry) int { return 2 + 12*len(entries) + 4 }
+	if exifIFD != nil {
+		ifd0 = append(ifd0[:len(ifd0):len(ifd0)], testIFDEntry{tag: exifTagExifIFD, typ: 4, count: 1, data: make([]byte, 4)})
+		order.PutUint32(ifd0[len(ifd0)-1].data, uint32(8+ifdSize(ifd0)))
+	}
+
+	tiff := make([]byte, 8+ifdSize(ifd0)+ifdSize(exifIFD))
+	if order == binary.LittleEndian {
+		copy(tiff, "II*\x00")
+	} else {
+		copy(tiff, "MM\x00*")
+	}
+	order.PutUint32(tiff[4:], 8)
+	write := func(offset int, entries []testIFDEntry) {
+		order.PutUint16(tiff[offset:], uint16(len(entries)))
+		for i, e := range entries {
+			entry := offset + 2 + i*12
+			order.PutUint16(tiff[entry:], uint16(e.tag))
+			order.PutUint16(tiff[entry+2:], uint16(e.typ))
+			order.PutUint32(tiff[entry+4:], uint32(e.count))
+			if len(e.data) <= 4 {
+				copy(tiff[entry+8:], e.data)
+			} else {
+				order.PutUint32(tiff[entry+8:], uint32(len(tiff)))
+				tiff = append(tiff, e.data...)
+			}
+		}
+	}
+	write(8, ifd0)
+	if exifIFD != nil {
+		write(8+ifdSize(ifd0), exifIFD)
+	}
+	return tiff
+}
+
+// testTIFF 包含方向,创作软件和带时区的拍摄时间
+func testTIFF(order binary.ByteOrder) []byte {
+	return makeTIFF(order,
+		[]testIFDEntry{ifdShort(order, exifTagOrientation, 6), ifdASCII(exifTagSoftware, "Krita 5.2"), ifdASCII(exifTagDateTime, "2024:05:02 08:00:00")},
+		[]testIFDEntry{ifdASCII(exifTagDateTimeOriginal, "2024:05:01 12:30:00"), ifdASCII(exifTagOffsetTimeOriginal, "+08:00")})
+}
+
+func makeJPEG(segments ...[]byte) []byte {
+	data := []byte{0xff, 0xd8}
+	for _, segment := range segments {
+		data = append(data, segment...)
+	}
+	return append(data, 0xff, 0xda, 0x00, 0x02, 0xff, 0xd9)
+}
+
+func jpegSegment(marker byte, payload []byte) []byte {
+	segment := []byte{0xff, marker, 0, 0}
+	binary.BigEndian.PutUint16(segment[2:], uint16(len(payload)+2))
+	return append(segment, payload...)
+}
+
+func makePNG(chunks ...[]byte) []byte {
+	data := []byte("\x89PNG\r\n\x1a\n")
+	for _, chunk := range chunks {
+		data = append(data, chunk...)
+	}
+	return data
+}
+
+func pngChunk(typ string, payload []byte) []byte {
+	chunk := make([]byte, 4, 12+len(payload))
+	binary.BigEndian.PutUint32(chunk, uint32(len(payload)))
+	chunk = append(chunk, typ...)
+	chunk = append(chunk, payload...)
+	return append(chunk, 0, 0, 0, 0) // CRC不校验
+}
+
+func makeRIFF(chunks ...[]byte) []byte {
+	data := []byte("RIFF\x00\x00\x00\x00WEBP")
+	for _, chunk := range chunks {
+		data = append(data, chunk...)
+	}
+	binary.LittleEndian.PutUint32(data[4:], uint32(len(data)-8))
+	return data
+}
+
+func riffChunk(typ string, payload []byte) []byte {
+	chunk := append([]byte(typ), 0, 0, 0, 0)
+	binary.LittleEndian.PutUint32(chunk[4:], uint32(len(payload)))
+	chunk = append(chunk, payload...)
+	if len(payload)%2 == 1 {
+		chunk = append(chunk, 0)
+	}
+	return chunk
+}
+
+func TestReadExif(t *testing.T) {
+	le, be := testTIFF(binary.LittleEndian), testTIFF(binary.BigEndian)
+	want := Exif{Orientation: 6, Software: "Krita 5.2", CapturedAt: time.Date(2024, 5, 1, 4, 30, 0, 0, time.UTC)}
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{name: "JPEG", data: makeJPEG(jpegSegment(0xe0, []byte("JFIF\x00\x01\x01")), jpegSegment(0xe1, append([]byte("Exif\x00\x00"), le...)))},
+		{name: "JPEG大端", data: makeJPEG(jpegSegment(0xe1, append([]byte("Exif\x00\x00"), be...)))},
+		{name: "JPEG跳过XMP", data: makeJPEG(jpegSegment(0xe1, []byte("http://ns.adobe.com/xap/1.0/\x00")), jpegSegment(0xe1, append([]byte("Exif\x00\x00"), le...)))},
+		{name: "PNG", data: makePNG(pngChunk("IHDR", make([]byte, 13)), pngChunk("eXIf", be), pngChunk("IDAT", nil))},
+		{name: "WebP", data: makeRIFF(riffChunk("VP8X", make([]byte, 10)), riffChunk("EXIF", le))},
+		{name: "WebP带Exif前缀", data: makeRIFF(riffChunk("VP8X", make([]byte, 10)), riffChunk("ICCP", []byte{1, 2, 3}), riffChunk("EXIF", append([]byte("Exif\x00\x00"), be...)))},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ReadExif(tt.data)
+			if got.Orientation != want.Orientation || got.Software != want.Software || !got.CapturedAt.Equal(want.CapturedAt) {
+				t.Errorf("got %+v, want %+v", got, want)
+			}
+		})
+	}
+}
+
+func TestFindExifMalformed(t *testing.T) {
+	tiff := testTIFF(binary.LittleEndian)
+	exifSegment := jpegSegment(0xe1, append([]byte("Exif\x00\x00"), tiff...))
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{name: "空文件", data: nil},
+		{name: "未知格式", data: []byte("GIF89a")},
+		{name: "JPEG只有SOI", data: []byte{0xff, 0xd8}},
+		{name: "JPEG段长度超出文件", data: append([]byte{0xff, 0xd8}, exifSegment[:len(exifSegment)-1]...)},
+		{name: "JPEG段长度小于2", data: []byte{0xff, 0xd8, 0xff, 0xe1, 0x00, 0x01, 0x00, 0x00}},
+		{name: "JPEG段头不完整", data: []byte{0xff, 0xd8, 0xff, 0xe1, 0x00}},
+		{name: "JPEG图像数据之后", data: append([]byte{0xff, 0xd8, 0xff, 0xda, 0x00, 0x02}, exifSegment...)},
+		{name: "JPEG段之间有多余数据", data: append([]byte{0xff, 0xd8, 0x00}, exifSegment...)},
+		{name: "JPEG没有Exif前缀", data: makeJPEG(jpegSegment(0xe1, tiff))},
+		{name: "PNG块长度超出文件", data: makePNG(pngChunk("eXIf", tiff))[:8+12+len(tiff)-1]},
+		{name: "PNG块长度溢出", data: makePNG([]byte{0xff, 0xff, 0xff, 0xff, 'e', 'X', 'I', 'f', 0, 0, 0, 0})},
+		{name: "PNG图像数据之后", data: makePNG(pngChunk("IDAT", nil), pngChunk("eXIf", tiff))},
+		{name: "WebP块长度超出文件", data: makeRIFF(riffChunk("EXIF", tiff))[:12+8+len(tiff)-1]},
+		{name: "WebP块长度溢出", data: makeRIFF([]byte{'E', 'X', 'I', 'F', 0xff, 0xff, 0xff, 0xff})},
+		{name: "RIFF但不是WebP", data: append([]byte("RIFF\x00\x00\x00\x00WAVE"), riffChunk("EXIF", tiff)...)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := findExif(tt.data); got != nil {
+				t.Errorf("应返回nil, got %d字节", len(got))
+			}
+		})
+	}
+}
+
+// TestReadExifTruncated 任意截断的图片都不应导致panic,找到的EXIF数据不应超出原数据
+func TestReadExifTruncated(t *testing.T) {
+	tiff := testTIFF(binary.BigEndian)
+	for _, data := range [][]byte{
+		makeJPEG(jpegSegment(0xe1, append([]byte("Exif\x00\x00"), tiff...))),
+		makePNG(pngChunk("eXIf", tiff)),
+		makeRIFF(riffChunk("EXIF", tiff)),
+	} {
+		for i := range data {
+			if found := findExif(data[:i]); found != nil && !bytes.Equal(found, tiff) {
+				t.Errorf("截断到%d字节时返回了错误的数据", i)
+			}
+			ReadExif(data[:i])
+		}
+	}
+	// EXIF数据本身被截断
+	for i := range tiff {
+		ReadExif(makeRIFF(riffChunk("EXIF", tiff[:i])))
+	}
+}
+
+func TestReadIFD(t *testing.T) {
+	order := binary.LittleEndian
+	valid := makeTIFF(order, []testIFDEntry{ifdShort(order, exifTagOrientation, 3), ifdASCII(exifTagSoftware, "Procreate")}, nil)
+	// 修改第index个字段的某个位置
+	patch := func(index int, pos int, value uint32) []byte {
+		tiff := bytes.Clone(valid)
+		order.PutUint32(tiff[8+2+index*12+pos:], value)
+		return tiff
+	}
+	tests := []struct {
+		name   string
+		tiff   []byte
+		offset int
+		want   map[int]string // tag对应的值,SHORT类型转为字符串比较
+	}{
+		{name: "正常", tiff: valid, offset: 8, want: map[int]string{exifTagOrientation: "\x03\x00", exifTagSoftware: "Procreate\x00"}},
+		{name: "偏移量小于文件头", tiff: valid, offset: 4, want: map[int]string{}},
+		{name: "偏移量超出数据", tiff: valid, offset: len(valid) - 1, want: map[int]string{}},
+		{name: "负偏移量", tiff: valid, offset: -1, want: map[int]string{}},
+		{name: "字段数量超出数据", tiff: valid[:8+2+12+6], offset: 8, want: map[int]string{exifTagOrientation: "\x03\x00"}},
+		{name: "值的偏移量超出数据", tiff: patch(1, 8, uint32(len(valid))), offset: 8, want: map[int]string{exifTagOrientation: "\x03\x00"}},
+		{name: "值的偏移量溢出", tiff: patch(1, 8, 0xffffffff), offset: 8, want: map[int]string{exifTagOrientation: "\x03\x00"}},
+		{name: "值的数量过大", tiff: patch(1, 4, 0xffffffff), offset: 8, want: map[int]string{exifTagOrientation: "\x03\x00"}},
+		{name: "不支持的类型", tiff: patch(0, 0, uint32(exifTagOrientation)|5<<16), offset: 8, want: map[int]string{exifTagSoftware: "Procreate\x00"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := readIFD(tt.tiff, order, tt.offset)
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %d个字段, want %d: %v", len(got), len(tt.want), got)
+			}
+			for tag, value := range tt.want {
+				if string(got[tag].data) != value {
+					t.Errorf("字段%#x: got %q, want %q", tag, got[tag].data, value)
+				}
+			}
+		})
+	}
+}
+
+func TestParseExifMalformed(t *testing.T) {
+	order := binary.BigEndian
+	tests := []struct {
+		name string
+		tiff []byte
+		want Exif
+	}{
+		{name: "文件头不完整", tiff: []byte("MM\x00*\x00\x00")},
+		{name: "字节序错误", tiff: append([]byte("XX\x00*"), testTIFF(order)[4:]...)},
+		{name: "方向超出范围", tiff: makeTIFF(order, []testIFDEntry{ifdShort(order, exifTagOrientation, 9)}, nil)},
+		{name: "方向类型错误", tiff: makeTIFF(order, []testIFDEntry{ifdASCII(exifTagOrientation, "6")}, nil)},
+		{name: "时间格式错误", tiff: makeTIFF(order, []testIFDEntry{ifdASCII(exifTagDateTime, "2024-05-01")}, nil)},
+		{name: "软件名去掉不可打印字符", tiff: makeTIFF(order, []testIFDEntry{ifdASCII(exifTagSoftware, " Sai\x01\xff ")}, nil), want: Exif{Software: "Sai"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseExif(tt.tiff); got != tt.want {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
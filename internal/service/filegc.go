@@ -0,0 +1,267 @@
+package service
+
+import (
+	"PaintingExchange/internal/env"
+	"PaintingExchange/internal/model"
+	"context"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"gorm.io/gorm"
+	"log"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// DefaultAvatar 默认头像,不会被清理
+var DefaultAvatar = filepath.Join(env.GetAvatarDir(), "0.png")
+
+// DeleteImageFiles 删除作品各页和各阶段的所有文件,原图和处理任务,以及延时视频
+func DeleteImageFiles(db *gorm.DB, storage Storage, image model.Image) {
+	var jobIDs []string
+	for _, page := range ImagePages(image) {
+		jobIDs = append(jobIDs, pageJobIDs(page)...)
+	}
+	for _, stage := range image.Stages {
+		jobIDs = append(jobIDs, stage.ID)
+	}
+	deleteFiles(db, storage, imageFiles(image), jobIDs)
+}
+
+// DeletePageFiles 删除一页图片(包括阶段图片和历史版本)的所有文件,原图和处理任务
+func DeletePageFiles(db *gorm.DB, storage Storage, page model.ImagePage) {
+	deleteFiles(db, storage, pageFiles(page), pageJobIDs(page))
+}
+
+// pageJobIDs 获取一页图片当前版本,正在处理的新版本和历史版本的处理任务id
+func pageJobIDs(page model.ImagePage) []string {
+	ids := []string{page.ID}
+	if page.Replacing != "" {
+		ids = append(ids, page.Replacing)
+	}
+	for _, revision := range page.Revisions {
+		ids = append(ids, revision.ID)
+	}
+	return ids
+}
+
+// deleteFiles 删除文件以及处理任务和任务的文件
+func deleteFiles(db *gorm.DB, storage Storage, keys []string, jobIDs []string) {
+	for _, id := range jobIDs {
+		var job model.ImageJob
+		if db.Where("id=?", id).Find(&job).RowsAffected != 0 {
+			keys = append(keys, job.Source)
+			keys = append(keys, pageFiles(model.ImagePage{BigURI: job.BigURI, MidURI: job.MidURI, Sizes: job.Sizes})...)
+			db.Delete(&job)
+		}
+	}
+	for _, key := range uniqueStrings(keys) {
+		if key == "" {
+			continue
+		}
+		if err := storage.Delete(key); err != nil {
+			log.Println("图片文件", key, "删除失败,将由无用文件清理删除", err)
+		}
+	}
+}
+
+// imageFiles 获取作品的所有文件,包括封面,各页,各阶段和延时视频
+func imageFiles(image model.Image) []string {
+	files := pageFiles(model.ImagePage{BigURI: image.BigURI, MidURI: image.MidURI, Sizes: image.Sizes})
+	for _, page := range image.Pages {
+		files = append(files, pageFiles(page)...)
+	}
+	for _, stage := range image.Stages {
+		files = append(files, pageFiles(stage.ImagePage)...)
+	}
+	if image.Timelapse != nil {
+		// 重新生成期间地址为空,文件仍在
+		files = append(files, TimelapseURI(image.ID, ".mp4"), TimelapseURI(image.ID, ".gif"))
+	}
+	return files
+}
+
+// pageFiles 获取一页图片的所有文件:大图,中图,其他尺寸以及它们的其他格式,包括历史版本
+func pageFiles(page model.ImagePage) []string {
+	uris := []string{page.BigURI, page.MidURI}
+	for _, size := range page.Sizes {
+		uris = append(uris, size.URI)
+	}
+	for _, revision := range page.Revisions {
+		uris = append(uris, revision.BigURI, revision.MidURI)
+		for _, size := range revision.Sizes {
+			uris = append(uris, size.URI)
+		}
+	}
+	files := append([]string(nil), uris...)
+	for _, uri := range uris {
+		for _, format := range ImageFormats {
+			files = append(files, VariantURI(uri, format.Ext))
+		}
+	}
+	return files
+}
+
+// FileGC 无用文件清理,删除图片目录,头像目录和原图目录下没有被任何图片或用户引用的文件
+// 修改时间在宽限期内的文件不会被清理,以免删除刚上传还未创建图片的文件
+type FileGC struct {
+	Db       *gorm.DB
+	Mg       *mongo.Client
+	Storage  Storage
+	Mode     string        // on:定期清理 dryrun:定期只报告 off:不定期执行
+	Interval time.Duration // 执行间隔
+	Grace    time.Duration // 宽限期
+
+	mu sync.Mutex
+}
+
+// NewFileGC 根据环境变量配置创建无用文件清理
+func NewFileGC(db *gorm.DB, mg *mongo.Client, storage Storage) *FileGC {
+	mode, interval, grace := env.GetFileGC()
+	return &FileGC{
+		Db:       db,
+		Mg:       mg,
+		Storage:  storage,
+		Mode:     mode,
+		Interval: interval,
+		Grace:    grace,
+	}
+}
+
+// Start 启动定期清理
+func (gc *FileGC) Start() {
+	if gc.Mode == "off" {
+		return
+	}
+	go func() {
+		for range time.Tick(gc.Interval) {
+			report, err := gc.Run(gc.Mode == "dryrun")
+			if err != nil {
+				log.Println("无用文件清理失败", err)
+				continue
+			}
+			for _, file := range report.Orphans {
+				log.Println("无用文件", file.Key, file.Size, file.ModTime)
+			}
+			log.Println("无用文件清理完成,检查", report.Scanned, "个文件,发现", len(report.Orphans), "个无用文件,删除", report.Deleted, "个,释放", report.FreedBytes, "字节")
+		}
+	}()
+}
+
+// Run 执行一次清理,dryRun为true时只报告不删除
+func (gc *FileGC) Run(dryRun bool) (model.FileGCReport, error) {
+	gc.mu.Lock()
+	defer gc.mu.Unlock()
+
+	report := model.FileGCReport{DryRun: dryRun, Orphans: []model.OrphanFile{}, StartedAt: time.Now()}
+	// 先列出文件再查询引用,列出之后新增的引用对应的文件都在宽限期内
+	var files []FileInfo
+	for _, dir := range []string{env.GetImgDir(), env.GetAvatarDir(), env.GetOriginDir()} {
+		items, err := gc.Storage.List(dir)
+		if err != nil {
+			return report, err
+		}
+		files = append(files, items...)
+	}
+	report.Scanned = len(files)
+
+	referenced, staleJobs, err := gc.references()
+	if err != nil {
+		return report, err
+	}
+
+	deadline := report.StartedAt.Add(-gc.Grace)
+	for _, file := range files {
+		key, err := cleanKey(file.Key)
+		if err != nil || referenced[key] || file.ModTime.After(deadline) {
+			continue
+		}
+		report.Orphans = append(report.Orphans, model.OrphanFile{Key: key, Size: file.Size, ModTime: file.ModTime})
+		if dryRun {
+			continue
+		}
+		if err := gc.Storage.Delete(key); err != nil {
+			log.Println("无用文件", key, "删除失败", err)
+			continue
+		}
+		report.Deleted++
+		report.FreedBytes += file.Size
+	}
+
+	// 上传后一直没有创建图片的处理任务
+	if !dryRun && len(staleJobs) != 0 {
+		gc.Db.Where("id IN ? AND updated_at<?", staleJobs, deadline).Delete(&model.ImageJob{})
+	}
+	return report, nil
+}
+
+// references 获取所有被引用的文件,以及没有对应图片的已结束处理任务
+func (gc *FileGC) references() (map[string]bool, []string, error) {
+	referenced := map[string]bool{}
+	add := func(uri string) {
+		if key, err := cleanKey(uri); err == nil {
+			referenced[key] = true
+		}
+	}
+	add(DefaultAvatar)
+
+	// 用户头像
+	var avatars []string
+	if err := gc.Db.Model(&model.User{}).Where("avatar_uri<>''").Distinct().Pluck("avatar_uri", &avatars).Error; err != nil {
+		return nil, nil, err
+	}
+	for _, avatar := range avatars {
+		add(avatar)
+		for _, uri := range AvatarURIs(avatar) {
+			add(uri)
+		}
+	}
+
+	// 图片
+	images := gc.Mg.Database("PaintingExchange").Collection("Images")
+	projection := options.Find().SetProjection(bson.M{"_id": 1, "bigURI": 1, "midURI": 1, "sizes": 1, "pages": 1, "stages": 1, "timelapse": 1})
+	cursor, err := images.Find(context.Background(), bson.D{}, projection)
+	if err != nil {
+		return nil, nil, err
+	}
+	var imageList []model.Image
+	if err := cursor.All(context.Background(), &imageList); err != nil {
+		return nil, nil, err
+	}
+	pageIDs := map[string]bool{}
+	for _, image := range imageList {
+		for _, page := range ImagePages(image) {
+			for _, id := range pageJobIDs(page) {
+				pageIDs[id] = true
+			}
+		}
+		for _, stage := range image.Stages {
+			pageIDs[stage.ID] = true
+		}
+		for _, uri := range imageFiles(image) {
+			add(uri)
+		}
+	}
+
+	// 处理任务:处理中的任务文件都保留,已创建图片的保留原图
+	var jobs []model.ImageJob
+	if err := gc.Db.Find(&jobs).Error; err != nil {
+		return nil, nil, err
+	}
+	var staleJobs []string
+	for _, job := range jobs {
+		switch {
+		case job.Status == ImageStatusPending:
+			add(job.Source)
+			for _, uri := range pageFiles(model.ImagePage{BigURI: job.BigURI, MidURI: job.MidURI}) {
+				add(uri)
+			}
+		case pageIDs[job.ID]:
+			add(job.Source)
+		default:
+			staleJobs = append(staleJobs, job.ID)
+		}
+	}
+	return referenced, staleJobs, nil
+}
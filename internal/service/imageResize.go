@@ -1,21 +1,81 @@
 package service
 
 import (
+	"PaintingExchange/internal/model"
+	"bytes"
+	"errors"
 	"fmt"
 	"gocv.io/x/gocv"
 	"image"
+	"image/draw"
+	"image/gif"
+	"image/png"
 	"io"
+	"log"
+	"math"
 	"mime/multipart"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
 )
 
-type SizeType int
-
+// 尺寸阶梯的计算方式
 const (
-	BigSize SizeType = 3000
-	MidSize SizeType = 300
+	SizeByHeight = "height" // 按高度
+	SizeByWidth  = "width"  // 按宽度
+	SizeByEdge   = "edge"   // 按最长边
 )
 
-// FileToMat 将multipart.File转换为gocv.Mat
+// SizeLadder 图片尺寸阶梯,每张图片按其中的每个尺寸等比缩放生成一张图片(不放大)
+// 最大的尺寸作为大图,最小的尺寸作为中图(缩略图)
+type SizeLadder struct {
+	Mode  string // 计算方式(height,width,edge)
+	Sizes []int  // 各个尺寸,从大到小
+}
+
+// NewSizeLadder 创建尺寸阶梯,sizes为逗号分隔的尺寸列表
+func NewSizeLadder(mode string, sizes string) (SizeLadder, error) {
+	if mode != SizeByHeight && mode != SizeByWidth && mode != SizeByEdge {
+		return SizeLadder{}, fmt.Errorf("不支持的尺寸计算方式%s", mode)
+	}
+	ladder := SizeLadder{Mode: mode}
+	for _, item := range strings.Split(sizes, ",") {
+		size, err := strconv.Atoi(strings.TrimSpace(item))
+		if err != nil || size <= 0 {
+			return SizeLadder{}, fmt.Errorf("图片尺寸%s不合法", item)
+		}
+		ladder.Sizes = append(ladder.Sizes, size)
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(ladder.Sizes)))
+	ladder.Sizes = slices.Compact(ladder.Sizes)
+	return ladder, nil
+}
+
+// Fit 计算按指定尺寸等比缩放后的宽高,不会超过原图
+func (l SizeLadder) Fit(width int, height int, size int) (int, int) {
+	base := height
+	switch l.Mode {
+	case SizeByWidth:
+		base = width
+	case SizeByEdge:
+		base = max(width, height)
+	}
+	if base <= size {
+		return width, height
+	}
+	scale := float64(size) / float64(base)
+	return max(1, int(math.Round(float64(width)*scale))), max(1, int(math.Round(float64(height)*scale)))
+}
+
+// SizedURI 获取中间尺寸的图片地址,如 assert/images/w1600_{id}.jpg
+func SizedURI(bigURI string, width int) string {
+	return filepath.Join(filepath.Dir(bigURI), fmt.Sprintf("w%d_%s", width, strings.TrimPrefix(filepath.Base(bigURI), "big_")))
+}
+
+// FileToMat 将multipart.File转换为gocv.Mat(已按EXIF方向旋转)
 func FileToMat(file multipart.File) (gocv.Mat, error) {
 	// 读取文件内容
 	fileBytes, err := io.ReadAll(file)
@@ -24,7 +84,7 @@ func FileToMat(file multipart.File) (gocv.Mat, error) {
 	}
 
 	// 将字节数据解码为 gocv.Mat
-	imgMat, err := gocv.IMDecode(fileBytes, gocv.IMReadColor)
+	imgMat, _, err := DecodeImage(fileBytes)
 	if err != nil {
 		return gocv.NewMat(), fmt.Errorf("failed to decode image: %w", err)
 	}
@@ -32,27 +92,253 @@ func FileToMat(file multipart.File) (gocv.Mat, error) {
 	return imgMat, nil
 }
 
-// ResizeImage 根据目标高度进行等比缩放并使用插值方法
-func ResizeImage(img gocv.Mat, originalWidth, originalHeight int, sizeType SizeType) gocv.Mat {
-	var targetHeight int = int(sizeType)
-	// 如果图片高度已经符合要求，则直接返回原图(只会出现在大尺寸上)
-	if originalHeight >= targetHeight && sizeType == BigSize {
+// DecodeImage 解码图片并按EXIF中的方向旋转
+// 解码后只保留像素,之后编码写入的图片都不包含原图的任何元数据(GPS,相机序列号等)
+func DecodeImage(data []byte) (gocv.Mat, Exif, error) {
+	// OpenCV不能解码GIF,先转换为PNG
+	if bytes.HasPrefix(data, []byte("GIF8")) {
+		converted, err := gifToPNG(data)
+		if err != nil {
+			return gocv.NewMat(), Exif{}, ErrImageDecode
+		}
+		data = converted
+	}
+	img, err := gocv.IMDecode(data, gocv.IMReadColor|gocv.IMReadIgnoreOrientation)
+	if err != nil || img.Empty() {
+		img.Close()
+		return gocv.NewMat(), Exif{}, ErrImageDecode
+	}
+	exif := ReadExif(data)
+	return orientImage(img, exif.Orientation), exif, nil
+}
+
+// gifToPNG 只解码GIF的第一帧,按逻辑画布大小重新编码为PNG
+func gifToPNG(data []byte) ([]byte, error) {
+	config, err := gif.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	frame, err := gif.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	// 第一帧可能小于画布,未覆盖的部分保持透明
+	canvas := image.NewNRGBA(image.Rect(0, 0, config.Width, config.Height))
+	draw.Draw(canvas, frame.Bounds(), frame, frame.Bounds().Min, draw.Src)
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, canvas); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// orientImage 按EXIF方向旋转或翻转图片,会关闭传入的图片
+// https://www.exif.org/Exif2-2.PDF 第18页
+func orientImage(img gocv.Mat, orientation int) gocv.Mat {
+	if orientation <= 1 {
+		return img
+	}
+	defer img.Close()
+	res := gocv.NewMat()
+	switch orientation {
+	case 2:
+		gocv.Flip(img, &res, 1)
+	case 3:
+		gocv.Rotate(img, &res, gocv.Rotate180Clockwise)
+	case 4:
+		gocv.Flip(img, &res, 0)
+	case 5:
+		gocv.Transpose(img, &res)
+	case 6:
+		gocv.Rotate(img, &res, gocv.Rotate90Clockwise)
+	case 7:
+		transposed := gocv.NewMat()
+		defer transposed.Close()
+		gocv.Transpose(img, &transposed)
+		gocv.Flip(transposed, &res, -1)
+	case 8:
+		gocv.Rotate(img, &res, gocv.Rotate90CounterClockwise)
+	default:
 		return img.Clone()
 	}
+	return res
+}
+
+// imageMetadata 获取可以公开的图片信息
+func imageMetadata(img gocv.Mat, exif Exif) *model.ImageMetadata {
+	metadata := &model.ImageMetadata{
+		Software: exif.Software,
+		Width:    img.Cols(),
+		Height:   img.Rows(),
+	}
+	if software := []rune(metadata.Software); len(software) > 64 {
+		metadata.Software = string(software[:64])
+	}
+	if !exif.CapturedAt.IsZero() {
+		metadata.CapturedAt = &exif.CapturedAt
+	}
+	return metadata
+}
+
+// ErrImageDecode 文件不是可识别的图片,重试也不会成功
+var ErrImageDecode = errors.New("图片解码失败")
 
-	// 计算缩放比例和目标宽度
-	scale := float64(targetHeight) / float64(originalHeight)
-	targetWidth := int(float64(originalWidth) * scale)
+// ImageResult 图片处理结果
+type ImageResult struct {
+	Sizes    []model.ImageSize    // 各尺寸图片,按宽度从小到大排列
+	Variants []model.ImageVariant // 其他格式的大图和中图
+	Metadata *model.ImageMetadata // 原图信息
+	Hash     uint64               // 差异哈希,用于发现转载
+}
+
+// ProcessImage 从存储中读取原图,按尺寸阶梯生成各尺寸图片(最大的写入bigURI,最小的写入midURI),
+// 以及可用的其他格式(AVIF,WebP)的各尺寸图片
+func ProcessImage(storage Storage, ladder SizeLadder, source string, bigURI string, midURI string) (ImageResult, error) {
+	var result ImageResult
+	fileBytes, err := ReadFile(storage, source)
+	if err != nil {
+		return result, fmt.Errorf("failed to read file: %w", err)
+	}
+	img, exif, err := DecodeImage(fileBytes)
+	if err != nil {
+		return result, err
+	}
+	defer img.Close()
+	result.Metadata = imageMetadata(img, exif)
+	result.Hash = DHash(img)
+
+	// 计算各尺寸,原图较小时多个尺寸会相同
+	originalWidth := img.Cols()
+	originalHeight := img.Rows()
+	var sizes []model.ImageSize
+	for _, size := range ladder.Sizes {
+		width, height := ladder.Fit(originalWidth, originalHeight, size)
+		if len(sizes) == 0 || sizes[len(sizes)-1].Width != width {
+			sizes = append(sizes, model.ImageSize{Width: width, Height: height, URI: SizedURI(bigURI, width)})
+		}
+	}
+	sizes[0].URI = bigURI
+	if len(sizes) > 1 {
+		sizes[len(sizes)-1].URI = midURI
+	}
+
+	ext := strings.ToLower(filepath.Ext(bigURI))
+	failed := map[string]bool{}
+	for _, size := range sizes {
+		uris := []string{size.URI}
+		if len(sizes) == 1 {
+			// 只有一个尺寸时大图和中图相同
+			uris = append(uris, midURI)
+		}
+		resized := ResizeImage(img, size.Width, size.Height)
+		for _, uri := range uris {
+			if err := writeImage(storage, uri, resized); err != nil {
+				resized.Close()
+				return result, err
+			}
+		}
+
+		// 其他格式编码失败不影响原格式图片的使用
+		for _, format := range VariantFormats() {
+			if format.Ext == ext || failed[format.Name] {
+				continue
+			}
+			for _, uri := range uris {
+				uri = VariantURI(uri, format.Ext)
+				if err := writeImage(storage, uri, resized); err != nil {
+					log.Println("图片", uri, "生成失败", err)
+					failed[format.Name] = true
+					break
+				}
+			}
+		}
+		resized.Close()
+	}
+
+	// 只保留所有尺寸都生成成功的格式
+	for _, format := range VariantFormats() {
+		if format.Ext == ext || failed[format.Name] {
+			continue
+		}
+		result.Variants = append(result.Variants, model.ImageVariant{
+			Format: format.Name,
+			BigURI: VariantURI(bigURI, format.Ext),
+			MidURI: VariantURI(midURI, format.Ext),
+		})
+	}
+	slices.Reverse(sizes)
+	result.Sizes = sizes
+	return result, nil
+}
+
+// ImageFormat 图片格式
+type ImageFormat struct {
+	Name        string
+	Ext         string
+	ContentType string
+}
+
+// ImageFormats 额外生成的图片格式,按优先级排列
+var ImageFormats = []ImageFormat{
+	{"avif", ".avif", "image/avif"},
+	{"webp", ".webp", "image/webp"},
+}
+
+var (
+	availableFormats     []ImageFormat
+	availableFormatsOnce sync.Once
+)
+
+// VariantFormats 获取当前OpenCV能够编码的额外格式(AVIF需要OpenCV编译时启用libavif)
+func VariantFormats() []ImageFormat {
+	availableFormatsOnce.Do(func() {
+		img := gocv.NewMatWithSize(8, 8, gocv.MatTypeCV8UC3)
+		defer img.Close()
+		for _, format := range ImageFormats {
+			if buf, err := gocv.IMEncode(gocv.FileExt(format.Ext), img); err == nil && buf.Len() != 0 {
+				buf.Close()
+				availableFormats = append(availableFormats, format)
+			} else {
+				log.Println("不支持编码", format.Name, "格式的图片", err)
+			}
+		}
+	})
+	return availableFormats
+}
+
+// VariantURI 获取其他格式的图片地址
+func VariantURI(uri string, ext string) string {
+	return strings.TrimSuffix(uri, filepath.Ext(uri)) + ext
+}
+
+// writeImage 按文件扩展名编码图片并写入存储
+func writeImage(storage Storage, uri string, img gocv.Mat) error {
+	buf, err := gocv.IMEncode(gocv.FileExt(strings.ToLower(filepath.Ext(uri))), img)
+	if err != nil {
+		return fmt.Errorf("failed to encode image: %w", err)
+	}
+	defer buf.Close()
+	data := buf.GetBytes()
+	return storage.Put(uri, bytes.NewReader(data), int64(len(data)), "")
+}
+
+// ResizeImage 将图片缩放到指定宽高,根据缩放方向选择插值方法
+func ResizeImage(img gocv.Mat, width int, height int) gocv.Mat {
+	// 尺寸相同时直接复制原图
+	if img.Cols() == width && img.Rows() == height {
+		return img.Clone()
+	}
 
 	// 选择插值方法
 	interpolation := gocv.InterpolationCubic
-	if originalHeight > targetHeight {
+	if img.Rows() > height {
 		interpolation = gocv.InterpolationArea
 	}
 
 	// 调整图片尺寸
 	resizedImg := gocv.NewMat()
-	gocv.Resize(img, &resizedImg, image.Point{X: targetWidth, Y: targetHeight}, 0, 0, interpolation)
+	gocv.Resize(img, &resizedImg, image.Point{X: width, Y: height}, 0, 0, interpolation)
 
 	return resizedImg
 }
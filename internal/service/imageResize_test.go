@@ -0,0 +1,46 @@
+package service
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/png"
+	"testing"
+)
+
+func TestGifToPNG(t *testing.T) {
+	palette := color.Palette{color.Black, color.White}
+	first := image.NewPaletted(image.Rect(2, 1, 6, 3), palette)
+	first.SetColorIndex(2, 1, 1)
+	second := image.NewPaletted(image.Rect(0, 0, 8, 4), palette)
+	var buf bytes.Buffer
+	err := gif.EncodeAll(&buf, &gif.GIF{
+		Image:  []*image.Paletted{first, second},
+		Delay:  []int{10, 10},
+		Config: image.Config{ColorModel: palette, Width: 8, Height: 4},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := gifToPNG(buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 按画布大小输出第一帧
+	if got := img.Bounds(); got != image.Rect(0, 0, 8, 4) {
+		t.Errorf("bounds = %v", got)
+	}
+	if r, g, b, _ := img.At(2, 1).RGBA(); r != 0xffff || g != 0xffff || b != 0xffff {
+		t.Errorf("pixel = %v,%v,%v", r, g, b)
+	}
+
+	if _, err := gifToPNG([]byte("GIF89a")); err == nil {
+		t.Error("截断的GIF应解码失败")
+	}
+}
@@ -0,0 +1,95 @@
+package service
+
+import (
+	"PaintingExchange/internal/model"
+	"errors"
+)
+
+var (
+	ErrPageOrder = errors.New("页面顺序必须包含作品的全部页面且不能重复")
+	ErrPageCover = errors.New("封面不是作品中的页面")
+)
+
+// ImagePages 获取作品的各页,旧数据没有分页时把图片本身作为唯一的一页
+func ImagePages(image model.Image) []model.ImagePage {
+	if len(image.Pages) != 0 {
+		return image.Pages
+	}
+	return []model.ImagePage{{
+		ID:       image.ID,
+		BigURI:   image.BigURI,
+		MidURI:   image.MidURI,
+		Status:   image.Status,
+		Sizes:    image.Sizes,
+		Variants: image.Variants,
+		Metadata: image.Metadata,
+	}}
+}
+
+// ApplyCover 用封面页填充作品的地址,各尺寸,其他格式和原图信息,封面为空或不存在时使用第一页
+// 作品的处理状态:有一页失败则失败,全部完成才完成
+func ApplyCover(image *model.Image) {
+	pages := ImagePages(*image)
+	image.Pages = pages
+
+	cover := pages[0]
+	if i, ok := pageIndex(pages, image.Cover); ok {
+		cover = pages[i]
+	}
+	image.Cover = cover.ID
+	image.BigURI = cover.BigURI
+	image.MidURI = cover.MidURI
+	image.Sizes = cover.Sizes
+	image.Variants = cover.Variants
+	image.Metadata = cover.Metadata
+
+	image.Status = ImageStatusReady
+	for _, page := range pages {
+		if page.Status == ImageStatusFailed {
+			image.Status = ImageStatusFailed
+			break
+		}
+		if page.Status == ImageStatusPending {
+			image.Status = ImageStatusPending
+		}
+	}
+}
+
+// ReorderPages 按给定的页面id顺序重新排列作品的各页并设置封面
+func ReorderPages(image *model.Image, order model.PageOrder) error {
+	pages := ImagePages(*image)
+	if len(order.Pages) != len(pages) {
+		return ErrPageOrder
+	}
+	byID := map[string]model.ImagePage{}
+	for _, page := range pages {
+		byID[page.ID] = page
+	}
+	reordered := make([]model.ImagePage, 0, len(pages))
+	for _, id := range order.Pages {
+		page, ok := byID[id]
+		if !ok {
+			return ErrPageOrder
+		}
+		delete(byID, id)
+		reordered = append(reordered, page)
+	}
+	if _, ok := pageIndex(reordered, order.Cover); order.Cover != "" && !ok {
+		return ErrPageCover
+	}
+
+	image.Pages = reordered
+	image.Cover = order.Cover
+	ApplyCover(image)
+	return nil
+}
+
+// pageIndex 查找页面在作品中的位置
+func pageIndex(pages []model.ImagePage, id string) (int, bool) {
+	for i, page := range pages {
+		if page.ID == id {
+			return i, true
+		}
+	}
+	return -1, false
+}
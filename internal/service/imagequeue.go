@@ -0,0 +1,353 @@
+package service
+
+import (
+	"PaintingExchange/internal/env"
+	"PaintingExchange/internal/model"
+	"context"
+	"errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"gorm.io/gorm"
+	"log"
+	"sync"
+	"time"
+)
+
+// 图片处理状态
+const (
+	ImageStatusPending = "pending" // 等待处理
+	ImageStatusReady   = "ready"   // 已生成各尺寸图片
+	ImageStatusFailed  = "failed"  // 处理失败
+)
+
+var ErrImageQueueFull = errors.New("图片处理队列已满,请稍后再试")
+
+// imageRescanInterval 定期检查未在队列中的待处理任务(重启前未完成或重试时队列已满)的间隔
+const imageRescanInterval = time.Minute
+
+// imageJobLease 任务开始处理后在此期间不会被定期检查重新加入,避免多个实例同时处理正在处理的任务
+const imageJobLease = 10 * time.Minute
+
+// ImageQueue 图片处理队列,固定数量的后台协程依次处理上传的原图
+// 任务记录在数据库中,未完成且不在队列中的任务会被定期重新加入;临时错误按指数退避重试
+type ImageQueue struct {
+	Db          *gorm.DB
+	Mg          *mongo.Client
+	Storage     Storage
+	Ladder      SizeLadder         // 尺寸阶梯
+	Duplicates  *DuplicateDetector // 疑似转载检测
+	Workers     int                // 并发处理数
+	MaxAttempts int                // 最多尝试次数
+	RetryDelay  time.Duration      // 首次重试间隔
+
+	jobs     chan string
+	queuedMu sync.Mutex
+	queued   map[string]bool // 在队列中,正在处理或等待重试的任务,避免重复加入
+	syncMu   sync.Mutex      // 同一作品的多页可能同时处理完成,修改各页时需要先读后写
+}
+
+// NewImageQueue 根据环境变量配置创建图片处理队列
+func NewImageQueue(db *gorm.DB, mg *mongo.Client, storage Storage, duplicates *DuplicateDetector) *ImageQueue {
+	workers, size, maxAttempts, retryDelay := env.GetImageQueue()
+	mode, sizes := env.GetImageSizes()
+	ladder, err := NewSizeLadder(mode, sizes)
+	if err != nil {
+		log.Println("图片尺寸配置错误,使用原有的大图和中图尺寸", err)
+		ladder, _ = NewSizeLadder(SizeByHeight, "3000,300")
+	}
+	return &ImageQueue{
+		Db:          db,
+		Mg:          mg,
+		Storage:     storage,
+		Ladder:      ladder,
+		Duplicates:  duplicates,
+		Workers:     workers,
+		MaxAttempts: maxAttempts,
+		RetryDelay:  retryDelay,
+		jobs:        make(chan string, size),
+		queued:      map[string]bool{},
+	}
+}
+
+// Start 启动处理协程,并定期重新加入未完成的任务(包括上次启动时未完成的)
+func (q *ImageQueue) Start() {
+	for i := 0; i < q.Workers; i++ {
+		go q.work()
+	}
+
+	go func() {
+		for {
+			q.rescan()
+			time.Sleep(imageRescanInterval)
+		}
+	}()
+}
+
+// Submit 提交图片处理任务,队列已满时返回 ErrImageQueueFull
+func (q *ImageQueue) Submit(job model.ImageJob) error {
+	job.Status = ImageStatusPending
+	job.CreatedAt = time.Now()
+	job.UpdatedAt = job.CreatedAt
+	if err := q.Db.Create(&job).Error; err != nil {
+		return err
+	}
+
+	if !q.enqueue(job.ID) {
+		q.Db.Delete(&job)
+		return ErrImageQueueFull
+	}
+	return nil
+}
+
+// Job 获取图片处理任务
+func (q *ImageQueue) Job(id string) (model.ImageJob, bool) {
+	var job model.ImageJob
+	return job, q.Db.Where("id=?", id).Find(&job).RowsAffected != 0
+}
+
+// SyncImageStatus 将任务的处理状态,生成的各尺寸,其他格式和原图信息同步到所属作品的对应页(作品不存在时忽略),
+// 并重新计算作品的处理状态和封面信息;处理完成时将该页的哈希加入疑似转载检测的索引.
+// 新版本文件处理完成时替换对应页(拒绝疑似转载时,与其他作者的图片相似则不替换,任务改为失败),阶段图片只同步到对应的阶段
+func (q *ImageQueue) SyncImageStatus(id string) {
+	job, ok := q.Job(id)
+	if !ok {
+		return
+	}
+	images := q.Mg.Database("PaintingExchange").Collection("Images")
+	stageUpdate := bson.M{"$set": bson.M{
+		"stages.$.status":   job.Status,
+		"stages.$.sizes":    job.Sizes,
+		"stages.$.variants": job.Variants,
+		"stages.$.metadata": job.Metadata,
+	}}
+	if res, err := images.UpdateOne(context.Background(), bson.M{"stages.id": id}, stageUpdate); err != nil {
+		log.Println("阶段图片", id, "处理状态同步失败", err)
+		return
+	} else if res.MatchedCount != 0 {
+		return
+	}
+
+	// 相似图片只查询一次,替换新版本时的判断和加入索引共用;查询失败时不拒绝,也不加入索引
+	var similar []model.DuplicateReport
+	similarOK := false
+	if job.Status == ImageStatusReady {
+		var err error
+		if similar, err = q.Duplicates.Similar(job.Username, job.Hash); err != nil {
+			log.Println("图片", id, "相似图片查询失败", err)
+		} else {
+			similarOK = true
+		}
+	}
+
+	var dropped []model.ImageRevision
+	rejected := false
+	filter := bson.M{"$or": bson.A{bson.M{"_id": id}, bson.M{"pages.id": id}, bson.M{"pages.replacing": id}}}
+	image, err := q.UpdatePages(filter, func(image *model.Image) error {
+		for i, page := range image.Pages {
+			switch id {
+			case page.ID:
+				image.Pages[i].Status = job.Status
+				image.Pages[i].Sizes = job.Sizes
+				image.Pages[i].Variants = job.Variants
+				image.Pages[i].Metadata = job.Metadata
+			case page.Replacing:
+				// 新版本处理完成后才替换,失败或疑似转载时保留当前版本
+				if job.Status == ImageStatusReady && q.Duplicates.Rejects(image.ID, similar) {
+					image.Pages[i].Replacing = ""
+					rejected = true
+				} else if job.Status == ImageStatusReady {
+					dropped = replacePage(image, i, model.ImagePage{
+						ID:       job.ID,
+						BigURI:   job.BigURI,
+						MidURI:   job.MidURI,
+						Status:   job.Status,
+						Sizes:    job.Sizes,
+						Variants: job.Variants,
+						Metadata: job.Metadata,
+					})
+				} else if job.Status == ImageStatusFailed {
+					image.Pages[i].Replacing = ""
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		if !errors.Is(err, mongo.ErrNoDocuments) {
+			log.Println("图片", id, "处理状态同步失败", err)
+		}
+		return
+	}
+	for _, revision := range dropped {
+		DeletePageFiles(q.Db, q.Storage, revision.ImagePage)
+	}
+	if rejected {
+		job.Status, job.Error = ImageStatusFailed, ErrDuplicateImage.Error()
+		q.Db.Model(&job).Updates(map[string]interface{}{"status": job.Status, "error": job.Error})
+		deleteFiles(q.Db, q.Storage, append(pageFiles(model.ImagePage{BigURI: job.BigURI, MidURI: job.MidURI, Sizes: job.Sizes}), job.Source), nil)
+	}
+	if job.Status == ImageStatusReady && similarOK {
+		q.Duplicates.Index(image.ID, job.ID, job.Username, job.Hash, similar)
+	}
+}
+
+// UpdatePages 读取作品并修改各页,然后重新计算封面和处理状态并保存,作品不存在时返回 mongo.ErrNoDocuments
+// 后台处理和作者操作都会修改各页,需要通过此方法串行执行,以免互相覆盖
+func (q *ImageQueue) UpdatePages(filter interface{}, update func(image *model.Image) error) (model.Image, error) {
+	q.syncMu.Lock()
+	defer q.syncMu.Unlock()
+
+	images := q.Mg.Database("PaintingExchange").Collection("Images")
+	var image model.Image
+	if err := images.FindOne(context.Background(), filter).Decode(&image); err != nil {
+		return image, err
+	}
+	image.Pages = ImagePages(image)
+	if err := update(&image); err != nil {
+		return image, err
+	}
+	ApplyCover(&image)
+	_, err := images.UpdateOne(context.Background(), bson.D{{"_id", image.ID}}, bson.M{"$set": bson.M{
+		"pages":    image.Pages,
+		"cover":    image.Cover,
+		"status":   image.Status,
+		"bigURI":   image.BigURI,
+		"midURI":   image.MidURI,
+		"sizes":    image.Sizes,
+		"variants": image.Variants,
+		"metadata": image.Metadata,
+	}})
+	return image, err
+}
+
+// enqueue 不阻塞地加入队列,已在队列中时忽略;队列已满时返回false
+func (q *ImageQueue) enqueue(id string) bool {
+	q.queuedMu.Lock()
+	defer q.queuedMu.Unlock()
+	if q.queued[id] {
+		return true
+	}
+	select {
+	case q.jobs <- id:
+		q.queued[id] = true
+		return true
+	default:
+		return false
+	}
+}
+
+// release 任务处理结束,或重试时队列已满,之后可以重新加入
+func (q *ImageQueue) release(id string) {
+	q.queuedMu.Lock()
+	defer q.queuedMu.Unlock()
+	delete(q.queued, id)
+}
+
+// retry 等待重试的任务重新加入队列,队列已满时保持pending,由定期检查重新加入
+func (q *ImageQueue) retry(id string) {
+	q.queuedMu.Lock()
+	defer q.queuedMu.Unlock()
+	select {
+	case q.jobs <- id:
+	default:
+		log.Println("图片", id, "重试时队列已满,稍后重新加入")
+		delete(q.queued, id)
+	}
+}
+
+// rescan 将未完成且不在队列中的任务重新加入队列,直到队列已满
+// 最近开始处理的任务可能正在其他实例中处理,租约过期后才重新加入
+func (q *ImageQueue) rescan() {
+	var pending []string
+	q.Db.Model(&model.ImageJob{}).Where("status=? AND updated_at<?", ImageStatusPending, time.Now().Add(-imageJobLease)).
+		Order("created_at").Pluck("id", &pending)
+	added := 0
+	for _, id := range pending {
+		q.queuedMu.Lock()
+		queued := q.queued[id]
+		q.queuedMu.Unlock()
+		if queued {
+			continue
+		}
+		if !q.enqueue(id) {
+			break
+		}
+		added++
+	}
+	if added != 0 {
+		log.Println("重新加入", added, "个未完成的图片处理任务")
+	}
+}
+
+// work 处理协程
+func (q *ImageQueue) work() {
+	for id := range q.jobs {
+		if delay := q.process(id); delay > 0 {
+			// 等待重试期间仍视为在队列中
+			time.AfterFunc(delay, func() {
+				q.retry(id)
+			})
+		} else {
+			q.release(id)
+		}
+	}
+}
+
+// process 处理单个任务,临时错误时返回重试前需要等待的时长
+func (q *ImageQueue) process(id string) time.Duration {
+	job, ok := q.Job(id)
+	if !ok || job.Status != ImageStatusPending {
+		return 0
+	}
+
+	// 以读取到的尝试次数为条件认领任务,多个实例同时取到同一任务时只有一个能认领成功
+	start := time.Now()
+	claim := q.Db.Model(&model.ImageJob{}).Where("id=? AND status=? AND attempts=?", id, ImageStatusPending, job.Attempts).
+		Updates(map[string]interface{}{"attempts": job.Attempts + 1, "updated_at": start})
+	if claim.Error != nil {
+		log.Println("图片", id, "处理任务认领失败,", q.RetryDelay, "后重试", claim.Error)
+		return q.RetryDelay
+	}
+	if claim.RowsAffected != 1 {
+		log.Println("图片", id, "已由其他实例处理")
+		return 0
+	}
+
+	var delay time.Duration
+	job.Attempts++
+	result, err := ProcessImage(q.Storage, q.Ladder, job.Source, job.BigURI, job.MidURI)
+	job.UpdatedAt = time.Now()
+	switch {
+	case err == nil:
+		log.Println("图片", id, "处理完成,耗时", time.Since(start))
+		job.Status = ImageStatusReady
+		job.Sizes = result.Sizes
+		job.Variants = result.Variants
+		job.Metadata = result.Metadata
+		job.Hash = result.Hash
+		job.Error = ""
+	case errors.Is(err, ErrImageDecode) || job.Attempts >= q.MaxAttempts:
+		log.Println("图片", id, "处理失败", err)
+		job.Status = ImageStatusFailed
+		job.Error = err.Error()
+	default:
+		// 临时错误,稍后重试
+		delay = q.RetryDelay << (job.Attempts - 1)
+		log.Println("图片", id, "第", job.Attempts, "次处理失败,", delay, "后重试", err)
+		job.Error = err.Error()
+	}
+
+	// 只写入处理结果,条件为本次认领时的尝试次数
+	saved := q.Db.Model(&job).Where("attempts=?", job.Attempts).
+		Select("status", "sizes", "variants", "metadata", "hash", "error", "updated_at").Updates(&job)
+	if saved.Error != nil {
+		log.Println("图片", id, "处理任务保存失败", saved.Error)
+	} else if saved.RowsAffected == 0 {
+		log.Println("图片", id, "处理期间任务已被其他实例认领,丢弃本次结果")
+		return 0
+	}
+	if job.Status != ImageStatusPending {
+		q.SyncImageStatus(id)
+	}
+	return delay
+}
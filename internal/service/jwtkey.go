@@ -0,0 +1,248 @@
+package service
+
+import (
+	"PaintingExchange/internal/env"
+	"PaintingExchange/internal/model"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"github.com/golang-jwt/jwt/v5"
+	"log"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// jwtSecretMinLen HS256密钥的最小长度(字节)
+const jwtSecretMinLen = 32
+
+// ephemeralKid 未配置密钥时临时生成的密钥id
+const ephemeralKid = "ephemeral"
+
+// 令牌类型,写入头部的typ;所有令牌使用同一组密钥,验证时必须检查类型,
+// 否则公开公钥后,其他服务会把两步验证等专用令牌当作访问令牌接受
+const (
+	AccessTokenType  = "at+jwt"      // 访问令牌(RFC 9068)
+	PurposeTokenType = "purpose+jwt" // 两步验证,邮箱验证,重置密码,第三方登录流程等专用令牌
+)
+
+// signingKey jwt签名密钥
+type signingKey struct {
+	ID      string            // 密钥id(kid)
+	Method  jwt.SigningMethod // 签名算法
+	Private interface{}       // 签名用密钥,为nil时仅用于验证
+	Public  interface{}       // 验证用密钥
+}
+
+var (
+	keyMu      sync.RWMutex
+	keys       map[string]*signingKey
+	signingKid string
+)
+
+// LoadJWTKeys 加载jwt密钥
+// 密钥目录(jwtKeyDir)下的每个文件为一个密钥,文件名(不含扩展名)即kid:
+// *.secret 为HS256密钥; *.pem 为RSA(RS256)或Ed25519(EdDSA)私钥,公钥文件仅用于验证.
+// jwtSecret 环境变量可额外提供一个kid为env的HS256密钥.
+// 签发使用 jwtSigningKid 指定的密钥,其余密钥在轮换期间继续用于验证.
+func LoadJWTKeys() error {
+	loaded := map[string]*signingKey{}
+
+	if secret := env.GetJWTSecret(); secret != "" {
+		if len(secret) < jwtSecretMinLen {
+			return fmt.Errorf("jwtSecret长度不足%d字节", jwtSecretMinLen)
+		}
+		loaded["env"] = &signingKey{ID: "env", Method: jwt.SigningMethodHS256, Private: []byte(secret), Public: []byte(secret)}
+	}
+
+	if dir := env.GetJWTKeyDir(); dir != "" {
+		entries, err := os.ReadDir(dir)
+		if err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("读取密钥目录失败: %w", err)
+		}
+		for _, entry := range entries {
+			if entry.IsDir() {
+				continue
+			}
+			key, err := loadKeyFile(filepath.Join(dir, entry.Name()))
+			if err != nil {
+				return err
+			}
+			if key != nil {
+				loaded[key.ID] = key
+			}
+		}
+	}
+
+	// 选择签发用密钥
+	kid := env.GetJWTSigningKid()
+	if kid == "" {
+		for id, key := range loaded {
+			if key.Private == nil {
+				continue
+			}
+			if kid != "" {
+				return errors.New("存在多个可签发的jwt密钥,请通过jwtSigningKid指定")
+			}
+			kid = id
+		}
+	}
+	if kid == "" {
+		// 未配置密钥时生成临时密钥,重启后已签发的访问令牌全部失效;重新加载时沿用已生成的临时密钥
+		kid = ephemeralKid
+		keyMu.RLock()
+		ephemeral := keys[kid]
+		keyMu.RUnlock()
+		if ephemeral == nil {
+			log.Println("未配置jwt密钥,使用临时生成的随机密钥")
+			secret := make([]byte, jwtSecretMinLen)
+			if _, err := rand.Read(secret); err != nil {
+				return err
+			}
+			ephemeral = &signingKey{ID: kid, Method: jwt.SigningMethodHS256, Private: secret, Public: secret}
+		}
+		loaded[kid] = ephemeral
+	}
+	if key, ok := loaded[kid]; !ok || key.Private == nil {
+		return fmt.Errorf("jwt签发密钥%s不存在或缺少私钥", kid)
+	}
+
+	keyMu.Lock()
+	keys = loaded
+	signingKid = kid
+	keyMu.Unlock()
+	log.Println("已加载", len(loaded), "个jwt密钥,签发密钥为", kid)
+	return nil
+}
+
+// SignToken 使用当前签发密钥签名,并在头部写入kid和令牌类型
+func SignToken(typ string, claims jwt.Claims) (string, error) {
+	keyMu.RLock()
+	key := keys[signingKid]
+	keyMu.RUnlock()
+	if key == nil {
+		return "", errors.New("jwt密钥未加载")
+	}
+
+	token := jwt.NewWithClaims(key.Method, claims)
+	token.Header["kid"] = key.ID
+	token.Header["typ"] = typ
+	return token.SignedString(key.Private)
+}
+
+// ParseToken 根据头部kid选择密钥验证并解析令牌,头部的令牌类型必须为typ
+func ParseToken(typ string, tokenString string) (*jwt.Token, error) {
+	return jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		if t, _ := token.Header["typ"].(string); t != typ {
+			return nil, fmt.Errorf("令牌类型%s不是%s", t, typ)
+		}
+		kid, _ := token.Header["kid"].(string)
+		keyMu.RLock()
+		key := keys[kid]
+		keyMu.RUnlock()
+		if key == nil {
+			return nil, fmt.Errorf("未知的密钥id: %s", kid)
+		}
+		// 算法必须与密钥一致,防止算法混淆攻击
+		if token.Method.Alg() != key.Method.Alg() {
+			return nil, fmt.Errorf("密钥%s不支持算法%s", kid, token.Method.Alg())
+		}
+		return key.Public, nil
+	})
+}
+
+// GetJWKS 获取所有非对称验证密钥的公钥
+func GetJWKS() model.JWKS {
+	keyMu.RLock()
+	defer keyMu.RUnlock()
+
+	res := model.JWKS{Keys: []model.JWK{}}
+	for _, key := range keys {
+		switch pub := key.Public.(type) {
+		case *rsa.PublicKey:
+			res.Keys = append(res.Keys, model.JWK{
+				Kty: "RSA",
+				Kid: key.ID,
+				Use: "sig",
+				Alg: key.Method.Alg(),
+				N:   base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+				E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+			})
+		case ed25519.PublicKey:
+			res.Keys = append(res.Keys, model.JWK{
+				Kty: "OKP",
+				Kid: key.ID,
+				Use: "sig",
+				Alg: key.Method.Alg(),
+				Crv: "Ed25519",
+				X:   base64.RawURLEncoding.EncodeToString(pub),
+			})
+		}
+	}
+	sort.Slice(res.Keys, func(i, j int) bool {
+		return res.Keys[i].Kid < res.Keys[j].Kid
+	})
+	return res
+}
+
+// loadKeyFile 读取单个密钥文件,不认识的扩展名返回nil
+func loadKeyFile(path string) (*signingKey, error) {
+	ext := filepath.Ext(path)
+	kid := strings.TrimSuffix(filepath.Base(path), ext)
+	if ext != ".secret" && ext != ".pem" {
+		return nil, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取密钥文件%s失败: %w", path, err)
+	}
+
+	if ext == ".secret" {
+		secret := []byte(strings.TrimSpace(string(data)))
+		if len(secret) < jwtSecretMinLen {
+			return nil, fmt.Errorf("密钥%s长度不足%d字节", kid, jwtSecretMinLen)
+		}
+		return &signingKey{ID: kid, Method: jwt.SigningMethodHS256, Private: secret, Public: secret}, nil
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("密钥文件%s不是PEM格式", path)
+	}
+	var parsed interface{}
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "PUBLIC KEY":
+		parsed, err = x509.ParsePKIXPublicKey(block.Bytes)
+	default:
+		err = fmt.Errorf("不支持的PEM类型%s", block.Type)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("解析密钥文件%s失败: %w", path, err)
+	}
+
+	switch k := parsed.(type) {
+	case *rsa.PrivateKey:
+		return &signingKey{ID: kid, Method: jwt.SigningMethodRS256, Private: k, Public: &k.PublicKey}, nil
+	case *rsa.PublicKey:
+		return &signingKey{ID: kid, Method: jwt.SigningMethodRS256, Public: k}, nil
+	case ed25519.PrivateKey:
+		return &signingKey{ID: kid, Method: jwt.SigningMethodEdDSA, Private: k, Public: k.Public()}, nil
+	case ed25519.PublicKey:
+		return &signingKey{ID: kid, Method: jwt.SigningMethodEdDSA, Public: k}, nil
+	default:
+		return nil, fmt.Errorf("密钥文件%s的密钥类型不受支持", path)
+	}
+}
@@ -0,0 +1,37 @@
+package service
+
+import (
+	"github.com/golang-jwt/jwt/v5"
+	"testing"
+	"time"
+)
+
+func TestParseTokenType(t *testing.T) {
+	t.Setenv("jwtSecret", "")
+	t.Setenv("jwtSigningKid", "")
+	t.Setenv("jwtKeyDir", t.TempDir())
+	if err := LoadJWTKeys(); err != nil {
+		t.Fatal(err)
+	}
+
+	claims := jwt.MapClaims{"username": "test", "exp": time.Now().Add(time.Minute).Unix()}
+	purpose, err := SignToken(PurposeTokenType, claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	access, err := SignToken(AccessTokenType, claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 签名相同,类型不同的令牌不能互相替代
+	if _, err := ParseToken(AccessTokenType, purpose); err == nil {
+		t.Error("专用令牌被当作访问令牌接受")
+	}
+	if _, err := ParseToken(PurposeTokenType, access); err == nil {
+		t.Error("访问令牌被当作专用令牌接受")
+	}
+	if token, err := ParseToken(AccessTokenType, access); err != nil || !token.Valid {
+		t.Errorf("访问令牌验证失败: %v", err)
+	}
+}
@@ -0,0 +1,245 @@
+package service
+
+import (
+	"PaintingExchange/internal/env"
+	"PaintingExchange/internal/model"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// AttemptStore 登录失败记录存储,默认使用内存实现,多实例部署时可替换为共享存储
+type AttemptStore interface {
+	Get(key string) (model.LoginAttempt, bool)
+	// Update 原子地读取并修改一条记录,fn返回false时删除该记录;并发的登录请求依赖它避免计数丢失
+	Update(key string, fn func(attempt model.LoginAttempt, ok bool) (model.LoginAttempt, bool))
+	Delete(key string)
+	List() []model.LoginAttempt
+	// Prune 删除最近一次失败早于 before 且未处于锁定的记录
+	Prune(before time.Time)
+}
+
+// MemoryAttemptStore 内存登录失败记录存储
+type MemoryAttemptStore struct {
+	mu       sync.Mutex
+	attempts map[string]model.LoginAttempt
+}
+
+// NewMemoryAttemptStore 创建内存登录失败记录存储
+func NewMemoryAttemptStore() *MemoryAttemptStore {
+	return &MemoryAttemptStore{attempts: map[string]model.LoginAttempt{}}
+}
+
+func (s *MemoryAttemptStore) Get(key string) (model.LoginAttempt, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	attempt, ok := s.attempts[key]
+	return attempt, ok
+}
+
+func (s *MemoryAttemptStore) Update(key string, fn func(attempt model.LoginAttempt, ok bool) (model.LoginAttempt, bool)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	attempt, ok := s.attempts[key]
+	if attempt, keep := fn(attempt, ok); keep {
+		s.attempts[key] = attempt
+	} else {
+		delete(s.attempts, key)
+	}
+}
+
+func (s *MemoryAttemptStore) Delete(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.attempts, key)
+}
+
+func (s *MemoryAttemptStore) List() []model.LoginAttempt {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	res := make([]model.LoginAttempt, 0, len(s.attempts))
+	for _, attempt := range s.attempts {
+		res = append(res, attempt)
+	}
+	return res
+}
+
+func (s *MemoryAttemptStore) Prune(before time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	for key, attempt := range s.attempts {
+		if attempt.LastFailure.Before(before) && !attempt.LockedUntil.After(now) {
+			delete(s.attempts, key)
+		}
+	}
+}
+
+// LoginGuard 登录暴力破解防护
+// 每次失败后按指数退避禁止登录,连续失败达到上限后锁定一段时间,
+// 最近一次失败超过锁定时长后失败次数清零.
+// 每次尝试在验证密码之前先按失败记录(Attempt),验证通过后再撤销(Cancel/Succeed),
+// 这样并发的猜测请求在第一个请求的退避期内都会被拒绝.
+type LoginGuard struct {
+	Store        AttemptStore
+	UserFailures int           // 同一用户名允许的连续失败次数
+	IPFailures   int           // 同一IP允许的连续失败次数
+	BaseDelay    time.Duration // 首次失败后的退避时长
+	Lockout      time.Duration // 锁定时长
+
+	mu        sync.Mutex
+	lastPrune time.Time
+}
+
+// NewLoginGuard 根据环境变量配置创建登录防护
+func NewLoginGuard(store AttemptStore) *LoginGuard {
+	userFailures, ipFailures, baseDelay, lockout := env.GetLoginLimit()
+	return &LoginGuard{
+		Store:        store,
+		UserFailures: userFailures,
+		IPFailures:   ipFailures,
+		BaseDelay:    baseDelay,
+		Lockout:      lockout,
+	}
+}
+
+// LoginKeys 获取一次登录需要统计的对象,第一个为账号,第二个为IP
+func LoginKeys(accountType string, username string, ip string) []string {
+	return []string{accountType + ":" + username, "ip:" + ip}
+}
+
+// MailGuard 重置密码邮件的发送频率限制,防止邮件轰炸
+// 与登录防护使用相同的退避和锁定规则,但使用独立的存储和键,重置密码请求不会影响登录
+type MailGuard struct {
+	*LoginGuard
+}
+
+// NewMailGuard 根据环境变量配置创建邮件发送频率限制
+func NewMailGuard(store AttemptStore) *MailGuard {
+	accountRequests, ipRequests, baseDelay, lockout := env.GetMailLimit()
+	return &MailGuard{&LoginGuard{
+		Store:        store,
+		UserFailures: accountRequests,
+		IPFailures:   ipRequests,
+		BaseDelay:    baseDelay,
+		Lockout:      lockout,
+	}}
+}
+
+// MailKeys 获取一次发送需要统计的对象,第一个为账号(用户名或邮箱),第二个为IP
+func MailKeys(account string, ip string) []string {
+	return []string{"mail:user:" + account, "mail:ip:" + ip}
+}
+
+// isIPKey 统计对象是否为IP
+func isIPKey(key string) bool {
+	return strings.HasPrefix(key, "ip:") || strings.HasPrefix(key, "mail:ip:")
+}
+
+// Attempt 开始一次登录尝试:仍在等待时返回需要等待的时长,不记录;
+// 否则先按失败记录并返回0,验证通过后需要调用 Cancel 或 Succeed 撤销
+func (g *LoginGuard) Attempt(keys ...string) time.Duration {
+	now := time.Now()
+	g.prune(now)
+
+	var wait time.Duration
+	var recorded []string
+	for _, key := range keys {
+		g.Store.Update(key, func(attempt model.LoginAttempt, ok bool) (model.LoginAttempt, bool) {
+			if ok && attempt.LockedUntil.After(now) {
+				wait = max(wait, attempt.LockedUntil.Sub(now))
+				return attempt, true
+			}
+			if !ok || now.Sub(attempt.LastFailure) > g.Lockout {
+				attempt = model.LoginAttempt{Key: key}
+			}
+			attempt.Failures++
+			attempt.LastFailure = now
+			attempt.LockedUntil = now.Add(g.delay(key, attempt.Failures))
+			recorded = append(recorded, key)
+			return attempt, true
+		})
+	}
+	if wait > 0 {
+		g.Cancel(recorded...)
+	}
+	return wait
+}
+
+// Cancel 撤销 Attempt 预先记录的一次失败(密码正确但还需要两步验证,或失败与密码无关时)
+func (g *LoginGuard) Cancel(keys ...string) {
+	for _, key := range keys {
+		g.Store.Update(key, func(attempt model.LoginAttempt, ok bool) (model.LoginAttempt, bool) {
+			if !ok || attempt.Failures <= 1 {
+				return attempt, false
+			}
+			attempt.Failures--
+			attempt.LockedUntil = attempt.LastFailure.Add(g.delay(key, attempt.Failures))
+			return attempt, true
+		})
+	}
+}
+
+// Succeed 登录成功(已签发令牌)后清除账号的失败记录,
+// IP的记录只撤销本次尝试,防止攻击者用自己的账号重置计数
+func (g *LoginGuard) Succeed(keys ...string) {
+	for _, key := range keys {
+		if isIPKey(key) {
+			g.Cancel(key)
+		} else {
+			g.Store.Delete(key)
+		}
+	}
+}
+
+// List 获取所有失败记录,正在锁定的排在前面
+func (g *LoginGuard) List() []model.LoginAttempt {
+	g.prune(time.Now())
+	res := g.Store.List()
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].LockedUntil.After(res[j].LockedUntil)
+	})
+	return res
+}
+
+// Clear 清除指定对象的失败记录和锁定
+func (g *LoginGuard) Clear(key string) bool {
+	if _, ok := g.Store.Get(key); !ok {
+		return false
+	}
+	g.Store.Delete(key)
+	return true
+}
+
+// delay 第n次失败后需要等待的时长
+func (g *LoginGuard) delay(key string, failures int) time.Duration {
+	limit := g.UserFailures
+	if isIPKey(key) {
+		limit = g.IPFailures
+	}
+	if failures >= limit {
+		return g.Lockout
+	}
+
+	delay := g.BaseDelay
+	for i := 1; i < failures && delay < g.Lockout; i++ {
+		delay *= 2
+	}
+	if delay > g.Lockout {
+		delay = g.Lockout
+	}
+	return delay
+}
+
+// prune 定期清理过期的失败记录
+func (g *LoginGuard) prune(now time.Time) {
+	g.mu.Lock()
+	if now.Sub(g.lastPrune) < g.Lockout {
+		g.mu.Unlock()
+		return
+	}
+	g.lastPrune = now
+	g.mu.Unlock()
+	g.Store.Prune(now.Add(-g.Lockout))
+}
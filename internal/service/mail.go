@@ -0,0 +1,65 @@
+package service
+
+import (
+	"PaintingExchange/internal/env"
+	"bytes"
+	"fmt"
+	"log"
+	"mime"
+	"net"
+	"net/smtp"
+	"time"
+)
+
+// Mailer 邮件发送
+type Mailer interface {
+	Send(to string, subject string, body string) error
+}
+
+// NewMailer 根据环境变量创建邮件发送器
+func NewMailer() Mailer {
+	if env.GetMailer() == "smtp" {
+		addr, username, password, from := env.GetSMTP()
+		return &SMTPMailer{Addr: addr, Username: username, Password: password, From: from}
+	}
+	return LogMailer{}
+}
+
+// LogMailer 只把邮件打印到日志,用于开发环境
+type LogMailer struct{}
+
+func (LogMailer) Send(to string, subject string, body string) error {
+	log.Println("[邮件] 发送至", to, "主题:", subject, "\n"+body)
+	return nil
+}
+
+// SMTPMailer 通过SMTP服务器发送邮件,服务器支持时自动使用STARTTLS
+type SMTPMailer struct {
+	Addr     string // 服务器地址 host:port
+	Username string // 为空时不认证
+	Password string
+	From     string // 发件人
+}
+
+func (m *SMTPMailer) Send(to string, subject string, body string) error {
+	host, _, err := net.SplitHostPort(m.Addr)
+	if err != nil {
+		return err
+	}
+	var auth smtp.Auth
+	if m.Username != "" {
+		auth = smtp.PlainAuth("", m.Username, m.Password, host)
+	}
+
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", m.From)
+	fmt.Fprintf(&msg, "To: %s\r\n", to)
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.BEncoding.Encode("UTF-8", subject))
+	fmt.Fprintf(&msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	msg.WriteString("Content-Transfer-Encoding: 8bit\r\n\r\n")
+	msg.WriteString(body)
+
+	return smtp.SendMail(m.Addr, auth, m.From, []string{to}, msg.Bytes())
+}
@@ -0,0 +1,147 @@
+package service
+
+import (
+	"bufio"
+	"encoding/base64"
+	"net"
+	"strings"
+	"testing"
+)
+
+// smtpSession 测试SMTP服务器收到的一封邮件
+type smtpSession struct {
+	Auth string
+	From string
+	To   []string
+	Data string
+}
+
+// startSMTPServer 启动只支持明文会话的本地SMTP服务器,每个连接处理完后把会话发送到返回的通道
+func startSMTPServer(t *testing.T, ehlo []string) (string, <-chan smtpSession) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	sessions := make(chan smtpSession, 1)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go serveSMTP(conn, ehlo, sessions)
+		}
+	}()
+	return ln.Addr().String(), sessions
+}
+
+func serveSMTP(conn net.Conn, ehlo []string, sessions chan<- smtpSession) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	reply := func(line string) { conn.Write([]byte(line + "\r\n")) }
+
+	var session smtpSession
+	reply("220 localhost ESMTP test")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		cmd := strings.ToUpper(strings.SplitN(line, " ", 2)[0])
+		switch cmd {
+		case "EHLO":
+			reply("250-localhost")
+			for _, ext := range ehlo {
+				reply("250-" + ext)
+			}
+			reply("250 8BITMIME")
+		case "AUTH":
+			session.Auth = line
+			reply("235 2.7.0 Authentication successful")
+		case "MAIL":
+			session.From = line
+			reply("250 OK")
+		case "RCPT":
+			session.To = append(session.To, line)
+			reply("250 OK")
+		case "DATA":
+			reply("354 End data with <CR><LF>.<CR><LF>")
+			var data strings.Builder
+			for {
+				line, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if line == ".\r\n" {
+					break
+				}
+				data.WriteString(line)
+			}
+			session.Data = data.String()
+			reply("250 OK")
+		case "QUIT":
+			reply("221 Bye")
+			sessions <- session
+			return
+		default:
+			reply("502 Command not implemented")
+		}
+	}
+}
+
+func TestSMTPMailerSend(t *testing.T) {
+	addr, sessions := startSMTPServer(t, nil)
+	mailer := &SMTPMailer{Addr: addr, From: "noreply@example.com"}
+
+	if err := mailer.Send("user@example.com", "重置密码", "点击链接重置密码"); err != nil {
+		t.Fatal(err)
+	}
+	session := <-sessions
+	if session.Auth != "" {
+		t.Errorf("未配置用户名时不应认证, got %q", session.Auth)
+	}
+	if session.From != "MAIL FROM:<noreply@example.com> BODY=8BITMIME" && session.From != "MAIL FROM:<noreply@example.com>" {
+		t.Errorf("发件人错误: %q", session.From)
+	}
+	if len(session.To) != 1 || session.To[0] != "RCPT TO:<user@example.com>" {
+		t.Errorf("收件人错误: %q", session.To)
+	}
+	for _, header := range []string{
+		"From: noreply@example.com\r\n",
+		"To: user@example.com\r\n",
+		"Subject: =?UTF-8?b?6YeN572u5a+G56CB?=\r\n",
+		"Content-Type: text/plain; charset=UTF-8\r\n",
+	} {
+		if !strings.Contains(session.Data, header) {
+			t.Errorf("邮件缺少 %q:\n%s", header, session.Data)
+		}
+	}
+	if !strings.HasSuffix(session.Data, "\r\n\r\n点击链接重置密码\r\n") {
+		t.Errorf("邮件正文错误:\n%s", session.Data)
+	}
+}
+
+func TestSMTPMailerAuth(t *testing.T) {
+	addr, sessions := startSMTPServer(t, []string{"AUTH PLAIN"})
+	mailer := &SMTPMailer{Addr: addr, Username: "smtp-user", Password: "smtp-pass", From: "noreply@example.com"}
+
+	if err := mailer.Send("user@example.com", "subject", "body"); err != nil {
+		t.Fatal(err)
+	}
+	session := <-sessions
+	want := "AUTH PLAIN " + base64.StdEncoding.EncodeToString([]byte("\x00smtp-user\x00smtp-pass"))
+	if session.Auth != want {
+		t.Errorf("认证信息错误: got %q, want %q", session.Auth, want)
+	}
+}
+
+func TestSMTPMailerInvalidAddr(t *testing.T) {
+	mailer := &SMTPMailer{Addr: "localhost", From: "noreply@example.com"}
+	if err := mailer.Send("user@example.com", "subject", "body"); err == nil {
+		t.Error("缺少端口的地址应返回错误")
+	}
+}
@@ -0,0 +1,297 @@
+package service
+
+import (
+	"PaintingExchange/internal/env"
+	"PaintingExchange/internal/model"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/golang-jwt/jwt/v5"
+	"gorm.io/gorm"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	totpPeriod        = 30 // 时间步长(秒)
+	totpDigits        = 6  // 验证码位数
+	recoveryCodeCount = 10 // 恢复码数量
+	mfaTokenTTL       = 5 * time.Minute
+)
+
+var (
+	ErrMFAEnabled      = errors.New("两步验证已启用")
+	ErrMFANotSetup     = errors.New("未绑定两步验证")
+	ErrMFACodeInvalid  = errors.New("验证码错误")
+	ErrMFATokenInvalid = errors.New("两步验证令牌无效或已过期")
+)
+
+var base32NoPad = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// IsMFAEnabled 验证账号是否启用了两步验证
+func IsMFAEnabled(db *gorm.DB, username string, isAdmin bool) bool {
+	var count int64
+	db.Model(&model.MFA{}).Where("username=? AND is_admin=? AND enabled=?", username, isAdmin, true).Count(&count)
+	return count != 0
+}
+
+// BeginMFASetup 生成新的TOTP密钥和恢复码,验证一次验证码后才会启用
+func BeginMFASetup(db *gorm.DB, username string, isAdmin bool) (model.MFASetup, error) {
+	var mfa model.MFA
+	db.Where("username=? AND is_admin=?", username, isAdmin).Find(&mfa)
+	if mfa.Enabled {
+		return model.MFASetup{}, ErrMFAEnabled
+	}
+
+	secret := make([]byte, 20)
+	if _, err := rand.Read(secret); err != nil {
+		return model.MFASetup{}, err
+	}
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		return model.MFASetup{}, err
+	}
+
+	mfa.Username = username
+	mfa.IsAdmin = isAdmin
+	mfa.Secret = base32NoPad.EncodeToString(secret)
+	mfa.LastStep = 0
+	mfa.RecoveryCodes = hashes
+	mfa.CreatedAt = time.Now()
+	if err := db.Save(&mfa).Error; err != nil {
+		return model.MFASetup{}, err
+	}
+
+	// otpauth://totp/发行者:用户名?secret=...&issuer=...
+	issuer := env.GetMFAIssuer()
+	query := url.Values{}
+	query.Set("secret", mfa.Secret)
+	query.Set("issuer", issuer)
+	query.Set("algorithm", "SHA1")
+	query.Set("digits", fmt.Sprint(totpDigits))
+	query.Set("period", fmt.Sprint(totpPeriod))
+	uri := url.URL{
+		Scheme:   "otpauth",
+		Host:     "totp",
+		Path:     "/" + issuer + ":" + username,
+		RawQuery: query.Encode(),
+	}
+
+	return model.MFASetup{
+		Secret:        mfa.Secret,
+		URI:           uri.String(),
+		RecoveryCodes: codes,
+	}, nil
+}
+
+// EnableMFA 验证绑定后的第一个验证码并启用两步验证
+func EnableMFA(db *gorm.DB, username string, isAdmin bool, code string) error {
+	var mfa model.MFA
+	if db.Where("username=? AND is_admin=?", username, isAdmin).Find(&mfa).RowsAffected == 0 {
+		return ErrMFANotSetup
+	}
+	if mfa.Enabled {
+		return ErrMFAEnabled
+	}
+	if !checkTOTP(&mfa, code) {
+		return ErrMFACodeInvalid
+	}
+
+	mfa.Enabled = true
+	return db.Save(&mfa).Error
+}
+
+// VerifyMFA 验证验证码或恢复码(恢复码使用后失效)
+func VerifyMFA(db *gorm.DB, username string, isAdmin bool, code string) error {
+	_, err := verifyMFA(db, username, isAdmin, code)
+	return err
+}
+
+// DisableMFA 验证后关闭两步验证
+func DisableMFA(db *gorm.DB, username string, isAdmin bool, code string) error {
+	if err := VerifyMFA(db, username, isAdmin, code); err != nil {
+		return err
+	}
+	return ResetMFA(db, username, isAdmin)
+}
+
+// ResetMFA 直接删除两步验证配置(管理员重置)
+func ResetMFA(db *gorm.DB, username string, isAdmin bool) error {
+	return db.Where("username=? AND is_admin=?", username, isAdmin).Delete(&model.MFA{}).Error
+}
+
+// RegenerateRecoveryCodes 验证后重新生成恢复码,旧恢复码全部失效
+func RegenerateRecoveryCodes(db *gorm.DB, username string, isAdmin bool, code string) ([]string, error) {
+	mfa, err := verifyMFA(db, username, isAdmin, code)
+	if err != nil {
+		return nil, err
+	}
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	mfa.RecoveryCodes = hashes
+	return codes, db.Save(&mfa).Error
+}
+
+// IssueMFAToken 签发只能用于完成两步验证的短期令牌
+func IssueMFAToken(username string, isAdmin bool, enrollRequired bool) (model.MFAChallenge, error) {
+	token, expiresAt, err := IssuePurposeToken("mfa", jwt.MapClaims{
+		"username": username,
+		"isAdmin":  isAdmin,
+	}, mfaTokenTTL)
+	if err != nil {
+		return model.MFAChallenge{}, err
+	}
+	return model.MFAChallenge{
+		MFAToken:       token,
+		ExpiresAt:      expiresAt,
+		EnrollRequired: enrollRequired,
+	}, nil
+}
+
+// ParseMFAToken 解析两步验证令牌,返回用户名和是否为管理员
+func ParseMFAToken(db *gorm.DB, tokenString string) (string, bool, error) {
+	claims, err := ParsePurposeToken(db, "mfa", tokenString)
+	if err != nil {
+		return "", false, ErrMFATokenInvalid
+	}
+	username, _ := claims["username"].(string)
+	isAdmin, _ := claims["isAdmin"].(bool)
+	if username == "" {
+		return "", false, ErrMFATokenInvalid
+	}
+	return username, isAdmin, nil
+}
+
+// RevokeMFAToken 两步验证完成后注销两步验证令牌,防止重复使用
+// 令牌已被其他请求使用时返回ErrMFATokenInvalid,此时不能签发令牌
+func RevokeMFAToken(db *gorm.DB, tokenString string) error {
+	claims, err := ParsePurposeToken(db, "mfa", tokenString)
+	if err != nil {
+		return ErrMFATokenInvalid
+	}
+	if err := RevokePurposeToken(db, claims); errors.Is(err, ErrPurposeTokenInvalid) {
+		return ErrMFATokenInvalid
+	} else if err != nil {
+		return err
+	}
+	return nil
+}
+
+// verifyMFA 验证验证码或恢复码,返回更新后的两步验证配置
+func verifyMFA(db *gorm.DB, username string, isAdmin bool, code string) (model.MFA, error) {
+	var mfa model.MFA
+	if db.Where("username=? AND is_admin=? AND enabled=?", username, isAdmin, true).Find(&mfa).RowsAffected == 0 {
+		return mfa, ErrMFANotSetup
+	}
+
+	// 写入以读取到的状态为条件,并发提交同一个验证码或恢复码时只有一个请求成功
+	if checkTOTP(&mfa, code) {
+		result := db.Model(&model.MFA{}).Where("id=? AND last_step<?", mfa.ID, mfa.LastStep).Update("last_step", mfa.LastStep)
+		if result.Error != nil {
+			return mfa, result.Error
+		}
+		if result.RowsAffected != 1 {
+			return mfa, ErrMFACodeInvalid
+		}
+		return mfa, nil
+	}
+
+	// 恢复码以json保存,条件为使用前的恢复码列表
+	prevCodes, err := json.Marshal(mfa.RecoveryCodes)
+	if err != nil {
+		return mfa, err
+	}
+	if !useRecoveryCode(&mfa, code) {
+		return mfa, ErrMFACodeInvalid
+	}
+	codes, err := json.Marshal(mfa.RecoveryCodes)
+	if err != nil {
+		return mfa, err
+	}
+	result := db.Model(&model.MFA{}).Where("id=? AND recovery_codes=?", mfa.ID, string(prevCodes)).Update("recovery_codes", string(codes))
+	if result.Error != nil {
+		return mfa, result.Error
+	}
+	if result.RowsAffected != 1 {
+		return mfa, ErrMFACodeInvalid
+	}
+	return mfa, nil
+}
+
+// checkTOTP 验证TOTP验证码,允许前后各一个时间步的误差,同一时间步只能使用一次
+func checkTOTP(mfa *model.MFA, code string) bool {
+	secret, err := base32NoPad.DecodeString(mfa.Secret)
+	if err != nil || len(code) != totpDigits {
+		return false
+	}
+
+	current := time.Now().Unix() / totpPeriod
+	for step := current - 1; step <= current+1; step++ {
+		if step <= mfa.LastStep {
+			continue
+		}
+		if subtle.ConstantTimeCompare([]byte(totpCode(secret, step)), []byte(code)) == 1 {
+			mfa.LastStep = step
+			return true
+		}
+	}
+	return false
+}
+
+// totpCode 计算指定时间步的验证码(RFC 6238, HMAC-SHA1)
+func totpCode(secret []byte, step int64) string {
+	msg := make([]byte, 8)
+	binary.BigEndian.PutUint64(msg, uint64(step))
+	mac := hmac.New(sha1.New, secret)
+	mac.Write(msg)
+	sum := mac.Sum(nil)
+
+	// 动态截断
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	return fmt.Sprintf("%06d", value%1000000)
+}
+
+// useRecoveryCode 使用恢复码
+func useRecoveryCode(mfa *model.MFA, code string) bool {
+	hash := hashToken(normalizeRecoveryCode(code))
+	for i, item := range mfa.RecoveryCodes {
+		if subtle.ConstantTimeCompare([]byte(item), []byte(hash)) == 1 {
+			remaining := make([]string, 0, len(mfa.RecoveryCodes)-1)
+			mfa.RecoveryCodes = append(append(remaining, mfa.RecoveryCodes[:i]...), mfa.RecoveryCodes[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// generateRecoveryCodes 生成恢复码及其哈希
+func generateRecoveryCodes() ([]string, []string, error) {
+	codes := make([]string, 0, recoveryCodeCount)
+	hashes := make([]string, 0, recoveryCodeCount)
+	for i := 0; i < recoveryCodeCount; i++ {
+		buf := make([]byte, 5)
+		if _, err := rand.Read(buf); err != nil {
+			return nil, nil, err
+		}
+		code := hex.EncodeToString(buf)
+		codes = append(codes, code[:5]+"-"+code[5:])
+		hashes = append(hashes, hashToken(code))
+	}
+	return codes, hashes, nil
+}
+
+// normalizeRecoveryCode 忽略恢复码中的分隔符和大小写
+func normalizeRecoveryCode(code string) string {
+	return strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), "-", ""))
+}
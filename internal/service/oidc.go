@@ -0,0 +1,661 @@
+package service
+
+import (
+	"PaintingExchange/internal/env"
+	"PaintingExchange/internal/model"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/golang-jwt/jwt/v5"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+	"io"
+	"log"
+	"math/big"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	oidcFlowTTL          = 10 * time.Minute // 从跳转到身份提供方到回调的最长时间
+	oidcLinkTTL          = 10 * time.Minute // 关联令牌有效期
+	oidcDiscoveryTTL     = time.Hour        // 提供方元数据缓存时间
+	oidcJWKSRefetchDelay = time.Minute      // 遇到未知kid时重新获取公钥的最小间隔
+)
+
+var (
+	ErrOIDCProviderNotFound = errors.New("身份提供方不存在")
+	ErrOIDCFlowInvalid      = errors.New("登录流程无效或已过期,请重新登录")
+	ErrOIDCTokenInvalid     = errors.New("身份提供方返回的令牌无效")
+	ErrOIDCRedirectURL      = errors.New("未配置第三方登录的回调地址,请设置siteURL或身份提供方的redirectURL")
+	ErrIdentityLinked       = errors.New("该外部身份已关联其他用户")
+	ErrIdentityLinkUser     = errors.New("关联令牌不属于当前用户")
+	ErrIdentityNotFound     = errors.New("关联的外部身份不存在")
+	ErrIdentityLastLogin    = errors.New("这是唯一的登录方式,请先验证邮箱以便通过找回密码设置密码")
+)
+
+// oidcClient 访问身份提供方使用的http客户端
+var oidcClient = &http.Client{Timeout: 10 * time.Second}
+
+// oidcProvider OIDC身份提供方配置及缓存的元数据
+type oidcProvider struct {
+	Name         string   `json:"name"`         // 名称,出现在回调地址中
+	DisplayName  string   `json:"displayName"`  // 显示名称
+	Issuer       string   `json:"issuer"`       // 发行者地址,元数据从 {issuer}/.well-known/openid-configuration 获取
+	ClientID     string   `json:"clientId"`     // 客户端id
+	ClientSecret string   `json:"clientSecret"` // 客户端密钥,公开客户端可为空
+	Scopes       []string `json:"scopes"`       // 请求的scope,默认 openid email profile
+	RedirectURL  string   `json:"redirectURL"`  // 回调地址,为空时使用 {siteURL}/oidc/{name}/callback
+
+	mu            sync.Mutex
+	authEndpoint  string
+	tokenEndpoint string
+	jwksURI       string
+	discoveredAt  time.Time
+	keys          map[string]interface{}
+	keysFetchedAt time.Time
+}
+
+// OIDCIdentity 身份提供方验证通过的用户信息
+type OIDCIdentity struct {
+	Provider          string
+	Subject           string
+	Email             string
+	EmailVerified     bool
+	PreferredUsername string
+	Name              string
+}
+
+var (
+	oidcMu        sync.RWMutex
+	oidcProviders map[string]*oidcProvider
+)
+
+// LoadOIDCProviders 加载OIDC身份提供方配置
+// 配置文件(oidcConfig)为JSON数组,每一项为一个提供方,文件不存在时不启用第三方登录
+func LoadOIDCProviders() error {
+	data, err := os.ReadFile(env.GetOIDCConfig())
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("读取OIDC配置失败: %w", err)
+	}
+
+	var list []*oidcProvider
+	if err := json.Unmarshal(data, &list); err != nil {
+		return fmt.Errorf("解析OIDC配置失败: %w", err)
+	}
+	loaded := map[string]*oidcProvider{}
+	for _, p := range list {
+		if p.Name == "" || p.Issuer == "" || p.ClientID == "" {
+			return errors.New("OIDC身份提供方缺少name,issuer或clientId")
+		}
+		if _, exist := loaded[p.Name]; exist {
+			return fmt.Errorf("OIDC身份提供方%s重复", p.Name)
+		}
+		if p.DisplayName == "" {
+			p.DisplayName = p.Name
+		}
+		if len(p.Scopes) == 0 {
+			p.Scopes = []string{"openid", "email", "profile"}
+		}
+		p.Issuer = strings.TrimSuffix(p.Issuer, "/")
+		loaded[p.Name] = p
+	}
+
+	oidcMu.Lock()
+	oidcProviders = loaded
+	oidcMu.Unlock()
+	log.Println("已加载", len(loaded), "个OIDC身份提供方")
+	return nil
+}
+
+// GetOIDCProviders 获取可用的身份提供方
+func GetOIDCProviders() []model.OIDCProvider {
+	oidcMu.RLock()
+	defer oidcMu.RUnlock()
+	res := []model.OIDCProvider{}
+	for _, p := range oidcProviders {
+		res = append(res, model.OIDCProvider{Name: p.Name, DisplayName: p.DisplayName})
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].Name < res[j].Name
+	})
+	return res
+}
+
+// BeginOIDCLogin 开始授权码流程,返回身份提供方的授权地址和保存流程状态的令牌
+// 流程令牌应放在HttpOnly的cookie中,回调时用于校验state,nonce和PKCE
+// 关联流程必须由已登录的用户发起,username会写入流程令牌,最终只能关联到该用户
+func BeginOIDCLogin(name string, mode string, username string) (string, string, error) {
+	p := getOIDCProvider(name)
+	if p == nil {
+		return "", "", ErrOIDCProviderNotFound
+	}
+	if mode == "link" && username == "" {
+		return "", "", ErrOIDCFlowInvalid
+	}
+	redirectURL, err := p.redirectURL()
+	if err != nil {
+		return "", "", err
+	}
+	if err := p.discover(); err != nil {
+		return "", "", err
+	}
+
+	state, nonce, verifier := randomString(), randomString(), randomString()
+	flowToken, _, err := IssuePurposeToken("oidcFlow", jwt.MapClaims{
+		"provider": name,
+		"state":    state,
+		"nonce":    nonce,
+		"verifier": verifier,
+		"mode":     mode,
+		"username": username,
+		"redirect": redirectURL,
+	}, oidcFlowTTL)
+	if err != nil {
+		return "", "", err
+	}
+
+	challenge := sha256.Sum256([]byte(verifier))
+	query := url.Values{}
+	query.Set("response_type", "code")
+	query.Set("client_id", p.ClientID)
+	query.Set("redirect_uri", redirectURL)
+	query.Set("scope", strings.Join(p.Scopes, " "))
+	query.Set("state", state)
+	query.Set("nonce", nonce)
+	query.Set("code_challenge", base64.RawURLEncoding.EncodeToString(challenge[:]))
+	query.Set("code_challenge_method", "S256")
+
+	authURL := p.authEndpoint
+	if strings.Contains(authURL, "?") {
+		authURL += "&" + query.Encode()
+	} else {
+		authURL += "?" + query.Encode()
+	}
+	return authURL, flowToken, nil
+}
+
+// FinishOIDCLogin 校验回调的state,用授权码换取并验证ID令牌,返回外部身份,流程模式和发起关联的用户名
+func FinishOIDCLogin(db *gorm.DB, name string, flowToken string, state string, code string) (OIDCIdentity, string, string, error) {
+	p := getOIDCProvider(name)
+	if p == nil {
+		return OIDCIdentity{}, "", "", ErrOIDCProviderNotFound
+	}
+
+	flow, err := ParsePurposeToken(db, "oidcFlow", flowToken)
+	if err != nil {
+		return OIDCIdentity{}, "", "", ErrOIDCFlowInvalid
+	}
+	expectState, _ := flow["state"].(string)
+	if flow["provider"] != name || state == "" || subtle.ConstantTimeCompare([]byte(expectState), []byte(state)) != 1 {
+		return OIDCIdentity{}, "", "", ErrOIDCFlowInvalid
+	}
+	mode, _ := flow["mode"].(string)
+	username, _ := flow["username"].(string)
+	if mode == "link" && username == "" {
+		return OIDCIdentity{}, "", "", ErrOIDCFlowInvalid
+	}
+	if err := RevokePurposeToken(db, flow); err != nil {
+		return OIDCIdentity{}, "", "", ErrOIDCFlowInvalid
+	}
+	nonce, _ := flow["nonce"].(string)
+	verifier, _ := flow["verifier"].(string)
+	redirectURL, _ := flow["redirect"].(string)
+
+	if err := p.discover(); err != nil {
+		return OIDCIdentity{}, "", "", err
+	}
+	idToken, err := p.exchange(code, verifier, redirectURL)
+	if err != nil {
+		return OIDCIdentity{}, "", "", err
+	}
+	identity, err := p.verifyIDToken(idToken, nonce)
+	return identity, mode, username, err
+}
+
+// LoginWithOIDC 获取外部身份关联的用户,首次登录时创建新用户并关联
+func LoginWithOIDC(db *gorm.DB, identity OIDCIdentity) (model.User, error) {
+	var user model.User
+	var linked model.Identity
+	if db.Where("provider=? AND subject=?", identity.Provider, identity.Subject).Find(&linked).RowsAffected != 0 {
+		db.Where("username=?", linked.Username).Find(&user)
+		if user.Password != "" {
+			return user, nil
+		}
+		// 关联的用户已不存在
+		db.Delete(&linked)
+	}
+
+	// 创建用户,密码随机生成,需要时可通过找回密码设置
+	password, err := bcrypt.GenerateFromPassword([]byte(randomString()), bcrypt.DefaultCost)
+	if err != nil {
+		return user, err
+	}
+	user = model.User{
+		Username:  availableUsername(db, identity),
+		Password:  string(password),
+		AvatarURI: DefaultAvatar,
+	}
+	user.Nickname = identity.Name
+	if utf8.RuneCountInString(user.Nickname) > NicknameMaxLen || user.Nickname == "" {
+		user.Nickname = user.Username
+	}
+	// 身份提供方验证过且未被其他用户使用的邮箱直接视为已验证
+	if identity.EmailVerified && IsValidEmail(identity.Email) {
+		var count int64
+		db.Model(&model.User{}).Where("email=?", identity.Email).Count(&count)
+		if count == 0 {
+			user.Email = identity.Email
+			user.EmailVerified = true
+		}
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&user).Error; err != nil {
+			return err
+		}
+		return tx.Create(&model.Identity{
+			Provider:  identity.Provider,
+			Subject:   identity.Subject,
+			Username:  user.Username,
+			Email:     identity.Email,
+			CreatedAt: time.Now(),
+		}).Error
+	})
+	return user, err
+}
+
+// IssueIdentityLinkToken 签发关联外部身份用的令牌,只能由发起关联的用户提交后完成关联
+func IssueIdentityLinkToken(identity OIDCIdentity, username string) (string, error) {
+	token, _, err := IssuePurposeToken("oidcLink", jwt.MapClaims{
+		"username": username,
+		"provider": identity.Provider,
+		"subject":  identity.Subject,
+		"email":    identity.Email,
+	}, oidcLinkTTL)
+	return token, err
+}
+
+// LinkIdentity 将关联令牌中的外部身份关联到用户
+func LinkIdentity(db *gorm.DB, username string, linkToken string) (model.Identity, error) {
+	claims, err := ParsePurposeToken(db, "oidcLink", linkToken)
+	if err != nil {
+		return model.Identity{}, err
+	}
+	// 令牌只能关联到发起关联流程的用户
+	if owner, _ := claims["username"].(string); owner == "" || owner != username {
+		return model.Identity{}, ErrIdentityLinkUser
+	}
+	provider, _ := claims["provider"].(string)
+	subject, _ := claims["subject"].(string)
+	email, _ := claims["email"].(string)
+
+	var identity model.Identity
+	linked := db.Where("provider=? AND subject=?", provider, subject).Find(&identity).RowsAffected != 0
+	if linked && identity.Username != username {
+		return identity, ErrIdentityLinked
+	}
+	if err := RevokePurposeToken(db, claims); err != nil {
+		return model.Identity{}, err
+	}
+	if linked {
+		return identity, nil
+	}
+
+	identity = model.Identity{
+		Provider:  provider,
+		Subject:   subject,
+		Username:  username,
+		Email:     email,
+		CreatedAt: time.Now(),
+	}
+	return identity, db.Create(&identity).Error
+}
+
+// ListIdentities 获取用户关联的外部身份
+func ListIdentities(db *gorm.DB, username string) []model.Identity {
+	identities := []model.Identity{}
+	db.Where("username=?", username).Order("created_at").Find(&identities)
+	return identities
+}
+
+// UnlinkIdentity 解除外部身份关联
+// 邮箱未验证的用户无法通过找回密码设置密码,不能解除最后一个关联
+func UnlinkIdentity(db *gorm.DB, username string, id uint) error {
+	var identity model.Identity
+	if db.Where("id=? AND username=?", id, username).Find(&identity).RowsAffected == 0 {
+		return ErrIdentityNotFound
+	}
+
+	var count int64
+	db.Model(&model.Identity{}).Where("username=?", username).Count(&count)
+	var user model.User
+	db.Where("username=?", username).Find(&user)
+	if count <= 1 && !user.EmailVerified {
+		return ErrIdentityLastLogin
+	}
+	return db.Delete(&identity).Error
+}
+
+// getOIDCProvider 获取身份提供方
+func getOIDCProvider(name string) *oidcProvider {
+	oidcMu.RLock()
+	defer oidcMu.RUnlock()
+	return oidcProviders[name]
+}
+
+// redirectURL 回调地址,不根据请求的Host生成,以免被伪造的Host头改写
+func (p *oidcProvider) redirectURL() (string, error) {
+	if p.RedirectURL != "" {
+		return p.RedirectURL, nil
+	}
+	if !env.IsSiteURLSet() {
+		return "", ErrOIDCRedirectURL
+	}
+	return strings.TrimSuffix(env.GetSiteURL(), "/") + "/oidc/" + p.Name + "/callback", nil
+}
+
+// discover 获取并缓存身份提供方元数据
+func (p *oidcProvider) discover() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if time.Since(p.discoveredAt) < oidcDiscoveryTTL {
+		return nil
+	}
+
+	var doc struct {
+		Issuer                string `json:"issuer"`
+		AuthorizationEndpoint string `json:"authorization_endpoint"`
+		TokenEndpoint         string `json:"token_endpoint"`
+		JWKSURI               string `json:"jwks_uri"`
+	}
+	if err := getJSON(p.Issuer+"/.well-known/openid-configuration", &doc); err != nil {
+		return fmt.Errorf("获取身份提供方%s元数据失败: %w", p.Name, err)
+	}
+	if strings.TrimSuffix(doc.Issuer, "/") != p.Issuer || doc.AuthorizationEndpoint == "" || doc.TokenEndpoint == "" || doc.JWKSURI == "" {
+		return fmt.Errorf("身份提供方%s的元数据不完整或issuer不一致", p.Name)
+	}
+
+	p.authEndpoint = doc.AuthorizationEndpoint
+	p.tokenEndpoint = doc.TokenEndpoint
+	if p.jwksURI != doc.JWKSURI {
+		p.keys = nil
+		p.keysFetchedAt = time.Time{}
+	}
+	p.jwksURI = doc.JWKSURI
+	p.discoveredAt = time.Now()
+	return nil
+}
+
+// exchange 用授权码换取ID令牌
+func (p *oidcProvider) exchange(code string, verifier string, redirectURL string) (string, error) {
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", redirectURL)
+	form.Set("code_verifier", verifier)
+	form.Set("client_id", p.ClientID)
+
+	p.mu.Lock()
+	tokenEndpoint := p.tokenEndpoint
+	p.mu.Unlock()
+	req, err := http.NewRequest(http.MethodPost, tokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if p.ClientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(p.ClientID), url.QueryEscape(p.ClientSecret))
+	}
+
+	res, err := oidcClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("请求身份提供方%s失败: %w", p.Name, err)
+	}
+	defer res.Body.Close()
+
+	var body struct {
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := json.NewDecoder(io.LimitReader(res.Body, 1<<20)).Decode(&body); err != nil {
+		return "", fmt.Errorf("解析身份提供方%s的响应失败: %w", p.Name, err)
+	}
+	if res.StatusCode != http.StatusOK || body.IDToken == "" {
+		return "", fmt.Errorf("身份提供方%s拒绝授权码: %s %s", p.Name, body.Error, body.ErrorDescription)
+	}
+	return body.IDToken, nil
+}
+
+// verifyIDToken 验证ID令牌的签名,发行者,受众,有效期和nonce
+func (p *oidcProvider) verifyIDToken(idToken string, nonce string) (OIDCIdentity, error) {
+	token, err := jwt.Parse(idToken, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		key, err := p.publicKey(kid)
+		if err != nil {
+			return nil, err
+		}
+		// 算法必须与密钥类型一致
+		switch key.(type) {
+		case *rsa.PublicKey:
+			_, ok := token.Method.(*jwt.SigningMethodRSA)
+			_, okPSS := token.Method.(*jwt.SigningMethodRSAPSS)
+			if !ok && !okPSS {
+				return nil, fmt.Errorf("密钥%s不支持算法%s", kid, token.Method.Alg())
+			}
+		case *ecdsa.PublicKey:
+			if _, ok := token.Method.(*jwt.SigningMethodECDSA); !ok {
+				return nil, fmt.Errorf("密钥%s不支持算法%s", kid, token.Method.Alg())
+			}
+		case ed25519.PublicKey:
+			if _, ok := token.Method.(*jwt.SigningMethodEd25519); !ok {
+				return nil, fmt.Errorf("密钥%s不支持算法%s", kid, token.Method.Alg())
+			}
+		}
+		return key, nil
+	},
+		jwt.WithIssuer(p.Issuer),
+		jwt.WithAudience(p.ClientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+		jwt.WithLeeway(time.Minute),
+	)
+	if err != nil || !token.Valid {
+		log.Println("身份提供方", p.Name, "的ID令牌验证失败", err)
+		return OIDCIdentity{}, ErrOIDCTokenInvalid
+	}
+
+	claims := token.Claims.(jwt.MapClaims)
+	if got, _ := claims["nonce"].(string); subtle.ConstantTimeCompare([]byte(got), []byte(nonce)) != 1 {
+		log.Println("身份提供方", p.Name, "的ID令牌nonce不一致")
+		return OIDCIdentity{}, ErrOIDCTokenInvalid
+	}
+	subject, _ := claims["sub"].(string)
+	if subject == "" {
+		return OIDCIdentity{}, ErrOIDCTokenInvalid
+	}
+
+	identity := OIDCIdentity{Provider: p.Name, Subject: subject}
+	identity.Email, _ = claims["email"].(string)
+	identity.PreferredUsername, _ = claims["preferred_username"].(string)
+	identity.Name, _ = claims["name"].(string)
+	// 部分提供方以字符串形式返回email_verified
+	switch verified := claims["email_verified"].(type) {
+	case bool:
+		identity.EmailVerified = verified
+	case string:
+		identity.EmailVerified = verified == "true"
+	}
+	return identity, nil
+}
+
+// publicKey 获取验证ID令牌的公钥,遇到未知kid时重新获取公钥集合(密钥轮换)
+func (p *oidcProvider) publicKey(kid string) (interface{}, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if key, ok := p.keys[kid]; ok {
+		return key, nil
+	}
+	if time.Since(p.keysFetchedAt) < oidcJWKSRefetchDelay {
+		return nil, fmt.Errorf("未知的密钥id: %s", kid)
+	}
+
+	var jwks model.JWKS
+	if err := getJSON(p.jwksURI, &jwks); err != nil {
+		return nil, fmt.Errorf("获取身份提供方%s的公钥失败: %w", p.Name, err)
+	}
+	p.keysFetchedAt = time.Now()
+	p.keys = map[string]interface{}{}
+	for _, jwk := range jwks.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		if key, err := parseJWK(jwk); err == nil {
+			p.keys[jwk.Kid] = key
+		}
+	}
+
+	if key, ok := p.keys[kid]; ok {
+		return key, nil
+	}
+	// 只有一个密钥时允许令牌不带kid
+	if kid == "" && len(p.keys) == 1 {
+		for _, key := range p.keys {
+			return key, nil
+		}
+	}
+	return nil, fmt.Errorf("未知的密钥id: %s", kid)
+}
+
+// parseJWK 解析JWK公钥
+func parseJWK(jwk model.JWK) (interface{}, error) {
+	decode := base64.RawURLEncoding.DecodeString
+	switch jwk.Kty {
+	case "RSA":
+		n, err := decode(jwk.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decode(jwk.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch jwk.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("不支持的曲线%s", jwk.Crv)
+		}
+		x, err := decode(jwk.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decode(jwk.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}, nil
+	case "OKP":
+		x, err := decode(jwk.X)
+		if err != nil || jwk.Crv != "Ed25519" || len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("不支持的OKP公钥")
+		}
+		return ed25519.PublicKey(x), nil
+	}
+	return nil, fmt.Errorf("不支持的密钥类型%s", jwk.Kty)
+}
+
+// availableUsername 根据外部身份生成未被使用的合法用户名
+func availableUsername(db *gorm.DB, identity OIDCIdentity) string {
+	base := ""
+	for _, candidate := range []string{identity.PreferredUsername, strings.Split(identity.Email, "@")[0], identity.Name} {
+		if base = sanitizeUsername(candidate); base != "" {
+			break
+		}
+	}
+	if base == "" {
+		base = "user"
+	}
+
+	name := base
+	for i := 0; ; i++ {
+		var v Validator
+		v.username(name)
+		var count int64
+		db.Model(&model.User{}).Where("username=?", name).Count(&count)
+		if v.Valid() && count == 0 {
+			return name
+		}
+		// 追加随机后缀,保证长度不超过上限
+		suffix := "-" + randomString()[:6]
+		if i > 5 {
+			suffix = "-" + randomString()[:12]
+		}
+		if utf8.RuneCountInString(base)+len(suffix) > UsernameMaxLen {
+			base = string([]rune(base)[:UsernameMaxLen-len(suffix)])
+		}
+		name = base + suffix
+	}
+}
+
+// sanitizeUsername 去掉用户名中不允许的字符
+func sanitizeUsername(name string) string {
+	var b strings.Builder
+	for _, r := range name {
+		if isUsernameRune(r) {
+			b.WriteRune(r)
+		}
+	}
+	res := []rune(b.String())
+	if len(res) > UsernameMaxLen {
+		res = res[:UsernameMaxLen]
+	}
+	return string(res)
+}
+
+// getJSON 请求并解析JSON
+func getJSON(target string, v interface{}) error {
+	res, err := oidcClient.Get(target)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s 返回 %s", target, res.Status)
+	}
+	return json.NewDecoder(io.LimitReader(res.Body, 1<<20)).Decode(v)
+}
+
+// randomString 生成32字节的随机字符串
+func randomString() string {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		panic(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(buf)
+}
@@ -0,0 +1,336 @@
+package service
+
+import (
+	"PaintingExchange/internal/model"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"github.com/golang-jwt/jwt/v5"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+)
+
+// testIssuer 本地模拟的OIDC身份提供方
+type testIssuer struct {
+	*httptest.Server
+	key     *rsa.PrivateKey
+	idToken string           // 令牌端点返回的ID令牌
+	form    chan http.Header // 令牌端点收到的请求头,表单放在 X-Form-* 中
+}
+
+func newTestIssuer(t *testing.T) *testIssuer {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	issuer := &testIssuer{key: key, form: make(chan http.Header, 1)}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 issuer.URL,
+			"authorization_endpoint": issuer.URL + "/authorize",
+			"token_endpoint":         issuer.URL + "/token",
+			"jwks_uri":               issuer.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(model.JWKS{Keys: []model.JWK{
+			{Kty: "RSA", Kid: "enc", Use: "enc", N: "AQAB", E: "AQAB"},
+			rsaJWK("k1", &key.PublicKey),
+		}})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		header := r.Header.Clone()
+		for k := range r.PostForm {
+			header.Set("X-Form-"+k, r.PostForm.Get(k))
+		}
+		issuer.form <- header
+		if r.PostForm.Get("code") != "good-code" {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant", "error_description": "bad code"})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"id_token": issuer.idToken})
+	})
+	issuer.Server = httptest.NewServer(mux)
+	t.Cleanup(issuer.Close)
+	return issuer
+}
+
+// provider 指向模拟身份提供方的配置
+func (s *testIssuer) provider() *oidcProvider {
+	return &oidcProvider{Name: "test", Issuer: s.URL, ClientID: "client", ClientSecret: "secret"}
+}
+
+// sign 使用模拟身份提供方的密钥签发ID令牌
+func (s *testIssuer) sign(t *testing.T, kid string, claims jwt.MapClaims) string {
+	t.Helper()
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = kid
+	signed, err := token.SignedString(s.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+// claims 合法的ID令牌内容
+func (s *testIssuer) claims() jwt.MapClaims {
+	now := time.Now()
+	return jwt.MapClaims{
+		"iss":            s.URL,
+		"aud":            "client",
+		"sub":            "subject-1",
+		"iat":            now.Unix(),
+		"exp":            now.Add(time.Hour).Unix(),
+		"nonce":          "nonce-1",
+		"email":          "user@example.com",
+		"email_verified": "true",
+		"name":           "Test User",
+	}
+}
+
+func rsaJWK(kid string, key *rsa.PublicKey) model.JWK {
+	return model.JWK{
+		Kty: "RSA",
+		Kid: kid,
+		Use: "sig",
+		N:   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+		E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+	}
+}
+
+func TestOIDCDiscover(t *testing.T) {
+	issuer := newTestIssuer(t)
+	p := issuer.provider()
+	if err := p.discover(); err != nil {
+		t.Fatal(err)
+	}
+	if p.authEndpoint != issuer.URL+"/authorize" || p.tokenEndpoint != issuer.URL+"/token" || p.jwksURI != issuer.URL+"/jwks" {
+		t.Errorf("元数据错误: %+v", p)
+	}
+
+	// 元数据地址不存在或issuer与配置不一致时拒绝
+	p = &oidcProvider{Name: "test", Issuer: issuer.URL + "/other", ClientID: "client"}
+	if err := p.discover(); err == nil {
+		t.Error("元数据地址不存在时应返回错误")
+	}
+	mismatch := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 issuer.URL,
+			"authorization_endpoint": issuer.URL + "/authorize",
+			"token_endpoint":         issuer.URL + "/token",
+			"jwks_uri":               issuer.URL + "/jwks",
+		})
+	}))
+	defer mismatch.Close()
+	p = &oidcProvider{Name: "test", Issuer: mismatch.URL, ClientID: "client"}
+	if err := p.discover(); err == nil {
+		t.Error("issuer不一致时应返回错误")
+	}
+}
+
+func TestOIDCExchange(t *testing.T) {
+	issuer := newTestIssuer(t)
+	issuer.idToken = "id-token"
+	p := issuer.provider()
+	if err := p.discover(); err != nil {
+		t.Fatal(err)
+	}
+
+	idToken, err := p.exchange("good-code", "verifier-1", "https://paint.example.com/oidc/test/callback")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if idToken != "id-token" {
+		t.Errorf("got %q", idToken)
+	}
+	header := <-issuer.form
+	for k, want := range map[string]string{
+		"X-Form-Grant_type":    "authorization_code",
+		"X-Form-Code":          "good-code",
+		"X-Form-Code_verifier": "verifier-1",
+		"X-Form-Redirect_uri":  "https://paint.example.com/oidc/test/callback",
+		"X-Form-Client_id":     "client",
+	} {
+		if got := header.Get(k); got != want {
+			t.Errorf("%s: got %q, want %q", k, got, want)
+		}
+	}
+	if want := "Basic " + base64.StdEncoding.EncodeToString([]byte("client:secret")); header.Get("Authorization") != want {
+		t.Errorf("客户端认证错误: %q", header.Get("Authorization"))
+	}
+
+	if _, err := p.exchange("bad-code", "verifier-1", "https://paint.example.com/oidc/test/callback"); err == nil {
+		t.Error("授权码被拒绝时应返回错误")
+	}
+	<-issuer.form
+}
+
+func TestOIDCVerifyIDToken(t *testing.T) {
+	issuer := newTestIssuer(t)
+
+	tests := []struct {
+		name   string
+		kid    string
+		modify func(jwt.MapClaims)
+		nonce  string
+		ok     bool
+	}{
+		{"合法", "k1", nil, "nonce-1", true},
+		{"不带kid", "", nil, "nonce-1", true},
+		{"nonce不一致", "k1", nil, "nonce-2", false},
+		{"受众错误", "k1", func(c jwt.MapClaims) { c["aud"] = "other" }, "nonce-1", false},
+		{"发行者错误", "k1", func(c jwt.MapClaims) { c["iss"] = "https://evil.example.com" }, "nonce-1", false},
+		{"已过期", "k1", func(c jwt.MapClaims) { c["exp"] = time.Now().Add(-time.Hour).Unix() }, "nonce-1", false},
+		{"缺少过期时间", "k1", func(c jwt.MapClaims) { delete(c, "exp") }, "nonce-1", false},
+		{"缺少sub", "k1", func(c jwt.MapClaims) { delete(c, "sub") }, "nonce-1", false},
+		{"未知kid", "k2", nil, "nonce-1", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := issuer.provider()
+			if err := p.discover(); err != nil {
+				t.Fatal(err)
+			}
+			claims := issuer.claims()
+			if tt.modify != nil {
+				tt.modify(claims)
+			}
+			identity, err := p.verifyIDToken(issuer.sign(t, tt.kid, claims), tt.nonce)
+			if !tt.ok {
+				if err == nil {
+					t.Error("应验证失败")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := OIDCIdentity{Provider: "test", Subject: "subject-1", Email: "user@example.com", EmailVerified: true, Name: "Test User"}
+			if identity != want {
+				t.Errorf("got %+v, want %+v", identity, want)
+			}
+		})
+	}
+
+	// 其他密钥签名的令牌
+	p := issuer.provider()
+	if err := p.discover(); err != nil {
+		t.Fatal(err)
+	}
+	other, _ := rsa.GenerateKey(rand.Reader, 2048)
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, issuer.claims())
+	token.Header["kid"] = "k1"
+	signed, _ := token.SignedString(other)
+	if _, err := p.verifyIDToken(signed, "nonce-1"); err == nil {
+		t.Error("签名错误的令牌应验证失败")
+	}
+
+	// 密钥类型与算法不一致
+	token = jwt.NewWithClaims(jwt.SigningMethodHS256, issuer.claims())
+	token.Header["kid"] = "k1"
+	signed, _ = token.SignedString([]byte("secret"))
+	if _, err := p.verifyIDToken(signed, "nonce-1"); err == nil {
+		t.Error("算法与密钥类型不一致的令牌应验证失败")
+	}
+}
+
+func TestParseJWK(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	edPub, _, _ := ed25519.GenerateKey(rand.Reader)
+	b64 := base64.RawURLEncoding.EncodeToString
+
+	tests := []struct {
+		name string
+		jwk  model.JWK
+		ok   bool
+	}{
+		{"RSA", rsaJWK("rsa", &rsaKey.PublicKey), true},
+		{"RSA模数格式错误", model.JWK{Kty: "RSA", N: "!!", E: "AQAB"}, false},
+		{"RSA指数格式错误", model.JWK{Kty: "RSA", N: "AQAB", E: "!!"}, false},
+		{"EC P-256", model.JWK{Kty: "EC", Crv: "P-256", X: b64(ecKey.X.Bytes()), Y: b64(ecKey.Y.Bytes())}, true},
+		{"EC不支持的曲线", model.JWK{Kty: "EC", Crv: "P-192", X: b64(ecKey.X.Bytes()), Y: b64(ecKey.Y.Bytes())}, false},
+		{"EC坐标格式错误", model.JWK{Kty: "EC", Crv: "P-256", X: "!!", Y: b64(ecKey.Y.Bytes())}, false},
+		{"Ed25519", model.JWK{Kty: "OKP", Crv: "Ed25519", X: b64(edPub)}, true},
+		{"Ed25519长度错误", model.JWK{Kty: "OKP", Crv: "Ed25519", X: b64(edPub[:16])}, false},
+		{"OKP不支持的曲线", model.JWK{Kty: "OKP", Crv: "X25519", X: b64(edPub)}, false},
+		{"不支持的类型", model.JWK{Kty: "oct"}, false},
+		{"空", model.JWK{}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key, err := parseJWK(tt.jwk)
+			if tt.ok != (err == nil) {
+				t.Fatalf("err = %v", err)
+			}
+			if !tt.ok {
+				return
+			}
+			switch k := key.(type) {
+			case *rsa.PublicKey:
+				if !k.Equal(&rsaKey.PublicKey) {
+					t.Error("RSA公钥不一致")
+				}
+			case *ecdsa.PublicKey:
+				if !k.Equal(&ecKey.PublicKey) {
+					t.Error("EC公钥不一致")
+				}
+			case ed25519.PublicKey:
+				if !k.Equal(edPub) {
+					t.Error("Ed25519公钥不一致")
+				}
+			}
+		})
+	}
+}
+
+func TestOIDCRedirectURL(t *testing.T) {
+	p := &oidcProvider{Name: "test", RedirectURL: "https://paint.example.com/custom"}
+	if got, err := p.redirectURL(); err != nil || got != "https://paint.example.com/custom" {
+		t.Errorf("got %q, %v", got, err)
+	}
+
+	// 都未配置时不根据请求地址生成
+	p.RedirectURL = ""
+	t.Setenv("siteURL", "")
+	os.Unsetenv("siteURL")
+	if _, err := p.redirectURL(); !errors.Is(err, ErrOIDCRedirectURL) {
+		t.Errorf("err = %v", err)
+	}
+
+	t.Setenv("siteURL", "https://paint.example.com/")
+	if got, err := p.redirectURL(); err != nil || got != "https://paint.example.com/oidc/test/callback" {
+		t.Errorf("got %q, %v", got, err)
+	}
+}
+
+func TestBeginOIDCLoginLinkRequiresUser(t *testing.T) {
+	oidcMu.Lock()
+	saved := oidcProviders
+	oidcProviders = map[string]*oidcProvider{"test": {Name: "test", RedirectURL: "https://paint.example.com/oidc/test/callback"}}
+	oidcMu.Unlock()
+	t.Cleanup(func() {
+		oidcMu.Lock()
+		oidcProviders = saved
+		oidcMu.Unlock()
+	})
+
+	// 关联流程必须绑定发起的用户
+	if _, _, err := BeginOIDCLogin("test", "link", ""); !errors.Is(err, ErrOIDCFlowInvalid) {
+		t.Errorf("err = %v", err)
+	}
+}
@@ -0,0 +1,120 @@
+package service
+
+import (
+	"PaintingExchange/internal/model"
+	"github.com/kataras/iris/v12"
+	"gorm.io/gorm"
+	"log"
+)
+
+// 角色
+const (
+	RoleModerator  = "moderator"  // 版主,只能管理图片
+	RoleAdmin      = "admin"      // 管理员,可管理用户和图片
+	RoleSuperAdmin = "superAdmin" // 超级管理员,可管理工作人员
+)
+
+// 权限
+const (
+	PermUserRead     = "user:read"     // 查看所有用户
+	PermUserBan      = "user:ban"      // 封禁和解封用户
+	PermImageRead    = "image:read"    // 查看所有图片
+	PermImageBan     = "image:ban"     // 封禁和解封图片
+	PermStaffManage  = "staff:manage"  // 管理管理员账号和角色分配
+	PermLockoutRead  = "lockout:read"  // 查看登录锁定
+	PermLockoutClear = "lockout:clear" // 解除登录锁定
+	PermFileGC       = "file:gc"       // 清理无用文件
+)
+
+// rolePermissions 角色拥有的权限
+var rolePermissions = map[string][]string{
+	RoleModerator:  {PermImageRead, PermImageBan},
+	RoleAdmin:      {PermUserRead, PermUserBan, PermImageRead, PermImageBan, PermLockoutRead, PermLockoutClear, PermFileGC},
+	RoleSuperAdmin: {PermUserRead, PermUserBan, PermImageRead, PermImageBan, PermLockoutRead, PermLockoutClear, PermFileGC, PermStaffManage},
+}
+
+// IsValidRole 验证角色是否存在
+func IsValidRole(role string) bool {
+	_, ok := rolePermissions[role]
+	return ok
+}
+
+// GetPermissions 获取角色拥有的所有权限(去重)
+func GetPermissions(roles []string) []string {
+	var res []string
+	exist := map[string]bool{}
+	for _, role := range roles {
+		for _, perm := range rolePermissions[role] {
+			if !exist[perm] {
+				exist[perm] = true
+				res = append(res, perm)
+			}
+		}
+	}
+	return res
+}
+
+// HasPermission 验证角色是否拥有指定权限
+func HasPermission(roles []string, perm string) bool {
+	for _, item := range GetPermissions(roles) {
+		if item == perm {
+			return true
+		}
+	}
+	return false
+}
+
+// GetUserRoles 获取普通用户被分配的角色
+func GetUserRoles(db *gorm.DB, username string) []string {
+	var roles []string
+	db.Model(&model.RoleAssignment{}).Where("username=?", username).Pluck("role", &roles)
+	return roles
+}
+
+// CheckIsStaff 工作人员验证中间件,登录者至少拥有一个角色
+func CheckIsStaff(ctx iris.Context) {
+	loginUser, err := ctx.User().GetRaw()
+	if err != nil || len(loginUser.(iris.SimpleUser).Roles) == 0 {
+		log.Println("工作人员验证失败,用户没有任何角色")
+		ctx.StatusCode(iris.StatusUnauthorized)
+		ctx.Text(iris.StatusText(iris.StatusUnauthorized))
+		return
+	}
+
+	ctx.Next()
+}
+
+// RequirePermission 权限验证中间件
+func RequirePermission(perm string) iris.Handler {
+	return func(ctx iris.Context) {
+		loginUser, err := ctx.User().GetRaw()
+		if err != nil {
+			log.Println("权限验证失败,用户对象不存在")
+			ctx.StatusCode(iris.StatusUnauthorized)
+			ctx.Text(iris.StatusText(iris.StatusUnauthorized))
+			return
+		}
+
+		user := loginUser.(iris.SimpleUser)
+		if !HasPermission(user.Roles, perm) {
+			log.Println("权限验证失败,用户", user.Username, "缺少权限", perm)
+			ctx.StatusCode(iris.StatusForbidden)
+			ctx.Text(iris.StatusText(iris.StatusForbidden))
+			return
+		}
+
+		ctx.Next()
+	}
+}
+
+// MigrateAdminRoles 将旧的超级管理员标记迁移为角色
+func MigrateAdminRoles(db *gorm.DB) error {
+	if !db.Migrator().HasColumn(&model.Admin{}, "is_super") {
+		return nil
+	}
+	log.Println("迁移管理员超级管理员标记为角色")
+	if err := db.Model(&model.Admin{}).Where("is_super=?", true).Update("role", RoleSuperAdmin).Error; err != nil {
+		return err
+	}
+	return db.Migrator().DropColumn(&model.Admin{}, "is_super")
+}
@@ -0,0 +1,104 @@
+package service
+
+import (
+	"PaintingExchange/internal/env"
+	"PaintingExchange/internal/model"
+	"context"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"log"
+	"time"
+)
+
+// Publisher 定时发布,到达发布时间的图片改为设置的可见性(默认公开)
+type Publisher struct {
+	Mg       *mongo.Client
+	Algo     SearchServiceClient
+	Interval time.Duration // 检查间隔
+}
+
+// NewPublisher 根据环境变量配置创建定时发布
+func NewPublisher(mg *mongo.Client, algo SearchServiceClient) *Publisher {
+	return &Publisher{Mg: mg, Algo: algo, Interval: env.GetPublishInterval()}
+}
+
+// Start 补全旧数据的公开时间,并启动定期检查
+func (p *Publisher) Start() {
+	p.backfillPublishedAt()
+	go func() {
+		for {
+			p.Run()
+			time.Sleep(p.Interval)
+		}
+	}()
+}
+
+// Run 发布所有到达发布时间的图片,返回发布的数量
+func (p *Publisher) Run() int {
+	images := p.Mg.Database("PaintingExchange").Collection("Images")
+	cursor, err := images.Find(context.Background(), bson.M{"publishAt": bson.M{"$lte": time.Now()}})
+	if err != nil {
+		log.Println("待发布图片查询失败", err)
+		return 0
+	}
+	var scheduled []model.Image
+	if err := cursor.All(context.Background(), &scheduled); err != nil {
+		log.Println("待发布图片查询失败", err)
+		return 0
+	}
+
+	published := 0
+	for _, image := range scheduled {
+		// 旧数据没有记录发布后的可见性,视为公开
+		image.Visibility = image.PublishVisibility
+		if image.Visibility == "" {
+			image.Visibility = VisibilityPublic
+		}
+		now := time.Now()
+		image.PublishedAt = &now
+
+		// 以发布时间为条件,期间作者修改了发布时间时跳过
+		filter := bson.M{"_id": image.ID, "publishAt": image.PublishAt}
+		update := bson.M{
+			"$set":   bson.M{"visibility": image.Visibility, "publishedAt": image.PublishedAt},
+			"$unset": bson.M{"publishAt": "", "publishVisibility": ""},
+		}
+		res, err := images.UpdateOne(context.Background(), filter, update)
+		if err != nil {
+			log.Println("图片", image.ID, "定时发布失败", err)
+			continue
+		}
+		if res.ModifiedCount == 0 {
+			continue
+		}
+		image.PublishAt = nil
+		image.PublishVisibility = ""
+		if _, err := p.Algo.UpdateImage(context.Background(), SearchImage(image)); err != nil {
+			log.Println("图片", image.ID, "向量记录更新失败", err)
+		}
+		published++
+	}
+	if published != 0 {
+		log.Println("定时发布", published, "张图片")
+	}
+	return published
+}
+
+// backfillPublishedAt 没有公开时间的旧的公开图片以创建时间作为公开时间
+func (p *Publisher) backfillPublishedAt() {
+	images := p.Mg.Database("PaintingExchange").Collection("Images")
+	filter := bson.M{
+		"publishedAt": bson.M{"$exists": false},
+		"publishAt":   bson.M{"$exists": false},
+		"visibility":  bson.M{"$in": bson.A{nil, "", VisibilityPublic}},
+	}
+	update := mongo.Pipeline{{{"$set", bson.M{"publishedAt": "$createAt"}}}}
+	res, err := images.UpdateMany(context.Background(), filter, update)
+	if err != nil {
+		log.Println("旧图片公开时间补全失败", err)
+		return
+	}
+	if res.ModifiedCount != 0 {
+		log.Println("补全", res.ModifiedCount, "张旧图片的公开时间")
+	}
+}
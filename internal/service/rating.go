@@ -0,0 +1,73 @@
+package service
+
+import (
+	"PaintingExchange/internal/model"
+	"gorm.io/gorm"
+)
+
+// 图片内容分级
+const (
+	RatingSFW  = "sfw"  // 全年龄
+	RatingNSFW = "nsfw" // 成人内容
+)
+
+// Ratings 所有内容分级
+var Ratings = []string{RatingSFW, RatingNSFW}
+
+// 用户对成人内容的显示偏好
+const (
+	RatingFilterHide = "hide" // 不在最新,搜索和用户主页中显示,通过id查看时隐去图片(默认)
+	RatingFilterBlur = "blur" // 在列表中显示,但隐去图片
+	RatingFilterShow = "show" // 正常显示
+)
+
+// RatingFilters 所有显示偏好
+var RatingFilters = []string{RatingFilterHide, RatingFilterBlur, RatingFilterShow}
+
+// IsMature 图片是否为成人内容(旧数据没有分级,视为全年龄)
+func IsMature(image model.Image) bool {
+	return image.Rating == RatingNSFW
+}
+
+// UserRatingFilter 查询用户的显示偏好,未登录或未设置时为hide
+func UserRatingFilter(db *gorm.DB, username string) string {
+	if username == "" {
+		return RatingFilterHide
+	}
+	var user model.User
+	db.Select("rating_filter").Where("username=?", username).Find(&user)
+	if user.RatingFilter == "" {
+		return RatingFilterHide
+	}
+	return user.RatingFilter
+}
+
+// FilterRating 按用户的显示偏好处理成人内容,作者本人不受限制
+// listing为true时用于列表,返回false表示不显示;否则总是返回true,需要时隐去图片
+func FilterRating(image *model.Image, username string, filter string, listing bool) bool {
+	if !IsMature(*image) || image.Auth == username || filter == RatingFilterShow {
+		return true
+	}
+	if listing && filter != RatingFilterBlur {
+		return false
+	}
+	RedactImage(image)
+	return true
+}
+
+// RedactImage 隐去图片的文件地址,原图信息和简介,只保留标题,作者,标签等信息,
+// 各页只保留id,阶段图片和延时视频不返回
+func RedactImage(image *model.Image) {
+	image.Redacted = true
+	image.BigURI = ""
+	image.MidURI = ""
+	image.Intro = ""
+	image.Sizes = nil
+	image.Variants = nil
+	image.Metadata = nil
+	for i, page := range image.Pages {
+		image.Pages[i] = model.ImagePage{ID: page.ID, Status: page.Status}
+	}
+	image.Stages = nil
+	image.Timelapse = nil
+}
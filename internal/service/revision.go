@@ -0,0 +1,73 @@
+package service
+
+import (
+	"PaintingExchange/internal/model"
+	"errors"
+	"time"
+)
+
+// ImageMaxRevisions 每页最多保留的历史版本数,超出时删除最早的版本
+const ImageMaxRevisions = 10
+
+var (
+	ErrPageNotExist     = errors.New("页面不存在")
+	ErrRevisionNotExist = errors.New("历史版本不存在")
+)
+
+// PageHistories 获取作品各页的当前版本和历史版本
+func PageHistories(image model.Image) []model.PageHistory {
+	var histories []model.PageHistory
+	for _, page := range ImagePages(image) {
+		histories = append(histories, PageHistory(page))
+	}
+	return histories
+}
+
+// PageHistory 获取一页的当前版本和历史版本
+func PageHistory(page model.ImagePage) model.PageHistory {
+	history := model.PageHistory{Current: page, Replacing: page.Replacing, Revisions: page.Revisions}
+	if history.Revisions == nil {
+		history.Revisions = []model.ImageRevision{}
+	}
+	return history
+}
+
+// RollbackPage 将一页回滚到历史版本,当前版本保存为历史版本,返回超出数量限制被移除的版本
+func RollbackPage(image *model.Image, pageID string, revisionID string) ([]model.ImageRevision, error) {
+	image.Pages = ImagePages(*image)
+	i, ok := pageIndex(image.Pages, pageID)
+	if !ok {
+		return nil, ErrPageNotExist
+	}
+	page := &image.Pages[i]
+	for j, revision := range page.Revisions {
+		if revision.ID == revisionID {
+			page.Revisions = append(page.Revisions[:j:j], page.Revisions[j+1:]...)
+			next := revision.ImagePage
+			next.Replacing = page.Replacing
+			return replacePage(image, i, next), nil
+		}
+	}
+	return nil, ErrRevisionNotExist
+}
+
+// replacePage 用新版本替换一页,当前版本保存为历史版本,封面随之更新,返回超出数量限制被移除的版本
+func replacePage(image *model.Image, i int, next model.ImagePage) []model.ImageRevision {
+	current := image.Pages[i]
+	revisions := current.Revisions
+	current.Revisions = nil
+	current.Replacing = ""
+	revisions = append(revisions, model.ImageRevision{ImagePage: current, ReplacedAt: time.Now()})
+
+	var dropped []model.ImageRevision
+	if len(revisions) > ImageMaxRevisions {
+		dropped = revisions[:len(revisions)-ImageMaxRevisions]
+		revisions = revisions[len(revisions)-ImageMaxRevisions:]
+	}
+	next.Revisions = revisions
+	image.Pages[i] = next
+	if image.Cover == current.ID {
+		image.Cover = next.ID
+	}
+	return dropped
+}
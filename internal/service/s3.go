@@ -0,0 +1,359 @@
+package service
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// S3PresignExpires 预签名访问地址的有效期
+const S3PresignExpires = time.Hour
+
+// s3UnsignedPayload 不对请求体签名,上传时无需先读取整个文件计算摘要
+const s3UnsignedPayload = "UNSIGNED-PAYLOAD"
+
+// S3Storage S3兼容对象存储(AWS S3,MinIO等),使用签名V4认证
+type S3Storage struct {
+	Endpoint  string // 服务地址,如 http://minio:9000
+	Region    string
+	Bucket    string
+	AccessKey string
+	SecretKey string
+	PathStyle bool   // 使用 endpoint/bucket/key 形式的地址
+	PublicURL string // 公开访问地址,为空时使用预签名地址
+
+	Client *http.Client // 为空时使用 http.DefaultClient
+	now    func() time.Time
+}
+
+// s3ListResult ListObjectsV2的返回结果
+type s3ListResult struct {
+	Contents []struct {
+		Key          string
+		LastModified time.Time
+		Size         int64
+	}
+	IsTruncated           bool
+	NextContinuationToken string
+}
+
+func (s *S3Storage) Put(key string, r io.Reader, size int64, contentType string) error {
+	if contentType == "" {
+		contentType = contentTypeOf(key)
+	}
+	// S3不支持分块传输,长度未知时先读入内存
+	if size < 0 {
+		data, err := io.ReadAll(r)
+		if err != nil {
+			return err
+		}
+		r, size = bytes.NewReader(data), int64(len(data))
+	}
+	req, err := s.newRequest(http.MethodPut, key, r)
+	if err != nil {
+		return err
+	}
+	req.ContentLength = size
+	req.Header.Set("Content-Type", contentType)
+	resp, err := s.do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+func (s *S3Storage) Get(key string) (io.ReadCloser, error) {
+	req, err := s.newRequest(http.MethodGet, key, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.do(req)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+func (s *S3Storage) Delete(key string) error {
+	req, err := s.newRequest(http.MethodDelete, key, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := s.do(req)
+	if err == ErrStorageNotExist {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+func (s *S3Storage) Stat(key string) (FileInfo, error) {
+	req, err := s.newRequest(http.MethodHead, key, nil)
+	if err != nil {
+		return FileInfo{}, err
+	}
+	resp, err := s.do(req)
+	if err != nil {
+		return FileInfo{}, err
+	}
+	resp.Body.Close()
+	modTime, _ := http.ParseTime(resp.Header.Get("Last-Modified"))
+	return FileInfo{Key: key, Size: resp.ContentLength, ModTime: modTime, ContentType: resp.Header.Get("Content-Type")}, nil
+}
+
+func (s *S3Storage) List(prefix string) ([]FileInfo, error) {
+	prefix, err := cleanKey(prefix)
+	if err != nil {
+		return nil, err
+	}
+	var files []FileInfo
+	token := ""
+	for {
+		u, err := s.bucketURL()
+		if err != nil {
+			return nil, err
+		}
+		query := url.Values{}
+		query.Set("list-type", "2")
+		query.Set("prefix", prefix+"/")
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+		u.RawQuery = s3CanonicalQuery(query)
+		resp, err := s.do(s.sign(http.MethodGet, u, nil))
+		if err != nil {
+			return nil, err
+		}
+		var result s3ListResult
+		err = xml.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		for _, item := range result.Contents {
+			files = append(files, FileInfo{Key: item.Key, Size: item.Size, ModTime: item.LastModified, ContentType: contentTypeOf(item.Key)})
+		}
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			return files, nil
+		}
+		token = result.NextContinuationToken
+	}
+}
+
+// URL 配置了公开访问地址时直接拼接,否则返回预签名的临时访问地址
+func (s *S3Storage) URL(key string) string {
+	key, err := cleanKey(key)
+	if err != nil {
+		return ""
+	}
+	if s.PublicURL != "" {
+		return s.PublicURL + "/" + s3EscapePath(key)
+	}
+
+	u, err := s.objectURL(key)
+	if err != nil {
+		return ""
+	}
+	t := s.time()
+	query := u.Query()
+	query.Set("X-Amz-Algorithm", "AWS4-HMAC-SHA256")
+	query.Set("X-Amz-Credential", s.AccessKey+"/"+s.scope(t))
+	query.Set("X-Amz-Date", t.Format("20060102T150405Z"))
+	query.Set("X-Amz-Expires", strconv.Itoa(int(S3PresignExpires.Seconds())))
+	query.Set("X-Amz-SignedHeaders", "host")
+	u.RawQuery = s3CanonicalQuery(query)
+	header := http.Header{}
+	signature := s.signature(http.MethodGet, u, header, []string{"host"}, s3UnsignedPayload, t)
+	u.RawQuery += "&X-Amz-Signature=" + signature
+	return u.String()
+}
+
+// newRequest 创建对象请求并签名
+func (s *S3Storage) newRequest(method string, key string, body io.Reader) (*http.Request, error) {
+	key, err := cleanKey(key)
+	if err != nil {
+		return nil, err
+	}
+	u, err := s.objectURL(key)
+	if err != nil {
+		return nil, err
+	}
+	return s.sign(method, u, body), nil
+}
+
+// sign 创建请求并使用签名V4认证
+func (s *S3Storage) sign(method string, u *url.URL, body io.Reader) *http.Request {
+	req := &http.Request{
+		Method: method,
+		URL:    u,
+		Host:   u.Host,
+		Header: http.Header{},
+	}
+	if body != nil {
+		req.Body = io.NopCloser(body)
+	}
+
+	t := s.time()
+	req.Header.Set("X-Amz-Date", t.Format("20060102T150405Z"))
+	req.Header.Set("X-Amz-Content-Sha256", s3UnsignedPayload)
+	signed := []string{"host", "x-amz-content-sha256", "x-amz-date"}
+	signature := s.signature(method, req.URL, req.Header, signed, s3UnsignedPayload, t)
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.AccessKey, s.scope(t), strings.Join(signed, ";"), signature))
+	return req
+}
+
+// do 发送请求,对象不存在时返回 ErrStorageNotExist
+func (s *S3Storage) do(req *http.Request) (*http.Response, error) {
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		resp.Body.Close()
+		return nil, ErrStorageNotExist
+	}
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		resp.Body.Close()
+		return nil, fmt.Errorf("S3请求%s %s失败: %s %s", req.Method, req.URL.Path, resp.Status, msg)
+	}
+	return resp, nil
+}
+
+// bucketURL 获取存储桶地址
+func (s *S3Storage) bucketURL() (*url.URL, error) {
+	u, err := url.Parse(s.Endpoint)
+	if err != nil {
+		return nil, err
+	}
+	if s.PathStyle {
+		u.Path = "/" + s.Bucket + "/"
+	} else {
+		u.Host = s.Bucket + "." + u.Host
+		u.Path = "/"
+	}
+	return u, nil
+}
+
+// objectURL 获取对象地址
+func (s *S3Storage) objectURL(key string) (*url.URL, error) {
+	u, err := s.bucketURL()
+	if err != nil {
+		return nil, err
+	}
+	u.Path += key
+	u.RawPath = s3EscapePath(u.Path)
+	return u, nil
+}
+
+// signature 计算签名V4
+// https://docs.aws.amazon.com/AmazonS3/latest/API/sig-v4-header-based-auth.html
+func (s *S3Storage) signature(method string, u *url.URL, header http.Header, signed []string, payloadHash string, t time.Time) string {
+	var headers strings.Builder
+	for _, name := range signed {
+		value := header.Get(name)
+		if name == "host" {
+			value = u.Host
+		}
+		headers.WriteString(name + ":" + strings.TrimSpace(value) + "\n")
+	}
+	canonicalRequest := strings.Join([]string{
+		method,
+		s3EscapePath(u.Path),
+		s3CanonicalQuery(u.Query()),
+		headers.String(),
+		strings.Join(signed, ";"),
+		payloadHash,
+	}, "\n")
+
+	hash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		t.Format("20060102T150405Z"),
+		s.scope(t),
+		hex.EncodeToString(hash[:]),
+	}, "\n")
+
+	key := s3HMAC([]byte("AWS4"+s.SecretKey), t.Format("20060102"))
+	key = s3HMAC(key, s.Region)
+	key = s3HMAC(key, "s3")
+	key = s3HMAC(key, "aws4_request")
+	return hex.EncodeToString(s3HMAC(key, stringToSign))
+}
+
+// scope 签名范围
+func (s *S3Storage) scope(t time.Time) string {
+	return t.Format("20060102") + "/" + s.Region + "/s3/aws4_request"
+}
+
+func (s *S3Storage) time() time.Time {
+	if s.now != nil {
+		return s.now().UTC()
+	}
+	return time.Now().UTC()
+}
+
+func s3HMAC(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// s3Escape 按RFC3986编码,只保留非保留字符
+func s3Escape(s string) string {
+	var b strings.Builder
+	for _, c := range []byte(s) {
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' || c == '-' || c == '_' || c == '.' || c == '~' {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+// s3EscapePath 编码路径,保留分隔符/
+func s3EscapePath(path string) string {
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		segments[i] = s3Escape(segment)
+	}
+	return strings.Join(segments, "/")
+}
+
+// s3CanonicalQuery 按参数名排序并编码查询字符串
+func s3CanonicalQuery(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var pairs []string
+	for _, k := range keys {
+		values := append([]string(nil), query[k]...)
+		sort.Strings(values)
+		for _, v := range values {
+			pairs = append(pairs, s3Escape(k)+"="+s3Escape(v))
+		}
+	}
+	return strings.Join(pairs, "&")
+}
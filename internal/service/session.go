@@ -0,0 +1,140 @@
+package service
+
+import (
+	"PaintingExchange/internal/model"
+	"errors"
+	"gorm.io/gorm"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// sessionTouchInterval 会话最近访问时间的更新间隔,减少数据库写入
+const sessionTouchInterval = time.Minute
+
+var ErrSessionNotFound = errors.New("会话不存在")
+
+var (
+	sessionListenerMu sync.RWMutex
+	sessionListeners  []func(sid string)
+)
+
+// OnSessionRevoked 注册会话结束时的回调(如断开该会话的websocket连接)
+func OnSessionRevoked(listener func(sid string)) {
+	sessionListenerMu.Lock()
+	defer sessionListenerMu.Unlock()
+	sessionListeners = append(sessionListeners, listener)
+}
+
+// ListSessions 获取账号所有未过期的会话,最近访问的排在前面
+func ListSessions(db *gorm.DB, username string, isAdmin bool) []model.Session {
+	sessions := []model.Session{}
+	db.Where("username=? AND is_admin=? AND expires_at>?", username, isAdmin, time.Now()).
+		Order("last_seen_at DESC").Find(&sessions)
+	return sessions
+}
+
+// RevokeSession 注销账号的指定会话
+func RevokeSession(db *gorm.DB, username string, isAdmin bool, sid string) error {
+	var count int64
+	db.Model(&model.Session{}).Where("id=? AND username=? AND is_admin=?", sid, username, isAdmin).Count(&count)
+	if count == 0 {
+		return ErrSessionNotFound
+	}
+	revokeFamily(db, sid)
+	return nil
+}
+
+// TouchSession 更新会话的最近访问时间和IP
+func TouchSession(db *gorm.DB, sid string, ip string) {
+	now := time.Now()
+	db.Model(&model.Session{}).Where("id=? AND last_seen_at<?", sid, now.Add(-sessionTouchInterval)).
+		Updates(map[string]interface{}{"last_seen_at": now, "ip": ip})
+}
+
+// createSession 登录时创建会话,并顺带清理已过期的会话
+func createSession(db *gorm.DB, sid string, username string, isAdmin bool, userAgent string, ip string, expiresAt time.Time) error {
+	now := time.Now()
+	db.Where("expires_at<?", now).Delete(&model.Session{})
+	if len(userAgent) > 512 {
+		userAgent = userAgent[:512]
+	}
+	return db.Create(&model.Session{
+		ID:         sid,
+		Username:   username,
+		IsAdmin:    isAdmin,
+		Device:     describeDevice(userAgent),
+		UserAgent:  userAgent,
+		IP:         ip,
+		CreatedAt:  now,
+		LastSeenAt: now,
+		ExpiresAt:  expiresAt,
+	}).Error
+}
+
+// endSession 删除会话并通知回调
+func endSession(db *gorm.DB, sid string) {
+	db.Where("id=?", sid).Delete(&model.Session{})
+
+	sessionListenerMu.RLock()
+	listeners := sessionListeners
+	sessionListenerMu.RUnlock()
+	for _, listener := range listeners {
+		listener(sid)
+	}
+}
+
+var (
+	browserPatterns = []struct {
+		name    string
+		pattern *regexp.Regexp
+	}{
+		{"Edge", regexp.MustCompile(`Edg(e|A|iOS)?/`)},
+		{"Opera", regexp.MustCompile(`OPR/|Opera`)},
+		{"Firefox", regexp.MustCompile(`Firefox/|FxiOS/`)},
+		{"Chrome", regexp.MustCompile(`Chrome/|CriOS/`)},
+		{"Safari", regexp.MustCompile(`Safari/`)},
+		{"curl", regexp.MustCompile(`^curl/`)},
+		{"Python", regexp.MustCompile(`python-requests|Python-urllib|aiohttp`)},
+		{"Go", regexp.MustCompile(`Go-http-client`)},
+	}
+	osPatterns = []struct {
+		name    string
+		pattern *regexp.Regexp
+	}{
+		{"iOS", regexp.MustCompile(`iPhone|iPad|iPod`)},
+		{"Android", regexp.MustCompile(`Android`)},
+		{"Windows", regexp.MustCompile(`Windows`)},
+		{"macOS", regexp.MustCompile(`Mac OS X|Macintosh`)},
+		{"Linux", regexp.MustCompile(`Linux|X11`)},
+	}
+)
+
+// describeDevice 根据UA生成简短的设备描述,如 Chrome on Windows
+func describeDevice(userAgent string) string {
+	var browser, os string
+	for _, item := range browserPatterns {
+		if item.pattern.MatchString(userAgent) {
+			browser = item.name
+			break
+		}
+	}
+	for _, item := range osPatterns {
+		if item.pattern.MatchString(userAgent) {
+			os = item.name
+			break
+		}
+	}
+
+	switch {
+	case browser != "" && os != "":
+		return browser + " on " + os
+	case browser != "" || os != "":
+		return browser + os
+	case strings.TrimSpace(userAgent) == "":
+		return "未知设备"
+	default:
+		return "其他设备"
+	}
+}
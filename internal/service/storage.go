@@ -0,0 +1,214 @@
+package service
+
+import (
+	"PaintingExchange/internal/env"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"mime"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+var (
+	ErrStorageNotExist = errors.New("文件不存在")
+	ErrStorageKey      = errors.New("文件路径非法")
+)
+
+// Storage 文件存储
+// 文件以相对路径(如 assert/images/big_xxx.jpg)作为key,与数据库中保存的图片地址一致
+type Storage interface {
+	// Put 写入文件,size未知时传-1
+	Put(key string, r io.Reader, size int64, contentType string) error
+	// Get 读取文件,文件不存在时返回 ErrStorageNotExist
+	Get(key string) (io.ReadCloser, error)
+	// Delete 删除文件,文件不存在时不返回错误
+	Delete(key string) error
+	// Stat 获取文件信息,文件不存在时返回 ErrStorageNotExist
+	Stat(key string) (FileInfo, error)
+	// List 列出指定目录下(含子目录)的所有文件
+	List(prefix string) ([]FileInfo, error)
+	// URL 获取客户端访问文件的地址,返回空字符串表示由本服务直接提供
+	URL(key string) string
+}
+
+// FileInfo 文件信息
+type FileInfo struct {
+	Key         string
+	Size        int64
+	ModTime     time.Time
+	ContentType string
+}
+
+// NewStorage 根据环境变量创建文件存储
+func NewStorage() (Storage, error) {
+	switch kind := env.GetStorage(); kind {
+	case "local":
+		return &LocalStorage{Root: "."}, nil
+	case "s3":
+		config := env.GetS3Config()
+		if config.Endpoint == "" || config.Bucket == "" {
+			return nil, errors.New("S3存储缺少s3Endpoint或s3Bucket")
+		}
+		log.Println("使用S3存储", config.Endpoint, config.Bucket)
+		return &S3Storage{
+			Endpoint:  strings.TrimSuffix(config.Endpoint, "/"),
+			Region:    config.Region,
+			Bucket:    config.Bucket,
+			AccessKey: config.AccessKey,
+			SecretKey: config.SecretKey,
+			PathStyle: config.PathStyle,
+			PublicURL: strings.TrimSuffix(config.PublicURL, "/"),
+		}, nil
+	default:
+		return nil, fmt.Errorf("不支持的存储类型%s", kind)
+	}
+}
+
+// ReadFile 读取存储中的整个文件
+func ReadFile(storage Storage, key string) ([]byte, error) {
+	r, err := storage.Get(key)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return io.ReadAll(r)
+}
+
+// cleanKey 检查并规范化文件key,不允许跳出存储根目录
+func cleanKey(key string) (string, error) {
+	key = path.Clean(strings.ReplaceAll(key, "\\", "/"))
+	if key == "." || strings.HasPrefix(key, "/") || key == ".." || strings.HasPrefix(key, "../") {
+		return "", ErrStorageKey
+	}
+	return key, nil
+}
+
+// contentTypeOf 根据扩展名推断文件类型
+func contentTypeOf(key string) string {
+	if contentType := mime.TypeByExtension(path.Ext(key)); contentType != "" {
+		return contentType
+	}
+	return "application/octet-stream"
+}
+
+// LocalStorage 本地磁盘存储
+type LocalStorage struct {
+	Root string // 根目录
+}
+
+func (s *LocalStorage) Put(key string, r io.Reader, size int64, contentType string) error {
+	name, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(name), os.ModePerm); err != nil {
+		return err
+	}
+
+	// 先写入临时文件再重命名,避免被访问到写了一半的文件
+	tmp, err := os.CreateTemp(filepath.Dir(name), ".tmp_*")
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), name)
+}
+
+func (s *LocalStorage) Get(key string) (io.ReadCloser, error) {
+	name, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Open(name)
+	if os.IsNotExist(err) {
+		return nil, ErrStorageNotExist
+	}
+	return file, err
+}
+
+func (s *LocalStorage) Delete(key string) error {
+	name, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(name); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func (s *LocalStorage) Stat(key string) (FileInfo, error) {
+	name, err := s.path(key)
+	if err != nil {
+		return FileInfo{}, err
+	}
+	info, err := os.Stat(name)
+	if os.IsNotExist(err) {
+		return FileInfo{}, ErrStorageNotExist
+	} else if err != nil {
+		return FileInfo{}, err
+	}
+	if info.IsDir() {
+		return FileInfo{}, ErrStorageNotExist
+	}
+	return FileInfo{Key: key, Size: info.Size(), ModTime: info.ModTime(), ContentType: contentTypeOf(key)}, nil
+}
+
+func (s *LocalStorage) List(prefix string) ([]FileInfo, error) {
+	dir, err := s.path(prefix)
+	if err != nil {
+		return nil, err
+	}
+	var files []FileInfo
+	err = filepath.WalkDir(dir, func(name string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if entry.IsDir() {
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(s.Root, name)
+		if err != nil {
+			return err
+		}
+		key := filepath.ToSlash(rel)
+		files = append(files, FileInfo{Key: key, Size: info.Size(), ModTime: info.ModTime(), ContentType: contentTypeOf(key)})
+		return nil
+	})
+	return files, err
+}
+
+// URL 本地文件没有直接访问地址,由 controller.ServeAsset 读取后返回
+func (s *LocalStorage) URL(key string) string {
+	return ""
+}
+
+// path 获取key对应的本地路径
+func (s *LocalStorage) path(key string) (string, error) {
+	key, err := cleanKey(key)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(s.Root, filepath.FromSlash(key)), nil
+}
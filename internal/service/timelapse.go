@@ -0,0 +1,406 @@
+package service
+
+import (
+	"PaintingExchange/internal/env"
+	"PaintingExchange/internal/model"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"gocv.io/x/gocv"
+	"image"
+	"image/color"
+	"image/color/palette"
+	"image/draw"
+	"image/gif"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// TimelapseGIFMaxSide GIF动图的最长边,GIF体积较大,只用于预览
+const TimelapseGIFMaxSide = 480
+
+// timelapseCodecs 按优先级尝试的视频编码,H.264浏览器支持最好,但部分OpenCV构建不包含
+var timelapseCodecs = []string{"avc1", "mp4v"}
+
+var (
+	ErrTimelapseStages    = errors.New("没有可用的阶段图片")
+	ErrTimelapsePending   = errors.New("延时视频正在生成中,请稍后再试")
+	ErrTimelapseQueueFull = errors.New("延时视频生成队列已满,请稍后再试")
+)
+
+// TimelapseURI 获取作品延时视频的地址
+func TimelapseURI(imageID string, ext string) string {
+	return filepath.Join(env.GetImgDir(), "timelapse_"+imageID+ext)
+}
+
+// TimelapseOptions 延时视频参数
+type TimelapseOptions struct {
+	FPS     int           // 帧率
+	Hold    time.Duration // 每个阶段的停留时间
+	Fade    time.Duration // 阶段之间交叉淡化的时间
+	MaxSide int           // 视频最长边
+}
+
+// RenderTimelapse 依次读取各阶段图片,按最后一张的比例居中缩放到同一画布,
+// 生成阶段之间交叉淡化的MP4视频和GIF动图并写入存储
+func RenderTimelapse(storage Storage, sources []string, videoURI string, gifURI string, opts TimelapseOptions) error {
+	if len(sources) < 2 {
+		return ErrTimelapseStages
+	}
+
+	// 画布尺寸以最终图片为准,视频编码要求宽高为偶数
+	last, err := readStage(storage, sources[len(sources)-1])
+	if err != nil {
+		return err
+	}
+	width, height := fitSide(last.Cols(), last.Rows(), opts.MaxSide)
+	width, height = max(width&^1, 2), max(height&^1, 2)
+	gifWidth, gifHeight := fitSide(width, height, TimelapseGIFMaxSide)
+	lastFrame := letterbox(last, width, height)
+	last.Close()
+	defer func() {
+		lastFrame.Close()
+	}()
+
+	// 视频只能写入本地文件,写完后再放入存储
+	tmp, err := os.CreateTemp("", "timelapse-*.mp4")
+	if err != nil {
+		return err
+	}
+	tmp.Close()
+	defer os.Remove(tmp.Name())
+	writer, err := openVideoWriter(tmp.Name(), opts.FPS, width, height)
+	if err != nil {
+		return err
+	}
+
+	anim := &gif.GIF{}
+	holdFrames := max(int(opts.Hold.Seconds()*float64(opts.FPS)), 1)
+	fadeFrames := int(opts.Fade.Seconds() * float64(opts.FPS))
+	// write 写入一帧并重复n次,GIF中只写入一帧并延长显示时间
+	write := func(frame gocv.Mat, n int) error {
+		for i := 0; i < n; i++ {
+			if err := writer.Write(frame); err != nil {
+				return err
+			}
+		}
+		paletted, err := gifFrame(frame, gifWidth, gifHeight)
+		if err != nil {
+			return err
+		}
+		anim.Image = append(anim.Image, paletted)
+		anim.Delay = append(anim.Delay, max(n*100/opts.FPS, 2))
+		return nil
+	}
+
+	// 各阶段解码后立即缩放到画布并释放原图,只保留交叉淡化需要的前一帧
+	blend := gocv.NewMat()
+	defer blend.Close()
+	prev := gocv.NewMat()
+	defer func() {
+		prev.Close()
+	}()
+	for i, source := range sources {
+		var frame gocv.Mat
+		if i == len(sources)-1 {
+			frame, lastFrame = lastFrame, gocv.NewMat()
+		} else {
+			img, err := readStage(storage, source)
+			if err != nil {
+				writer.Close()
+				return err
+			}
+			frame = letterbox(img, width, height)
+			img.Close()
+		}
+
+		if i > 0 {
+			for f := 1; f <= fadeFrames; f++ {
+				alpha := float64(f) / float64(fadeFrames+1)
+				gocv.AddWeighted(prev, 1-alpha, frame, alpha, 0, &blend)
+				if err := write(blend, 1); err != nil {
+					frame.Close()
+					writer.Close()
+					return err
+				}
+			}
+		}
+		if err := write(frame, holdFrames); err != nil {
+			frame.Close()
+			writer.Close()
+			return err
+		}
+		prev.Close()
+		prev = frame
+	}
+	if err := writer.Close(); err != nil {
+		return err
+	}
+
+	// 写入存储
+	video, err := os.Open(tmp.Name())
+	if err != nil {
+		return err
+	}
+	defer video.Close()
+	info, err := video.Stat()
+	if err != nil {
+		return err
+	}
+	if err := storage.Put(videoURI, video, info.Size(), "video/mp4"); err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	if err := gif.EncodeAll(&buf, anim); err != nil {
+		return err
+	}
+	return storage.Put(gifURI, bytes.NewReader(buf.Bytes()), int64(buf.Len()), "image/gif")
+}
+
+// readStage 读取并解码一张阶段图片
+func readStage(storage Storage, uri string) (gocv.Mat, error) {
+	data, err := ReadFile(storage, uri)
+	if err != nil {
+		return gocv.NewMat(), fmt.Errorf("failed to read file: %w", err)
+	}
+	img, _, err := DecodeImage(data)
+	return img, err
+}
+
+// timelapseSource 选择最长边不小于视频最长边的最小尺寸图片,都不够大时使用大图,避免解码过大的图片
+func timelapseSource(bigURI string, sizes []model.ImageSize, maxSide int) string {
+	source, side := bigURI, 0
+	for _, size := range sizes {
+		if s := max(size.Width, size.Height); s >= maxSide && (side == 0 || s < side) {
+			source, side = size.URI, s
+		}
+	}
+	return source
+}
+
+// openVideoWriter 按优先级尝试可用的视频编码
+func openVideoWriter(name string, fps int, width int, height int) (*gocv.VideoWriter, error) {
+	var lastErr error
+	for _, codec := range timelapseCodecs {
+		writer, err := gocv.VideoWriterFile(name, codec, float64(fps), width, height, true)
+		if err == nil && writer.IsOpened() {
+			return writer, nil
+		}
+		if err == nil {
+			writer.Close()
+			err = fmt.Errorf("视频编码%s不可用", codec)
+		}
+		lastErr = err
+	}
+	return nil, lastErr
+}
+
+// fitSide 等比缩放到最长边不超过maxSide(不放大)
+func fitSide(width int, height int, maxSide int) (int, int) {
+	if width <= maxSide && height <= maxSide {
+		return width, height
+	}
+	if width >= height {
+		return maxSide, max(height*maxSide/width, 1)
+	}
+	return max(width*maxSide/height, 1), maxSide
+}
+
+// letterbox 等比缩放图片并居中放到白色画布上
+func letterbox(img gocv.Mat, width int, height int) gocv.Mat {
+	w, h := width, img.Rows()*width/img.Cols()
+	if h > height {
+		w, h = img.Cols()*height/img.Rows(), height
+	}
+	resized := ResizeImage(img, max(w, 1), max(h, 1))
+	defer resized.Close()
+	top, left := (height-resized.Rows())/2, (width-resized.Cols())/2
+	canvas := gocv.NewMat()
+	gocv.CopyMakeBorder(resized, &canvas, top, height-resized.Rows()-top, left, width-resized.Cols()-left,
+		gocv.BorderConstant, color.RGBA{R: 255, G: 255, B: 255, A: 255})
+	return canvas
+}
+
+// gifFrame 缩放并转换为GIF使用的调色板图片
+func gifFrame(frame gocv.Mat, width int, height int) (*image.Paletted, error) {
+	resized := ResizeImage(frame, width, height)
+	defer resized.Close()
+	img, err := resized.ToImage()
+	if err != nil {
+		return nil, err
+	}
+	paletted := image.NewPaletted(img.Bounds(), palette.Plan9)
+	draw.FloydSteinberg.Draw(paletted, img.Bounds(), img, image.Point{})
+	return paletted, nil
+}
+
+// TimelapseRenderer 延时视频生成队列,生成耗时较长,由一个后台协程依次处理
+// 生成状态记录在图片对象中,未完成且不在队列中的(包括重启前未完成的)会被定期重新加入
+type TimelapseRenderer struct {
+	Mg      *mongo.Client
+	Storage Storage
+	Options TimelapseOptions
+
+	jobs     chan string
+	queuedMu sync.Mutex
+	queued   map[string]bool // 在队列中或正在生成的作品,避免重复加入
+}
+
+// NewTimelapseRenderer 根据环境变量配置创建延时视频生成队列
+func NewTimelapseRenderer(mg *mongo.Client, storage Storage) *TimelapseRenderer {
+	fps, hold, fade, maxSide := env.GetTimelapse()
+	_, size, _, _ := env.GetImageQueue()
+	return &TimelapseRenderer{
+		Mg:      mg,
+		Storage: storage,
+		Options: TimelapseOptions{FPS: fps, Hold: hold, Fade: fade, MaxSide: maxSide},
+		jobs:    make(chan string, size),
+		queued:  map[string]bool{},
+	}
+}
+
+// Start 启动后台协程,并定期重新加入未完成的任务(包括上次启动时未完成的)
+func (r *TimelapseRenderer) Start() {
+	go func() {
+		for id := range r.jobs {
+			r.render(id)
+			r.release(id)
+		}
+	}()
+
+	go func() {
+		for {
+			r.rescan()
+			time.Sleep(imageRescanInterval)
+		}
+	}()
+}
+
+// Submit 提交延时视频生成任务,正在生成时返回 ErrTimelapsePending,队列已满时返回 ErrTimelapseQueueFull,
+// 两种情况都不修改作品中已有的延时视频
+func (r *TimelapseRenderer) Submit(imageID string) (model.Timelapse, error) {
+	r.queuedMu.Lock()
+	defer r.queuedMu.Unlock()
+	if r.queued[imageID] {
+		return model.Timelapse{}, ErrTimelapsePending
+	}
+	// 只有持有锁时才会加入队列,此时队列未满则之后的发送不会阻塞
+	if len(r.jobs) == cap(r.jobs) {
+		return model.Timelapse{}, ErrTimelapseQueueFull
+	}
+
+	// 先记录状态再加入队列,避免覆盖已生成完成的结果
+	timelapse := model.Timelapse{Status: ImageStatusPending, UpdatedAt: time.Now()}
+	r.update(imageID, timelapse)
+	r.jobs <- imageID
+	r.queued[imageID] = true
+	return timelapse, nil
+}
+
+// enqueue 将未在队列中的作品加入队列,队列已满时返回false
+func (r *TimelapseRenderer) enqueue(imageID string) bool {
+	r.queuedMu.Lock()
+	defer r.queuedMu.Unlock()
+	if r.queued[imageID] {
+		return true
+	}
+	select {
+	case r.jobs <- imageID:
+		r.queued[imageID] = true
+		return true
+	default:
+		return false
+	}
+}
+
+// release 生成结束,之后可以重新加入
+func (r *TimelapseRenderer) release(imageID string) {
+	r.queuedMu.Lock()
+	defer r.queuedMu.Unlock()
+	delete(r.queued, imageID)
+}
+
+// rescan 将未完成且不在队列中的延时视频重新加入队列,直到队列已满
+func (r *TimelapseRenderer) rescan() {
+	images := r.Mg.Database("PaintingExchange").Collection("Images")
+	projection := options.Find().SetProjection(bson.M{"_id": 1}).SetSort(bson.M{"timelapse.updatedAt": 1})
+	cursor, err := images.Find(context.Background(), bson.M{"timelapse.status": ImageStatusPending}, projection)
+	if err != nil {
+		log.Println("未完成的延时视频查询失败", err)
+		return
+	}
+	var pending []model.Image
+	if err := cursor.All(context.Background(), &pending); err != nil {
+		log.Println("未完成的延时视频查询失败", err)
+		return
+	}
+
+	added := 0
+	for _, image := range pending {
+		r.queuedMu.Lock()
+		queued := r.queued[image.ID]
+		r.queuedMu.Unlock()
+		if queued {
+			continue
+		}
+		if !r.enqueue(image.ID) {
+			break
+		}
+		added++
+	}
+	if added != 0 {
+		log.Println("重新加入", added, "个未完成的延时视频")
+	}
+}
+
+// render 生成作品的延时视频:各阶段图片之后接最终图片(封面)
+func (r *TimelapseRenderer) render(imageID string) {
+	images := r.Mg.Database("PaintingExchange").Collection("Images")
+	var image model.Image
+	if err := images.FindOne(context.Background(), bson.D{{"_id", imageID}}).Decode(&image); err != nil {
+		log.Println("图片", imageID, "查找失败,跳过延时视频生成", err)
+		return
+	}
+
+	// 只需要不小于视频尺寸的图片
+	var sources []string
+	for _, stage := range image.Stages {
+		if stage.Status == ImageStatusReady {
+			sources = append(sources, timelapseSource(stage.BigURI, stage.Sizes, r.Options.MaxSide))
+		}
+	}
+	if len(sources) != 0 && (image.Status == "" || image.Status == ImageStatusReady) {
+		sources = append(sources, timelapseSource(image.BigURI, image.Sizes, r.Options.MaxSide))
+	}
+
+	start := time.Now()
+	timelapse := model.Timelapse{
+		Status:   ImageStatusReady,
+		VideoURI: TimelapseURI(imageID, ".mp4"),
+		GifURI:   TimelapseURI(imageID, ".gif"),
+	}
+	if err := RenderTimelapse(r.Storage, sources, timelapse.VideoURI, timelapse.GifURI, r.Options); err != nil {
+		log.Println("图片", imageID, "延时视频生成失败", err)
+		timelapse = model.Timelapse{Status: ImageStatusFailed, Error: err.Error()}
+	} else {
+		log.Println("图片", imageID, "延时视频生成完成,耗时", time.Since(start))
+	}
+	timelapse.UpdatedAt = time.Now()
+	r.update(imageID, timelapse)
+}
+
+// update 更新图片对象中的延时视频信息
+func (r *TimelapseRenderer) update(imageID string, timelapse model.Timelapse) {
+	images := r.Mg.Database("PaintingExchange").Collection("Images")
+	update := bson.M{"$set": bson.M{"timelapse": timelapse}}
+	if _, err := images.UpdateOne(context.Background(), bson.D{{"_id", imageID}}, update); err != nil {
+		log.Println("图片", imageID, "延时视频状态更新失败", err)
+	}
+}
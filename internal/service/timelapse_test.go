@@ -0,0 +1,51 @@
+package service
+
+import (
+	"PaintingExchange/internal/model"
+	"testing"
+)
+
+func TestTimelapseSource(t *testing.T) {
+	sizes := []model.ImageSize{
+		{Width: 300, Height: 200, URI: "w300"},
+		{Width: 1200, Height: 800, URI: "w1200"},
+		{Width: 1600, Height: 2400, URI: "w1600"},
+		{Width: 3000, Height: 2000, URI: "w3000"},
+	}
+	tests := []struct {
+		name    string
+		sizes   []model.ImageSize
+		maxSide int
+		want    string
+	}{
+		{name: "最接近的尺寸", sizes: sizes, maxSide: 1080, want: "w1200"},
+		{name: "按最长边比较", sizes: sizes, maxSide: 2200, want: "w1600"},
+		{name: "刚好相等", sizes: sizes, maxSide: 1200, want: "w1200"},
+		{name: "都不够大时用大图", sizes: sizes, maxSide: 4000, want: "big"},
+		{name: "旧数据没有尺寸", sizes: nil, maxSide: 1080, want: "big"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := timelapseSource("big", tt.sizes, tt.maxSide); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFitSide(t *testing.T) {
+	tests := []struct {
+		width, height, maxSide int
+		wantW, wantH           int
+	}{
+		{1000, 500, 2000, 1000, 500},
+		{4000, 2000, 1000, 1000, 500},
+		{2000, 4000, 1000, 500, 1000},
+		{5000, 1, 1000, 1000, 1},
+	}
+	for _, tt := range tests {
+		if w, h := fitSide(tt.width, tt.height, tt.maxSide); w != tt.wantW || h != tt.wantH {
+			t.Errorf("fitSide(%d, %d, %d) = %d, %d", tt.width, tt.height, tt.maxSide, w, h)
+		}
+	}
+}
@@ -0,0 +1,238 @@
+package service
+
+import (
+	"PaintingExchange/internal/env"
+	"PaintingExchange/internal/model"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/kataras/iris/v12"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"log"
+	"time"
+)
+
+var (
+	ErrRefreshTokenInvalid = errors.New("刷新令牌无效")
+	ErrRefreshTokenExpired = errors.New("刷新令牌已过期")
+	ErrRefreshTokenReused  = errors.New("刷新令牌被重复使用")
+	ErrPurposeTokenInvalid = errors.New("令牌无效,已过期或已被使用")
+)
+
+// IssueToken 签发访问令牌和刷新令牌,开启新的令牌族并记录为一个登录会话
+func IssueToken(db *gorm.DB, username string, isAdmin bool, userAgent string, ip string) (model.Token, error) {
+	family := uuid.New().String()
+	token, err := issueToken(db, username, isAdmin, family)
+	if err != nil {
+		return token, err
+	}
+	if err := createSession(db, family, username, isAdmin, userAgent, ip, time.Now().Add(env.GetRefreshTokenTTL())); err != nil {
+		return model.Token{}, err
+	}
+	return token, nil
+}
+
+// RefreshToken 使用刷新令牌换取新的令牌,旧的刷新令牌及其访问令牌随即失效
+func RefreshToken(db *gorm.DB, refreshToken string) (model.Token, error) {
+	var prev model.RefreshToken
+	if db.Where("id=?", hashToken(refreshToken)).Find(&prev).RowsAffected == 0 {
+		return model.Token{}, ErrRefreshTokenInvalid
+	}
+
+	// 已失效的刷新令牌再次出现,说明令牌可能已泄露,注销整个令牌族
+	if prev.Revoked {
+		log.Println("用户", prev.Username, "的刷新令牌被重复使用,注销其令牌族", prev.Family)
+		revokeFamily(db, prev.Family)
+		return model.Token{}, ErrRefreshTokenReused
+	}
+	if time.Now().After(prev.ExpiresAt) {
+		return model.Token{}, ErrRefreshTokenExpired
+	}
+
+	// 轮换令牌,并发请求中只有一个能注销成功,其余视为重复使用
+	if !revokeRefreshToken(db, prev) {
+		log.Println("用户", prev.Username, "的刷新令牌被并发使用,注销其令牌族", prev.Family)
+		revokeFamily(db, prev.Family)
+		return model.Token{}, ErrRefreshTokenReused
+	}
+	return issueToken(db, prev.Username, prev.IsAdmin, prev.Family)
+}
+
+// RevokeToken 注销访问令牌及与其同时签发的令牌族(单设备登出)
+func RevokeToken(db *gorm.DB, jti string, expiresAt time.Time) {
+	var current model.RefreshToken
+	if db.Where("access_jti=?", jti).Find(&current).RowsAffected != 0 {
+		revokeFamily(db, current.Family)
+	}
+	revokeAccessToken(db, jti, expiresAt)
+}
+
+// RevokeCurrentToken 注销当前登录用户所用的令牌
+func RevokeCurrentToken(db *gorm.DB, user iris.SimpleUser) {
+	jti, _ := user.Fields["jti"].(string)
+	expiresAt, _ := user.Fields["expiresAt"].(time.Time)
+	RevokeToken(db, jti, expiresAt)
+}
+
+// RevokeAllTokens 注销用户的所有令牌并结束所有会话(所有设备登出)
+func RevokeAllTokens(db *gorm.DB, username string, isAdmin bool) {
+	var families []string
+	db.Model(&model.Session{}).Where("username=? AND is_admin=?", username, isAdmin).Pluck("id", &families)
+	for _, family := range families {
+		revokeFamily(db, family)
+	}
+
+	var tokens []model.RefreshToken
+	db.Where("username=? AND is_admin=? AND revoked=?", username, isAdmin, false).Find(&tokens)
+	revokeRefreshTokens(db, tokens)
+}
+
+// IsTokenRevoked 验证访问令牌是否已被注销
+func IsTokenRevoked(db *gorm.DB, jti string) bool {
+	var count int64
+	db.Model(&model.RevokedToken{}).Where("jti=?", jti).Count(&count)
+	return count != 0
+}
+
+// IssuePurposeToken 签发只能用于特定用途的短期令牌(两步验证,邮箱验证,重置密码等)
+// 头部类型为purpose+jwt,jwt中间件只接受访问令牌类型并拒绝带有purpose的令牌,因此它们不能作为访问令牌使用
+func IssuePurposeToken(purpose string, claims jwt.MapClaims, ttl time.Duration) (string, time.Time, error) {
+	now := time.Now()
+	expiresAt := now.Add(ttl)
+	claims["jti"] = uuid.New().String()
+	claims["iat"] = now.Unix()
+	claims["exp"] = expiresAt.Unix()
+	claims["purpose"] = purpose
+	token, err := SignToken(PurposeTokenType, claims)
+	return token, expiresAt, err
+}
+
+// ParsePurposeToken 解析特定用途的令牌,验证签名,用途以及是否已被使用
+func ParsePurposeToken(db *gorm.DB, purpose string, tokenString string) (jwt.MapClaims, error) {
+	token, err := ParseToken(PurposeTokenType, tokenString)
+	if err != nil || !token.Valid {
+		return nil, ErrPurposeTokenInvalid
+	}
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || claims["purpose"] != purpose {
+		return nil, ErrPurposeTokenInvalid
+	}
+	if jti, _ := claims["jti"].(string); jti == "" || IsTokenRevoked(db, jti) {
+		return nil, ErrPurposeTokenInvalid
+	}
+	return claims, nil
+}
+
+// RevokePurposeToken 注销已使用的特定用途令牌,保证其只能使用一次
+// 注销和检查在同一条插入语句中完成,并发使用同一令牌时只有一个请求成功,其余返回ErrPurposeTokenInvalid,
+// 调用方应在产生任何副作用之前调用
+func RevokePurposeToken(db *gorm.DB, claims jwt.MapClaims) error {
+	jti, _ := claims["jti"].(string)
+	expiresAt, err := claims.GetExpirationTime()
+	if jti == "" || err != nil || expiresAt == nil || !time.Now().Before(expiresAt.Time) {
+		return ErrPurposeTokenInvalid
+	}
+	db.Where("expires_at<?", time.Now()).Delete(&model.RevokedToken{})
+	result := db.Clauses(clause.OnConflict{DoNothing: true}).Create(&model.RevokedToken{JTI: jti, ExpiresAt: expiresAt.Time})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected != 1 {
+		return ErrPurposeTokenInvalid
+	}
+	return nil
+}
+
+// issueToken 在指定令牌族中签发令牌
+func issueToken(db *gorm.DB, username string, isAdmin bool, family string) (model.Token, error) {
+	now := time.Now()
+	jti := uuid.New().String()
+	expiresAt := now.Add(env.GetAccessTokenTTL())
+
+	// 签发jwt
+	accessToken, err := SignToken(AccessTokenType, jwt.MapClaims{
+		"jti":      jti,              // 令牌id
+		"iat":      now.Unix(),       // 签发时间
+		"exp":      expiresAt.Unix(), // 过期时间
+		"username": username,         // 用户名
+		"isAdmin":  isAdmin,          // 是否为管理员
+		"sid":      family,           // 会话id(令牌族)
+	})
+	if err != nil {
+		return model.Token{}, err
+	}
+
+	// 生成刷新令牌
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return model.Token{}, err
+	}
+	refreshToken := base64.RawURLEncoding.EncodeToString(buf)
+
+	// 服务端只保存刷新令牌的哈希
+	refreshExpiresAt := now.Add(env.GetRefreshTokenTTL())
+	if err := db.Create(&model.RefreshToken{
+		ID:              hashToken(refreshToken),
+		Family:          family,
+		Username:        username,
+		IsAdmin:         isAdmin,
+		AccessJTI:       jti,
+		AccessExpiresAt: expiresAt,
+		ExpiresAt:       refreshExpiresAt,
+		CreatedAt:       now,
+	}).Error; err != nil {
+		return model.Token{}, err
+	}
+
+	// 轮换时延长会话
+	db.Model(&model.Session{}).Where("id=?", family).
+		Updates(map[string]interface{}{"last_seen_at": now, "expires_at": refreshExpiresAt})
+
+	return model.Token{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		ExpiresAt:    expiresAt,
+	}, nil
+}
+
+// revokeFamily 注销整个令牌族并结束对应的会话
+func revokeFamily(db *gorm.DB, family string) {
+	var tokens []model.RefreshToken
+	db.Where("family=? AND revoked=?", family, false).Find(&tokens)
+	revokeRefreshTokens(db, tokens)
+	endSession(db, family)
+}
+
+// revokeRefreshTokens 使刷新令牌失效,并注销与其同时签发的访问令牌
+func revokeRefreshTokens(db *gorm.DB, tokens []model.RefreshToken) {
+	for _, token := range tokens {
+		revokeRefreshToken(db, token)
+	}
+}
+
+// revokeRefreshToken 使单个刷新令牌失效,只有未失效时才会更新,返回是否由本次调用注销
+func revokeRefreshToken(db *gorm.DB, token model.RefreshToken) bool {
+	revoked := db.Model(&model.RefreshToken{}).Where("id=? AND revoked=?", token.ID, false).Update("revoked", true).RowsAffected == 1
+	revokeAccessToken(db, token.AccessJTI, token.AccessExpiresAt)
+	return revoked
+}
+
+// revokeAccessToken 将访问令牌加入注销列表,并顺带清理已过期的记录
+func revokeAccessToken(db *gorm.DB, jti string, expiresAt time.Time) {
+	if !time.Now().Before(expiresAt) {
+		return
+	}
+	db.Where("expires_at<?", time.Now()).Delete(&model.RevokedToken{})
+	db.Where(model.RevokedToken{JTI: jti}).FirstOrCreate(&model.RevokedToken{JTI: jti, ExpiresAt: expiresAt})
+}
+
+// hashToken 计算令牌的sha256哈希
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
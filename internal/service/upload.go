@@ -0,0 +1,121 @@
+package service
+
+import (
+	"PaintingExchange/internal/env"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"mime/multipart"
+	"net/http"
+)
+
+var (
+	ErrUploadTooLarge = errors.New("文件过大")
+	ErrUploadType     = errors.New("只支持JPEG,PNG,WebP和GIF格式的图片")
+	ErrUploadPixels   = errors.New("图片尺寸过大")
+)
+
+// uploadFormats 允许上传的图片格式及对应的扩展名
+var uploadFormats = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/webp": ".webp",
+	"image/gif":  ".gif",
+}
+
+// Upload 经过检查的上传图片
+type Upload struct {
+	Data        []byte
+	ContentType string // 根据文件内容识别的类型
+	Ext         string // 根据文件内容确定的扩展名
+	Width       int
+	Height      int
+}
+
+// OutputExt 生成各尺寸图片使用的扩展名,GIF无法编码,使用PNG保存第一帧
+func (u Upload) OutputExt() string {
+	if u.ContentType == "image/gif" {
+		return ".png"
+	}
+	return u.Ext
+}
+
+// MaxUploadBody 上传请求体的大小上限,在文件大小上限之外留出表单其他部分的空间
+func MaxUploadBody() int64 {
+	maxSize, _, _ := env.GetUploadLimit()
+	return maxSize + 1<<20
+}
+
+// ReadUpload 读取并检查上传的图片
+// 文件类型根据内容识别,不信任客户端提供的文件名和类型;只解析图片头部获取尺寸,防止解压炸弹
+func ReadUpload(file multipart.File, info *multipart.FileHeader) (Upload, error) {
+	maxSize, maxSide, maxPixels := env.GetUploadLimit()
+	if info.Size > maxSize {
+		return Upload{}, ErrUploadTooLarge
+	}
+	data, err := io.ReadAll(io.LimitReader(file, maxSize+1))
+	if err != nil {
+		return Upload{}, err
+	}
+	if int64(len(data)) > maxSize {
+		return Upload{}, ErrUploadTooLarge
+	}
+
+	upload := Upload{Data: data, ContentType: http.DetectContentType(data)}
+	ext, ok := uploadFormats[upload.ContentType]
+	if !ok {
+		return Upload{}, ErrUploadType
+	}
+	upload.Ext = ext
+
+	if upload.ContentType == "image/webp" {
+		upload.Width, upload.Height, err = webpSize(data)
+	} else {
+		var config image.Config
+		config, _, err = image.DecodeConfig(bytes.NewReader(data))
+		upload.Width, upload.Height = config.Width, config.Height
+	}
+	if err != nil || upload.Width <= 0 || upload.Height <= 0 {
+		return Upload{}, ErrUploadType
+	}
+	if upload.Width > maxSide || upload.Height > maxSide || upload.Width*upload.Height > maxPixels {
+		return Upload{}, fmt.Errorf("%w: %dx%d", ErrUploadPixels, upload.Width, upload.Height)
+	}
+	return upload, nil
+}
+
+// webpSize 解析WebP文件头获取图片尺寸
+// https://developers.google.com/speed/webp/docs/riff_container
+func webpSize(data []byte) (int, int, error) {
+	if len(data) < 30 {
+		return 0, 0, ErrUploadType
+	}
+	chunk := data[12:30]
+	switch string(chunk[:4]) {
+	case "VP8 ":
+		// 有损格式,关键帧起始码之后是14位的宽和高
+		if chunk[11] != 0x9d || chunk[12] != 0x01 || chunk[13] != 0x2a {
+			return 0, 0, ErrUploadType
+		}
+		return int(binary.LittleEndian.Uint16(chunk[14:16]) & 0x3fff), int(binary.LittleEndian.Uint16(chunk[16:18]) & 0x3fff), nil
+	case "VP8L":
+		// 无损格式,签名之后是14位的宽减一和高减一
+		if chunk[8] != 0x2f {
+			return 0, 0, ErrUploadType
+		}
+		bits := binary.LittleEndian.Uint32(chunk[9:13])
+		return int(bits&0x3fff) + 1, int(bits>>14&0x3fff) + 1, nil
+	case "VP8X":
+		// 扩展格式,24位的画布宽减一和高减一
+		width := int(chunk[12]) | int(chunk[13])<<8 | int(chunk[14])<<16
+		height := int(chunk[15]) | int(chunk[16])<<8 | int(chunk[17])<<16
+		return width + 1, height + 1, nil
+	}
+	return 0, 0, ErrUploadType
+}
@@ -0,0 +1,89 @@
+package service
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+// makeWebP 构造只包含第一个块头部的WebP文件,payload为块内容的开头
+func makeWebP(chunk string, payload []byte) []byte {
+	data := []byte("RIFF\x00\x00\x00\x00WEBP" + chunk + "\x00\x00\x00\x00")
+	data = append(data, payload...)
+	binary.LittleEndian.PutUint32(data[4:], uint32(len(data)-8))
+	binary.LittleEndian.PutUint32(data[16:], uint32(len(payload)))
+	return data
+}
+
+// vp8Payload 有损格式:3字节帧标记,起始码,14位宽高(高2位为缩放)
+func vp8Payload(width, height uint16) []byte {
+	payload := []byte{0x50, 0x2a, 0x00, 0x9d, 0x01, 0x2a, 0, 0, 0, 0, 0, 0}
+	binary.LittleEndian.PutUint16(payload[6:], width)
+	binary.LittleEndian.PutUint16(payload[8:], height)
+	return payload
+}
+
+// vp8lPayload 无损格式:签名0x2f,14位宽减一和高减一
+func vp8lPayload(width, height uint32) []byte {
+	payload := []byte{0x2f, 0, 0, 0, 0, 0, 0, 0, 0, 0}
+	binary.LittleEndian.PutUint32(payload[1:], (width-1)|(height-1)<<14)
+	return payload
+}
+
+// vp8xPayload 扩展格式:4字节标志,24位画布宽减一和高减一
+func vp8xPayload(width, height int) []byte {
+	width, height = width-1, height-1
+	return []byte{0x10, 0, 0, 0, byte(width), byte(width >> 8), byte(width >> 16), byte(height), byte(height >> 8), byte(height >> 16)}
+}
+
+func TestWebpSize(t *testing.T) {
+	tests := []struct {
+		name          string
+		data          []byte
+		width, height int
+		wantErr       bool
+	}{
+		{name: "有损", data: makeWebP("VP8 ", vp8Payload(640, 480)), width: 640, height: 480},
+		{name: "有损,忽略缩放位", data: makeWebP("VP8 ", vp8Payload(0xc000|640, 0x4000|480)), width: 640, height: 480},
+		{name: "无损", data: makeWebP("VP8L", vp8lPayload(1920, 1080)), width: 1920, height: 1080},
+		{name: "无损最大尺寸", data: makeWebP("VP8L", vp8lPayload(16384, 16384)), width: 16384, height: 16384},
+		{name: "扩展", data: makeWebP("VP8X", vp8xPayload(3000, 2000)), width: 3000, height: 2000},
+		{name: "扩展超过16位", data: makeWebP("VP8X", vp8xPayload(70000, 1)), width: 70000, height: 1},
+		{name: "空文件", data: nil, wantErr: true},
+		{name: "只有RIFF头", data: []byte("RIFF\x00\x00\x00\x00WEBP"), wantErr: true},
+		{name: "有损文件头不完整", data: makeWebP("VP8 ", vp8Payload(640, 480))[:29], wantErr: true},
+		{name: "无损文件头不完整", data: makeWebP("VP8L", vp8lPayload(640, 480))[:25], wantErr: true},
+		{name: "扩展文件头不完整", data: makeWebP("VP8X", vp8xPayload(640, 480))[:29], wantErr: true},
+		{name: "有损起始码错误", data: makeWebP("VP8 ", append([]byte{0x50, 0x2a, 0x00, 0x9d, 0x01, 0x2b}, make([]byte, 6)...)), wantErr: true},
+		{name: "无损签名错误", data: makeWebP("VP8L", append([]byte{0x2e}, make([]byte, 9)...)), wantErr: true},
+		{name: "未知的块", data: makeWebP("ALPH", make([]byte, 10)), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			width, height, err := webpSize(tt.data)
+			if tt.wantErr {
+				if err != ErrUploadType {
+					t.Errorf("应返回 ErrUploadType, got %d x %d, %v", width, height, err)
+				}
+				return
+			}
+			if err != nil || width != tt.width || height != tt.height {
+				t.Errorf("got %d x %d, %v, want %d x %d", width, height, err, tt.width, tt.height)
+			}
+		})
+	}
+}
+
+// TestWebpSizeTruncated 任意截断的文件都不应导致panic
+func TestWebpSizeTruncated(t *testing.T) {
+	for _, data := range [][]byte{
+		makeWebP("VP8 ", vp8Payload(640, 480)),
+		makeWebP("VP8L", vp8lPayload(640, 480)),
+		makeWebP("VP8X", vp8xPayload(640, 480)),
+	} {
+		for i := range data {
+			if _, _, err := webpSize(data[:i]); err == nil && i < 30 {
+				t.Errorf("截断到%d字节时应返回错误", i)
+			}
+		}
+	}
+}
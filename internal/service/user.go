@@ -1,10 +1,15 @@
 package service
 
 import (
+	"PaintingExchange/internal/env"
 	"PaintingExchange/internal/model"
+	"crypto/subtle"
 	"errors"
+	"fmt"
 	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
+	"log"
+	"strings"
 )
 
 // CheckPass 验证密码(因为循环依赖原因,无法放于user对象中)
@@ -25,15 +30,75 @@ func CheckPass(user model.User, database gorm.DB) (bool, error) {
 	return true, nil
 }
 
-// CheckAdmin 验证管理员密码
-func CheckAdmin(admin model.Admin, database gorm.DB) bool {
+// CheckAdmin 验证管理员密码,旧的明文密码在验证通过后自动改为哈希存储
+func CheckAdmin(admin model.Admin, database gorm.DB) (bool, error) {
 	var res model.Admin
 	res.Username = admin.Username
 	// 确认存在用户
 	rows := database.Where(&res).Find(&res).RowsAffected
-	if rows == 0 || res.Password != admin.Password {
-		// 不存在用户名或密码错误
-		return false
+	if rows == 0 {
+		// 不存在用户名
+		return false, nil
+	}
+
+	if _, err := bcrypt.Cost([]byte(res.Password)); err != nil {
+		// 明文密码
+		if subtle.ConstantTimeCompare([]byte(res.Password), []byte(admin.Password)) != 1 {
+			return false, nil
+		}
+		if password, err := bcrypt.GenerateFromPassword([]byte(admin.Password), bcrypt.DefaultCost); err != nil {
+			log.Println("管理员", res.Username, "密码哈希失败", err)
+		} else {
+			log.Println("管理员", res.Username, "的明文密码已改为哈希存储")
+			database.Model(&model.Admin{}).Where("username=?", res.Username).Update("password", string(password))
+		}
+	} else if bcrypt.CompareHashAndPassword([]byte(res.Password), []byte(admin.Password)) != nil {
+		// 密码错误
+		return false, nil
+	}
+
+	if res.IsDisabled {
+		return false, errors.New("disabled")
+	}
+
+	return true, nil
+}
+
+// BootstrapAdmin 不存在超级管理员时,根据配置创建第一个超级管理员(已存在同名管理员时将其提升为超级管理员)
+func BootstrapAdmin(database *gorm.DB) error {
+	username, password := env.GetBootstrapAdmin()
+	if username == "" || password == "" {
+		return nil
+	}
+
+	var count int64
+	database.Model(&model.Admin{}).Where("role=?", RoleSuperAdmin).Count(&count)
+	if count != 0 {
+		return nil
+	}
+
+	var prev model.Admin
+	if database.Where("username=?", username).Find(&prev).RowsAffected != 0 {
+		log.Println("提升管理员", username, "为超级管理员")
+		return database.Model(&model.Admin{}).Where("username=?", username).Update("role", RoleSuperAdmin).Error
+	}
+
+	// 初始超级管理员的密码同样需要满足密码强度要求
+	if errs := ValidatePassword("adminPassword", password, username); len(errs) != 0 {
+		messages := make([]string, 0, len(errs))
+		for _, e := range errs {
+			messages = append(messages, e.Message)
+		}
+		return fmt.Errorf("adminPassword不符合要求: %s", strings.Join(messages, ";"))
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
 	}
-	return true
+	log.Println("创建初始超级管理员", username)
+	return database.Create(&model.Admin{
+		Username: username,
+		Password: string(hash),
+		Role:     RoleSuperAdmin,
+	}).Error
 }
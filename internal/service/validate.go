@@ -0,0 +1,238 @@
+package service
+
+import (
+	"PaintingExchange/internal/model"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// 字段长度限制(按字符计)
+const (
+	UsernameMinLen   = 3
+	UsernameMaxLen   = 32
+	PasswordMinLen   = 8
+	PasswordMaxLen   = 72 // bcrypt只使用前72字节
+	NicknameMaxLen   = 32
+	UserIntroMaxLen  = 500
+	EmailMaxLen      = 191
+	TitleMaxLen      = 64
+	ImageIntroMaxLen = 2000
+	LabelMaxCount    = 10
+	LabelMaxLen      = 20
+	ImageMaxPages    = 50 // 作品最多页数
+	ImageMaxStages   = 20 // 作品最多阶段数
+	StageNameMaxLen  = 20
+)
+
+// reservedUsernames 保留的用户名,与工作人员身份或 /user 下的路由冲突
+var reservedUsernames = map[string]bool{
+	"admin": true, "administrator": true, "root": true, "system": true, "superadmin": true,
+	"moderator": true, "staff": true, "support": true, "null": true, "undefined": true,
+	"me": true, "login": true, "logout": true, "register": true, "avatar": true, "star": true,
+	"mfa": true, "password": true, "email": true, "token": true, "tokens": true, "sessions": true, "identities": true,
+	"follow": true,
+}
+
+// Validator 收集字段校验错误
+type Validator struct {
+	Errors []model.FieldError
+}
+
+// Add 记录一个字段错误
+func (v *Validator) Add(field string, format string, args ...interface{}) {
+	v.Errors = append(v.Errors, model.FieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+// Valid 是否没有任何错误
+func (v *Validator) Valid() bool {
+	return len(v.Errors) == 0
+}
+
+// Length 检查字符数是否在[min,max]范围内
+func (v *Validator) Length(field string, name string, value string, min int, max int) bool {
+	if n := utf8.RuneCountInString(value); n < min || n > max {
+		if min == 0 {
+			v.Add(field, "%s不能超过%d个字符", name, max)
+		} else {
+			v.Add(field, "%s长度应为%d到%d个字符", name, min, max)
+		}
+		return false
+	}
+	return true
+}
+
+// Printable 检查文本中不含控制字符(multiline为true时允许换行和制表符)
+func (v *Validator) Printable(field string, name string, value string, multiline bool) bool {
+	for _, r := range value {
+		if multiline && (r == '\n' || r == '\r' || r == '\t') {
+			continue
+		}
+		if unicode.IsControl(r) || r == utf8.RuneError {
+			v.Add(field, "%s包含不允许的字符", name)
+			return false
+		}
+	}
+	return true
+}
+
+// ValidateRegister 校验注册信息
+func ValidateRegister(user model.User) []model.FieldError {
+	var v Validator
+	v.username(user.Username)
+	v.password("password", user.Password, user.Username)
+	v.profile(user)
+	return v.Errors
+}
+
+// ValidateUserUpdate 校验用户修改的个人信息,密码和头像为空表示不修改
+func ValidateUserUpdate(user model.User) []model.FieldError {
+	var v Validator
+	if user.Password != "" {
+		v.password("password", user.Password, user.Username)
+	}
+	v.profile(user)
+	if user.AvatarURI != "" && !IsAvatarKey(user.AvatarURI) {
+		v.Add("avatarURI", "头像地址只能是上传头像返回的地址")
+	}
+	return v.Errors
+}
+
+// ValidatePassword 校验新密码强度
+func ValidatePassword(field string, password string, username string) []model.FieldError {
+	var v Validator
+	v.password(field, password, username)
+	return v.Errors
+}
+
+// ValidateImage 校验图片的标题,简介和标签
+func ValidateImage(image model.Image) []model.FieldError {
+	var v Validator
+	if strings.TrimSpace(image.Title) == "" {
+		v.Add("title", "标题不能为空")
+	} else if v.Length("title", "标题", image.Title, 1, TitleMaxLen) {
+		v.Printable("title", "标题", image.Title, false)
+	}
+	if v.Length("intro", "简介", image.Intro, 0, ImageIntroMaxLen) {
+		v.Printable("intro", "简介", image.Intro, true)
+	}
+	if len(image.Pages) > ImageMaxPages {
+		v.Add("pages", "作品不能超过%d页", ImageMaxPages)
+	}
+	if image.Visibility != "" && !slices.Contains(Visibilities, image.Visibility) {
+		v.Add("visibility", "可见性只能是%s", strings.Join(Visibilities, ","))
+	}
+	if image.PublishAt != nil && !image.PublishAt.After(time.Now()) {
+		v.Add("publishAt", "定时发布时间必须晚于当前时间")
+	} else if image.PublishAt != nil && image.CancelPublish {
+		v.Add("publishAt", "取消定时发布时不能同时设置定时发布时间")
+	}
+	if image.Rating != "" && !slices.Contains(Ratings, image.Rating) {
+		v.Add("rating", "内容分级只能是%s", strings.Join(Ratings, ","))
+	}
+
+	if len(image.Label) > LabelMaxCount {
+		v.Add("label", "标签不能超过%d个", LabelMaxCount)
+	}
+	exist := map[string]bool{}
+	for i, label := range image.Label {
+		field := fmt.Sprintf("label[%d]", i)
+		if strings.TrimSpace(label) == "" {
+			v.Add(field, "标签不能为空")
+			continue
+		}
+		if !v.Length(field, "标签", label, 1, LabelMaxLen) || !v.Printable(field, "标签", label, false) {
+			continue
+		}
+		if key := strings.ToLower(label); exist[key] {
+			v.Add(field, "标签%s重复", label)
+		} else {
+			exist[key] = true
+		}
+	}
+	return v.Errors
+}
+
+// ValidateStage 校验阶段名称
+func ValidateStage(name string) []model.FieldError {
+	var v Validator
+	if strings.TrimSpace(name) == "" {
+		v.Add("name", "阶段名称不能为空")
+	} else if v.Length("name", "阶段名称", name, 1, StageNameMaxLen) {
+		v.Printable("name", "阶段名称", name, false)
+	}
+	return v.Errors
+}
+
+// username 用户名只允许ASCII字母,数字,下划线和连字符,且不能使用保留名称
+// 不接受其他语言的字母,避免用形近字(如西里尔字母а)绕过保留名称或冒充其他用户
+func (v *Validator) username(username string) {
+	if !v.Length("username", "用户名", username, UsernameMinLen, UsernameMaxLen) {
+		return
+	}
+	for _, r := range username {
+		if !isUsernameRune(r) {
+			v.Add("username", "用户名只能包含英文字母,数字,下划线和连字符")
+			return
+		}
+	}
+	if reservedUsernames[strings.ToLower(username)] {
+		v.Add("username", "用户名%s为保留名称", username)
+	}
+}
+
+// isUsernameRune 用户名允许的字符
+func isUsernameRune(r rune) bool {
+	return r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' || r == '-'
+}
+
+// password 密码长度8到72字节,至少包含字母,数字,符号中的两类,且不能与用户名相同
+func (v *Validator) password(field string, password string, username string) {
+	if n := len(password); n < PasswordMinLen || n > PasswordMaxLen {
+		v.Add(field, "密码长度应为%d到%d个字节", PasswordMinLen, PasswordMaxLen)
+		return
+	}
+
+	var letter, digit, symbol int
+	for _, r := range password {
+		switch {
+		case unicode.IsLetter(r):
+			letter = 1
+		case unicode.IsDigit(r):
+			digit = 1
+		case unicode.IsControl(r):
+			v.Add(field, "密码包含不允许的字符")
+			return
+		default:
+			symbol = 1
+		}
+	}
+	if letter+digit+symbol < 2 {
+		v.Add(field, "密码至少需要包含字母,数字,符号中的两类")
+		return
+	}
+	if username != "" && strings.EqualFold(password, username) {
+		v.Add(field, "密码不能与用户名相同")
+	}
+}
+
+// profile 校验昵称,简介,邮箱和成人内容显示偏好
+func (v *Validator) profile(user model.User) {
+	if v.Length("nickname", "昵称", user.Nickname, 0, NicknameMaxLen) {
+		v.Printable("nickname", "昵称", user.Nickname, false)
+	}
+	if v.Length("intro", "简介", user.Intro, 0, UserIntroMaxLen) {
+		v.Printable("intro", "简介", user.Intro, true)
+	}
+	if user.Email != "" {
+		if len(user.Email) > EmailMaxLen || !IsValidEmail(user.Email) {
+			v.Add("email", ErrEmailInvalid.Error())
+		}
+	}
+	if user.RatingFilter != "" && !slices.Contains(RatingFilters, user.RatingFilter) {
+		v.Add("ratingFilter", "成人内容显示偏好只能是%s", strings.Join(RatingFilters, ","))
+	}
+}
@@ -0,0 +1,31 @@
+package service
+
+import "testing"
+
+func TestValidateUsername(t *testing.T) {
+	for _, name := range []string{"alice", "Bob_2", "paint-er"} {
+		var v Validator
+		v.username(name)
+		if !v.Valid() {
+			t.Errorf("用户名%s应当合法: %v", name, v.Errors)
+		}
+	}
+
+	// 保留名称和用形近字冒充的保留名称都不能注册
+	for _, name := range []string{"admin", "ADMIN", "аdmin", "ａｄｍｉｎ", "画家"} {
+		var v Validator
+		v.username(name)
+		if v.Valid() {
+			t.Errorf("用户名%s不应当合法", name)
+		}
+	}
+}
+
+func TestValidatePasswordUsername(t *testing.T) {
+	if errs := ValidatePassword("newPassword", "alice2024", "alice2024"); len(errs) == 0 {
+		t.Error("与用户名相同的密码被接受")
+	}
+	if errs := ValidatePassword("newPassword", "alice2024", "bob"); len(errs) != 0 {
+		t.Error(errs)
+	}
+}
@@ -0,0 +1,83 @@
+package service
+
+import (
+	"PaintingExchange/internal/model"
+	"gorm.io/gorm"
+	"time"
+)
+
+// 图片可见性
+const (
+	VisibilityPublic    = "public"    // 公开,出现在最新,搜索和用户主页中
+	VisibilityUnlisted  = "unlisted"  // 不公开列出,知道链接(图片id)的用户可以查看
+	VisibilityFollowers = "followers" // 仅关注作者的用户可以查看,出现在作者主页中
+	VisibilityPrivate   = "private"   // 私密(草稿),仅作者可以查看
+)
+
+// Visibilities 所有可见性
+var Visibilities = []string{VisibilityPublic, VisibilityUnlisted, VisibilityFollowers, VisibilityPrivate}
+
+// NormalizeVisibility 补全默认可见性(公开);定时发布的图片在发布前为私密,
+// 设置的其他可见性作为发布后的可见性(默认公开),到达发布时间后再改为该可见性.
+// 公开时记录公开的时间
+func NormalizeVisibility(image *model.Image) {
+	if image.Visibility == "" {
+		image.Visibility = VisibilityPublic
+	}
+	if image.PublishAt == nil {
+		image.PublishVisibility = ""
+	} else {
+		if image.Visibility != VisibilityPrivate {
+			image.PublishVisibility = image.Visibility
+			image.Visibility = VisibilityPrivate
+		}
+		if image.PublishVisibility == "" {
+			image.PublishVisibility = VisibilityPublic
+		}
+	}
+	if IsListed(*image) && image.PublishedAt == nil {
+		now := time.Now()
+		image.PublishedAt = &now
+	}
+}
+
+// IsListed 图片是否公开,只有公开的图片出现在最新和搜索结果中(旧数据没有可见性,视为公开)
+func IsListed(image model.Image) bool {
+	return image.Visibility == "" || image.Visibility == VisibilityPublic
+}
+
+// CanListImage 图片是否出现在用户看到的作者主页中,作者本人可以看到全部;following为用户是否关注了作者
+func CanListImage(image model.Image, username string, following bool) bool {
+	if image.Auth == username || IsListed(image) {
+		return true
+	}
+	return image.Visibility == VisibilityFollowers && following
+}
+
+// CanViewImage 用户是否可以通过id查看图片(封禁另行判断),仅关注者可见时查询关注关系
+func CanViewImage(db *gorm.DB, image model.Image, username string) bool {
+	switch {
+	case image.Auth == username || IsListed(image) || image.Visibility == VisibilityUnlisted:
+		return true
+	case image.Visibility == VisibilityFollowers:
+		return IsFollowing(db, username, image.Auth)
+	}
+	return false
+}
+
+// IsFollowing 用户是否关注了作者
+func IsFollowing(db *gorm.DB, username string, author string) bool {
+	var count int64
+	db.Model(&model.Follow{}).Where("username=? AND following=?", username, author).Count(&count)
+	return count != 0
+}
+
+// SearchImage 算法层的图片记录,封禁和不公开的图片不出现在智能检索结果中
+func SearchImage(image model.Image) *Image {
+	return &Image{
+		Id:    image.ID,
+		Title: image.Title,
+		Label: image.Label,
+		IsBan: image.IsBan || image.AuthIsBan || !IsListed(image),
+	}
+}
@@ -20,6 +20,8 @@ import (
 	"gorm.io/gorm"
 	"log"
 	"os"
+	"os/signal"
+	"syscall"
 )
 
 // @title 绘画交流平台
@@ -48,6 +50,26 @@ func main() {
 	app.Get("/swagger", swaggerUI)
 	app.Get("/swagger/{any:path}", swaggerUI)
 
+	// 加载jwt密钥和第三方登录配置,收到SIGHUP信号时重新加载以便轮换密钥
+	if err := service.LoadJWTKeys(); err != nil {
+		log.Fatalln("jwt密钥加载失败:", err)
+	}
+	if err := service.LoadOIDCProviders(); err != nil {
+		log.Fatalln("OIDC配置加载失败:", err)
+	}
+	reload := make(chan os.Signal, 1)
+	signal.Notify(reload, syscall.SIGHUP)
+	go func() {
+		for range reload {
+			if err := service.LoadJWTKeys(); err != nil {
+				log.Println("jwt密钥重新加载失败,继续使用原密钥:", err)
+			}
+			if err := service.LoadOIDCProviders(); err != nil {
+				log.Println("OIDC配置重新加载失败,继续使用原配置:", err)
+			}
+		}
+	}()
+
 	// MySQL数据库连接
 	db, err := gorm.Open(mysql.New(mysql.Config{
 		DSN: fmt.Sprintf("%s:%s@tcp(%s:3306)/%s?charset=utf8mb4&parseTime=True&loc=Local", env.GetEnv("dbUserName", "paintingExchange"), env.GetEnv("dbPassword", "1234567"), env.GetEnv("dbHost", "localhost"), env.GetEnv("dbName", "paintingExchange")),
@@ -57,8 +79,28 @@ func main() {
 	} else {
 		db.AutoMigrate(&model.User{})
 		db.AutoMigrate(&model.Star{})
+		db.AutoMigrate(&model.Follow{})
 		db.AutoMigrate(&model.Admin{})
 		db.AutoMigrate(&model.Message{})
+		db.AutoMigrate(&model.RefreshToken{})
+		db.AutoMigrate(&model.RevokedToken{})
+		db.AutoMigrate(&model.RoleAssignment{})
+		db.AutoMigrate(&model.MFA{})
+		db.AutoMigrate(&model.APIToken{})
+		db.AutoMigrate(&model.Session{})
+		db.AutoMigrate(&model.Identity{})
+		db.AutoMigrate(&model.ImageJob{})
+		db.AutoMigrate(&model.ImageHash{})
+		db.AutoMigrate(&model.DuplicateReport{})
+		if err := service.MigrateAdminRoles(db); err != nil {
+			log.Fatalln("管理员角色迁移失败:", err)
+		}
+		if err := service.MigrateHashBands(db); err != nil {
+			log.Fatalln("哈希索引迁移失败:", err)
+		}
+		if err := service.BootstrapAdmin(db); err != nil {
+			log.Fatalln("初始超级管理员创建失败:", err)
+		}
 	}
 	app.Use(func(ctx iris.Context) {
 		ctx.Values().Set("db", db)
@@ -87,26 +129,67 @@ func main() {
 		algo = service.NewSearchServiceClient(conn)
 	}
 
-	// 创建图片缓存目录并绑定路由
-	err = os.MkdirAll(env.GetImgDir(), os.ModePerm)
-	err = os.MkdirAll(env.GetAvatarDir(), os.ModePerm)
+	// 文件存储,本地存储时创建图片缓存目录
+	storage, err := service.NewStorage()
 	if err != nil {
-		log.Fatalln("创建图片缓存目录失败:", err)
+		log.Fatalln("文件存储初始化失败:", err)
 	}
-	app.HandleDir("/assert/images", env.GetImgDir())
-	app.HandleDir("/assert/avatars", env.GetAvatarDir())
+	if _, ok := storage.(*service.LocalStorage); ok {
+		err = os.MkdirAll(env.GetImgDir(), os.ModePerm)
+		err = os.MkdirAll(env.GetAvatarDir(), os.ModePerm)
+		err = os.MkdirAll(env.GetOriginDir(), os.ModePerm)
+		if err != nil {
+			log.Fatalln("创建图片缓存目录失败:", err)
+		}
+	}
+	// 本地存储时直接返回文件,对象存储时重定向到存储地址
+	app.Get("/assert/{path:path}", controller.ServeAsset(storage))
+
+	// 启动图片处理队列
+	duplicates := service.NewDuplicateDetector(db)
+	queue := service.NewImageQueue(db, mg, storage, duplicates)
+	queue.Start()
+
+	// 启动延时视频生成队列
+	timelapse := service.NewTimelapseRenderer(mg, storage)
+	timelapse.Start()
+
+	// 定时发布到期的图片
+	service.NewPublisher(mg, algo).Start()
+
+	// 定期清理无用文件
+	gc := service.NewFileGC(db, mg, storage)
+	gc.Start()
 
 	// 绑定依赖和路由
 	mvc.Configure(app, func(application *mvc.Application) {
 		application.Register(db)
 		application.Register(mg)
 		application.Register(algo)
+		application.Register(service.NewLoginGuard(service.NewMemoryAttemptStore()))
+		application.Register(service.NewMailGuard(service.NewMemoryAttemptStore()))
+		application.Register(service.NewMailer())
+		application.Register(queue)
+		application.Register(storage)
+		application.Register(gc)
+		application.Register(duplicates)
+		application.Register(timelapse)
 		application.Party("/").Handle(new(controller.AuthController))
+		application.Party("/user/mfa", service.JWTMiddleware).Handle(new(controller.MFAController))
+		application.Party("/back/mfa", service.JWTMiddleware).Handle(new(controller.MFAController))
+		application.Party("/user/tokens", service.JWTMiddleware).Handle(new(controller.TokenController))
+		application.Party("/user/sessions", service.JWTMiddleware).Handle(new(controller.SessionController))
+		application.Party("/user/identities", service.JWTMiddleware).Handle(new(controller.IdentityController))
+		application.Party("/back/sessions", service.JWTMiddleware).Handle(new(controller.SessionController))
 		application.Party("/user", service.JWTMiddleware).Handle(new(controller.UserController))
 		application.Party("/image", service.JWTMiddleware).Handle(new(controller.ImageController))
-		application.Party("/back", service.JWTMiddleware, service.CheckIsAdmin).Handle(new(controller.BackController))
+		application.Party("/back/admin", service.JWTMiddleware, service.RequirePermission(service.PermStaffManage)).Handle(new(controller.AdminController))
+		application.Party("/back", service.JWTMiddleware, service.CheckIsStaff).Handle(new(controller.BackController))
 	})
 
+	// 公钥集合,供其他服务验证令牌
+	app.Get("/.well-known/jwks.json", controller.GetJWKS)
+
 	// 绑定websocket
 	app.Get("/chat", service.BeginWsRequest, controller.HandleWebsocket)
 